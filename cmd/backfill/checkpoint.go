@@ -0,0 +1,75 @@
+package main
+
+import (
+	"cb_grok/internal/exchange"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// checkpoint is the backfill progress, saved after every window so an interrupted run resumes where it stopped
+type checkpoint struct {
+	path  string
+	Tasks map[string]*taskProgress `json:"tasks"`
+}
+
+// taskProgress is the progress of one symbol, timeframe and range
+type taskProgress struct {
+	// Cursor is the time every candle before has been stored or found missing on the exchange
+	Cursor int64 `json:"cursor"`
+	// Unavailable are the candles the exchange did not return
+	Unavailable []exchange.Gap `json:"unavailable"`
+}
+
+func loadCheckpoint(path string) (*checkpoint, error) {
+	cp := &checkpoint{path: path, Tasks: map[string]*taskProgress{}}
+	if path == "" {
+		return cp, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return cp, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read checkpoint: %w", err)
+	}
+	if err := json.Unmarshal(data, cp); err != nil {
+		return nil, fmt.Errorf("failed to parse checkpoint: %w", err)
+	}
+	if cp.Tasks == nil {
+		cp.Tasks = map[string]*taskProgress{}
+	}
+	return cp, nil
+}
+
+// task returns the progress of the task, starting it at start if it is new
+func (c *checkpoint) task(key string, start int64) *taskProgress {
+	progress, ok := c.Tasks[key]
+	if !ok {
+		progress = &taskProgress{Cursor: start}
+		c.Tasks[key] = progress
+	}
+	return progress
+}
+
+// save writes the checkpoint to a temporary file first so an interruption never leaves it truncated
+func (c *checkpoint) save() error {
+	if c.path == "" {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode checkpoint: %w", err)
+	}
+	tmp := c.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write checkpoint: %w", err)
+	}
+	if err := os.Rename(tmp, c.path); err != nil {
+		return fmt.Errorf("failed to write checkpoint: %w", err)
+	}
+	return nil
+}
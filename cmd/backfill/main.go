@@ -0,0 +1,385 @@
+package main
+
+import (
+	"cb_grok/config"
+	"cb_grok/internal/candle"
+	candleProvider "cb_grok/internal/candle/provider"
+	candleRepository "cb_grok/internal/candle/repository"
+	"cb_grok/internal/exchange"
+	"cb_grok/internal/exchange/venue"
+	"cb_grok/internal/utils"
+	"cb_grok/internal/utils/logger"
+	"cb_grok/pkg/postgres"
+	"context"
+	"flag"
+	"fmt"
+	"go.uber.org/fx"
+	"go.uber.org/fx/fxevent"
+	"go.uber.org/zap"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+)
+
+var (
+	Version = "dev"
+)
+
+func main() {
+	configPath := os.Getenv("CONFIG_PATH")
+
+	cfg, err := config.LoadConfig(configPath)
+	if err != nil {
+		fmt.Printf("Failed to load config: %v\n", err)
+		os.Exit(1)
+	}
+
+	app := fx.New(
+		// Configuration
+		fx.Provide(func() *config.Config { return cfg }),
+
+		// Logger
+		fx.Provide(func(cfg *config.Config) (*zap.Logger, error) {
+			return logger.NewZapLogger(logger.ZapConfig{
+				Level:       cfg.Logger.Level,
+				Development: cfg.Logger.Development,
+				Encoding:    cfg.Logger.Encoding,
+				OutputPaths: cfg.Logger.OutputPaths,
+			})
+		}),
+
+		// Postgres
+		fx.Provide(func(cfg *config.Config) (postgres.Postgres, error) {
+			return postgres.InitPsqlDB(&postgres.Conn{
+				Host:     cfg.Postgres.Host,
+				Port:     cfg.Postgres.Port,
+				User:     cfg.Postgres.User,
+				Password: cfg.Postgres.Password,
+				DBName:   cfg.Postgres.DBName,
+				SSLMode:  cfg.Postgres.SSLMode,
+				PgDriver: cfg.Postgres.PgDriver,
+			})
+		}),
+
+		fx.Provide(func(db postgres.Postgres) candle.Repository { return candleRepository.New(db) }),
+
+		// Lifecycle hooks
+		fx.Invoke(registerLifecycleHooks),
+
+		// FX settings
+		fx.WithLogger(func(log *zap.Logger) fxevent.Logger {
+			return &fxevent.ZapLogger{Logger: log}
+		}),
+	)
+
+	app.Run()
+}
+
+// timeRange is a backfilled range, end is exclusive
+type timeRange struct {
+	start time.Time
+	end   time.Time
+}
+
+func runBackfill(cfg *config.Config, log *zap.Logger, candleRepo candle.Repository) error {
+	var (
+		symbols        string
+		timeframes     string
+		exchangeName   string
+		from           string
+		to             string
+		ranges         string
+		windowDays     int
+		checkpointPath string
+		reportOnly     bool
+		retention      bool
+	)
+
+	flag.StringVar(&symbols, "symbols", "", "Comma-separated symbols (f.e BTCUSDT,ETHUSDT)")
+	flag.StringVar(&timeframes, "timeframes", "1m", "Comma-separated timeframes (f.e 1m,1h)")
+	flag.StringVar(&exchangeName, "exchange", venue.Bybit, fmt.Sprintf("Exchange to fetch candles from (%s)", strings.Join(venue.Names(), ", ")))
+	flag.StringVar(&from, "from", "", "Start of the range, 2006-01-02 or RFC 3339")
+	flag.StringVar(&to, "to", "", "End of the range, exclusive (default: now)")
+	flag.StringVar(&ranges, "ranges", "", "Comma-separated ranges as from..to, an empty to means now (overrides --from/--to)")
+	flag.IntVar(&windowDays, "window-days", 7, "Days fetched and checkpointed at a time")
+	flag.StringVar(&checkpointPath, "checkpoint", "backfill.checkpoint.json", "Progress file an interrupted backfill resumes from, empty disables it")
+	flag.BoolVar(&reportOnly, "report-only", false, "Only print the coverage report")
+	flag.BoolVar(&retention, "retention", false, "Apply the candles.retention policies of the config to the symbols")
+	flag.Parse()
+
+	if symbols == "" {
+		return fmt.Errorf("--symbols is required")
+	}
+	if windowDays <= 0 {
+		return fmt.Errorf("--window-days must be positive")
+	}
+	timeRanges, err := parseRanges(from, to, ranges)
+	if err != nil {
+		return err
+	}
+
+	ex, err := venue.New(exchangeName, cfg, exchange.TradingModeLive)
+	if err != nil {
+		return fmt.Errorf("failed to initialize exchange: %w", err)
+	}
+	cache := candleProvider.New(candleRepo, ex, log)
+
+	cp, err := loadCheckpoint(checkpointPath)
+	if err != nil {
+		return err
+	}
+
+	for _, symbol := range splitList(symbols) {
+		for _, tf := range splitList(timeframes) {
+			timeframe := exchange.Timeframe(tf)
+			for _, r := range timeRanges {
+				progress := cp.task(taskKey(ex.Name(), symbol, timeframe, r), r.start.UnixMilli())
+				if reportOnly {
+					continue
+				}
+				if err := backfill(log, cache, cp, progress, symbol, timeframe, r, time.Duration(windowDays)*24*time.Hour); err != nil {
+					return fmt.Errorf("failed to backfill %s %s: %w", symbol, timeframe, err)
+				}
+			}
+		}
+	}
+
+	if retention {
+		if err := applyRetention(cfg, log, candleRepo, ex.Name(), splitList(symbols)); err != nil {
+			return err
+		}
+	}
+
+	var rows []coverageRow
+	for _, symbol := range splitList(symbols) {
+		for _, tf := range splitList(timeframes) {
+			timeframe := exchange.Timeframe(tf)
+			for _, r := range timeRanges {
+				progress := cp.task(taskKey(ex.Name(), symbol, timeframe, r), r.start.UnixMilli())
+				row, err := coverage(candleRepo, ex.Name(), symbol, timeframe, r, progress)
+				if err != nil {
+					return err
+				}
+				rows = append(rows, *row)
+			}
+		}
+	}
+
+	printCoverage(rows)
+	return nil
+}
+
+// taskKey identifies the checkpointed progress of a range, the end is left out so a later --to resumes it
+func taskKey(exchangeName, symbol string, timeframe exchange.Timeframe, r timeRange) string {
+	return fmt.Sprintf("%s/%s/%s/%d", exchangeName, symbol, timeframe, r.start.UnixMilli())
+}
+
+// backfill fetches the missing candles of the range window by window starting from the checkpointed cursor
+func backfill(log *zap.Logger, cache candle.Provider, cp *checkpoint, progress *taskProgress, symbol string, timeframe exchange.Timeframe, r timeRange, window time.Duration) error {
+	// the forming candle is left for the next run
+	_, forming, err := exchange.LastClosedRange(timeframe, 0)
+	if err != nil {
+		return err
+	}
+	end := min(r.end.UnixMilli(), forming)
+
+	for progress.Cursor < end {
+		windowEnd := min(progress.Cursor+window.Milliseconds(), end)
+
+		candleRange, err := cache.FetchSpotOHLCVRange(symbol, timeframe, progress.Cursor, windowEnd)
+		if err != nil {
+			return err
+		}
+		progress.Unavailable = append(progress.Unavailable, candleRange.Gaps...)
+		progress.Cursor = windowEnd
+		if err := cp.save(); err != nil {
+			return err
+		}
+
+		log.Info("backfill: window done",
+			zap.String("symbol", symbol),
+			zap.String("timeframe", string(timeframe)),
+			zap.Time("cursor", time.UnixMilli(progress.Cursor).UTC()),
+			zap.Int("candles", len(candleRange.Candles)),
+			zap.Int("unavailable", candleRange.Missing(timeframe)),
+		)
+	}
+
+	return nil
+}
+
+// applyRetention downsamples and drops the old candles of the symbols as configured
+func applyRetention(cfg *config.Config, log *zap.Logger, candleRepo candle.Repository, exchangeName string, symbols []string) error {
+	now := time.Now()
+	for _, symbol := range symbols {
+		for _, rc := range cfg.Candles.Retention {
+			policy := candle.RetentionPolicy{
+				Timeframe:    exchange.Timeframe(rc.Timeframe),
+				KeepFor:      time.Duration(rc.KeepDays) * 24 * time.Hour,
+				DownsampleTo: exchange.Timeframe(rc.DownsampleTo),
+			}
+			result, err := candle.ApplyRetention(context.Background(), candleRepo, strings.ReplaceAll(symbol, "/", ""), exchangeName, policy, now)
+			if err != nil {
+				return fmt.Errorf("failed to apply %s retention to %s: %w", rc.Timeframe, symbol, err)
+			}
+			log.Info("backfill: retention applied",
+				zap.String("symbol", symbol),
+				zap.String("timeframe", rc.Timeframe),
+				zap.String("downsample_to", rc.DownsampleTo),
+				zap.Int("downsampled", result.Downsampled),
+				zap.Int64("deleted", result.Deleted),
+			)
+		}
+	}
+	return nil
+}
+
+// coverageRow is the coverage of one symbol, timeframe and range in the candles table
+type coverageRow struct {
+	symbol      string
+	timeframe   exchange.Timeframe
+	r           timeRange
+	stored      int
+	missing     int
+	unavailable int
+	gaps        []exchange.Gap
+}
+
+func coverage(candleRepo candle.Repository, exchangeName, symbol string, timeframe exchange.Timeframe, r timeRange, progress *taskProgress) (*coverageRow, error) {
+	stored, err := candleRepo.Select(context.Background(), strings.ReplaceAll(symbol, "/", ""), exchangeName, string(timeframe), r.start.UnixMilli(), r.end.UnixMilli()-1)
+	if err != nil {
+		return nil, err
+	}
+	gaps, err := exchange.FindGaps(timeframe, stored, r.start.UnixMilli(), r.end.UnixMilli())
+	if err != nil {
+		return nil, err
+	}
+
+	return &coverageRow{
+		symbol:      symbol,
+		timeframe:   timeframe,
+		r:           r,
+		stored:      len(stored),
+		missing:     exchange.CandleRange{Gaps: gaps}.Missing(timeframe),
+		unavailable: exchange.CandleRange{Gaps: progress.Unavailable}.Missing(timeframe),
+		gaps:        gaps,
+	}, nil
+}
+
+func printCoverage(rows []coverageRow) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "SYMBOL\tTIMEFRAME\tFROM\tTO\tCANDLES\tMISSING\tUNAVAILABLE\tCOVERAGE\tGAPS\tLARGEST GAP")
+	for _, row := range rows {
+		coverage := 100.0
+		if total := row.stored + row.missing; total > 0 {
+			coverage = float64(row.stored) / float64(total) * 100
+		}
+
+		largest := "-"
+		largestMissing := 0
+		for _, gap := range row.gaps {
+			if n := (exchange.CandleRange{Gaps: []exchange.Gap{gap}}).Missing(row.timeframe); n > largestMissing {
+				largestMissing = n
+				largest = fmt.Sprintf("%s (%d)", time.UnixMilli(gap.Start).UTC().Format(time.RFC3339), n)
+			}
+		}
+
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\t%d\t%d\t%.2f%%\t%d\t%s\n",
+			row.symbol, row.timeframe,
+			row.r.start.Format(time.RFC3339), row.r.end.Format(time.RFC3339),
+			row.stored, row.missing, row.unavailable, coverage, len(row.gaps), largest)
+	}
+	_ = w.Flush()
+}
+
+// parseRanges parses --ranges, or --from/--to when it is empty
+func parseRanges(from, to, ranges string) ([]timeRange, error) {
+	if ranges == "" {
+		if from == "" {
+			return nil, fmt.Errorf("--from or --ranges is required")
+		}
+		start, end, err := utils.ParseTimeRange(from, to)
+		if err != nil {
+			return nil, err
+		}
+		return []timeRange{{start: start, end: end}}, nil
+	}
+
+	var result []timeRange
+	for _, item := range splitList(ranges) {
+		bounds := strings.SplitN(item, "..", 2)
+		if len(bounds) != 2 || bounds[0] == "" {
+			return nil, fmt.Errorf("invalid range %q, expected from..to", item)
+		}
+		start, end, err := utils.ParseTimeRange(bounds[0], bounds[1])
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, timeRange{start: start, end: end})
+	}
+	return result, nil
+}
+
+func splitList(value string) []string {
+	var result []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			result = append(result, item)
+		}
+	}
+	return result
+}
+
+// registerLifecycleHooks registers lifecycle hooks for the application
+func registerLifecycleHooks(
+	lifecycle fx.Lifecycle,
+	log *zap.Logger,
+	cfg *config.Config,
+	candleRepo candle.Repository,
+	shutdowner fx.Shutdowner,
+) {
+	lifecycle.Append(fx.Hook{
+		OnStart: func(ctx context.Context) error {
+			log.Info("Starting backfill",
+				zap.String("version", cfg.App.Version),
+				zap.String("environment", cfg.App.Environment),
+			)
+
+			exitCode := 0
+			go func() {
+				err := runBackfill(cfg, log, candleRepo)
+				if err != nil {
+					log.Error("Failed to run backfill", zap.Error(err))
+					exitCode = 1
+				}
+
+				_ = shutdowner.Shutdown(fx.ExitCode(exitCode))
+			}()
+
+			return nil
+		},
+		OnStop: func(ctx context.Context) error {
+			log.Info("Stopping backfill")
+
+			log.Info("Backfill stopped")
+			return nil
+		},
+	})
+
+	// Handle OS signals
+	go handleSignals(log)
+}
+
+// handleSignals handles OS signals for graceful shutdown
+func handleSignals(log *zap.Logger) {
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+
+	sig := <-sigChan
+	log.Info("Received signal", zap.String("signal", sig.String()))
+
+	// fx will handle graceful shutdown automatically
+}
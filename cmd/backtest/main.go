@@ -3,14 +3,22 @@ package main
 import (
 	"cb_grok/config"
 	"cb_grok/internal/backtest"
+	"cb_grok/internal/candle"
+	"cb_grok/internal/candle/dataset"
+	candleProvider "cb_grok/internal/candle/provider"
+	"cb_grok/internal/candle/quality"
+	candleRepository "cb_grok/internal/candle/repository"
 	"cb_grok/internal/exchange"
-	"cb_grok/internal/exchange/bybit"
-	"cb_grok/internal/model"
+	"cb_grok/internal/exchange/venue"
+	"cb_grok/internal/strategy"
 	"cb_grok/internal/telegram"
 	"cb_grok/internal/trader"
 	"cb_grok/internal/utils"
 	"cb_grok/internal/utils/logger"
+	"cb_grok/pkg/models"
+	"cb_grok/pkg/postgres"
 	"context"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"github.com/ethereum/go-ethereum/log"
@@ -19,6 +27,7 @@ import (
 	"go.uber.org/zap"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 )
@@ -50,6 +59,21 @@ func main() {
 			})
 		}),
 
+		// Postgres
+		fx.Provide(func(cfg *config.Config) (postgres.Postgres, error) {
+			return postgres.InitPsqlDB(&postgres.Conn{
+				Host:     cfg.Postgres.Host,
+				Port:     cfg.Postgres.Port,
+				User:     cfg.Postgres.User,
+				Password: cfg.Postgres.Password,
+				DBName:   cfg.Postgres.DBName,
+				SSLMode:  cfg.Postgres.SSLMode,
+				PgDriver: cfg.Postgres.PgDriver,
+			})
+		}),
+
+		fx.Provide(func(db postgres.Postgres) candle.Repository { return candleRepository.New(db) }),
+
 		// Modules
 		backtest.Module,
 		telegram.Module,
@@ -67,43 +91,114 @@ func main() {
 	app.Run()
 }
 
-func runBacktest(cfg *config.Config, backtest backtest.Backtest, tg *telegram.TelegramService) error {
+func runBacktest(cfg *config.Config, backtest backtest.Backtest, tg *telegram.TelegramService, candleRepo candle.Repository) error {
 
 	var (
 		modelFilename string
 		setDays       int
 		timeframe     string
+		exchangeName  string
+		from          string
+		to            string
+		dataFile      string
+		dataColumns   string
+		dataTimeUnit  string
+		qualityPolicy string
 	)
 
 	flag.StringVar(&timeframe, "timeframe", "", "Timeframe (f.e 1h)")
 	flag.IntVar(&setDays, "set-days", 0, "Number of days for trading set")
 	flag.StringVar(&modelFilename, "model", "", "Model filename")
+	flag.StringVar(&exchangeName, "exchange", venue.Bybit, fmt.Sprintf("Exchange to fetch candles from (%s)", strings.Join(venue.Names(), ", ")))
+	flag.StringVar(&from, "from", "", "Start of the candle range, 2006-01-02 or RFC 3339 (default: the last set-days)")
+	flag.StringVar(&to, "to", "", "End of the candle range, exclusive (default: now)")
+	flag.StringVar(&dataFile, "data-file", "", "CSV or Parquet file to read candles from instead of the exchange")
+	flag.StringVar(&dataColumns, "data-columns", "", "Column mapping of the data file (f.e timestamp=open_time,volume=vol)")
+	flag.StringVar(&dataTimeUnit, "data-time-unit", "ms", "Timestamp unit of the data file (s, ms, us, ns, rfc3339)")
+	flag.StringVar(&qualityPolicy, "quality-policy", "", "Repair policy of bad candles: ffill, drop, interpolate, fail or keep (default: candles.quality.policy)")
 	flag.Parse()
 
-	ex, err := bybit.NewBybit(cfg.Bybit.APIKey, cfg.Bybit.APISecret, exchange.TradingModeLive)
+	start, end, err := utils.ParseTimeRange(from, to)
+	if err != nil {
+		return err
+	}
+	dataOptions, err := dataset.NewOptions(dataColumns, dataTimeUnit)
+	if err != nil {
+		return err
+	}
+	tf, err := exchange.Timeframe(timeframe).Parse()
+	if err != nil {
+		return err
+	}
+	qualityOptions, err := quality.NewOptions(cfg.Candles.Quality, tf)
+	if err != nil {
+		return err
+	}
+	if qualityPolicy != "" {
+		if qualityOptions.Policy, err = quality.ParsePolicy(qualityPolicy); err != nil {
+			return err
+		}
+	}
+
+	ex, err := venue.New(exchangeName, cfg, exchange.TradingModeLive)
 	if err != nil {
 		log.Error("backtest: initialize exchange", zap.Error(err))
 		return err
 	}
+	cache := candleProvider.New(candleRepo, ex, zap.L())
 
-	mod, err := model.Load(modelFilename)
+	mod, err := loadModel(modelFilename)
 	if err != nil {
 		log.Error("Failed to load model params", zap.Error(err))
 		return fmt.Errorf("error to load model: %w", err)
 	}
 
-	timeframeSec := utils.TimeframeToMilliseconds(timeframe) / 1000
-	candlesPerDay := (24 * 60 * 60) / int(timeframeSec)
+	var candles []models.OHLCV
+	switch {
+	case dataFile != "":
+		candles, err = dataset.Load(dataFile, dataOptions)
+		if err != nil {
+			zap.L().Error("backtest: load data file", zap.Error(err))
+			return err
+		}
+		if start.IsZero() {
+			candles = dataset.Last(candles, tf.Candles(setDays))
+		} else {
+			candles = dataset.Between(candles, start.UnixMilli(), end.UnixMilli())
+			setDays = int(end.Sub(start).Hours() / 24)
+		}
+	case start.IsZero():
+		candles, err = cache.FetchSpotOHLCV(mod.Symbol, exchange.Timeframe(timeframe), tf.Candles(setDays))
+		if err != nil {
+			zap.L().Error("backtest: fetch ohlcv", zap.Error(err))
+			return err
+		}
+	default:
+		candleRange, err := cache.FetchSpotOHLCVRange(mod.Symbol, exchange.Timeframe(timeframe), start.UnixMilli(), end.UnixMilli())
+		if err != nil {
+			zap.L().Error("backtest: fetch ohlcv range", zap.Error(err))
+			return err
+		}
+		for _, gap := range candleRange.Gaps {
+			zap.L().Warn("backtest: missing candles", zap.Time("from", time.UnixMilli(gap.Start).UTC()), zap.Time("to", time.UnixMilli(gap.End).UTC()))
+		}
+		candles = candleRange.Candles
+		setDays = int(end.Sub(start).Hours() / 24)
+	}
 
-	candlesTotal := setDays * candlesPerDay
+	candles, report, err := quality.Repair(fmt.Sprintf("%s %s", mod.Symbol, timeframe), candles, qualityOptions)
+	report.Log(zap.L())
+	if err != nil {
+		return err
+	}
 
-	candles, err := ex.FetchSpotOHLCV(mod.Symbol, exchange.Timeframe(timeframe), candlesTotal)
+	str, err := strategy.New(mod.StrategyType, mod.StrategyParams)
 	if err != nil {
-		zap.L().Error("backtest: fetch ohlcv", zap.Error(err))
+		log.Error("Failed to build strategy", zap.Error(err))
 		return err
 	}
 
-	result, err := backtest.Run(candles, mod)
+	result, err := backtest.Run(candles, str)
 	if err != nil {
 		zap.L().Error("backtest: run backtest", zap.Error(err))
 		return err
@@ -133,6 +228,30 @@ func runBacktest(cfg *config.Config, backtest backtest.Backtest, tg *telegram.Te
 
 }
 
+// backtestModel is a strategy snapshot stored as JSON
+type backtestModel struct {
+	Symbol         string          `json:"symbol"`
+	StrategyType   string          `json:"strategy_type"`
+	StrategyParams json.RawMessage `json:"strategy_params"`
+}
+
+func loadModel(filename string) (*backtestModel, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read model file: %w", err)
+	}
+
+	var mod backtestModel
+	if err := json.Unmarshal(data, &mod); err != nil {
+		return nil, fmt.Errorf("failed to parse model file: %w", err)
+	}
+	if mod.StrategyType == "" {
+		mod.StrategyType = strategy.LinearBiasType
+	}
+
+	return &mod, nil
+}
+
 // registerLifecycleHooks registers lifecycle hooks for the application
 func registerLifecycleHooks(
 	lifecycle fx.Lifecycle,
@@ -140,6 +259,7 @@ func registerLifecycleHooks(
 	cfg *config.Config,
 	tg *telegram.TelegramService,
 	backtest backtest.Backtest,
+	candleRepo candle.Repository,
 	shutdowner fx.Shutdowner,
 ) {
 	lifecycle.Append(fx.Hook{
@@ -151,7 +271,7 @@ func registerLifecycleHooks(
 
 			exitCode := 0
 			go func() {
-				err := runBacktest(cfg, backtest, tg)
+				err := runBacktest(cfg, backtest, tg, candleRepo)
 				if err != nil {
 					log.Error("Failed to run backtest", zap.Error(err))
 					exitCode = 1
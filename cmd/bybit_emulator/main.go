@@ -0,0 +1,147 @@
+package main
+
+import (
+	"cb_grok/internal/exchange/bybit/emulator"
+	"cb_grok/internal/utils/logger"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"go.uber.org/zap"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// bybit_emulator serves the Bybit v5 API subset the bybit adapter uses on a local port.
+// Run a demo trader against it with the printed bybit config section.
+func main() {
+	var (
+		addr      string
+		symbols   string
+		balances  string
+		apiKey    string
+		apiSecret string
+		tick      time.Duration
+		step      time.Duration
+		history   int
+		seed      int64
+		takerFee  float64
+		makerFee  float64
+	)
+
+	defaults := emulator.DefaultConfig()
+	flag.StringVar(&addr, "addr", "127.0.0.1:8090", "listen address")
+	flag.StringVar(&symbols, "symbols", "BTCUSDT:60000,ETHUSDT:3000", "markets with their start price, SYMBOL:PRICE,...")
+	flag.StringVar(&balances, "balances", "USDT:10000", "initial wallet balances, COIN:AMOUNT,...")
+	flag.StringVar(&apiKey, "api-key", "emulator", "API key of signed requests, empty accepts any key")
+	flag.StringVar(&apiSecret, "api-secret", "emulator", "API secret of signed requests")
+	flag.DurationVar(&tick, "tick", defaults.TickInterval, "wall time between price moves")
+	flag.DurationVar(&step, "step", defaults.TickStep, "market time a price move advances, f.e. 15s with a 1s tick closes a 1m candle every 4s")
+	flag.IntVar(&history, "history", defaults.HistoryCandles, "number of 1m history candles")
+	flag.Int64Var(&seed, "seed", time.Now().UnixNano(), "random walk seed")
+	flag.Float64Var(&takerFee, "taker-fee", defaults.TakerFee, "taker fee rate")
+	flag.Float64Var(&makerFee, "maker-fee", defaults.MakerFee, "maker fee rate")
+	flag.Parse()
+
+	log, err := logger.NewZapLogger(logger.ZapConfig{
+		Level:       "info",
+		Development: true,
+		Encoding:    "console",
+		OutputPaths: []string{"stdout"},
+	})
+	if err != nil {
+		fmt.Printf("Failed to create logger: %v\n", err)
+		os.Exit(1)
+	}
+
+	cfg := defaults
+	cfg.APIKey, cfg.APISecret = apiKey, apiSecret
+	cfg.TickInterval, cfg.TickStep = tick, step
+	cfg.HistoryCandles = history
+	cfg.Seed = seed
+	cfg.TakerFee, cfg.MakerFee = takerFee, makerFee
+
+	cfg.Symbols = nil
+	symbolPrices, err := parsePairs(symbols)
+	if err != nil {
+		log.Fatal("bybit_emulator: invalid symbols", zap.Error(err))
+	}
+	for _, pair := range symbolPrices {
+		cfg.Symbols = append(cfg.Symbols, emulator.DefaultSymbol(pair.key, pair.value))
+	}
+	coinBalances, err := parsePairs(balances)
+	if err != nil {
+		log.Fatal("bybit_emulator: invalid balances", zap.Error(err))
+	}
+	cfg.Balances = make(map[string]float64, len(coinBalances))
+	for _, pair := range coinBalances {
+		cfg.Balances[pair.key] = pair.value
+	}
+
+	emu, err := emulator.New(cfg)
+	if err != nil {
+		log.Fatal("bybit_emulator: create emulator", zap.Error(err))
+	}
+
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		log.Fatal("bybit_emulator: listen", zap.Error(err))
+	}
+	server := &http.Server{Handler: emu.Handler()}
+
+	emu.Start()
+	go func() {
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatal("bybit_emulator: serve", zap.Error(err))
+		}
+	}()
+
+	host := listener.Addr().String()
+	fmt.Printf("bybit emulator is listening on %s, trader config:\n\n", host)
+	fmt.Printf("bybit:\n  api_key: %q\n  api_secret: %q\n  base_url: \"http://%s\"\n  public_ws_url: \"ws://%s/v5/public/spot\"\n  private_ws_url: \"ws://%s/v5/private\"\n\n",
+		apiKey, apiSecret, host, host, host)
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
+	<-stop
+
+	log.Info("bybit_emulator: shutting down")
+	emu.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		log.Error("bybit_emulator: shutdown", zap.Error(err))
+	}
+}
+
+type pair struct {
+	key   string
+	value float64
+}
+
+// parsePairs parses KEY:VALUE,... lists
+func parsePairs(raw string) ([]pair, error) {
+	var pairs []pair
+	for _, item := range strings.Split(raw, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(item, ":")
+		if !ok {
+			return nil, fmt.Errorf("expected KEY:VALUE, got %q", item)
+		}
+		v, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value of %s: %w", key, err)
+		}
+		pairs = append(pairs, pair{key: strings.ToUpper(key), value: v})
+	}
+	return pairs, nil
+}
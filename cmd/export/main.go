@@ -0,0 +1,155 @@
+package main
+
+import (
+	"cb_grok/config"
+	"cb_grok/internal/candle"
+	"cb_grok/internal/candle/dataset"
+	candleRepository "cb_grok/internal/candle/repository"
+	"cb_grok/internal/exchange/venue"
+	"cb_grok/internal/utils"
+	"cb_grok/internal/utils/logger"
+	"cb_grok/pkg/postgres"
+	"context"
+	"flag"
+	"fmt"
+	"go.uber.org/fx"
+	"go.uber.org/fx/fxevent"
+	"go.uber.org/zap"
+	"os"
+	"strings"
+)
+
+var (
+	Version = "dev"
+)
+
+func main() {
+	configPath := os.Getenv("CONFIG_PATH")
+
+	cfg, err := config.LoadConfig(configPath)
+	if err != nil {
+		fmt.Printf("Failed to load config: %v\n", err)
+		os.Exit(1)
+	}
+
+	app := fx.New(
+		// Configuration
+		fx.Provide(func() *config.Config { return cfg }),
+
+		// Logger
+		fx.Provide(func(cfg *config.Config) (*zap.Logger, error) {
+			return logger.NewZapLogger(logger.ZapConfig{
+				Level:       cfg.Logger.Level,
+				Development: cfg.Logger.Development,
+				Encoding:    cfg.Logger.Encoding,
+				OutputPaths: cfg.Logger.OutputPaths,
+			})
+		}),
+
+		// Postgres
+		fx.Provide(func(cfg *config.Config) (postgres.Postgres, error) {
+			return postgres.InitPsqlDB(&postgres.Conn{
+				Host:     cfg.Postgres.Host,
+				Port:     cfg.Postgres.Port,
+				User:     cfg.Postgres.User,
+				Password: cfg.Postgres.Password,
+				DBName:   cfg.Postgres.DBName,
+				SSLMode:  cfg.Postgres.SSLMode,
+				PgDriver: cfg.Postgres.PgDriver,
+			})
+		}),
+
+		fx.Provide(func(db postgres.Postgres) candle.Repository { return candleRepository.New(db) }),
+
+		// Lifecycle hooks
+		fx.Invoke(registerLifecycleHooks),
+
+		// FX settings
+		fx.WithLogger(func(log *zap.Logger) fxevent.Logger {
+			return &fxevent.ZapLogger{Logger: log}
+		}),
+	)
+
+	app.Run()
+}
+
+// runExport writes the stored candles of a symbol and timeframe to a CSV or Parquet file
+func runExport(log *zap.Logger, candleRepo candle.Repository) error {
+	var (
+		symbol       string
+		timeframe    string
+		exchangeName string
+		from         string
+		to           string
+		out          string
+		dataColumns  string
+		dataTimeUnit string
+	)
+
+	flag.StringVar(&symbol, "symbol", "", "Symbol (f.e BTCUSDT)")
+	flag.StringVar(&timeframe, "timeframe", "", "Timeframe (f.e 1h)")
+	flag.StringVar(&exchangeName, "exchange", venue.Bybit, fmt.Sprintf("Exchange the candles were stored from (%s)", strings.Join(venue.Names(), ", ")))
+	flag.StringVar(&from, "from", "", "Start of the range, 2006-01-02 or RFC 3339")
+	flag.StringVar(&to, "to", "", "End of the range, exclusive (default: now)")
+	flag.StringVar(&out, "out", "", "Output file, .csv or .parquet")
+	flag.StringVar(&dataColumns, "data-columns", "", "Column names of the output file (f.e timestamp=open_time,volume=vol)")
+	flag.StringVar(&dataTimeUnit, "data-time-unit", "ms", "Timestamp unit of the output file (s, ms, us, ns, rfc3339)")
+	flag.Parse()
+
+	if symbol == "" || timeframe == "" || out == "" {
+		return fmt.Errorf("--symbol, --timeframe and --out are required")
+	}
+	if from == "" {
+		return fmt.Errorf("--from is required")
+	}
+	start, end, err := utils.ParseTimeRange(from, to)
+	if err != nil {
+		return err
+	}
+	opts, err := dataset.NewOptions(dataColumns, dataTimeUnit)
+	if err != nil {
+		return err
+	}
+
+	candles, err := candleRepo.Select(context.Background(), strings.ReplaceAll(symbol, "/", ""), exchangeName, timeframe, start.UnixMilli(), end.UnixMilli()-1)
+	if err != nil {
+		return err
+	}
+	if err := dataset.Save(out, candles, opts); err != nil {
+		return err
+	}
+
+	log.Info("export completed", zap.String("file", out), zap.Int("candles", len(candles)))
+	return nil
+}
+
+// registerLifecycleHooks registers lifecycle hooks for the application
+func registerLifecycleHooks(
+	lifecycle fx.Lifecycle,
+	log *zap.Logger,
+	cfg *config.Config,
+	candleRepo candle.Repository,
+	shutdowner fx.Shutdowner,
+) {
+	lifecycle.Append(fx.Hook{
+		OnStart: func(ctx context.Context) error {
+			log.Info("Starting export",
+				zap.String("version", cfg.App.Version),
+				zap.String("environment", cfg.App.Environment),
+			)
+
+			exitCode := 0
+			go func() {
+				err := runExport(log, candleRepo)
+				if err != nil {
+					log.Error("Failed to run export", zap.Error(err))
+					exitCode = 1
+				}
+
+				_ = shutdowner.Shutdown(fx.ExitCode(exitCode))
+			}()
+
+			return nil
+		},
+	})
+}
@@ -4,13 +4,19 @@ import (
 	"cb_grok/config"
 	"cb_grok/internal/backtest"
 	"cb_grok/internal/candle"
+	"cb_grok/internal/candle/dataset"
+	"cb_grok/internal/candle/quality"
 	candleRepository "cb_grok/internal/candle/repository"
+	"cb_grok/internal/exchange/venue"
 	"cb_grok/internal/optimize"
 	"cb_grok/internal/optimize/model"
-	"cb_grok/internal/order"
-	orderRepository "cb_grok/internal/order/repository"
-	orderUsecase "cb_grok/internal/order/usecase"
+	optimizeRepository "cb_grok/internal/optimize/repository"
+	"cb_grok/internal/strategy"
+	strategyRepository "cb_grok/internal/strategy/repository"
+	"cb_grok/internal/symbol"
+	symbolRepository "cb_grok/internal/symbol/repository"
 	"cb_grok/internal/telegram"
+	"cb_grok/internal/utils"
 	"cb_grok/internal/utils/logger"
 	"cb_grok/pkg/postgres"
 	"context"
@@ -22,6 +28,7 @@ import (
 	"go.uber.org/zap"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 )
 
@@ -65,17 +72,13 @@ func main() {
 			})
 		}),
 
-		fx.Provide(func(db postgres.Postgres) order.Repository {
-			return orderRepository.New(db)
-		}),
+		fx.Provide(func(db postgres.Postgres) optimize.Repository { return optimizeRepository.New(db) }),
 
-		fx.Provide(func(repo order.Repository, log *zap.Logger) order.Order {
-			return orderUsecase.New(repo, log)
-		}),
+		fx.Provide(func(db postgres.Postgres) strategy.Repository { return strategyRepository.New(db) }),
 
-		fx.Provide(func(db postgres.Postgres) candle.Repository {
-			return candleRepository.New(db)
-		}),
+		fx.Provide(func(db postgres.Postgres) symbol.Repository { return symbolRepository.New(db) }),
+
+		fx.Provide(func(db postgres.Postgres) candle.Repository { return candleRepository.New(db) }),
 
 		// Modules
 		optimize.Module,
@@ -107,24 +110,83 @@ func runOptimize(cfg *config.Config, opt optimize.Optimize) error {
 		timeframe    string
 		trials       int
 		workers      int
+		strategyType string
+		searchSpace  string
+		walkForward  bool
+		stepDays     int
+		historyDays  int
+		studyName    string
+		promote      bool
+		multi        bool
+		exchangeName string
+		from         string
+		to           string
+		dataFile     string
+		dataColumns  string
+		dataTimeUnit string
+		repair       string
 	)
 
 	flag.StringVar(&symbol, "symbol", "", "Symbol (f.e BNB/USDT)")
 	flag.StringVar(&timeframe, "timeframe", "", "Timeframe (f.e 1h)")
+	flag.StringVar(&exchangeName, "exchange", venue.Bybit, fmt.Sprintf("Exchange to fetch candles from (%s)", strings.Join(venue.Names(), ", ")))
 	flag.IntVar(&trials, "trials", 100, "Number of trials")
 	flag.IntVar(&trainSetDays, "train-set-days", 0, "Number of days for training set")
 	flag.IntVar(&valSetDays, "val-set-days", 0, "Number of days for validation set")
 	flag.IntVar(&workers, "workers", 2, "Number of parallel workers")
+	flag.StringVar(&strategyType, "strategy", strategy.LinearBiasType, fmt.Sprintf("Strategy type (%s)", strings.Join(strategy.Types(), ", ")))
+	flag.StringVar(&searchSpace, "search-space", "", "YAML/JSON file overriding the strategy search space")
+	flag.BoolVar(&walkForward, "walk-forward", false, "Re-optimize on rolling train/validation windows")
+	flag.IntVar(&stepDays, "step-days", 0, "Walk-forward window step in days (default: val-set-days)")
+	flag.IntVar(&historyDays, "history-days", 0, "Walk-forward history length in days")
+	flag.BoolVar(&multi, "multi-objective", false, "Random search over the search space, reports the trials not dominated in return, max drawdown and trade count (Pareto front)")
+	flag.StringVar(&studyName, "study", "", "Study name, an existing study is resumed (default: generated)")
+	flag.BoolVar(&promote, "promote", false, "Insert the best trial params into a new strategy row")
+	flag.StringVar(&from, "from", "", "Start of the candle history, 2006-01-02 or RFC 3339 (default: the days before now)")
+	flag.StringVar(&to, "to", "", "End of the candle history, exclusive (default: now)")
+	flag.StringVar(&dataFile, "data-file", "", "CSV or Parquet file to read candles from instead of the exchange")
+	flag.StringVar(&dataColumns, "data-columns", "", "Column mapping of the data file (f.e timestamp=open_time,volume=vol)")
+	flag.StringVar(&dataTimeUnit, "data-time-unit", "ms", "Timestamp unit of the data file (s, ms, us, ns, rfc3339)")
+	flag.StringVar(&repair, "quality-policy", "", "Repair policy of bad candles: ffill, drop, interpolate, fail or keep (default: candles.quality.policy)")
 
 	flag.Parse()
 
+	start, end, err := utils.ParseTimeRange(from, to)
+	if err != nil {
+		return err
+	}
+	dataOptions, err := dataset.NewOptions(dataColumns, dataTimeUnit)
+	if err != nil {
+		return err
+	}
+	var qualityPolicy quality.Policy
+	if repair != "" {
+		if qualityPolicy, err = quality.ParsePolicy(repair); err != nil {
+			return err
+		}
+	}
+
 	return opt.Run(model.RunOptimizeParams{
-		Symbol:       symbol,
-		Timeframe:    timeframe,
-		TrainSetDays: trainSetDays,
-		ValSetDays:   valSetDays,
-		Trials:       trials,
-		Workers:      workers,
+		Symbol:          symbol,
+		Timeframe:       timeframe,
+		TrainSetDays:    trainSetDays,
+		ValSetDays:      valSetDays,
+		Trials:          trials,
+		Workers:         workers,
+		StrategyType:    strategyType,
+		SearchSpaceFile: searchSpace,
+		WalkForward:     walkForward,
+		StepDays:        stepDays,
+		HistoryDays:     historyDays,
+		MultiObjective:  multi,
+		StudyName:       studyName,
+		Promote:         promote,
+		Exchange:        exchangeName,
+		From:            start,
+		To:              end,
+		DataFile:        dataFile,
+		DataOptions:     dataOptions,
+		QualityPolicy:   qualityPolicy,
 	})
 }
 
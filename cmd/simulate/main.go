@@ -2,10 +2,16 @@ package main
 
 import (
 	"cb_grok/config"
+	"cb_grok/internal/candle"
+	"cb_grok/internal/candle/dataset"
+	candleProvider "cb_grok/internal/candle/provider"
+	candleRepository "cb_grok/internal/candle/repository"
 	"cb_grok/internal/exchange"
 	"cb_grok/internal/exchange/bybit"
 	"cb_grok/internal/utils"
 	"cb_grok/internal/utils/logger"
+	"cb_grok/pkg/models"
+	"cb_grok/pkg/postgres"
 	"context"
 	"encoding/json"
 	"flag"
@@ -54,6 +60,21 @@ func main() {
 			})
 		}),
 
+		// Postgres
+		fx.Provide(func(cfg *config.Config) (postgres.Postgres, error) {
+			return postgres.InitPsqlDB(&postgres.Conn{
+				Host:     cfg.Postgres.Host,
+				Port:     cfg.Postgres.Port,
+				User:     cfg.Postgres.User,
+				Password: cfg.Postgres.Password,
+				DBName:   cfg.Postgres.DBName,
+				SSLMode:  cfg.Postgres.SSLMode,
+				PgDriver: cfg.Postgres.PgDriver,
+			})
+		}),
+
+		fx.Provide(func(db postgres.Postgres) candle.Repository { return candleRepository.New(db) }),
+
 		// Lifecycle hooks
 		fx.Invoke(registerLifecycleHooks),
 
@@ -66,20 +87,39 @@ func main() {
 	app.Run()
 }
 
-func runSimulation() error {
+func runSimulation(candleRepo candle.Repository) error {
 	var (
-		symbol      string
-		timeframe   string
-		tradingDays int
+		symbol       string
+		timeframe    string
+		tradingDays  int
+		from         string
+		to           string
+		dataFile     string
+		dataColumns  string
+		dataTimeUnit string
 	)
 	flag.StringVar(&symbol, "symbol", "", "Symbol (f.e BNB/USDT)")
 	flag.StringVar(&timeframe, "timeframe", "", "Timeframe (f.e 1h)")
 	flag.IntVar(&tradingDays, "trading-days", 0, "Trading days")
+	flag.StringVar(&from, "from", "", "Start of the candle range, 2006-01-02 or RFC 3339 (default: the last trading-days)")
+	flag.StringVar(&to, "to", "", "End of the candle range, exclusive (default: now)")
+	flag.StringVar(&dataFile, "data-file", "", "CSV or Parquet file to replay candles from instead of the exchange")
+	flag.StringVar(&dataColumns, "data-columns", "", "Column mapping of the data file (f.e timestamp=open_time,volume=vol)")
+	flag.StringVar(&dataTimeUnit, "data-time-unit", "ms", "Timestamp unit of the data file (s, ms, us, ns, rfc3339)")
 	flag.Parse()
 
+	start, end, err := utils.ParseTimeRange(from, to)
+	if err != nil {
+		return err
+	}
+	dataOptions, err := dataset.NewOptions(dataColumns, dataTimeUnit)
+	if err != nil {
+		return err
+	}
+
 	log.Info("starting ws server", zap.String("symbol", symbol), zap.String("timeframe", timeframe), zap.Int("trading-days", tradingDays))
 
-	if err := runServer(symbol, timeframe, tradingDays); err != nil {
+	if err := runServer(candleRepo, symbol, timeframe, tradingDays, start, end, dataFile, dataOptions); err != nil {
 		zap.L().Error(fmt.Sprintf("run ws server error: %s", err.Error()), zap.String("symbol", symbol), zap.String("timeframe", timeframe), zap.Int("trading-days", tradingDays))
 		return err
 	}
@@ -88,7 +128,7 @@ func runSimulation() error {
 }
 
 // Server function - Entry point 1
-func runServer(symbol string, timeframe string, tradingDays int) error {
+func runServer(candleRepo candle.Repository, symbol string, timeframe string, tradingDays int, start, end time.Time, dataFile string, dataOptions dataset.Options) error {
 	var upgrader = websocket.Upgrader{
 		ReadBufferSize:  1024,
 		WriteBufferSize: 1024,
@@ -98,17 +138,40 @@ func runServer(symbol string, timeframe string, tradingDays int) error {
 	if err != nil {
 		return err
 	}
-
-	timeframeSec := utils.TimeframeToMilliseconds(timeframe) / 1000
-	candlesPerDay := (24 * 60 * 60) / int(timeframeSec)
-
-	totalCandles := tradingDays * candlesPerDay
-
-	candles, err := ex.FetchSpotOHLCV(symbol, exchange.Timeframe(timeframe), totalCandles)
+	cache := candleProvider.New(candleRepo, ex, zap.L())
+	tf, err := exchange.Timeframe(timeframe).Parse()
 	if err != nil {
 		return err
 	}
 
+	var candles []models.OHLCV
+	switch {
+	case dataFile != "":
+		candles, err = dataset.Load(dataFile, dataOptions)
+		if err != nil {
+			return err
+		}
+		if start.IsZero() {
+			candles = dataset.Last(candles, tf.Candles(tradingDays))
+		} else {
+			candles = dataset.Between(candles, start.UnixMilli(), end.UnixMilli())
+		}
+	case start.IsZero():
+		candles, err = cache.FetchSpotOHLCV(symbol, exchange.Timeframe(timeframe), tf.Candles(tradingDays))
+		if err != nil {
+			return err
+		}
+	default:
+		candleRange, err := cache.FetchSpotOHLCVRange(symbol, exchange.Timeframe(timeframe), start.UnixMilli(), end.UnixMilli())
+		if err != nil {
+			return err
+		}
+		for _, gap := range candleRange.Gaps {
+			log.Warn("simulate: missing candles", zap.Time("from", time.UnixMilli(gap.Start).UTC()), zap.Time("to", time.UnixMilli(gap.End).UTC()))
+		}
+		candles = candleRange.Candles
+	}
+
 	log.Info("simulate: ohlcv data", zap.Int("length", len(candles)))
 
 	http.HandleFunc("/ws", func(w http.ResponseWriter, r *http.Request) {
@@ -158,6 +221,7 @@ func registerLifecycleHooks(
 	lifecycle fx.Lifecycle,
 	log *zap.Logger,
 	cfg *config.Config,
+	candleRepo candle.Repository,
 	shutdowner fx.Shutdowner,
 ) {
 	lifecycle.Append(fx.Hook{
@@ -169,7 +233,7 @@ func registerLifecycleHooks(
 
 			exitCode := 0
 			go func() {
-				err := runSimulation()
+				err := runSimulation(candleRepo)
 				if err != nil {
 					log.Error("Failed to run optimize", zap.Error(err))
 					exitCode = 1
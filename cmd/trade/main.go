@@ -94,8 +94,8 @@ func main() {
 
 		fx.Provide(func(db postgres.Postgres) symbol.Repository { return symbolRepository.New(db) }),
 
-		fx.Provide(func(repo order.Repository, log *zap.Logger) order.Order {
-			return orderUsecase.New(repo, log)
+		fx.Provide(func(repo order.Repository, log *zap.Logger, cfg *config.Config) order.Order {
+			return orderUsecase.New(repo, log, orderUsecase.WithExchangeExits(cfg.Trader.ExchangeExits))
 		}),
 
 		fx.Provide(func(db postgres.Postgres) candle.Repository {
@@ -139,6 +139,7 @@ func main() {
 }
 
 func runTrade(
+	ctx context.Context,
 	log *zap.Logger,
 	tg *telegram.TelegramService,
 	cfg *config.Config,
@@ -164,7 +165,7 @@ func runTrade(
 	default:
 		runStage = stage_model.StageProd
 	}
-	return launcher.Launch(runStage, log, tg, cfg, orderUC, candleRepo, metricsDB, strategyRepo, traderRepo, symbolRepo)
+	return launcher.Launch(ctx, runStage, log, tg, cfg, orderUC, candleRepo, metricsDB, strategyRepo, traderRepo, symbolRepo)
 }
 
 // registerLifecycleHooks registers lifecycle hooks for the application
@@ -182,6 +183,9 @@ func registerLifecycleHooks(
 	metricsDB postgres.Postgres,
 	shutdowner fx.Shutdowner,
 ) {
+	// stops the order sync of the traders
+	runCtx, cancel := context.WithCancel(context.Background())
+
 	lifecycle.Append(fx.Hook{
 		OnStart: func(ctx context.Context) error {
 			log.Info("Starting backtest",
@@ -191,7 +195,7 @@ func registerLifecycleHooks(
 
 			exitCode := 0
 			go func() {
-				err := runTrade(log, tg, cfg, orderUC, candleRepo, metricsDB, strategyRepo, traderRepo, symbolRepo)
+				err := runTrade(runCtx, log, tg, cfg, orderUC, candleRepo, metricsDB, strategyRepo, traderRepo, symbolRepo)
 				if err != nil {
 					log.Error("Failed to run trade", zap.Error(err))
 					exitCode = 1
@@ -204,6 +208,7 @@ func registerLifecycleHooks(
 		},
 		OnStop: func(ctx context.Context) error {
 			log.Info("Stopping trader")
+			cancel()
 
 			log.Info("Trader stopped")
 			return nil
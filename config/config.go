@@ -5,9 +5,55 @@ type Config struct {
 	Logger          LoggerConfig          `yaml:"logger"`
 	Telegram        TelegramConfig        `yaml:"telegram"`
 	Bybit           BybitConfig           `yaml:"bybit"`
+	Binance         BinanceConfig         `yaml:"binance"`
 	Postgres        PostgresConfig        `yaml:"postgres"`
 	PostgresMetrics PostgresMetricsConfig `yaml:"postgres_metrics"`
 	DemoTrading     DemoTrading           `yaml:"demo_trading"`
+	Trader          TraderConfig          `yaml:"trader"`
+	Candles         CandlesConfig         `yaml:"candles"`
+}
+
+type CandlesConfig struct {
+	// Retention is applied by cmd/backfill --retention to the backfilled symbols
+	Retention []CandleRetentionConfig `yaml:"retention"`
+	// Quality validates and repairs the candles of backtest, optimize and the live trader
+	Quality CandleQualityConfig `yaml:"quality"`
+}
+
+type CandleQualityConfig struct {
+	// Policy repairs missing, duplicate, zero-volume, invalid and spike bars: ffill, drop, interpolate,
+	// fail or keep (default ffill)
+	Policy string `yaml:"policy"`
+	// Policies overrides Policy per issue, keyed by missing, duplicate, zero_volume, invalid_range or spike
+	Policies map[string]string `yaml:"policies"`
+	// SpikeThreshold is the jump of a spike in median absolute returns of the SpikeWindow bars before it
+	// (default 10 and 20)
+	SpikeThreshold float64 `yaml:"spike_threshold"`
+	SpikeWindow    int     `yaml:"spike_window"`
+}
+
+type CandleRetentionConfig struct {
+	// Timeframe of the candles dropped once they are older than KeepDays
+	Timeframe string `yaml:"timeframe"`
+	KeepDays  int    `yaml:"keep_days"`
+	// DownsampleTo aggregates the candles into a coarser timeframe before they are dropped, empty drops them
+	DownsampleTo string `yaml:"downsample_to"`
+}
+
+type TraderConfig struct {
+	// Exchange is the venue demo traders run on: bybit (demo trading) or binance (spot testnet), default bybit
+	Exchange string `yaml:"exchange"`
+	// IntrabarExits checks stop-loss and take-profit on every update of the forming candle (default true)
+	IntrabarExits *bool `yaml:"intrabar_exits"`
+	// ExchangeExits places take-profit and stop-loss as exchange-side orders once an entry is filled
+	ExchangeExits bool `yaml:"exchange_exits"`
+	// PassiveEntries enters with post-only limit orders instead of market orders
+	PassiveEntries bool `yaml:"passive_entries"`
+	// EntryTimeoutCandles cancels a passive entry that is not filled after this many candles (default 3)
+	EntryTimeoutCandles int `yaml:"entry_timeout_candles"`
+	// WindowCandles is the number of closed candles a trader keeps in memory, at least the lookback of its
+	// strategy (default 1000, or the lookback when longer)
+	WindowCandles int `yaml:"window_candles"`
 }
 
 type DemoTrading struct {
@@ -30,6 +76,15 @@ type LoggerConfig struct {
 type BybitConfig struct {
 	APIKey    string `yaml:"api_key"`
 	APISecret string `yaml:"api_secret"`
+	// BaseURL, PublicWSURL and PrivateWSURL override the endpoints of the trading mode, f.e. with cmd/bybit_emulator
+	BaseURL      string `yaml:"base_url"`
+	PublicWSURL  string `yaml:"public_ws_url"`
+	PrivateWSURL string `yaml:"private_ws_url"`
+}
+
+type BinanceConfig struct {
+	APIKey    string `yaml:"api_key"`
+	APISecret string `yaml:"api_secret"`
 }
 
 type TelegramConfig struct {
@@ -63,4 +63,19 @@ func applyDefaults(cfg *Config) {
 	if len(cfg.Logger.OutputPaths) == 0 {
 		cfg.Logger.OutputPaths = []string{"stdout"}
 	}
+
+	// Trader
+	if cfg.Trader.Exchange == "" {
+		cfg.Trader.Exchange = "bybit"
+	}
+	if cfg.Trader.IntrabarExits == nil {
+		intrabarExits := true
+		cfg.Trader.IntrabarExits = &intrabarExits
+	}
+	if cfg.Trader.EntryTimeoutCandles <= 0 {
+		cfg.Trader.EntryTimeoutCandles = 3
+	}
+	if cfg.Trader.WindowCandles < 0 {
+		cfg.Trader.WindowCandles = 0
+	}
 }
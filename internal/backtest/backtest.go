@@ -1,13 +1,13 @@
 package backtest
 
 import (
-	"cb_grok/internal/candle"
 	"cb_grok/internal/exchange"
-	"cb_grok/internal/order"
+	"cb_grok/internal/order/paper"
 	"cb_grok/internal/strategy"
 	strategyModel "cb_grok/internal/strategy/model"
 	"cb_grok/internal/telegram"
 	"cb_grok/internal/trader"
+	traderModel "cb_grok/internal/trader/model"
 	"cb_grok/pkg/models"
 	"fmt"
 	"go.uber.org/fx"
@@ -15,7 +15,7 @@ import (
 )
 
 type Backtest interface {
-	Run(candles []models.OHLCV, params strategyModel.StrategyParams) (*BacktestResult, error)
+	Run(candles []models.OHLCV, str strategy.Strategy) (*BacktestResult, error)
 }
 
 type backtest struct {
@@ -28,12 +28,9 @@ type backtest struct {
 
 	tg  *telegram.TelegramService
 	log *zap.Logger
-
-	orderUC    order.Order
-	candleRepo candle.Repository
 }
 
-func NewBacktest(log *zap.Logger, tg *telegram.TelegramService, orderUC order.Order, candleRepo candle.Repository) Backtest {
+func NewBacktest(log *zap.Logger, tg *telegram.TelegramService) Backtest {
 	return &backtest{
 		InitialCapital:       10000.0,
 		Commission:           0.001,  // 0.1%
@@ -42,32 +39,15 @@ func NewBacktest(log *zap.Logger, tg *telegram.TelegramService, orderUC order.Or
 		StopLossMultiplier:   5,
 		TakeProfitMultiplier: 30,
 
-		tg:         tg,
-		log:        log,
-		orderUC:    orderUC,
-		candleRepo: candleRepo,
+		tg:  tg,
+		log: log,
 	}
 }
 
-func (b *backtest) Run(ohlcv []models.OHLCV, params strategyModel.StrategyParams) (*BacktestResult, error) {
-	str := strategy.NewLinearBiasStrategy()
-
-	trade := trader.NewTrader(b.log, b.tg, b.orderUC, b.candleRepo)
-	trade.Setup(trader.Params{
-		StrategyParams: params,
-		Exchange:       exchange.NewMockExchange(),
-		Strategy:       str,
-		Settings: &trader.Settings{
-			Commission:           b.Commission,
-			SlippagePercent:      b.SlippagePercent,
-			Spread:               b.Spread,
-			StopLossMultiplier:   b.StopLossMultiplier,
-			TakeProfitMultiplier: b.TakeProfitMultiplier,
-		},
-		InitialCapital: b.InitialCapital,
-	})
+func (b *backtest) Run(ohlcv []models.OHLCV, str strategy.Strategy) (*BacktestResult, error) {
+	trade := b.newTrader(str)
 
-	appliedCandles := str.ApplyIndicators(ohlcv, params)
+	appliedCandles := str.ApplyIndicators(ohlcv)
 	if appliedCandles == nil {
 		return nil, fmt.Errorf("no candles after strategy apply")
 	}
@@ -78,12 +58,10 @@ func (b *backtest) Run(ohlcv []models.OHLCV, params strategyModel.StrategyParams
 		}
 	}
 
-	var valCandles []models.AppliedOHLCV
-
-	for i := 0; i < len(appliedCandles); i++ {
-		valCandles = append(valCandles, appliedCandles[i])
-
-		_, _ = trade.BacktestAlgo(valCandles)
+	// indicators and signals are computed once, a candle only sees the candles before it
+	errs := trade.BacktestSeries(appliedCandles)
+	if len(errs) > 0 {
+		b.log.Warn("backtest: failed to decide on candles", zap.Int("candles", len(errs)), zap.Error(errs[0]))
 	}
 
 	tradeState := trade.GetState()
@@ -96,6 +74,7 @@ func (b *backtest) Run(ohlcv []models.OHLCV, params strategyModel.StrategyParams
 			MaxDrawdown:  tradeState.CalculateMaxDrawdown(),
 			WinRate:      tradeState.CalculateWinRate(),
 			TradeState:   tradeState,
+			Errors:       errs,
 		}, nil
 	}
 
@@ -103,9 +82,37 @@ func (b *backtest) Run(ohlcv []models.OHLCV, params strategyModel.StrategyParams
 		Orders:       tradeState.GetOrders(),
 		FinalCapital: tradeState.GetPortfolioValue(),
 		TradeState:   tradeState,
+		Errors:       errs,
 	}, nil
 }
 
+// newTrader sets up a trader on its own paper broker, so parallel runs never share balances or orders
+func (b *backtest) newTrader(str strategy.Strategy) trader.Trader {
+	broker := paper.New(paper.Settings{
+		Commission:      b.Commission,
+		SlippagePercent: b.SlippagePercent,
+		Spread:          b.Spread,
+	}, b.InitialCapital)
+
+	trade := trader.NewTrader(b.log, b.tg, broker, nil)
+	trade.Setup(trader.Params{
+		Exchange: exchange.NewMockExchange(),
+		Strategy: str,
+		Settings: &trader.Settings{
+			Commission:           b.Commission,
+			SlippagePercent:      b.SlippagePercent,
+			Spread:               b.Spread,
+			StopLossMultiplier:   b.StopLossMultiplier,
+			TakeProfitMultiplier: b.TakeProfitMultiplier,
+		},
+		InitialCapital: b.InitialCapital,
+		StrategyModel:  &strategyModel.Strategy{Type: str.Type()},
+		Model:          &traderModel.Trader{InitQty: b.InitialCapital},
+	})
+
+	return trade
+}
+
 var Module = fx.Module("backtest",
 	fx.Provide(NewBacktest),
 )
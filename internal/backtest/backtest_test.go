@@ -0,0 +1,126 @@
+package backtest
+
+import (
+	"cb_grok/internal/strategy"
+	"cb_grok/pkg/models"
+	"math"
+	"math/rand"
+	"slices"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const testParams = `{"ma_short_period":10,"ma_long_period":50,"rsi_period":14,"atr_period":14,"buy_rsi_threshold":40,"sell_rsi_threshold":60,"ema_short_period":10,"ema_long_period":50,"macd_short_period":12,"macd_long_period":26,"macd_signal_period":9,"bollinger_period":20,"bollinger_std_dev":2,"stochastic_k_period":14,"stochastic_d_period":3,"adx_period":14,"buy_signal_threshold":0.3,"sell_signal_threshold":-0.3,"stop_loss_multiplier":2,"take_profit_multiplier":3,"ema_weight":1,"trend_weight":1,"rsi_weight":1,"macd_weight":1,"bb_weight":1,"stochastic_weight":1,"atr_threshold":0}`
+
+// testCandles returns a random walk of 1m candles with bursts of volatility, the same for the same seed
+func testCandles(n int, seed int64) []models.OHLCV {
+	rnd := rand.New(rand.NewSource(seed))
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC).UnixMilli()
+
+	candles := make([]models.OHLCV, n)
+	price, volatility := 40000.0, 0.0008
+	for i := range candles {
+		if rnd.Intn(1440) == 0 {
+			volatility = 0.0004 + rnd.Float64()*0.002
+		}
+		open := price
+		price *= math.Exp(rnd.NormFloat64() * volatility)
+		wick := price * volatility * rnd.Float64()
+		candles[i] = models.OHLCV{
+			Timestamp: start + int64(i)*time.Minute.Milliseconds(),
+			Open:      open,
+			High:      math.Max(open, price) + wick,
+			Low:       math.Min(open, price) - wick,
+			Close:     price,
+			Volume:    1 + rnd.Float64()*100,
+		}
+	}
+	return candles
+}
+
+func testStrategy(tb testing.TB) strategy.Strategy {
+	tb.Helper()
+	str, err := strategy.New(strategy.LinearBiasType, []byte(testParams))
+	if err != nil {
+		tb.Fatalf("build strategy: %v", err)
+	}
+	return str
+}
+
+func TestBacktestSeriesMatchesPerPrefixEvaluation(t *testing.T) {
+	str := testStrategy(t)
+	b := NewBacktest(zap.NewNop(), nil).(*backtest)
+	applied := str.ApplyIndicators(testCandles(5000, 1))
+
+	series := b.newTrader(str)
+	if errs := series.BacktestSeries(slices.Clone(applied)); len(errs) > 0 {
+		t.Fatalf("BacktestSeries: %v", errs[0])
+	}
+
+	// the engine before the single pass re-applied the signals to every prefix
+	prefix := b.newTrader(str)
+	var valCandles []models.AppliedOHLCV
+	for i := range applied {
+		valCandles = append(valCandles, applied[i])
+		if _, err := prefix.BacktestAlgo(valCandles); err != nil {
+			t.Fatalf("BacktestAlgo at candle %d: %v", i, err)
+		}
+	}
+
+	want, got := prefix.GetState(), series.GetState()
+	if len(want.GetOrders()) < 10 {
+		t.Fatalf("expected the candles to trade, got %d orders", len(want.GetOrders()))
+	}
+	if !slices.Equal(want.GetOrders(), got.GetOrders()) {
+		t.Fatalf("orders differ: %d per prefix, %d in a single pass", len(want.GetOrders()), len(got.GetOrders()))
+	}
+	if !slices.Equal(want.GetPortfolioValues(), got.GetPortfolioValues()) {
+		t.Fatal("portfolio values differ")
+	}
+	if want.GetPortfolioValue() != got.GetPortfolioValue() {
+		t.Fatalf("final capital %v per prefix, %v in a single pass", want.GetPortfolioValue(), got.GetPortfolioValue())
+	}
+}
+
+// BenchmarkRun backtests 1m candles, a year of them takes the single pass seconds where
+// BenchmarkPerPrefixEvaluation grows with the square of the candles
+func BenchmarkRun(b *testing.B) {
+	str := testStrategy(b)
+	bt := NewBacktest(zap.NewNop(), nil)
+
+	for _, bench := range []struct {
+		name    string
+		candles int
+	}{
+		{name: "20k", candles: 20000},
+		{name: "1y", candles: 365 * 24 * 60},
+	} {
+		candles := testCandles(bench.candles, 1)
+		b.Run(bench.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, err := bt.Run(candles, str); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkPerPrefixEvaluation(b *testing.B) {
+	str := testStrategy(b)
+	bt := NewBacktest(zap.NewNop(), nil).(*backtest)
+	applied := str.ApplyIndicators(testCandles(20000, 1))
+
+	b.Run("20k", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			trade := bt.newTrader(str)
+			var valCandles []models.AppliedOHLCV
+			for j := range applied {
+				valCandles = append(valCandles, applied[j])
+				_, _ = trade.BacktestAlgo(valCandles)
+			}
+		}
+	})
+}
@@ -11,6 +11,8 @@ type BacktestResult struct {
 	FinalCapital float64
 	MaxDrawdown  float64
 	WinRate      float64
+	// Errors holds the candles the trader failed to decide on, the backtest goes on with the next one
+	Errors []error
 }
 
 type Order struct {
@@ -0,0 +1,150 @@
+package dataset
+
+import (
+	"cb_grok/pkg/models"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// ReadCSV reads candles from CSV, columns are found by header name or by mapped index
+func ReadCSV(r io.Reader, opts Options) ([]models.OHLCV, error) {
+	reader := csv.NewReader(r)
+	if opts.Comma != 0 {
+		reader.Comma = opts.Comma
+	}
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	var header []string
+	if !opts.NoHeader {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read header: %w", err)
+		}
+		header = record
+	}
+
+	index, err := csvColumns(header, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var candles []models.OHLCV
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read line %d: %w", line, err)
+		}
+
+		candle, err := csvCandle(record, index, opts.timeUnit())
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		candles = append(candles, candle)
+	}
+
+	return candles, nil
+}
+
+// WriteCSV writes candles as CSV with a header of the mapped column names
+func WriteCSV(w io.Writer, candles []models.OHLCV, opts Options) error {
+	writer := csv.NewWriter(w)
+	if opts.Comma != 0 {
+		writer.Comma = opts.Comma
+	}
+
+	header := make([]string, len(Fields))
+	for i, field := range Fields {
+		header[i] = opts.column(field)
+	}
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+
+	unit := opts.timeUnit()
+	for _, c := range candles {
+		record := []string{
+			formatTimestamp(c.Timestamp, unit),
+			strconv.FormatFloat(c.Open, 'f', -1, 64),
+			strconv.FormatFloat(c.High, 'f', -1, 64),
+			strconv.FormatFloat(c.Low, 'f', -1, 64),
+			strconv.FormatFloat(c.Close, 'f', -1, 64),
+			strconv.FormatFloat(c.Volume, 'f', -1, 64),
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// csvColumns returns the record index of every field
+func csvColumns(header []string, opts Options) ([]int, error) {
+	index := make([]int, len(Fields))
+	for i, field := range Fields {
+		column := opts.column(field)
+		if n, err := strconv.Atoi(column); err == nil {
+			if n < 0 {
+				return nil, fmt.Errorf("invalid column index %d of %s", n, field)
+			}
+			index[i] = n
+			continue
+		}
+		if header == nil {
+			return nil, fmt.Errorf("field %s must be mapped to a column index in a file without a header", field)
+		}
+
+		index[i] = -1
+		for j, name := range header {
+			if strings.EqualFold(strings.TrimSpace(name), column) {
+				index[i] = j
+				break
+			}
+		}
+		if index[i] < 0 {
+			return nil, fmt.Errorf("column %q of %s not found in the header", column, field)
+		}
+	}
+	return index, nil
+}
+
+func csvCandle(record []string, index []int, unit TimeUnit) (models.OHLCV, error) {
+	var values [6]float64
+	var candle models.OHLCV
+	for i, field := range Fields {
+		if index[i] >= len(record) {
+			return candle, fmt.Errorf("missing %s column", field)
+		}
+		value := strings.TrimSpace(record[index[i]])
+
+		if i == 0 {
+			ts, err := parseTimestamp(value, unit)
+			if err != nil {
+				return candle, err
+			}
+			candle.Timestamp = ts
+			continue
+		}
+
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return candle, fmt.Errorf("invalid %s %q", field, value)
+		}
+		values[i] = f
+	}
+
+	candle.Open, candle.High, candle.Low, candle.Close, candle.Volume = values[1], values[2], values[3], values[4], values[5]
+	return candle, nil
+}
@@ -0,0 +1,258 @@
+package dataset
+
+import (
+	"cb_grok/pkg/models"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Format is the file format of a dataset, picked from the file extension
+type Format string
+
+const (
+	FormatCSV     Format = "csv"
+	FormatParquet Format = "parquet"
+)
+
+// TimeUnit is the unit of the timestamps in a file, candles always use milliseconds
+type TimeUnit string
+
+const (
+	TimeUnitSeconds      TimeUnit = "s"
+	TimeUnitMilliseconds TimeUnit = "ms"
+	TimeUnitMicroseconds TimeUnit = "us"
+	TimeUnitNanoseconds  TimeUnit = "ns"
+	// TimeUnitRFC3339 reads and writes timestamps as text, a plain 2006-01-02 date is accepted on read
+	TimeUnitRFC3339 TimeUnit = "rfc3339"
+)
+
+// Fields are the OHLCV fields in the default column order
+var Fields = []string{"timestamp", "open", "high", "low", "close", "volume"}
+
+// Options describe how candles are laid out in a file
+type Options struct {
+	// Columns maps an OHLCV field to a column name, or to a 0-based column index for CSV files without
+	// a header. Unmapped fields use their own name.
+	Columns map[string]string
+	// TimeUnit of the timestamp column, default milliseconds. Parquet timestamp columns carry their own unit.
+	TimeUnit TimeUnit
+	// Comma is the CSV field delimiter, default ','
+	Comma rune
+	// NoHeader reads a CSV file without a header row, every field must be mapped to an index
+	NoHeader bool
+}
+
+// ParseColumns parses a column mapping like "timestamp=open_time,volume=vol" or "timestamp=0,close=4"
+func ParseColumns(value string) (map[string]string, error) {
+	columns := map[string]string{}
+	if strings.TrimSpace(value) == "" {
+		return columns, nil
+	}
+	for _, item := range strings.Split(value, ",") {
+		field, column, ok := strings.Cut(strings.TrimSpace(item), "=")
+		if !ok || column == "" {
+			return nil, fmt.Errorf("invalid column mapping %q, expected field=column", item)
+		}
+		if !isField(field) {
+			return nil, fmt.Errorf("unknown field %q, expected one of %s", field, strings.Join(Fields, ", "))
+		}
+		columns[field] = column
+	}
+	return columns, nil
+}
+
+// ParseTimeUnit validates a --time-unit flag, empty means milliseconds
+func ParseTimeUnit(value string) (TimeUnit, error) {
+	switch unit := TimeUnit(value); unit {
+	case "":
+		return TimeUnitMilliseconds, nil
+	case TimeUnitSeconds, TimeUnitMilliseconds, TimeUnitMicroseconds, TimeUnitNanoseconds, TimeUnitRFC3339:
+		return unit, nil
+	}
+	return "", fmt.Errorf("unsupported time unit %q, expected s, ms, us, ns or rfc3339", value)
+}
+
+// NewOptions builds the options of the --data-columns and --data-time-unit flags
+func NewOptions(columns, timeUnit string) (Options, error) {
+	mapping, err := ParseColumns(columns)
+	if err != nil {
+		return Options{}, err
+	}
+	unit, err := ParseTimeUnit(timeUnit)
+	if err != nil {
+		return Options{}, err
+	}
+	return Options{Columns: mapping, TimeUnit: unit}, nil
+}
+
+// FormatOf returns the format of the file from its extension
+func FormatOf(path string) (Format, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv", ".txt":
+		return FormatCSV, nil
+	case ".parquet", ".pq":
+		return FormatParquet, nil
+	}
+	return "", fmt.Errorf("unsupported data file %s, expected .csv or .parquet", path)
+}
+
+// Load reads the candles of a CSV or Parquet file, sorted ascending by open time
+func Load(path string, opts Options) ([]models.OHLCV, error) {
+	format, err := FormatOf(path)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open data file: %w", err)
+	}
+	defer f.Close()
+
+	var candles []models.OHLCV
+	switch format {
+	case FormatCSV:
+		candles, err = ReadCSV(f, opts)
+	case FormatParquet:
+		var info os.FileInfo
+		if info, err = f.Stat(); err == nil {
+			candles, err = ReadParquet(f, info.Size(), opts)
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	sort.SliceStable(candles, func(i, j int) bool {
+		return candles[i].Timestamp < candles[j].Timestamp
+	})
+	return candles, nil
+}
+
+// Save writes the candles to a CSV or Parquet file
+func Save(path string, candles []models.OHLCV, opts Options) error {
+	format, err := FormatOf(path)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create data file: %w", err)
+	}
+
+	switch format {
+	case FormatCSV:
+		err = WriteCSV(f, candles, opts)
+	case FormatParquet:
+		err = WriteParquet(f, candles, opts)
+	}
+	if err != nil {
+		_ = f.Close()
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return f.Close()
+}
+
+// Between returns the candles opened in [start, end) of candles sorted ascending
+func Between(candles []models.OHLCV, start, end int64) []models.OHLCV {
+	from := sort.Search(len(candles), func(i int) bool { return candles[i].Timestamp >= start })
+	to := sort.Search(len(candles), func(i int) bool { return candles[i].Timestamp >= end })
+	return candles[from:to]
+}
+
+// Last returns the last total candles of candles sorted ascending
+func Last(candles []models.OHLCV, total int) []models.OHLCV {
+	if total < len(candles) {
+		return candles[len(candles)-total:]
+	}
+	return candles
+}
+
+// column returns the column name mapped to the field
+func (o Options) column(field string) string {
+	if column, ok := o.Columns[field]; ok {
+		return column
+	}
+	return field
+}
+
+func (o Options) timeUnit() TimeUnit {
+	if o.TimeUnit == "" {
+		return TimeUnitMilliseconds
+	}
+	return o.TimeUnit
+}
+
+// toMilliseconds converts a numeric timestamp of the unit to milliseconds
+func toMilliseconds(value int64, unit TimeUnit) int64 {
+	switch unit {
+	case TimeUnitSeconds:
+		return value * 1000
+	case TimeUnitMicroseconds:
+		return value / 1000
+	case TimeUnitNanoseconds:
+		return value / 1000000
+	}
+	return value
+}
+
+// fromMilliseconds converts an open time to a numeric timestamp of the unit
+func fromMilliseconds(ts int64, unit TimeUnit) int64 {
+	switch unit {
+	case TimeUnitSeconds:
+		return ts / 1000
+	case TimeUnitMicroseconds:
+		return ts * 1000
+	case TimeUnitNanoseconds:
+		return ts * 1000000
+	}
+	return ts
+}
+
+// parseTimestamp parses a text timestamp of the unit into milliseconds
+func parseTimestamp(value string, unit TimeUnit) (int64, error) {
+	if unit == TimeUnitRFC3339 {
+		if t, err := time.Parse(time.DateOnly, value); err == nil {
+			return t.UnixMilli(), nil
+		}
+		t, err := time.Parse(time.RFC3339Nano, value)
+		if err != nil {
+			return 0, fmt.Errorf("invalid timestamp %q", value)
+		}
+		return t.UnixMilli(), nil
+	}
+
+	ts, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		// some archives write integral timestamps as floats
+		f, ferr := strconv.ParseFloat(value, 64)
+		if ferr != nil {
+			return 0, fmt.Errorf("invalid timestamp %q", value)
+		}
+		ts = int64(f)
+	}
+	return toMilliseconds(ts, unit), nil
+}
+
+// formatTimestamp formats an open time in the unit
+func formatTimestamp(ts int64, unit TimeUnit) string {
+	if unit == TimeUnitRFC3339 {
+		return time.UnixMilli(ts).UTC().Format(time.RFC3339)
+	}
+	return strconv.FormatInt(fromMilliseconds(ts, unit), 10)
+}
+
+func isField(name string) bool {
+	for _, field := range Fields {
+		if field == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,192 @@
+package dataset
+
+import (
+	"cb_grok/pkg/models"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+// parquetBatch is the number of rows read at a time
+const parquetBatch = 1024
+
+// ReadParquet reads candles from a flat Parquet file, columns are found by mapped name.
+// Timestamp columns with a TIMESTAMP logical type use its unit instead of opts.TimeUnit.
+func ReadParquet(r io.ReaderAt, size int64, opts Options) ([]models.OHLCV, error) {
+	file, err := parquet.OpenFile(r, size)
+	if err != nil {
+		return nil, err
+	}
+
+	schema := file.Schema()
+	leaves := make([]parquet.LeafColumn, len(Fields))
+	for i, field := range Fields {
+		leaf, ok := schema.Lookup(opts.column(field))
+		if !ok {
+			return nil, fmt.Errorf("column %q of %s not found", opts.column(field), field)
+		}
+		leaves[i] = leaf
+	}
+	unit := opts.timeUnit()
+	if logical := leaves[0].Node.Type().LogicalType(); logical != nil && logical.Timestamp != nil {
+		switch {
+		case logical.Timestamp.Unit.Micros != nil:
+			unit = TimeUnitMicroseconds
+		case logical.Timestamp.Unit.Nanos != nil:
+			unit = TimeUnitNanoseconds
+		default:
+			unit = TimeUnitMilliseconds
+		}
+	}
+
+	reader := parquet.NewReader(file)
+	defer reader.Close()
+
+	candles := make([]models.OHLCV, 0, file.NumRows())
+	rows := make([]parquet.Row, parquetBatch)
+	for {
+		n, err := reader.ReadRows(rows)
+		for _, row := range rows[:n] {
+			candle, err := parquetCandle(row, leaves, unit)
+			if err != nil {
+				return nil, fmt.Errorf("row %d: %w", len(candles)+1, err)
+			}
+			candles = append(candles, candle)
+		}
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return candles, nil
+}
+
+// WriteParquet writes candles to Parquet with the mapped column names. The timestamp is an int64 of
+// opts.TimeUnit, rfc3339 writes it with the TIMESTAMP(MILLIS) logical type.
+func WriteParquet(w io.Writer, candles []models.OHLCV, opts Options) error {
+	unit := opts.timeUnit()
+	timestamp := parquet.Leaf(parquet.Int64Type)
+	if unit == TimeUnitRFC3339 {
+		timestamp = parquet.Timestamp(parquet.Millisecond)
+	}
+	group := parquet.Group{opts.column("timestamp"): parquet.Required(timestamp)}
+	for _, field := range Fields[1:] {
+		group[opts.column(field)] = parquet.Required(parquet.Leaf(parquet.DoubleType))
+	}
+	schema := parquet.NewSchema("candles", group)
+
+	// group columns are ordered by name, so the row layout follows the schema rather than Fields
+	columns := make([]int, len(Fields))
+	for i, field := range Fields {
+		leaf, _ := schema.Lookup(opts.column(field))
+		columns[i] = leaf.ColumnIndex
+	}
+
+	writer := parquet.NewWriter(w, schema)
+	rows := make([]parquet.Row, 0, parquetBatch)
+	flush := func() error {
+		if _, err := writer.WriteRows(rows); err != nil {
+			return err
+		}
+		rows = rows[:0]
+		return nil
+	}
+
+	for _, c := range candles {
+		ts := c.Timestamp
+		if unit != TimeUnitRFC3339 {
+			ts = fromMilliseconds(ts, unit)
+		}
+		row := make(parquet.Row, len(Fields))
+		row[columns[0]] = parquet.Int64Value(ts).Level(0, 0, columns[0])
+		for i, value := range []float64{c.Open, c.High, c.Low, c.Close, c.Volume} {
+			row[columns[i+1]] = parquet.DoubleValue(value).Level(0, 0, columns[i+1])
+		}
+
+		rows = append(rows, row)
+		if len(rows) == cap(rows) {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+	if err := flush(); err != nil {
+		return err
+	}
+
+	return writer.Close()
+}
+
+func parquetCandle(row parquet.Row, leaves []parquet.LeafColumn, unit TimeUnit) (models.OHLCV, error) {
+	var values [6]float64
+	var candle models.OHLCV
+	for i, leaf := range leaves {
+		value, ok := rowValue(row, leaf.ColumnIndex)
+		if !ok {
+			return candle, fmt.Errorf("missing %s", Fields[i])
+		}
+
+		if i == 0 {
+			ts, err := parquetTimestamp(value, unit)
+			if err != nil {
+				return candle, err
+			}
+			candle.Timestamp = ts
+			continue
+		}
+
+		f, err := parquetFloat(value)
+		if err != nil {
+			return candle, fmt.Errorf("invalid %s: %w", Fields[i], err)
+		}
+		values[i] = f
+	}
+
+	candle.Open, candle.High, candle.Low, candle.Close, candle.Volume = values[1], values[2], values[3], values[4], values[5]
+	return candle, nil
+}
+
+func rowValue(row parquet.Row, column int) (parquet.Value, bool) {
+	for _, value := range row {
+		if value.Column() == column {
+			return value, !value.IsNull()
+		}
+	}
+	return parquet.Value{}, false
+}
+
+func parquetTimestamp(value parquet.Value, unit TimeUnit) (int64, error) {
+	switch value.Kind() {
+	case parquet.Int64:
+		return toMilliseconds(value.Int64(), unit), nil
+	case parquet.Int32:
+		return toMilliseconds(int64(value.Int32()), unit), nil
+	case parquet.Double:
+		return toMilliseconds(int64(value.Double()), unit), nil
+	case parquet.ByteArray:
+		return parseTimestamp(string(value.ByteArray()), unit)
+	}
+	return 0, fmt.Errorf("unsupported timestamp type %s", value.Kind())
+}
+
+func parquetFloat(value parquet.Value) (float64, error) {
+	switch value.Kind() {
+	case parquet.Double:
+		return value.Double(), nil
+	case parquet.Float:
+		return float64(value.Float()), nil
+	case parquet.Int64:
+		return float64(value.Int64()), nil
+	case parquet.Int32:
+		return float64(value.Int32()), nil
+	case parquet.ByteArray:
+		return strconv.ParseFloat(string(value.ByteArray()), 64)
+	}
+	return 0, fmt.Errorf("unsupported type %s", value.Kind())
+}
@@ -0,0 +1,14 @@
+package candle
+
+import (
+	"cb_grok/internal/exchange"
+	"cb_grok/pkg/models"
+)
+
+// Provider serves closed candles from the candles table and fetches only the missing ones from the exchange
+type Provider interface {
+	// FetchSpotOHLCV returns the last total closed candles sorted ascending
+	FetchSpotOHLCV(symbol string, timeframe exchange.Timeframe, total int) ([]models.OHLCV, error)
+	// FetchSpotOHLCVRange returns the closed candles opened in [start, end) and the ones neither source has
+	FetchSpotOHLCVRange(symbol string, timeframe exchange.Timeframe, start, end int64) (*exchange.CandleRange, error)
+}
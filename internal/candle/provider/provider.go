@@ -0,0 +1,201 @@
+package provider
+
+import (
+	"cb_grok/internal/candle"
+	"cb_grok/internal/exchange"
+	tfpkg "cb_grok/internal/timeframe"
+	"cb_grok/pkg/models"
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// gapSettleTime is how long after its last candle closed a gap reported by the exchange is recorded as known,
+// candles the exchange publishes late are requested again until then
+const gapSettleTime = time.Hour
+
+type provider struct {
+	repo candle.Repository
+	ex   exchange.Exchange
+	log  *zap.Logger
+}
+
+// New returns a provider reading through repo, candles are stored under the exchange name and the symbol without "/"
+func New(repo candle.Repository, ex exchange.Exchange, log *zap.Logger) candle.Provider {
+	return &provider{
+		repo: repo,
+		ex:   ex,
+		log:  log,
+	}
+}
+
+func (p *provider) FetchSpotOHLCV(symbol string, timeframe exchange.Timeframe, total int) ([]models.OHLCV, error) {
+	if total <= 0 {
+		return nil, nil
+	}
+	start, end, err := exchange.LastClosedRange(timeframe, total)
+	if err != nil {
+		return nil, err
+	}
+	candleRange, err := p.FetchSpotOHLCVRange(symbol, timeframe, start, end)
+	if err != nil {
+		return nil, err
+	}
+	return candleRange.Candles, nil
+}
+
+func (p *provider) FetchSpotOHLCVRange(symbol string, timeframe exchange.Timeframe, start, end int64) (*exchange.CandleRange, error) {
+	if end <= start {
+		return nil, fmt.Errorf("invalid range: end %d is not after start %d", end, start)
+	}
+	if !timeframe.Native() {
+		return p.resampled(symbol, timeframe, start, end)
+	}
+	ctx := context.Background()
+	key := strings.ReplaceAll(symbol, "/", "")
+
+	cached, err := p.repo.Select(ctx, key, p.ex.Name(), string(timeframe), start, end-1)
+	if err != nil {
+		return nil, err
+	}
+	tf, err := timeframe.Parse()
+	if err != nil {
+		return nil, err
+	}
+	gaps, err := exchange.FindGaps(timeframe, cached, start, end)
+	if err != nil {
+		return nil, err
+	}
+	// gaps the exchange has no data for either are not requested again
+	known, err := p.repo.SelectGaps(ctx, key, p.ex.Name(), string(timeframe), start, end-1)
+	if err != nil {
+		return nil, err
+	}
+	missing := subtractGaps(tf, gaps, known)
+
+	result := &exchange.CandleRange{Candles: cached, Gaps: gaps}
+	if len(missing) == 0 {
+		p.log.Debug("candles served from cache", zap.String("symbol", key), zap.String("timeframe", string(timeframe)), zap.Int("candles", len(cached)))
+		return result, nil
+	}
+
+	var (
+		fetched []models.OHLCV
+		settled []exchange.Gap
+	)
+	settledBefore := time.Now().Add(-gapSettleTime).UnixMilli()
+	for _, gap := range missing {
+		candleRange, err := p.ex.FetchSpotOHLCVRange(symbol, timeframe, gap.Start, gap.End+1)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch missing candles: %w", err)
+		}
+		fetched = append(fetched, candleRange.Candles...)
+		for _, empty := range candleRange.Gaps {
+			if tf.Next(empty.End) <= settledBefore {
+				settled = append(settled, empty)
+			}
+		}
+	}
+
+	if err := p.repo.CreateBatch(ctx, key, p.ex.Name(), string(timeframe), fetched); err != nil {
+		return nil, err
+	}
+	if err := p.repo.CreateGaps(ctx, key, p.ex.Name(), string(timeframe), settled); err != nil {
+		return nil, err
+	}
+
+	p.log.Info("missing candles fetched from the exchange",
+		zap.String("symbol", key),
+		zap.String("timeframe", string(timeframe)),
+		zap.Int("cached", len(cached)),
+		zap.Int("fetched", len(fetched)),
+		zap.Time("from", time.UnixMilli(missing[0].Start).UTC()),
+		zap.Time("to", time.UnixMilli(missing[len(missing)-1].End).UTC()),
+	)
+
+	result.Candles = append(append(make([]models.OHLCV, 0, len(cached)+len(fetched)), cached...), fetched...)
+	sort.Slice(result.Candles, func(i, j int) bool {
+		return result.Candles[i].Timestamp < result.Candles[j].Timestamp
+	})
+	if result.Gaps, err = exchange.FindGaps(timeframe, result.Candles, start, end); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// subtractGaps returns the parts of the gaps not covered by the known ones
+func subtractGaps(tf tfpkg.Timeframe, gaps []exchange.Gap, known []exchange.Gap) []exchange.Gap {
+	var rest []exchange.Gap
+	for _, gap := range gaps {
+		parts := []exchange.Gap{gap}
+		for _, k := range known {
+			var next []exchange.Gap
+			for _, part := range parts {
+				if k.End < part.Start || k.Start > part.End {
+					next = append(next, part)
+					continue
+				}
+				if k.Start > part.Start {
+					next = append(next, exchange.Gap{Start: part.Start, End: tf.Prev(k.Start)})
+				}
+				if k.End < part.End {
+					next = append(next, exchange.Gap{Start: tf.Next(k.End), End: part.End})
+				}
+			}
+			parts = next
+		}
+		rest = append(rest, parts...)
+	}
+	return rest
+}
+
+// resampled builds the candles of a timeframe the exchange does not serve, like 2h or 3d, from cached 1m
+// candles, so only 1m candles need to be stored. Minutes the exchange has no candles for are left out of
+// their bar, a bar without any is reported as a gap.
+func (p *provider) resampled(symbol string, timeframe exchange.Timeframe, start, end int64) (*exchange.CandleRange, error) {
+	tf, err := timeframe.Parse()
+	if err != nil {
+		return nil, err
+	}
+	base, err := exchange.Timeframe1m.Parse()
+	if err != nil {
+		return nil, err
+	}
+
+	first := tf.Open(start)
+	if first < start {
+		first = tf.Next(first)
+	}
+	last := tf.Open(end - 1)
+	if last < first {
+		return &exchange.CandleRange{}, nil
+	}
+
+	minutes, err := p.FetchSpotOHLCVRange(symbol, exchange.Timeframe1m, first, tf.Next(last))
+	if err != nil {
+		return nil, err
+	}
+	bars, err := tfpkg.Resample(minutes.Candles, base, tf, true)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UnixMilli()
+	closed := bars[:0]
+	for _, bar := range bars {
+		if tf.Next(bar.Timestamp) <= now {
+			closed = append(closed, bar)
+		}
+	}
+	gaps, err := exchange.FindGaps(timeframe, closed, start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	return &exchange.CandleRange{Candles: closed, Gaps: gaps}, nil
+}
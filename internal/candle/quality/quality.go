@@ -0,0 +1,238 @@
+package quality
+
+import (
+	"cb_grok/config"
+	"cb_grok/internal/timeframe"
+	"cb_grok/pkg/models"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Kind is the kind of a data-quality issue
+type Kind string
+
+const (
+	// KindMissing is a run of bars absent from the timeframe grid
+	KindMissing Kind = "missing"
+	// KindDuplicate is a bar whose open time was already seen, the last copy is kept
+	KindDuplicate Kind = "duplicate"
+	// KindZeroVolume is a bar without any traded volume
+	KindZeroVolume Kind = "zero_volume"
+	// KindInvalidRange is a bar with high below low, open or close outside [low, high] or a non-positive price
+	KindInvalidRange Kind = "invalid_range"
+	// KindSpike is a close jumping away from its neighbours and reverting on the next bar
+	KindSpike Kind = "spike"
+)
+
+// Kinds are the issue kinds in report order
+var Kinds = []Kind{KindMissing, KindDuplicate, KindZeroVolume, KindInvalidRange, KindSpike}
+
+// Policy is how the bars affected by an issue are repaired
+type Policy string
+
+const (
+	// PolicyForwardFill replaces bad bars and fills missing ones with a flat bar at the previous close
+	PolicyForwardFill Policy = "ffill"
+	// PolicyDrop removes bad bars and leaves missing ones out
+	PolicyDrop Policy = "drop"
+	// PolicyInterpolate replaces bad bars and fills missing ones with closes interpolated between the
+	// surrounding good bars, the open is the previous close
+	PolicyInterpolate Policy = "interpolate"
+	// PolicyFail rejects the candles
+	PolicyFail Policy = "fail"
+	// PolicyKeep only reports the issue
+	PolicyKeep Policy = "keep"
+)
+
+const (
+	defaultSpikeThreshold = 10
+	defaultSpikeWindow    = 20
+	// maxLoggedIssues caps the issues logged one by one
+	maxLoggedIssues = 100
+)
+
+// Options configure the validation of a candle series
+type Options struct {
+	// Timeframe is the grid missing bars are detected on, zero skips the check
+	Timeframe timeframe.Timeframe
+	// Policy repairs every kind of issue without an entry in Policies, default forward-fill
+	Policy   Policy
+	Policies map[Kind]Policy
+	// SpikeThreshold is the return, in multiples of the median absolute return of the previous
+	// SpikeWindow bars, above which a reverting close is a spike (default 10 and 20)
+	SpikeThreshold float64
+	SpikeWindow    int
+}
+
+// ParsePolicy validates a policy name, empty means forward-fill
+func ParsePolicy(value string) (Policy, error) {
+	switch policy := Policy(value); policy {
+	case "":
+		return PolicyForwardFill, nil
+	case PolicyForwardFill, PolicyDrop, PolicyInterpolate, PolicyFail, PolicyKeep:
+		return policy, nil
+	}
+	return "", fmt.Errorf("unsupported repair policy %q, expected ffill, drop, interpolate, fail or keep", value)
+}
+
+// NewOptions builds the options of the candles.quality config for candles of the timeframe
+func NewOptions(cfg config.CandleQualityConfig, tf timeframe.Timeframe) (Options, error) {
+	policy, err := ParsePolicy(cfg.Policy)
+	if err != nil {
+		return Options{}, err
+	}
+	opts := Options{
+		Timeframe:      tf,
+		Policy:         policy,
+		Policies:       map[Kind]Policy{},
+		SpikeThreshold: cfg.SpikeThreshold,
+		SpikeWindow:    cfg.SpikeWindow,
+	}
+	for name, value := range cfg.Policies {
+		if !isKind(Kind(name)) {
+			return Options{}, fmt.Errorf("unknown candle issue %q", name)
+		}
+		if opts.Policies[Kind(name)], err = ParsePolicy(value); err != nil {
+			return Options{}, err
+		}
+	}
+	return opts, nil
+}
+
+// PolicyFor returns the policy repairing the kind of issue
+func (o Options) PolicyFor(kind Kind) Policy {
+	if policy, ok := o.Policies[kind]; ok {
+		return policy
+	}
+	if o.Policy == "" {
+		return PolicyForwardFill
+	}
+	return o.Policy
+}
+
+func (o Options) spikeThreshold() float64 {
+	if o.SpikeThreshold <= 0 {
+		return defaultSpikeThreshold
+	}
+	return o.SpikeThreshold
+}
+
+func (o Options) spikeWindow() int {
+	if o.SpikeWindow <= 0 {
+		return defaultSpikeWindow
+	}
+	return o.SpikeWindow
+}
+
+// Issue is a problem found at a bar. Count is the number of bars it covers, the missing bars of a run
+// or the extra copies of a duplicate.
+type Issue struct {
+	Kind      Kind
+	Timestamp int64
+	Count     int
+}
+
+// Report lists the issues found in a dataset and what the repair did about them
+type Report struct {
+	Dataset string
+	Candles int
+	Issues  []Issue
+	// Filled is the number of bars inserted in place of missing ones
+	Filled int
+	// Replaced is the number of bad bars rewritten by forward-fill or interpolation
+	Replaced int
+	// Dropped is the number of bars removed, duplicates included
+	Dropped int
+}
+
+// Count returns the number of bars affected by the kind of issue
+func (r *Report) Count(kind Kind) int {
+	count := 0
+	for _, issue := range r.Issues {
+		if issue.Kind == kind {
+			count += issue.Count
+		}
+	}
+	return count
+}
+
+// Clean reports whether no issue was found
+func (r *Report) Clean() bool {
+	return len(r.Issues) == 0
+}
+
+// String summarizes the report, like "BTCUSDT 1h: 8760 candles, missing 3; filled 3, replaced 0, dropped 0"
+func (r *Report) String() string {
+	var b strings.Builder
+	if r.Dataset != "" {
+		b.WriteString(r.Dataset + ": ")
+	}
+	fmt.Fprintf(&b, "%d candles", r.Candles)
+	if r.Clean() {
+		b.WriteString(", no issues")
+		return b.String()
+	}
+	for _, kind := range Kinds {
+		if count := r.Count(kind); count > 0 {
+			fmt.Fprintf(&b, ", %s %d", kind, count)
+		}
+	}
+	fmt.Fprintf(&b, "; filled %d, replaced %d, dropped %d", r.Filled, r.Replaced, r.Dropped)
+	return b.String()
+}
+
+// Log writes the summary of the report, as a warning when issues were found
+func (r *Report) Log(log *zap.Logger) {
+	if r.Clean() {
+		log.Info("candle quality", zap.String("report", r.String()))
+		return
+	}
+	log.Warn("candle quality issues", zap.String("report", r.String()), zap.Int("issues", len(r.Issues)))
+	for i, issue := range r.Issues {
+		if i == maxLoggedIssues {
+			log.Debug("candle quality issues truncated", zap.Int("more", len(r.Issues)-i))
+			break
+		}
+		log.Debug("candle quality issue",
+			zap.String("kind", string(issue.Kind)),
+			zap.Time("at", time.UnixMilli(issue.Timestamp).UTC()),
+			zap.Int("bars", issue.Count),
+		)
+	}
+}
+
+func (r *Report) add(kind Kind, ts int64, count int) {
+	r.Issues = append(r.Issues, Issue{Kind: kind, Timestamp: ts, Count: count})
+}
+
+func isKind(kind Kind) bool {
+	for _, k := range Kinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// badBar returns the issue of a single bar, empty when the bar is fine
+func badBar(candle models.OHLCV) Kind {
+	for _, price := range []float64{candle.Open, candle.High, candle.Low, candle.Close} {
+		if !(price > 0) || math.IsInf(price, 0) {
+			return KindInvalidRange
+		}
+	}
+	switch {
+	case candle.High < candle.Low,
+		candle.Open < candle.Low || candle.Open > candle.High,
+		candle.Close < candle.Low || candle.Close > candle.High,
+		!(candle.Volume >= 0) || math.IsInf(candle.Volume, 0):
+		return KindInvalidRange
+	case candle.Volume == 0:
+		return KindZeroVolume
+	}
+	return ""
+}
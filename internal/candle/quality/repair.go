@@ -0,0 +1,261 @@
+package quality
+
+import (
+	"cb_grok/pkg/models"
+	"cmp"
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+)
+
+const (
+	// minSpikeWindow is the number of returns needed before spikes are looked for
+	minSpikeWindow = 5
+	// minSpikeScale keeps a flat market from turning every tick into a spike
+	minSpikeScale = 1e-4
+)
+
+// patch is a bar of the output to compute once the kept bars are known
+type patch struct {
+	policy  Policy
+	missing bool
+}
+
+// Repair validates candles and repairs the issues found with the policies of the options, candles are
+// sorted by open time first. When an issue has the fail policy the sorted candles are returned with an
+// error listing the report.
+func Repair(dataset string, candles []models.OHLCV, opts Options) ([]models.OHLCV, *Report, error) {
+	return repair(dataset, candles, opts, math.MinInt64)
+}
+
+// Append adds a closed candle to a series repaired before, like the rolling window of a live trader.
+// Only the candle, the gap before it and the bar before it are validated: a spike is only confirmed
+// by the bar after it, so it is repaired once the next candle arrives. On failure the candle is kept as is.
+func Append(history []models.OHLCV, candle models.OHLCV, opts Options) ([]models.OHLCV, *Report, error) {
+	i := len(history)
+	for i > 0 && history[i-1].Timestamp >= candle.Timestamp {
+		i--
+	}
+	since := int64(math.MinInt64)
+	if i > 0 {
+		since = history[i-1].Timestamp
+	}
+
+	from := max(i-opts.spikeWindow()-1, 0)
+	tail := append(slices.Clone(history[from:]), candle)
+	repaired, report, err := repair("", tail, opts, since)
+	report.Candles = 1
+	return append(history[:from], repaired...), report, err
+}
+
+// repair validates the bars opened after since, spikes from since on, and repairs them
+func repair(dataset string, candles []models.OHLCV, opts Options, since int64) ([]models.OHLCV, *Report, error) {
+	report := &Report{Dataset: dataset, Candles: len(candles)}
+	if !slices.IsSortedFunc(candles, byTime) {
+		candles = slices.Clone(candles)
+		slices.SortStableFunc(candles, byTime)
+	}
+
+	var failed []Kind
+	fail := func(kind Kind) {
+		if !slices.Contains(failed, kind) {
+			failed = append(failed, kind)
+		}
+	}
+
+	rows := make([]models.OHLCV, 0, len(candles))
+	for i := 0; i < len(candles); {
+		j := i + 1
+		for j < len(candles) && candles[j].Timestamp == candles[i].Timestamp {
+			j++
+		}
+		if extra := j - i - 1; extra > 0 {
+			report.add(KindDuplicate, candles[i].Timestamp, extra)
+			switch opts.PolicyFor(KindDuplicate) {
+			case PolicyFail:
+				fail(KindDuplicate)
+			case PolicyKeep:
+				rows = append(rows, candles[i:j-1]...)
+			default:
+				report.Dropped += extra
+			}
+		}
+		rows = append(rows, candles[j-1])
+		i = j
+	}
+
+	kinds := make([]Kind, len(rows))
+	for i, row := range rows {
+		if row.Timestamp > since {
+			kinds[i] = badBar(row)
+		}
+	}
+	markSpikes(rows, kinds, opts, since)
+
+	out := make([]models.OHLCV, 0, len(rows))
+	patches := make([]patch, 0, len(rows))
+	for i, row := range rows {
+		if i > 0 && row.Timestamp > since && !opts.Timeframe.IsZero() {
+			if first, count := opts.gap(rows[i-1].Timestamp, row.Timestamp); count > 0 {
+				report.add(KindMissing, first, count)
+				switch policy := opts.PolicyFor(KindMissing); policy {
+				case PolicyFail:
+					fail(KindMissing)
+				case PolicyForwardFill, PolicyInterpolate:
+					for ts, k := first, 0; k < count; ts, k = opts.Timeframe.Next(ts), k+1 {
+						out = append(out, models.OHLCV{Timestamp: ts})
+						patches = append(patches, patch{policy: policy, missing: true})
+					}
+				}
+			}
+		}
+
+		kind := kinds[i]
+		if kind == "" {
+			out = append(out, row)
+			patches = append(patches, patch{})
+			continue
+		}
+		report.add(kind, row.Timestamp, 1)
+		switch policy := opts.PolicyFor(kind); policy {
+		case PolicyFail:
+			fail(kind)
+			fallthrough
+		case PolicyKeep:
+			out = append(out, row)
+			patches = append(patches, patch{})
+		case PolicyDrop:
+			report.Dropped++
+		default:
+			out = append(out, row)
+			patches = append(patches, patch{policy: policy})
+		}
+	}
+
+	if len(failed) > 0 {
+		return candles, report, fmt.Errorf("candles failed validation on %v: %s", failed, report)
+	}
+	return resolve(out, patches, report), report, nil
+}
+
+// resolve computes the patched bars: flat at the previous close for forward-fill, or with a close
+// interpolated in time between the kept bars around them. A patched bar with no bar before it is dropped.
+func resolve(out []models.OHLCV, patches []patch, report *Report) []models.OHLCV {
+	next := make([]int, len(out))
+	n := -1
+	for i := len(out) - 1; i >= 0; i-- {
+		next[i] = n
+		if patches[i].policy == "" {
+			n = i
+		}
+	}
+
+	result := out[:0]
+	var anchor models.OHLCV
+	for i, candle := range out {
+		p := patches[i]
+		if p.policy == "" {
+			result = append(result, candle)
+			anchor = candle
+			continue
+		}
+		if len(result) == 0 {
+			if !p.missing {
+				report.Dropped++
+			}
+			continue
+		}
+
+		open := result[len(result)-1].Close
+		price := open
+		if j := next[i]; p.policy == PolicyInterpolate && j >= 0 {
+			price = anchor.Close + (out[j].Close-anchor.Close)*float64(candle.Timestamp-anchor.Timestamp)/float64(out[j].Timestamp-anchor.Timestamp)
+		}
+		volume := 0.0
+		if !p.missing && candle.Volume > 0 && !math.IsInf(candle.Volume, 0) {
+			volume = candle.Volume
+		}
+		result = append(result, models.OHLCV{
+			Timestamp: candle.Timestamp,
+			Open:      open,
+			High:      max(open, price),
+			Low:       min(open, price),
+			Close:     price,
+			Volume:    volume,
+		})
+
+		if p.missing {
+			report.Filled++
+		} else {
+			report.Replaced++
+		}
+	}
+	return result
+}
+
+// markSpikes marks the closes jumping further from the previous close than the threshold, in multiples of
+// the median absolute return of the window before, and reverting most of the way on the next bar.
+// The last bar can't be told from a breakout yet and is never a spike.
+func markSpikes(rows []models.OHLCV, kinds []Kind, opts Options, since int64) {
+	threshold, size := opts.spikeThreshold(), opts.spikeWindow()
+	window := make([]float64, 0, size)
+	sorted := make([]float64, 0, size)
+	pos := 0
+	push := func(r float64) {
+		if len(window) < size {
+			window = append(window, r)
+			return
+		}
+		window[pos] = r
+		pos = (pos + 1) % size
+	}
+
+	for i := 1; i+1 < len(rows); i++ {
+		if !validPrice(rows[i-1].Close) || !validPrice(rows[i].Close) || !validPrice(rows[i+1].Close) ||
+			kinds[i-1] == KindInvalidRange || kinds[i+1] == KindInvalidRange {
+			continue
+		}
+		jump := math.Log(rows[i].Close / rows[i-1].Close)
+		back := math.Log(rows[i+1].Close / rows[i].Close)
+
+		if len(window) >= minSpikeWindow && kinds[i] == "" && rows[i].Timestamp >= since {
+			sorted = append(sorted[:0], window...)
+			sort.Float64s(sorted)
+			limit := threshold * max(sorted[len(sorted)/2], minSpikeScale)
+			if math.Abs(jump) > limit && math.Abs(back) > limit/2 && jump*back < 0 && math.Abs(jump+back) < math.Abs(jump)/2 {
+				kinds[i] = KindSpike
+				// the revert is no move of the market either
+				i++
+				continue
+			}
+		}
+		push(math.Abs(jump))
+	}
+}
+
+// gap returns the open time and the number of the bars missing between the bars opened at prev and next
+func (o Options) gap(prev, next int64) (int64, int) {
+	tf := o.Timeframe
+	first := tf.Next(tf.Open(prev))
+	if next <= first {
+		return first, 0
+	}
+	if !tf.Calendar() {
+		step := tf.Duration().Milliseconds()
+		return first, int((next - first + step - 1) / step)
+	}
+	count := 0
+	for ts := first; ts < next; ts = tf.Next(ts) {
+		count++
+	}
+	return first, count
+}
+
+func validPrice(price float64) bool {
+	return price > 0 && !math.IsInf(price, 0)
+}
+
+func byTime(a, b models.OHLCV) int {
+	return cmp.Compare(a.Timestamp, b.Timestamp)
+}
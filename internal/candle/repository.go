@@ -1,13 +1,22 @@
 package candle
 
 import (
+	"cb_grok/internal/exchange"
 	"cb_grok/pkg/models"
 	"context"
 )
 
-
-
 type Repository interface {
 	Create(ctx context.Context, symbol, exchange, timeframe string, candle models.OHLCV) error
+	// CreateBatch upserts candles in bulk, copying them into a staging table and merging it
+	CreateBatch(ctx context.Context, symbol, exchange, timeframe string, candles []models.OHLCV) error
 	Select(ctx context.Context, symbol, exchange, timeframe string, startTime, endTime int64) ([]models.OHLCV, error)
+	// FirstTimestamp returns the open time of the oldest stored candle, false when there is none
+	FirstTimestamp(ctx context.Context, symbol, exchange, timeframe string) (int64, bool, error)
+	// Delete removes the candles opened in [startTime, endTime] and returns their number
+	Delete(ctx context.Context, symbol, exchange, timeframe string, startTime, endTime int64) (int64, error)
+	// CreateGaps records runs of candles the exchange has no data for
+	CreateGaps(ctx context.Context, symbol, exchange, timeframe string, gaps []exchange.Gap) error
+	// SelectGaps returns the recorded gaps overlapping the candles opened in [startTime, endTime]
+	SelectGaps(ctx context.Context, symbol, exchange, timeframe string, startTime, endTime int64) ([]exchange.Gap, error)
 }
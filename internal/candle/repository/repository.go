@@ -6,8 +6,11 @@ import (
 	"time"
 
 	"cb_grok/internal/candle"
+	exchangeModel "cb_grok/internal/exchange"
 	"cb_grok/pkg/models"
 	"cb_grok/pkg/postgres"
+
+	"github.com/jackc/pgx/v4"
 )
 
 type Candle struct {
@@ -57,6 +60,115 @@ func (r *repository) Create(ctx context.Context, symbol, exchange, timeframe str
 	return nil
 }
 
+func (r *repository) CreateBatch(ctx context.Context, symbol, exchange, timeframe string, candles []models.OHLCV) error {
+	if len(candles) == 0 {
+		return nil
+	}
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, `
+		CREATE TEMPORARY TABLE candles_staging (
+			timestamp BIGINT NOT NULL,
+			open DOUBLE PRECISION NOT NULL,
+			high DOUBLE PRECISION NOT NULL,
+			low DOUBLE PRECISION NOT NULL,
+			close DOUBLE PRECISION NOT NULL,
+			volume DOUBLE PRECISION NOT NULL
+		) ON COMMIT DROP
+		;
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create staging table: %w", err)
+	}
+
+	_, err = tx.CopyFrom(ctx,
+		pgx.Identifier{"candles_staging"},
+		[]string{"timestamp", "open", "high", "low", "close", "volume"},
+		pgx.CopyFromSlice(len(candles), func(i int) ([]interface{}, error) {
+			c := candles[i]
+			return []interface{}{c.Timestamp, c.Open, c.High, c.Low, c.Close, c.Volume}, nil
+		}),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to copy candles: %w", err)
+	}
+
+	// DISTINCT ON keeps a batch with a repeated timestamp from failing the upsert
+	_, err = tx.Exec(ctx, `
+		INSERT INTO candles (symbol, exchange, timeframe, timestamp, open, high, low, close, volume)
+		SELECT DISTINCT ON (timestamp) $1, $2, $3, timestamp, open, high, low, close, volume
+		FROM candles_staging
+		ORDER BY timestamp
+		ON CONFLICT (symbol, exchange, timeframe, timestamp)
+		DO UPDATE SET
+			open = EXCLUDED.open,
+			high = EXCLUDED.high,
+			low = EXCLUDED.low,
+			close = EXCLUDED.close,
+			volume = EXCLUDED.volume,
+			updated_at = CURRENT_TIMESTAMP
+		WHERE (candles.open, candles.high, candles.low, candles.close, candles.volume)
+			IS DISTINCT FROM (EXCLUDED.open, EXCLUDED.high, EXCLUDED.low, EXCLUDED.close, EXCLUDED.volume)
+		;
+	`, symbol, exchange, timeframe)
+	if err != nil {
+		return fmt.Errorf("failed to merge candles: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit candles: %w", err)
+	}
+
+	return nil
+}
+
+func (r *repository) FirstTimestamp(ctx context.Context, symbol, exchange, timeframe string) (int64, bool, error) {
+	query := `
+		SELECT MIN(timestamp)
+		FROM candles
+		WHERE TRUE
+			AND symbol = $1
+			AND exchange = $2
+			AND timeframe = $3
+		;
+	`
+
+	var first *int64
+	if err := r.db.QueryRow(query, symbol, exchange, timeframe).Scan(&first); err != nil {
+		return 0, false, fmt.Errorf("failed to get first candle: %w", err)
+	}
+	if first == nil {
+		return 0, false, nil
+	}
+
+	return *first, true, nil
+}
+
+func (r *repository) Delete(ctx context.Context, symbol, exchange, timeframe string, startTime, endTime int64) (int64, error) {
+	query := `
+		DELETE FROM candles
+		WHERE TRUE
+			AND symbol = $1
+			AND exchange = $2
+			AND timeframe = $3
+			AND timestamp >= $4
+			AND timestamp <= $5
+		;
+	`
+
+	tag, err := r.db.Exec(query, symbol, exchange, timeframe, startTime, endTime)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete candles: %w", err)
+	}
+
+	return tag.RowsAffected(), nil
+}
+
 func (r *repository) Select(ctx context.Context, symbol, exchange, timeframe string, startTime, endTime int64) ([]models.OHLCV, error) {
 	query := `
 		SELECT
@@ -99,3 +211,72 @@ func (r *repository) Select(ctx context.Context, symbol, exchange, timeframe str
 
 	return result, nil
 }
+
+func (r *repository) CreateGaps(ctx context.Context, symbol, exchange, timeframe string, gaps []exchangeModel.Gap) error {
+	if len(gaps) == 0 {
+		return nil
+	}
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	query := `
+		INSERT INTO candle_gaps (symbol, exchange, timeframe, start_timestamp, end_timestamp)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (symbol, exchange, timeframe, start_timestamp)
+		DO UPDATE SET end_timestamp = GREATEST(candle_gaps.end_timestamp, EXCLUDED.end_timestamp)
+		;
+	`
+	for _, gap := range gaps {
+		if _, err := tx.Exec(ctx, query, symbol, exchange, timeframe, gap.Start, gap.End); err != nil {
+			return fmt.Errorf("failed to save candle gap: %w", err)
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit candle gaps: %w", err)
+	}
+
+	return nil
+}
+
+func (r *repository) SelectGaps(ctx context.Context, symbol, exchange, timeframe string, startTime, endTime int64) ([]exchangeModel.Gap, error) {
+	query := `
+		SELECT
+			start_timestamp,
+			end_timestamp
+		FROM candle_gaps
+		WHERE TRUE
+			AND symbol = $1
+			AND exchange = $2
+			AND timeframe = $3
+			AND start_timestamp <= $5
+			AND end_timestamp >= $4
+		ORDER BY start_timestamp ASC
+		;
+	`
+
+	rows, err := r.db.Query(query, symbol, exchange, timeframe, startTime, endTime)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query candle gaps: %w", err)
+	}
+	defer rows.Close()
+
+	var result []exchangeModel.Gap
+	for rows.Next() {
+		var gap exchangeModel.Gap
+		if err := rows.Scan(&gap.Start, &gap.End); err != nil {
+			return nil, fmt.Errorf("failed to scan candle gap: %w", err)
+		}
+		result = append(result, gap)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate rows: %w", err)
+	}
+
+	return result, nil
+}
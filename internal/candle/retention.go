@@ -0,0 +1,118 @@
+package candle
+
+import (
+	"cb_grok/internal/exchange"
+	"cb_grok/internal/timeframe"
+	"cb_grok/pkg/models"
+	"context"
+	"fmt"
+	"time"
+)
+
+// downsampleWindow bounds the fine-grained candles loaded at once while downsampling
+const downsampleWindow = 24 * time.Hour
+
+// RetentionPolicy removes candles of Timeframe older than KeepFor. When DownsampleTo is set they are
+// aggregated into that timeframe first, coarse candles already stored are kept as they are.
+type RetentionPolicy struct {
+	Timeframe    exchange.Timeframe
+	KeepFor      time.Duration
+	DownsampleTo exchange.Timeframe
+}
+
+// RetentionResult is what applying a policy to one series changed
+type RetentionResult struct {
+	Downsampled int
+	Deleted     int64
+}
+
+// ApplyRetention applies the policy to the candles of symbol on the exchange. Only whole coarse candles
+// are downsampled, so the cutoff is moved back to the open of the coarse candle containing it.
+func ApplyRetention(ctx context.Context, repo Repository, symbol, exchangeName string, policy RetentionPolicy, now time.Time) (*RetentionResult, error) {
+	if policy.KeepFor <= 0 {
+		return nil, fmt.Errorf("retention of %s candles must keep a positive duration", policy.Timeframe)
+	}
+
+	result := &RetentionResult{}
+	first, ok, err := repo.FirstTimestamp(ctx, symbol, exchangeName, string(policy.Timeframe))
+	if err != nil || !ok {
+		return result, err
+	}
+	cutoff := now.Add(-policy.KeepFor).UnixMilli()
+
+	if policy.DownsampleTo == "" {
+		if first >= cutoff {
+			return result, nil
+		}
+		result.Deleted, err = repo.Delete(ctx, symbol, exchangeName, string(policy.Timeframe), first, cutoff-1)
+		return result, err
+	}
+
+	fine, err := policy.Timeframe.Parse()
+	if err != nil {
+		return nil, err
+	}
+	coarse, err := policy.DownsampleTo.Parse()
+	if err != nil {
+		return nil, err
+	}
+	if fine == coarse || !fine.Divides(coarse) {
+		return nil, fmt.Errorf("cannot downsample %s candles into %s", fine, coarse)
+	}
+	cutoff = coarse.Open(cutoff)
+	cursor := coarse.Open(first)
+
+	for cursor < cutoff {
+		windowEnd := coarse.Next(cursor)
+		for windowEnd < cutoff && windowEnd-cursor < downsampleWindow.Milliseconds() {
+			windowEnd = coarse.Next(windowEnd)
+		}
+		windowEnd = min(windowEnd, cutoff)
+
+		candles, err := repo.Select(ctx, symbol, exchangeName, string(policy.Timeframe), cursor, windowEnd-1)
+		if err != nil {
+			return nil, err
+		}
+		if len(candles) > 0 {
+			stored, err := repo.Select(ctx, symbol, exchangeName, string(policy.DownsampleTo), cursor, windowEnd-1)
+			if err != nil {
+				return nil, err
+			}
+			bars, err := timeframe.Resample(candles, fine, coarse, true)
+			if err != nil {
+				return nil, err
+			}
+			bars = withoutStored(bars, stored)
+			// the aggregate is written before the fine candles are removed, an interrupted run loses nothing
+			if err := repo.CreateBatch(ctx, symbol, exchangeName, string(policy.DownsampleTo), bars); err != nil {
+				return nil, err
+			}
+			deleted, err := repo.Delete(ctx, symbol, exchangeName, string(policy.Timeframe), cursor, windowEnd-1)
+			if err != nil {
+				return nil, err
+			}
+			result.Downsampled += len(bars)
+			result.Deleted += deleted
+		}
+
+		cursor = windowEnd
+	}
+
+	return result, nil
+}
+
+// withoutStored returns the bars whose open is not among the stored candles
+func withoutStored(bars, stored []models.OHLCV) []models.OHLCV {
+	skip := make(map[int64]bool, len(stored))
+	for _, candle := range stored {
+		skip[candle.Timestamp] = true
+	}
+
+	result := bars[:0]
+	for _, bar := range bars {
+		if !skip[bar.Timestamp] {
+			result = append(result, bar)
+		}
+	}
+	return result
+}
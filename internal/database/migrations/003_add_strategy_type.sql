@@ -0,0 +1,3 @@
+-- Strategy implementation name, resolved through the strategy registry
+ALTER TABLE public.strategy
+    ADD COLUMN IF NOT EXISTS type VARCHAR(50) NOT NULL DEFAULT 'linear_bias';
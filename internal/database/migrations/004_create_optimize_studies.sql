@@ -0,0 +1,43 @@
+-- Optimizer studies, resumed by name
+CREATE TABLE IF NOT EXISTS optimize_study (
+    id BIGSERIAL PRIMARY KEY,
+    name VARCHAR(200) NOT NULL UNIQUE,
+    symbol VARCHAR(50) NOT NULL,
+    timeframe VARCHAR(10) NOT NULL,
+    strategy_type VARCHAR(50) NOT NULL,
+    direction VARCHAR(20) NOT NULL,
+    -- strategy row created from the best trial
+    promoted_strategy_id BIGINT,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Finished trials of a study
+CREATE TABLE IF NOT EXISTS optimize_trial (
+    id BIGSERIAL PRIMARY KEY,
+    study_id BIGINT NOT NULL REFERENCES optimize_study(id) ON DELETE CASCADE,
+    number INTEGER NOT NULL,
+    state VARCHAR(20) NOT NULL,
+    value DOUBLE PRECISION NOT NULL,
+
+    -- Strategy params as passed to the strategy
+    params JSONB NOT NULL,
+    -- goptuna internal representation, used to restore the sampler history on resume
+    internal_params JSONB NOT NULL,
+    distributions JSONB NOT NULL,
+
+    -- Backtest metrics of the trial
+    metrics JSONB,
+
+    started_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    completed_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(study_id, number)
+);
+
+CREATE INDEX idx_optimize_trial__study__value ON optimize_trial(study_id, value);
+
+CREATE TRIGGER update_optimize_study_updated_at
+    BEFORE UPDATE ON optimize_study
+    FOR EACH ROW
+    EXECUTE FUNCTION update_updated_at_column();
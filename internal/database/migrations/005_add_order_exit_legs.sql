@@ -0,0 +1,10 @@
+-- Exchange-side take-profit and stop-loss orders reference the entry order they protect
+ALTER TABLE public.order
+    ADD COLUMN IF NOT EXISTS parent_id BIGINT REFERENCES public.order(id);
+
+CREATE INDEX IF NOT EXISTS idx_order__parent_id ON public.order(parent_id);
+
+INSERT INTO public.order_type (id, code) VALUES
+    (2, 'take_profit'),
+    (3, 'stop_loss')
+ON CONFLICT (id) DO NOTHING;
@@ -0,0 +1,12 @@
+-- Limit and post-only orders keep their limit price and time in force
+ALTER TABLE public.order
+    ADD COLUMN IF NOT EXISTS price DOUBLE PRECISION,
+    ADD COLUMN IF NOT EXISTS time_in_force VARCHAR(16);
+
+INSERT INTO public.order_type (id, code) VALUES
+    (4, 'limit')
+ON CONFLICT (id) DO NOTHING;
+
+INSERT INTO public.order_status (id, code) VALUES
+    (5, 'partially_filled')
+ON CONFLICT (id) DO NOTHING;
@@ -0,0 +1,4 @@
+-- Orders placed through the Binance adapter reference its exchange row
+INSERT INTO public.exchange (name)
+SELECT 'binance'
+WHERE NOT EXISTS (SELECT 1 FROM public.exchange WHERE name = 'binance');
@@ -0,0 +1,6 @@
+-- Orders keep the commission of their executed quantity, reported by the exchange account stream
+ALTER TABLE public.order
+    ADD COLUMN IF NOT EXISTS fee DOUBLE PRECISION,
+    ADD COLUMN IF NOT EXISTS fee_currency VARCHAR(16);
+
+CREATE INDEX IF NOT EXISTS order_ext_id_idx ON public.order (ext_id);
@@ -0,0 +1,17 @@
+-- Fills of orders as reported by the exchange. Fees keep the coin they were charged in,
+-- positions and PnL are derived from executions instead of the netted order quantity.
+CREATE TABLE IF NOT EXISTS public.order_execution (
+    id BIGSERIAL PRIMARY KEY,
+    order_id BIGINT NOT NULL REFERENCES public.order(id),
+    ext_id VARCHAR(64) NOT NULL,
+    price DOUBLE PRECISION NOT NULL,
+    qty DOUBLE PRECISION NOT NULL,
+    value DOUBLE PRECISION NOT NULL,
+    fee DOUBLE PRECISION NOT NULL DEFAULT 0,
+    fee_currency VARCHAR(16) NOT NULL DEFAULT '',
+    is_maker BOOLEAN NOT NULL DEFAULT FALSE,
+    executed_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_order_execution__order_id_ext_id ON public.order_execution(order_id, ext_id);
@@ -0,0 +1,7 @@
+-- Years of 1m candles for many symbols outgrow a 32-bit id
+ALTER TABLE candles ALTER COLUMN id TYPE BIGINT;
+ALTER SEQUENCE candles_id_seq AS BIGINT;
+
+-- The UNIQUE(symbol, exchange, timeframe, timestamp) constraint already indexes these columns,
+-- a second copy only slows down bulk ingestion
+DROP INDEX IF EXISTS idx_candles_symbol_exchange_timeframe_timestamp;
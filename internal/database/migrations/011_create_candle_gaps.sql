@@ -0,0 +1,11 @@
+-- Runs of candles the exchange has no data for, f.e. before the listing or during maintenance,
+-- so the candle provider does not request them again
+CREATE TABLE IF NOT EXISTS candle_gaps (
+    symbol VARCHAR(50) NOT NULL,
+    exchange VARCHAR(50) NOT NULL,
+    timeframe VARCHAR(10) NOT NULL,
+    start_timestamp BIGINT NOT NULL,
+    end_timestamp BIGINT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (symbol, exchange, timeframe, start_timestamp)
+);
@@ -0,0 +1,69 @@
+package exchange
+
+import (
+	"cb_grok/internal/order/model"
+	"context"
+)
+
+// AccountStreamer is implemented by exchanges that push updates of the account's orders, fills and balances
+type AccountStreamer interface {
+	// SubscribeAccount streams account events until ctx is done, then closes the channel. The stream reconnects
+	// on its own, events missed while it was disconnected are not replayed: an event with Reconnected set
+	// asks consumers to reconcile.
+	SubscribeAccount(ctx context.Context) (<-chan AccountEvent, error)
+}
+
+// AccountEvent holds one update of the account, only one of its fields is set
+type AccountEvent struct {
+	Order  *OrderUpdate
+	Fill   *Fill
+	Wallet []WalletBalance
+	// Reconnected is sent once the stream is back after a disconnect
+	Reconnected bool
+}
+
+// OrderUpdate is the state of an order after a change
+type OrderUpdate struct {
+	OrderID string
+	Symbol  string
+	Side    OrderSide
+	Status  order_model.OrderStatus
+	// CumExecQty is the executed base quantity and CumExecValue its quote value
+	CumExecQty   float64
+	CumExecValue float64
+	// CumExecFee is the commission charged so far in FeeCurrency
+	CumExecFee  float64
+	FeeCurrency string
+	UpdatedAt   int64
+}
+
+// ReceivedQty is what the executed part of the order received net of fees: base for buys,
+// quote for sells. It matches Exchange.GetOrderQuoteQty.
+func (u OrderUpdate) ReceivedQty() float64 {
+	if u.Side == OrderSideSell {
+		return u.CumExecValue - u.CumExecFee
+	}
+	return u.CumExecQty - u.CumExecFee
+}
+
+// Fill is a single execution of an order
+type Fill struct {
+	ID      string
+	OrderID string
+	Symbol  string
+	Side    OrderSide
+	Price   float64
+	Qty     float64
+	Value   float64
+	Fee     float64
+	// FeeCurrency is the coin the fee is charged in, on spot the received coin
+	FeeCurrency string
+	IsMaker     bool
+	Time        int64
+}
+
+type WalletBalance struct {
+	Coin    string
+	Balance float64
+	Locked  float64
+}
@@ -0,0 +1,91 @@
+package binance
+
+import (
+	"cb_grok/internal/exchange"
+	"fmt"
+	"go.uber.org/zap"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	restLive    = "https://api.binance.com"
+	restTestnet = "https://testnet.binance.vision"
+
+	wsLive    = "wss://stream.binance.com:9443/ws"
+	wsTestnet = "wss://stream.testnet.binance.vision/ws"
+
+	httpTimeout = 15 * time.Second
+)
+
+type binance struct {
+	apiKey    string
+	apiSecret string
+
+	baseURL    string
+	wsURL      string
+	httpClient *http.Client
+	logger     *zap.Logger
+
+	instrumentsMu sync.Mutex
+	instruments   map[string]cachedInstrument
+}
+
+type Option func(b *binance)
+
+// WithBaseURL overrides the REST endpoint, f.e. with a recorded-response stand-in
+func WithBaseURL(url string) Option {
+	return func(b *binance) {
+		b.baseURL = url
+	}
+}
+
+// WithWebSocketURL overrides the market data stream endpoint
+func WithWebSocketURL(url string) Option {
+	return func(b *binance) {
+		b.wsURL = url
+	}
+}
+
+func WithHTTPClient(client *http.Client) Option {
+	return func(b *binance) {
+		b.httpClient = client
+	}
+}
+
+func NewBinance(apiKey, apiSecret string, tradingMode exchange.TradingMode, opts ...Option) (exchange.Exchange, error) {
+	b := &binance{
+		apiKey:      apiKey,
+		apiSecret:   apiSecret,
+		httpClient:  &http.Client{Timeout: httpTimeout},
+		logger:      zap.L(),
+		instruments: make(map[string]cachedInstrument),
+	}
+
+	switch tradingMode {
+	case exchange.TradingModeTestnet:
+		if apiKey == "" || apiSecret == "" {
+			return nil, fmt.Errorf("API key and secret are required for testnet mode")
+		}
+		b.baseURL, b.wsURL = restTestnet, wsTestnet
+	case exchange.TradingModeLive:
+		b.baseURL, b.wsURL = restLive, wsLive
+	default:
+		return nil, fmt.Errorf("unsupported trading mode: %s", tradingMode)
+	}
+
+	for _, opt := range opts {
+		opt(b)
+	}
+
+	return b, nil
+}
+
+func (b *binance) Name() string {
+	return "binance"
+}
+
+func (b *binance) Account() string {
+	return b.Name() + ":" + b.apiKey
+}
@@ -0,0 +1,84 @@
+package binance_test
+
+import (
+	"cb_grok/internal/exchange"
+	"cb_grok/internal/exchange/binance"
+	"cb_grok/internal/exchange/binance/binancetest"
+	"errors"
+	"testing"
+)
+
+// newTestBinance returns an adapter with credentials talking to a server answering with the spot recordings
+func newTestBinance(t *testing.T, opts ...binance.Option) (exchange.Exchange, *binancetest.Server) {
+	t.Helper()
+	srv := binancetest.NewServer(binancetest.SpotRecordings()...)
+	t.Cleanup(srv.Close)
+
+	ex, err := binance.NewBinance("key", "secret", exchange.TradingModeLive, append([]binance.Option{binance.WithBaseURL(srv.URL)}, opts...)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ex, srv
+}
+
+// lastRequest returns the last request received on path
+func lastRequest(t *testing.T, srv *binancetest.Server, method string, path string) binancetest.Request {
+	t.Helper()
+	requests := srv.Requests()
+	for i := len(requests) - 1; i >= 0; i-- {
+		if requests[i].Method == method && requests[i].Path == path {
+			return requests[i]
+		}
+	}
+	t.Fatalf("no %s %s request", method, path)
+	return binancetest.Request{}
+}
+
+func TestGetAvailableSpotWalletBalance(t *testing.T) {
+	ex, srv := newTestBinance(t)
+
+	for coin, want := range map[string]float64{"USDT": 9900.901, "BTC": 0.00104895, "ETH": 0} {
+		got, err := ex.GetAvailableSpotWalletBalance(coin)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("balance of %s = %v, want %v", coin, got, want)
+		}
+	}
+
+	req := lastRequest(t, srv, "GET", "/api/v3/account")
+	if req.APIKey != "key" || req.Query.Get("signature") == "" {
+		t.Errorf("account request is not signed: %+v", req)
+	}
+}
+
+func TestSignedRequestsRequireCredentials(t *testing.T) {
+	srv := binancetest.NewServer(binancetest.SpotRecordings()...)
+	t.Cleanup(srv.Close)
+	ex, err := binance.NewBinance("", "", exchange.TradingModeLive, binance.WithBaseURL(srv.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := ex.GetAvailableSpotWalletBalance("USDT"); err == nil {
+		t.Fatal("balance fetched without credentials")
+	}
+	// public endpoints work without them
+	if _, err := ex.GetInstrumentInfo("BTC/USDT"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAPIErrorsAreReturned(t *testing.T) {
+	ex, _ := newTestBinance(t)
+
+	err := ex.CancelOrder("BTC/USDT", "BTCUSDT:28457512")
+	var apiErr *binance.APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("error = %v, want an API error", err)
+	}
+	if apiErr.Code != -2011 {
+		t.Errorf("code = %d, want -2011", apiErr.Code)
+	}
+}
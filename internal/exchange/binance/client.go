@@ -0,0 +1,90 @@
+package binance
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// recvWindow is how long a signed request stays valid, in milliseconds
+const recvWindow = 5000
+
+// APIError is the error payload of the Binance REST API
+type APIError struct {
+	Code    int    `json:"code"`
+	Message string `json:"msg"`
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("request failed: %s, code: %d", e.Message, e.Code)
+}
+
+// request calls the REST API and decodes the response into out. Signed requests
+// carry the API key header and an HMAC-SHA256 signature of the query.
+func (b *binance) request(method string, path string, params url.Values, signed bool, out interface{}) error {
+	if params == nil {
+		params = url.Values{}
+	}
+	query := params.Encode()
+	if signed {
+		if b.apiKey == "" || b.apiSecret == "" {
+			return fmt.Errorf("%s %s requires API key and secret", method, path)
+		}
+		params.Set("recvWindow", strconv.Itoa(recvWindow))
+		params.Set("timestamp", strconv.FormatInt(time.Now().UnixMilli(), 10))
+		query = params.Encode()
+		query += "&signature=" + b.sign(query)
+	}
+
+	endpoint := b.baseURL + path
+	if query != "" {
+		endpoint += "?" + query
+	}
+	req, err := http.NewRequest(method, endpoint, nil)
+	if err != nil {
+		return fmt.Errorf("failed to build request %s %s: %w", method, path, err)
+	}
+	if b.apiKey != "" {
+		req.Header.Set("X-MBX-APIKEY", b.apiKey)
+	}
+
+	resp, err := b.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to call %s %s: %w", method, path, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response of %s %s: %w", method, path, err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		apiErr := &APIError{}
+		if err := json.Unmarshal(body, apiErr); err != nil || apiErr.Code == 0 {
+			return fmt.Errorf("%s %s: unexpected status %d: %s", method, path, resp.StatusCode, string(body))
+		}
+		return apiErr
+	}
+
+	if out == nil {
+		return nil
+	}
+	if err := json.Unmarshal(body, out); err != nil {
+		return fmt.Errorf("failed to unmarshal response of %s %s: %w", method, path, err)
+	}
+	return nil
+}
+
+func (b *binance) sign(query string) string {
+	mac := hmac.New(sha256.New, []byte(b.apiSecret))
+	mac.Write([]byte(query))
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,106 @@
+package binance
+
+import (
+	"cb_grok/internal/exchange"
+	"cb_grok/pkg/models"
+	"encoding/json"
+	"fmt"
+	"go.uber.org/zap"
+	"net/url"
+	"strconv"
+)
+
+// klinesLimit is the maximum number of candles per request
+const klinesLimit = 1000
+
+// FetchSpotOHLCV returns the last total candles including the forming one, sorted ascending
+func (b *binance) FetchSpotOHLCV(symbol string, timeframe exchange.Timeframe, total int) ([]models.OHLCV, error) {
+	page, err := b.klinePage(symbol, timeframe, total)
+	if err != nil {
+		return nil, err
+	}
+	return exchange.FetchLast(page, timeframe, total, klinesLimit)
+}
+
+// FetchSpotOHLCVRange returns the closed candles opened in [start, end) and the missing ones
+func (b *binance) FetchSpotOHLCVRange(symbol string, timeframe exchange.Timeframe, start, end int64) (*exchange.CandleRange, error) {
+	page, err := b.klinePage(symbol, timeframe, 0)
+	if err != nil {
+		return nil, err
+	}
+	return exchange.FetchRange(page, timeframe, start, end, klinesLimit)
+}
+
+// klinePage requests one page of candles, Binance returns the oldest limit candles of [start, end]
+func (b *binance) klinePage(symbol string, timeframe exchange.Timeframe, total int) (exchange.KlinePage, error) {
+	interval := GetBinanceTimeframe(timeframe)
+	if interval == "" {
+		return nil, fmt.Errorf("unsupported timeframe: %s", timeframe)
+	}
+
+	fetched := 0
+	return func(start, end int64, limit int) ([]models.OHLCV, error) {
+		params := url.Values{}
+		params.Set("symbol", Symbol(symbol))
+		params.Set("interval", interval)
+		params.Set("limit", strconv.Itoa(limit))
+		params.Set("startTime", strconv.FormatInt(start, 10))
+		params.Set("endTime", strconv.FormatInt(end, 10))
+
+		var rows [][]json.RawMessage
+		if err := b.request("GET", "/api/v3/klines", params, false, &rows); err != nil {
+			return nil, fmt.Errorf("failed to fetch ohlcv: %w", err)
+		}
+
+		candles := make([]models.OHLCV, 0, len(rows))
+		for _, row := range rows {
+			candle, err := parseKline(row)
+			if err != nil {
+				return nil, err
+			}
+			candles = append(candles, candle)
+		}
+
+		fetched += len(candles)
+		if total > 0 {
+			b.logger.Info(fmt.Sprintf("fetched ohlcv: %d/%d", fetched, total), zap.String("exchange", b.Name()))
+		} else {
+			b.logger.Debug(fmt.Sprintf("fetched ohlcv: %d", fetched), zap.String("exchange", b.Name()), zap.Int64("start", start), zap.Int64("end", end))
+		}
+		return candles, nil
+	}, nil
+}
+
+// parseKline decodes a kline row: [open time, open, high, low, close, volume, close time, ...]
+func parseKline(row []json.RawMessage) (models.OHLCV, error) {
+	if len(row) < 6 {
+		return models.OHLCV{}, fmt.Errorf("unexpected kline row length %d", len(row))
+	}
+
+	var ts int64
+	if err := json.Unmarshal(row[0], &ts); err != nil {
+		return models.OHLCV{}, fmt.Errorf("failed to parse timestamp: %w", err)
+	}
+
+	values := make([]float64, 0, 5)
+	for _, raw := range row[1:6] {
+		var s string
+		if err := json.Unmarshal(raw, &s); err != nil {
+			return models.OHLCV{}, fmt.Errorf("failed to parse decimal in ohlcv: %w", err)
+		}
+		v, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return models.OHLCV{}, fmt.Errorf("failed to parse decimal in ohlcv: %w", err)
+		}
+		values = append(values, v)
+	}
+
+	return models.OHLCV{
+		Timestamp: ts,
+		Open:      values[0],
+		High:      values[1],
+		Low:       values[2],
+		Close:     values[3],
+		Volume:    values[4],
+	}, nil
+}
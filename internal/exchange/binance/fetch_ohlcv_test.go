@@ -0,0 +1,72 @@
+package binance_test
+
+import (
+	"cb_grok/internal/exchange"
+	"cb_grok/pkg/models"
+	"testing"
+)
+
+// recordedKlines are the 1h BTCUSDT candles of the spot recordings, the last one is in progress
+var recordedKlines = []models.OHLCV{
+	{Timestamp: 1735678800000, Open: 93520.01, High: 93790, Low: 93430, Close: 93676.34, Volume: 412.38211},
+	{Timestamp: 1735682400000, Open: 93676.34, High: 94060.19, Low: 93600, Close: 93990.01, Volume: 535.10345},
+	{Timestamp: 1735686000000, Open: 93990.01, High: 94200, Low: 93842.17, Close: 94001.57, Volume: 486.87003},
+	{Timestamp: 1735689600000, Open: 94001.57, High: 94299, Low: 93890.9, Close: 94217.22, Volume: 398.66911},
+	{Timestamp: 1735693200000, Open: 94217.22, High: 94412.36, Low: 94150, Close: 94380, Volume: 121.02275},
+}
+
+func TestFetchSpotOHLCV(t *testing.T) {
+	ex, srv := newTestBinance(t)
+
+	candles, err := ex.FetchSpotOHLCV("BTC/USDT", exchange.Timeframe("1h"), 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := recordedKlines[2:]
+	if len(candles) != len(want) {
+		t.Fatalf("candles = %d, want %d", len(candles), len(want))
+	}
+	for i := range want {
+		if candles[i] != want[i] {
+			t.Errorf("candle %d = %+v, want %+v", i, candles[i], want[i])
+		}
+	}
+
+	req := lastRequest(t, srv, "GET", "/api/v3/klines")
+	if got := req.Query.Get("symbol"); got != "BTCUSDT" {
+		t.Errorf("symbol = %s, want BTCUSDT", got)
+	}
+	if got := req.Query.Get("interval"); got != "1h" {
+		t.Errorf("interval = %s, want 1h", got)
+	}
+}
+
+func TestFetchSpotOHLCVRange(t *testing.T) {
+	ex, _ := newTestBinance(t)
+
+	// the candle opening at end is outside the range
+	result, err := ex.FetchSpotOHLCVRange("BTC/USDT", exchange.Timeframe("1h"), recordedKlines[0].Timestamp, recordedKlines[4].Timestamp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := recordedKlines[:4]
+	if len(result.Candles) != len(want) {
+		t.Fatalf("candles = %d, want %d", len(result.Candles), len(want))
+	}
+	for i := range want {
+		if result.Candles[i] != want[i] {
+			t.Errorf("candle %d = %+v, want %+v", i, result.Candles[i], want[i])
+		}
+	}
+	if len(result.Gaps) != 0 {
+		t.Errorf("gaps = %+v, want none", result.Gaps)
+	}
+}
+
+func TestFetchSpotOHLCVRejectsUnknownTimeframe(t *testing.T) {
+	ex, _ := newTestBinance(t)
+
+	if _, err := ex.FetchSpotOHLCV("BTC/USDT", exchange.Timeframe("7m"), 3); err == nil {
+		t.Fatal("unsupported timeframe accepted")
+	}
+}
@@ -0,0 +1,133 @@
+package binance
+
+import (
+	"cb_grok/internal/exchange"
+	"cb_grok/internal/order/model"
+	"fmt"
+	"net/url"
+	"strconv"
+)
+
+func (b *binance) GetOrderStatus(orderId string) (order_model.OrderStatus, error) {
+	symbol, id, err := parseOrderID(orderId)
+	if err != nil {
+		return 0, err
+	}
+
+	params := url.Values{}
+	params.Set("symbol", symbol)
+	params.Set("orderId", id)
+
+	var ord Order
+	if err := b.request("GET", "/api/v3/order", params, true, &ord); err != nil {
+		return 0, fmt.Errorf("failed to get order %s: %w", orderId, err)
+	}
+
+	status, err := ParseOrderStatus(ord.Status)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse order status: %w", err)
+	}
+	return status, nil
+}
+
+// GetOrderQuoteQty follows the bybit semantics: a buy returns the base quantity received,
+// a sell the quote amount received, both net of commissions paid in that asset
+func (b *binance) GetOrderQuoteQty(orderId string) (float64, error) {
+	symbol, id, err := parseOrderID(orderId)
+	if err != nil {
+		return 0, err
+	}
+	instrument, err := b.instrument(symbol)
+	if err != nil {
+		return 0, err
+	}
+	trades, err := b.orderTrades(symbol, id)
+	if err != nil {
+		return 0, err
+	}
+
+	var amount float64
+	for _, trade := range trades {
+		qty, err := strconv.ParseFloat(trade.Qty, 64)
+		if err != nil {
+			return 0, fmt.Errorf("failed to parse trade qty: %w", err)
+		}
+		quoteQty, err := strconv.ParseFloat(trade.QuoteQty, 64)
+		if err != nil {
+			return 0, fmt.Errorf("failed to parse trade quote qty: %w", err)
+		}
+		commission, err := strconv.ParseFloat(trade.Commission, 64)
+		if err != nil {
+			return 0, fmt.Errorf("failed to parse trade commission: %w", err)
+		}
+
+		if trade.IsBuyer {
+			amount += qty
+			if trade.CommissionAsset == instrument.baseAsset {
+				amount -= commission
+			}
+		} else {
+			amount += quoteQty
+			if trade.CommissionAsset == instrument.quoteAsset {
+				amount -= commission
+			}
+		}
+	}
+
+	return amount, nil
+}
+
+// GetOrderFills maps the trades of the order, commissions keep the asset Binance charged them in, f.e. BNB
+func (b *binance) GetOrderFills(orderId string) ([]exchange.Fill, error) {
+	symbol, id, err := parseOrderID(orderId)
+	if err != nil {
+		return nil, err
+	}
+	trades, err := b.orderTrades(symbol, id)
+	if err != nil {
+		return nil, err
+	}
+
+	fills := make([]exchange.Fill, 0, len(trades))
+	for _, trade := range trades {
+		fill := exchange.Fill{
+			ID:          strconv.FormatInt(trade.ID, 10),
+			OrderID:     orderId,
+			Symbol:      trade.Symbol,
+			Side:        exchange.OrderSideSell,
+			FeeCurrency: trade.CommissionAsset,
+			IsMaker:     trade.IsMaker,
+			Time:        trade.Time,
+		}
+		if trade.IsBuyer {
+			fill.Side = exchange.OrderSideBuy
+		}
+		if fill.Price, err = strconv.ParseFloat(trade.Price, 64); err != nil {
+			return nil, fmt.Errorf("failed to parse trade price: %w", err)
+		}
+		if fill.Qty, err = strconv.ParseFloat(trade.Qty, 64); err != nil {
+			return nil, fmt.Errorf("failed to parse trade qty: %w", err)
+		}
+		if fill.Value, err = strconv.ParseFloat(trade.QuoteQty, 64); err != nil {
+			return nil, fmt.Errorf("failed to parse trade quote qty: %w", err)
+		}
+		if fill.Fee, err = strconv.ParseFloat(trade.Commission, 64); err != nil {
+			return nil, fmt.Errorf("failed to parse trade commission: %w", err)
+		}
+		fills = append(fills, fill)
+	}
+
+	return fills, nil
+}
+
+func (b *binance) orderTrades(symbol string, orderId string) ([]Trade, error) {
+	params := url.Values{}
+	params.Set("symbol", symbol)
+	params.Set("orderId", orderId)
+
+	var trades []Trade
+	if err := b.request("GET", "/api/v3/myTrades", params, true, &trades); err != nil {
+		return nil, fmt.Errorf("failed to get trades of order %s: %w", orderId, err)
+	}
+	return trades, nil
+}
@@ -0,0 +1,60 @@
+package binance_test
+
+import (
+	"cb_grok/internal/exchange"
+	"cb_grok/internal/order/model"
+	"math"
+	"testing"
+)
+
+const testOrderID = "BTCUSDT:28457512"
+
+func TestGetOrderStatus(t *testing.T) {
+	ex, _ := newTestBinance(t)
+
+	status, err := ex.GetOrderStatus(testOrderID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status != order_model.OrderStatusFilled {
+		t.Errorf("status = %v, want filled", status)
+	}
+
+	if _, err := ex.GetOrderStatus("28457512"); err == nil {
+		t.Error("order id without symbol accepted")
+	}
+}
+
+func TestGetOrderQuoteQty(t *testing.T) {
+	ex, _ := newTestBinance(t)
+
+	// a buy receives the traded base quantity net of the commission charged in it
+	got, err := ex.GetOrderQuoteQty(testOrderID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := 0.00105 - 0.00000105; math.Abs(got-want) > 1e-12 {
+		t.Errorf("quote qty = %v, want %v", got, want)
+	}
+}
+
+func TestGetOrderFills(t *testing.T) {
+	ex, _ := newTestBinance(t)
+
+	fills, err := ex.GetOrderFills(testOrderID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []exchange.Fill{
+		{ID: "4410372", OrderID: testOrderID, Symbol: "BTCUSDT", Side: exchange.OrderSideBuy, Price: 94378, Qty: 0.0006, Value: 56.6268, Fee: 0.0000006, FeeCurrency: "BTC", Time: 1735693260123},
+		{ID: "4410373", OrderID: testOrderID, Symbol: "BTCUSDT", Side: exchange.OrderSideBuy, Price: 94380, Qty: 0.00045, Value: 42.471, Fee: 0.00000045, FeeCurrency: "BTC", Time: 1735693260123},
+	}
+	if len(fills) != len(want) {
+		t.Fatalf("fills = %d, want %d", len(fills), len(want))
+	}
+	for i := range want {
+		if fills[i] != want[i] {
+			t.Errorf("fill %d = %+v, want %+v", i, fills[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,29 @@
+package binance
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+)
+
+func (b *binance) GetAvailableSpotWalletBalance(coin string) (float64, error) {
+	params := url.Values{}
+	params.Set("omitZeroBalances", "true")
+
+	var account Account
+	if err := b.request("GET", "/api/v3/account", params, true, &account); err != nil {
+		return 0, fmt.Errorf("failed to get wallet balance: %w", err)
+	}
+
+	for _, balance := range account.Balances {
+		if balance.Asset == coin {
+			free, err := strconv.ParseFloat(balance.Free, 64)
+			if err != nil {
+				return 0, fmt.Errorf("failed to parse balance for coin %s: %w", coin, err)
+			}
+			return free, nil
+		}
+	}
+
+	return 0, nil
+}
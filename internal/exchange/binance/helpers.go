@@ -0,0 +1,67 @@
+package binance
+
+import (
+	"cb_grok/internal/exchange"
+	"cb_grok/internal/order/model"
+	"fmt"
+	"strings"
+)
+
+// Symbol converts a symbol code like BTC/USDT to the Binance notation
+func Symbol(symbol string) string {
+	return strings.ToUpper(strings.ReplaceAll(symbol, "/", ""))
+}
+
+func GetBinanceOrderSide(orderSide exchange.OrderSide) string {
+	switch orderSide {
+	case exchange.OrderSideBuy:
+		return "BUY"
+	case exchange.OrderSideSell:
+		return "SELL"
+	default:
+		return ""
+	}
+}
+
+// GetBinanceTimeframe returns the kline interval, Binance uses the same notation
+func GetBinanceTimeframe(timeframe exchange.Timeframe) string {
+	switch timeframe {
+	case exchange.Timeframe1m, exchange.Timeframe5m, exchange.Timeframe15m, exchange.Timeframe30m,
+		exchange.Timeframe1h, exchange.Timeframe4h, exchange.Timeframe1d, exchange.Timeframe1w, exchange.Timeframe1M:
+		return string(timeframe)
+	default:
+		return ""
+	}
+}
+
+func ParseOrderStatus(status string) (order_model.OrderStatus, error) {
+	switch status {
+	case "PENDING_NEW":
+		return order_model.OrderStatusNew, nil
+	case "NEW":
+		return order_model.OrderStatusPlaced, nil
+	case "PARTIALLY_FILLED":
+		return order_model.OrderStatusPartiallyFilled, nil
+	case "FILLED":
+		return order_model.OrderStatusFilled, nil
+	case "CANCELED", "PENDING_CANCEL", "REJECTED", "EXPIRED", "EXPIRED_IN_MATCH":
+		return order_model.OrderStatusCanceled, nil
+	default:
+		return 0, fmt.Errorf("unknown order status: %s", status)
+	}
+}
+
+// Binance looks orders up by symbol and id, so the symbol travels inside the order id
+// handed out to the order usecase: BTCUSDT:12345
+
+func formatOrderID(symbol string, orderID int64) string {
+	return fmt.Sprintf("%s:%d", symbol, orderID)
+}
+
+func parseOrderID(id string) (string, string, error) {
+	symbol, orderID, ok := strings.Cut(id, ":")
+	if !ok || symbol == "" || orderID == "" {
+		return "", "", fmt.Errorf("invalid binance order id %q", id)
+	}
+	return symbol, orderID, nil
+}
@@ -0,0 +1,101 @@
+package binance
+
+import (
+	"cb_grok/internal/exchange"
+	"fmt"
+	"math"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// instrumentInfoTTL bounds how long the trading rules of a symbol are cached
+const instrumentInfoTTL = time.Hour
+
+type cachedInstrument struct {
+	info       exchange.InstrumentInfo
+	baseAsset  string
+	quoteAsset string
+	fetchedAt  time.Time
+}
+
+func (b *binance) GetInstrumentInfo(symbol string) (*exchange.InstrumentInfo, error) {
+	instrument, err := b.instrument(symbol)
+	if err != nil {
+		return nil, err
+	}
+	info := instrument.info
+	return &info, nil
+}
+
+func (b *binance) instrument(symbol string) (cachedInstrument, error) {
+	symbol = Symbol(symbol)
+
+	b.instrumentsMu.Lock()
+	defer b.instrumentsMu.Unlock()
+
+	if cached, ok := b.instruments[symbol]; ok && time.Since(cached.fetchedAt) < instrumentInfoTTL {
+		return cached, nil
+	}
+
+	params := url.Values{}
+	params.Set("symbol", symbol)
+
+	var exchangeInfo ExchangeInfo
+	if err := b.request("GET", "/api/v3/exchangeInfo", params, false, &exchangeInfo); err != nil {
+		return cachedInstrument{}, fmt.Errorf("failed to get instrument info of %s: %w", symbol, err)
+	}
+	if len(exchangeInfo.Symbols) != 1 {
+		return cachedInstrument{}, fmt.Errorf("expected one instrument %s in response, got %d", symbol, len(exchangeInfo.Symbols))
+	}
+
+	info, err := parseSymbolInfo(exchangeInfo.Symbols[0])
+	if err != nil {
+		return cachedInstrument{}, fmt.Errorf("failed to parse instrument info of %s: %w", symbol, err)
+	}
+
+	cached := cachedInstrument{
+		info:       info,
+		baseAsset:  exchangeInfo.Symbols[0].BaseAsset,
+		quoteAsset: exchangeInfo.Symbols[0].QuoteAsset,
+		fetchedAt:  time.Now(),
+	}
+	b.instruments[symbol] = cached
+	return cached, nil
+}
+
+func parseSymbolInfo(symbol SymbolInfo) (exchange.InstrumentInfo, error) {
+	info := exchange.InstrumentInfo{
+		Symbol:         symbol.Symbol,
+		QuotePrecision: math.Pow10(-symbol.QuoteAssetPrecision),
+	}
+
+	type field struct {
+		value string
+		dst   *float64
+	}
+	for _, filter := range symbol.Filters {
+		var fields []field
+		switch filter.FilterType {
+		case "PRICE_FILTER":
+			fields = []field{{filter.TickSize, &info.TickSize}}
+		case "LOT_SIZE":
+			fields = []field{{filter.StepSize, &info.BasePrecision}, {filter.MinQty, &info.MinOrderQty}, {filter.MaxQty, &info.MaxOrderQty}}
+		case "NOTIONAL", "MIN_NOTIONAL":
+			fields = []field{{filter.MinNotional, &info.MinOrderAmt}, {filter.MaxNotional, &info.MaxOrderAmt}}
+		}
+
+		for _, f := range fields {
+			if f.value == "" {
+				continue
+			}
+			value, err := strconv.ParseFloat(f.value, 64)
+			if err != nil {
+				return exchange.InstrumentInfo{}, err
+			}
+			*f.dst = value
+		}
+	}
+
+	return info, nil
+}
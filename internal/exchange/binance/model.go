@@ -0,0 +1,92 @@
+package binance
+
+type ExchangeInfo struct {
+	Symbols []SymbolInfo `json:"symbols"`
+}
+
+type SymbolInfo struct {
+	Symbol              string         `json:"symbol"`
+	Status              string         `json:"status"`
+	BaseAsset           string         `json:"baseAsset"`
+	QuoteAsset          string         `json:"quoteAsset"`
+	QuoteAssetPrecision int            `json:"quoteAssetPrecision"`
+	Filters             []SymbolFilter `json:"filters"`
+}
+
+type SymbolFilter struct {
+	FilterType  string `json:"filterType"`
+	TickSize    string `json:"tickSize"`
+	StepSize    string `json:"stepSize"`
+	MinQty      string `json:"minQty"`
+	MaxQty      string `json:"maxQty"`
+	MinNotional string `json:"minNotional"`
+	MaxNotional string `json:"maxNotional"`
+}
+
+type Order struct {
+	Symbol              string `json:"symbol"`
+	OrderID             int64  `json:"orderId"`
+	ClientOrderID       string `json:"clientOrderId"`
+	Price               string `json:"price"`
+	OrigQty             string `json:"origQty"`
+	ExecutedQty         string `json:"executedQty"`
+	CummulativeQuoteQty string `json:"cummulativeQuoteQty"`
+	Status              string `json:"status"`
+	TimeInForce         string `json:"timeInForce"`
+	Type                string `json:"type"`
+	Side                string `json:"side"`
+	StopPrice           string `json:"stopPrice"`
+}
+
+// OrderList is an OCO order list, OrderReports holds its legs
+type OrderList struct {
+	OrderListID  int64   `json:"orderListId"`
+	OrderReports []Order `json:"orderReports"`
+}
+
+type Trade struct {
+	Symbol          string `json:"symbol"`
+	ID              int64  `json:"id"`
+	OrderID         int64  `json:"orderId"`
+	Price           string `json:"price"`
+	Qty             string `json:"qty"`
+	QuoteQty        string `json:"quoteQty"`
+	Commission      string `json:"commission"`
+	CommissionAsset string `json:"commissionAsset"`
+	Time            int64  `json:"time"`
+	IsBuyer         bool   `json:"isBuyer"`
+	IsMaker         bool   `json:"isMaker"`
+}
+
+type Account struct {
+	Balances []Balance `json:"balances"`
+}
+
+type Balance struct {
+	Asset  string `json:"asset"`
+	Free   string `json:"free"`
+	Locked string `json:"locked"`
+}
+
+// WSKlineMessage is a kline stream event. encoding/json matches keys case-insensitively, so the
+// upper-case keys need fields of their own or they would overwrite their lower-case namesakes.
+type WSKlineMessage struct {
+	EventType string `json:"e"`
+	EventTime int64  `json:"E"`
+	Symbol    string `json:"s"`
+	Kline     struct {
+		Start               int64  `json:"t"`
+		End                 int64  `json:"T"`
+		Interval            string `json:"i"`
+		Open                string `json:"o"`
+		Close               string `json:"c"`
+		High                string `json:"h"`
+		Low                 string `json:"l"`
+		LastTradeID         int64  `json:"L"`
+		Volume              string `json:"v"`
+		TakerBuyVolume      string `json:"V"`
+		QuoteVolume         string `json:"q"`
+		TakerBuyQuoteVolume string `json:"Q"`
+		Closed              bool   `json:"x"`
+	} `json:"k"`
+}
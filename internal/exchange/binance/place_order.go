@@ -0,0 +1,223 @@
+package binance
+
+import (
+	"cb_grok/internal/exchange"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+)
+
+func (b *binance) PlaceSpotMarketOrder(symbol string, orderSide exchange.OrderSide, baseQty float64, takeProfit *float64, stopLoss *float64) (string, error) {
+	if takeProfit != nil || stopLoss != nil {
+		return "", errors.New("binance: take-profit and stop-loss cannot be attached to spot orders")
+	}
+
+	info, err := b.GetInstrumentInfo(symbol)
+	if err != nil {
+		return "", err
+	}
+
+	params, err := orderParams(symbol, orderSide, "MARKET")
+	if err != nil {
+		return "", err
+	}
+	// market buys are sized in the quote asset, as on bybit
+	if orderSide == exchange.OrderSideBuy {
+		params.Set("quoteOrderQty", info.FormatQuote(baseQty))
+	} else {
+		params.Set("quantity", info.FormatQty(baseQty))
+	}
+
+	return b.placeOrder(params)
+}
+
+// PlaceSpotConditionalOrder places a STOP_LOSS or TAKE_PROFIT market order depending on which side
+// of the last price the trigger is. Unlike bybit, Binance locks the balance of a resting conditional
+// order, so only one exit per position fits into the balance, see PlaceSpotOCOOrder for both.
+func (b *binance) PlaceSpotConditionalOrder(symbol string, orderSide exchange.OrderSide, baseQty float64, triggerPrice float64) (string, error) {
+	info, err := b.GetInstrumentInfo(symbol)
+	if err != nil {
+		return "", err
+	}
+	lastPrice, err := b.lastPrice(symbol)
+	if err != nil {
+		return "", err
+	}
+
+	orderType := "STOP_LOSS"
+	if (orderSide == exchange.OrderSideSell) == (triggerPrice > lastPrice) {
+		orderType = "TAKE_PROFIT"
+	}
+
+	params, err := orderParams(symbol, orderSide, orderType)
+	if err != nil {
+		return "", err
+	}
+	params.Set("quantity", info.FormatQty(baseQty))
+	params.Set("stopPrice", info.FormatPrice(triggerPrice))
+
+	return b.placeOrder(params)
+}
+
+// PlaceSpotOCOOrder places the take-profit and the stop-loss as an OCO order list, both legs share
+// the locked balance and Binance cancels one once the other fires
+func (b *binance) PlaceSpotOCOOrder(symbol string, orderSide exchange.OrderSide, baseQty float64, takeProfit float64, stopLoss float64) (string, string, error) {
+	info, err := b.GetInstrumentInfo(symbol)
+	if err != nil {
+		return "", "", err
+	}
+	side := GetBinanceOrderSide(orderSide)
+	if side == "" {
+		return "", "", fmt.Errorf("unsupported order side: %s", orderSide)
+	}
+
+	// a sell takes profit above the price and stops the loss below it, a buy the other way round
+	aboveType, abovePrice, belowType, belowPrice := "TAKE_PROFIT", takeProfit, "STOP_LOSS", stopLoss
+	if orderSide == exchange.OrderSideBuy {
+		aboveType, abovePrice, belowType, belowPrice = "STOP_LOSS", stopLoss, "TAKE_PROFIT", takeProfit
+	}
+
+	params := url.Values{}
+	params.Set("symbol", Symbol(symbol))
+	params.Set("side", side)
+	params.Set("quantity", info.FormatQty(baseQty))
+	params.Set("aboveType", aboveType)
+	params.Set("aboveStopPrice", info.FormatPrice(abovePrice))
+	params.Set("belowType", belowType)
+	params.Set("belowStopPrice", info.FormatPrice(belowPrice))
+	params.Set("newOrderRespType", "RESULT")
+
+	var list OrderList
+	if err := b.request("POST", "/api/v3/orderList/oco", params, true, &list); err != nil {
+		return "", "", err
+	}
+
+	var takeProfitID, stopLossID string
+	for _, ord := range list.OrderReports {
+		switch ord.Type {
+		case "TAKE_PROFIT":
+			takeProfitID = formatOrderID(ord.Symbol, ord.OrderID)
+		case "STOP_LOSS":
+			stopLossID = formatOrderID(ord.Symbol, ord.OrderID)
+		}
+	}
+	if takeProfitID == "" || stopLossID == "" {
+		return "", "", fmt.Errorf("order list %d misses its take-profit or stop-loss order", list.OrderListID)
+	}
+	return takeProfitID, stopLossID, nil
+}
+
+func (b *binance) PlaceSpotLimitOrder(symbol string, orderSide exchange.OrderSide, baseQty float64, price float64, timeInForce exchange.TimeInForce) (string, error) {
+	info, err := b.GetInstrumentInfo(symbol)
+	if err != nil {
+		return "", err
+	}
+
+	orderType := "LIMIT"
+	if timeInForce == exchange.TimeInForcePostOnly {
+		orderType = "LIMIT_MAKER"
+	}
+	params, err := orderParams(symbol, orderSide, orderType)
+	if err != nil {
+		return "", err
+	}
+	params.Set("quantity", info.FormatQty(baseQty))
+	params.Set("price", info.FormatPrice(info.LimitPrice(orderSide, price)))
+
+	switch timeInForce {
+	case exchange.TimeInForcePostOnly:
+	case "":
+		params.Set("timeInForce", string(exchange.TimeInForceGTC))
+	default:
+		params.Set("timeInForce", string(timeInForce))
+	}
+
+	return b.placeOrder(params)
+}
+
+func (b *binance) CancelOrder(symbol string, orderId string) error {
+	orderSymbol, id, err := parseOrderID(orderId)
+	if err != nil {
+		return err
+	}
+
+	params := url.Values{}
+	params.Set("symbol", orderSymbol)
+	params.Set("orderId", id)
+	if err := b.request("DELETE", "/api/v3/order", params, true, nil); err != nil {
+		return fmt.Errorf("failed to cancel order %s: %w", orderId, err)
+	}
+	return nil
+}
+
+// AmendOrder can only reduce the quantity, Binance cancels and replaces orders to move their price
+// which would change the order id
+func (b *binance) AmendOrder(symbol string, orderId string, baseQty *float64, price *float64) error {
+	if price != nil {
+		return fmt.Errorf("failed to amend order %s: binance does not amend the price of an order", orderId)
+	}
+	if baseQty == nil {
+		return nil
+	}
+
+	orderSymbol, id, err := parseOrderID(orderId)
+	if err != nil {
+		return err
+	}
+	info, err := b.GetInstrumentInfo(orderSymbol)
+	if err != nil {
+		return err
+	}
+
+	params := url.Values{}
+	params.Set("symbol", orderSymbol)
+	params.Set("orderId", id)
+	params.Set("newQty", info.FormatQty(*baseQty))
+	if err := b.request("PUT", "/api/v3/order/amend/keepPriority", params, true, nil); err != nil {
+		return fmt.Errorf("failed to amend order %s: %w", orderId, err)
+	}
+	return nil
+}
+
+func orderParams(symbol string, orderSide exchange.OrderSide, orderType string) (url.Values, error) {
+	side := GetBinanceOrderSide(orderSide)
+	if side == "" {
+		return nil, fmt.Errorf("unsupported order side: %s", orderSide)
+	}
+
+	params := url.Values{}
+	params.Set("symbol", Symbol(symbol))
+	params.Set("side", side)
+	params.Set("type", orderType)
+	params.Set("newOrderRespType", "RESULT")
+	return params, nil
+}
+
+func (b *binance) placeOrder(params url.Values) (string, error) {
+	var ord Order
+	if err := b.request("POST", "/api/v3/order", params, true, &ord); err != nil {
+		return "", err
+	}
+	if ord.OrderID == 0 {
+		return "", fmt.Errorf("orderId not found in response")
+	}
+	return formatOrderID(ord.Symbol, ord.OrderID), nil
+}
+
+func (b *binance) lastPrice(symbol string) (float64, error) {
+	params := url.Values{}
+	params.Set("symbol", Symbol(symbol))
+
+	var ticker struct {
+		Price string `json:"price"`
+	}
+	if err := b.request("GET", "/api/v3/ticker/price", params, false, &ticker); err != nil {
+		return 0, fmt.Errorf("failed to get last price of %s: %w", symbol, err)
+	}
+	price, err := strconv.ParseFloat(ticker.Price, 64)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse last price of %s: %w", symbol, err)
+	}
+	return price, nil
+}
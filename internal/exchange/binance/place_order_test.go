@@ -0,0 +1,122 @@
+package binance_test
+
+import (
+	"cb_grok/internal/exchange"
+	"testing"
+)
+
+func TestPlaceSpotMarketOrder(t *testing.T) {
+	tests := []struct {
+		name      string
+		side      exchange.OrderSide
+		qty       float64
+		wantSide  string
+		param     string
+		wantParam string
+	}{
+		// buys are sized in the quote asset
+		{name: "buy", side: exchange.OrderSideBuy, qty: 100, wantSide: "BUY", param: "quoteOrderQty", wantParam: "100.00000000"},
+		// sells are floored to the lot step
+		{name: "sell", side: exchange.OrderSideSell, qty: 0.001049, wantSide: "SELL", param: "quantity", wantParam: "0.00104"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ex, srv := newTestBinance(t)
+
+			id, err := ex.PlaceSpotMarketOrder("BTC/USDT", tt.side, tt.qty, nil, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if id != "BTCUSDT:28457512" {
+				t.Errorf("order id = %s, want BTCUSDT:28457512", id)
+			}
+
+			req := lastRequest(t, srv, "POST", "/api/v3/order")
+			if got := req.Query.Get("type"); got != "MARKET" {
+				t.Errorf("type = %s, want MARKET", got)
+			}
+			if got := req.Query.Get("side"); got != tt.wantSide {
+				t.Errorf("side = %s, want %s", got, tt.wantSide)
+			}
+			if got := req.Query.Get(tt.param); got != tt.wantParam {
+				t.Errorf("%s = %s, want %s", tt.param, got, tt.wantParam)
+			}
+		})
+	}
+}
+
+func TestPlaceSpotMarketOrderRejectsAttachedExits(t *testing.T) {
+	ex, _ := newTestBinance(t)
+
+	stopLoss := 90000.0
+	if _, err := ex.PlaceSpotMarketOrder("BTC/USDT", exchange.OrderSideBuy, 100, nil, &stopLoss); err == nil {
+		t.Fatal("market order placed with an attached stop-loss")
+	}
+}
+
+func TestPlaceSpotConditionalOrder(t *testing.T) {
+	// the recorded last price is 94380
+	tests := []struct {
+		name      string
+		side      exchange.OrderSide
+		trigger   float64
+		wantType  string
+		wantPrice string
+	}{
+		{name: "sell below", side: exchange.OrderSideSell, trigger: 92500.004, wantType: "STOP_LOSS", wantPrice: "92500.00"},
+		{name: "sell above", side: exchange.OrderSideSell, trigger: 97000, wantType: "TAKE_PROFIT", wantPrice: "97000.00"},
+		{name: "buy above", side: exchange.OrderSideBuy, trigger: 97000, wantType: "STOP_LOSS", wantPrice: "97000.00"},
+		{name: "buy below", side: exchange.OrderSideBuy, trigger: 92500, wantType: "TAKE_PROFIT", wantPrice: "92500.00"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ex, srv := newTestBinance(t)
+
+			if _, err := ex.PlaceSpotConditionalOrder("BTC/USDT", tt.side, 0.00104, tt.trigger); err != nil {
+				t.Fatal(err)
+			}
+
+			req := lastRequest(t, srv, "POST", "/api/v3/order")
+			if got := req.Query.Get("type"); got != tt.wantType {
+				t.Errorf("type = %s, want %s", got, tt.wantType)
+			}
+			if got := req.Query.Get("stopPrice"); got != tt.wantPrice {
+				t.Errorf("stopPrice = %s, want %s", got, tt.wantPrice)
+			}
+			if got := req.Query.Get("quantity"); got != "0.00104" {
+				t.Errorf("quantity = %s, want 0.00104", got)
+			}
+		})
+	}
+}
+
+func TestPlaceSpotOCOOrder(t *testing.T) {
+	ex, srv := newTestBinance(t)
+	placer, ok := ex.(exchange.OCOPlacer)
+	if !ok {
+		t.Fatal("binance does not place OCO orders")
+	}
+
+	takeProfitID, stopLossID, err := placer.PlaceSpotOCOOrder("BTC/USDT", exchange.OrderSideSell, 0.00104, 97000, 92500)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if takeProfitID != "BTCUSDT:28457591" || stopLossID != "BTCUSDT:28457590" {
+		t.Errorf("leg ids = %s, %s, want BTCUSDT:28457591, BTCUSDT:28457590", takeProfitID, stopLossID)
+	}
+
+	req := lastRequest(t, srv, "POST", "/api/v3/orderList/oco")
+	want := map[string]string{
+		"side":           "SELL",
+		"quantity":       "0.00104",
+		"aboveType":      "TAKE_PROFIT",
+		"aboveStopPrice": "97000.00",
+		"belowType":      "STOP_LOSS",
+		"belowStopPrice": "92500.00",
+	}
+	for key, value := range want {
+		if got := req.Query.Get(key); got != value {
+			t.Errorf("%s = %s, want %s", key, got, value)
+		}
+	}
+}
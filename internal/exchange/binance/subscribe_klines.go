@@ -0,0 +1,175 @@
+package binance
+
+import (
+	"cb_grok/internal/exchange"
+	"cb_grok/pkg/models"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/gorilla/websocket"
+	"go.uber.org/zap"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// the server pings every 20 seconds, the pong is sent by the default ping handler
+	wsReadTimeout   = time.Minute
+	klineBufferSize = 100
+)
+
+type klineStream struct {
+	b         *binance
+	symbol    string
+	timeframe exchange.Timeframe
+	url       string
+	out       chan exchange.Kline
+
+	// lastConfirmed is the start of the last closed candle sent to the channel
+	lastConfirmed int64
+}
+
+func (b *binance) SubscribeKlines(ctx context.Context, symbol string, timeframe exchange.Timeframe) (<-chan exchange.Kline, error) {
+	interval := GetBinanceTimeframe(timeframe)
+	if interval == "" {
+		return nil, fmt.Errorf("unsupported timeframe: %s", timeframe)
+	}
+	// the stream silently stays empty for unknown symbols
+	if _, err := b.GetInstrumentInfo(symbol); err != nil {
+		return nil, err
+	}
+
+	s := &klineStream{
+		b:         b,
+		symbol:    symbol,
+		timeframe: timeframe,
+		url:       fmt.Sprintf("%s/%s@kline_%s", b.wsURL, strings.ToLower(Symbol(symbol)), interval),
+		out:       make(chan exchange.Kline, klineBufferSize),
+	}
+	stream := &exchange.WSStream{
+		Name:        "binance: kline stream",
+		Logger:      b.logger.With(zap.String("url", s.url)),
+		Connect:     s.connect,
+		Handle:      s.handle,
+		Reconnected: s.reconnected,
+		ReadTimeout: wsReadTimeout,
+	}
+
+	conn, err := s.connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	go func() {
+		defer close(s.out)
+		stream.Run(ctx, conn)
+	}()
+
+	return s.out, nil
+}
+
+func (s *klineStream) connect(ctx context.Context) (*websocket.Conn, error) {
+	conn, _, err := websocket.DefaultDialer.DialContext(ctx, s.url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s: %w", s.url, err)
+	}
+	return conn, nil
+}
+
+// handle forwards a kline message to the channel
+func (s *klineStream) handle(ctx context.Context, message []byte) {
+	var msg WSKlineMessage
+	if err := json.Unmarshal(message, &msg); err != nil {
+		s.b.logger.Error("binance: cannot parse received message", zap.String("url", s.url), zap.Error(err))
+		return
+	}
+	if msg.EventType != "kline" {
+		return
+	}
+
+	k := msg.Kline
+	candle, err := parseWSKline(k.Start, k.Open, k.High, k.Low, k.Close, k.Volume)
+	if err != nil {
+		s.b.logger.Error("binance: cannot parse kline", zap.String("url", s.url), zap.Error(err))
+		return
+	}
+	s.emit(ctx, candle, k.Closed)
+}
+
+func (s *klineStream) reconnected(ctx context.Context) {
+	if err := s.backfill(ctx); err != nil {
+		s.b.logger.Error("binance: kline stream backfill", zap.String("url", s.url), zap.Error(err))
+	}
+}
+
+// backfill sends the candles closed since the last confirmed one, so consumers do not miss bars on reconnect
+func (s *klineStream) backfill(ctx context.Context) error {
+	if s.lastConfirmed == 0 {
+		return nil
+	}
+	tf, err := s.timeframe.Parse()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UnixMilli()
+	missed := 1
+	for open := tf.Next(s.lastConfirmed); open <= now; open = tf.Next(open) {
+		missed++
+	}
+	candles, err := s.b.FetchSpotOHLCV(s.symbol, s.timeframe, missed)
+	if err != nil {
+		return err
+	}
+
+	backfilled := 0
+	for _, candle := range candles {
+		// the candle in progress comes with the stream
+		if candle.Timestamp <= s.lastConfirmed || tf.Next(candle.Timestamp) > now {
+			continue
+		}
+		s.emit(ctx, candle, true)
+		backfilled++
+	}
+	s.b.logger.Info("binance: kline stream backfilled", zap.String("url", s.url), zap.Int("candles", backfilled))
+
+	return nil
+}
+
+func (s *klineStream) emit(ctx context.Context, candle models.OHLCV, confirm bool) {
+	if candle.Timestamp < s.lastConfirmed || (confirm && candle.Timestamp == s.lastConfirmed) {
+		return
+	}
+	if confirm {
+		s.lastConfirmed = candle.Timestamp
+	}
+	select {
+	case s.out <- exchange.Kline{
+		Symbol:    s.symbol,
+		Timeframe: s.timeframe,
+		OHLCV:     candle,
+		Confirm:   confirm,
+	}:
+	case <-ctx.Done():
+	}
+}
+
+func parseWSKline(start int64, open, high, low, close, volume string) (models.OHLCV, error) {
+	values := make([]float64, 0, 5)
+	for _, raw := range []string{open, high, low, close, volume} {
+		v, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return models.OHLCV{}, errors.New("failed to parse decimal in kline: " + err.Error())
+		}
+		values = append(values, v)
+	}
+	return models.OHLCV{
+		Timestamp: start,
+		Open:      values[0],
+		High:      values[1],
+		Low:       values[2],
+		Close:     values[3],
+		Volume:    values[4],
+	}, nil
+}
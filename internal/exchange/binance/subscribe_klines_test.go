@@ -0,0 +1,91 @@
+package binance_test
+
+import (
+	"cb_grok/internal/exchange"
+	"cb_grok/internal/exchange/binance"
+	"cb_grok/pkg/models"
+	"context"
+	"github.com/gorilla/websocket"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// recordedKlineMessages are stream messages of the forming and the closed 1h candle, with a reply to a request in between
+var recordedKlineMessages = []string{
+	`{"e":"kline","E":1735693260000,"s":"BTCUSDT","k":{"t":1735693200000,"T":1735696799999,"s":"BTCUSDT","i":"1h","f":4410300,"L":4410373,"o":"94217.22000000","c":"94380.00000000","h":"94412.36000000","l":"94150.00000000","v":"121.02275000","n":25033,"x":false,"q":"11411002.38821390","V":"66.58220000","Q":"6278212.87455900","B":"0"}}`,
+	`{"result":null,"id":1}`,
+	`{"e":"kline","E":1735696800001,"s":"BTCUSDT","k":{"t":1735693200000,"T":1735696799999,"s":"BTCUSDT","i":"1h","f":4410300,"L":4412019,"o":"94217.22000000","c":"94401.10000000","h":"94452.00000000","l":"94150.00000000","v":"487.30121000","n":99871,"x":true,"q":"45987142.10023310","V":"250.11021000","Q":"23602184.00132200","B":"0"}}`,
+}
+
+func TestSubscribeKlines(t *testing.T) {
+	upgrader := websocket.Upgrader{}
+	paths := make(chan string, 1)
+	wsSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths <- r.URL.Path
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for _, msg := range recordedKlineMessages {
+			if err := conn.WriteMessage(websocket.TextMessage, []byte(msg)); err != nil {
+				return
+			}
+		}
+		// keeps the connection open until the client closes it
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}))
+	t.Cleanup(wsSrv.Close)
+
+	ex, _ := newTestBinance(t, binance.WithWebSocketURL("ws"+strings.TrimPrefix(wsSrv.URL, "http")+"/ws"))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	klines, err := ex.SubscribeKlines(ctx, "BTC/USDT", exchange.Timeframe("1h"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if path := <-paths; path != "/ws/btcusdt@kline_1h" {
+		t.Errorf("stream path = %s, want /ws/btcusdt@kline_1h", path)
+	}
+
+	want := []exchange.Kline{
+		{Symbol: "BTC/USDT", Timeframe: "1h", OHLCV: models.OHLCV{Timestamp: 1735693200000, Open: 94217.22, High: 94412.36, Low: 94150, Close: 94380, Volume: 121.02275}},
+		{Symbol: "BTC/USDT", Timeframe: "1h", OHLCV: models.OHLCV{Timestamp: 1735693200000, Open: 94217.22, High: 94452, Low: 94150, Close: 94401.1, Volume: 487.30121}, Confirm: true},
+	}
+	for i := range want {
+		select {
+		case got := <-klines:
+			if got != want[i] {
+				t.Errorf("kline %d = %+v, want %+v", i, got, want[i])
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("no kline %d", i)
+		}
+	}
+
+	cancel()
+	select {
+	case _, ok := <-klines:
+		if ok {
+			t.Error("kline received after cancel")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("stream not closed by its context")
+	}
+}
+
+func TestSubscribeKlinesRejectsUnknownSymbol(t *testing.T) {
+	ex, _ := newTestBinance(t)
+
+	if _, err := ex.SubscribeKlines(context.Background(), "ETH/USDT", exchange.Timeframe("1h")); err == nil {
+		t.Fatal("stream of a symbol without instrument info subscribed")
+	}
+}
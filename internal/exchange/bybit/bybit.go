@@ -6,14 +6,63 @@ import (
 	bybitapi "github.com/bybit-exchange/bybit.go.api"
 	//bybitapi "cb_grok/pkg/bybit_connector"
 	"go.uber.org/zap"
+	"sync"
+)
+
+const (
+	publicSpotWSLive    = "wss://stream.bybit.com/v5/public/spot"
+	publicSpotWSTestnet = "wss://stream-testnet.bybit.com/v5/public/spot"
+
+	privateWSLive    = "wss://stream.bybit.com/v5/private"
+	privateWSTestnet = "wss://stream-testnet.bybit.com/v5/private"
+	privateWSDemo    = "wss://stream-demo.bybit.com/v5/private"
 )
 
 type bybit struct {
 	client *bybitapi.Client
 	logger *zap.Logger
+
+	// baseURL overrides the REST endpoint of the trading mode
+	baseURL string
+	// publicWSURL is the public spot stream, demo trading uses the live market data
+	publicWSURL string
+	// privateWSURL is the order, execution and wallet stream of the account
+	privateWSURL string
+
+	instrumentsMu sync.Mutex
+	instruments   map[string]cachedInstrument
 }
 
-func NewBybit(apiKey, apiSecret string, tradingMode exchange.TradingMode) (exchange.Exchange, error) {
+type Option func(b *bybit)
+
+// WithBaseURL overrides the REST endpoint, f.e. with the local emulator
+func WithBaseURL(url string) Option {
+	return func(b *bybit) {
+		b.baseURL = url
+	}
+}
+
+// WithPublicWSURL overrides the public spot stream endpoint
+func WithPublicWSURL(url string) Option {
+	return func(b *bybit) {
+		b.publicWSURL = url
+	}
+}
+
+// WithPrivateWSURL overrides the private account stream endpoint
+func WithPrivateWSURL(url string) Option {
+	return func(b *bybit) {
+		b.privateWSURL = url
+	}
+}
+
+func NewBybit(apiKey, apiSecret string, tradingMode exchange.TradingMode, opts ...Option) (exchange.Exchange, error) {
+	b := &bybit{
+		logger:       zap.L(),
+		publicWSURL:  publicSpotWSLive,
+		privateWSURL: privateWSLive,
+		instruments:  make(map[string]cachedInstrument),
+	}
 	var clientOptions []bybitapi.ClientOption
 
 	switch tradingMode {
@@ -21,21 +70,35 @@ func NewBybit(apiKey, apiSecret string, tradingMode exchange.TradingMode) (excha
 		if apiKey == "" || apiSecret == "" {
 			return nil, fmt.Errorf("API key and secret are required for demo mode")
 		}
-		clientOptions = append(clientOptions, bybitapi.WithBaseURL(bybitapi.DEMO_ENV), bybitapi.WithDebug(true))
+		b.baseURL = bybitapi.DEMO_ENV
+		b.privateWSURL = privateWSDemo
+		clientOptions = append(clientOptions, bybitapi.WithDebug(true))
 	case exchange.TradingModeTestnet:
-		clientOptions = append(clientOptions, bybitapi.WithBaseURL(bybitapi.TESTNET), bybitapi.WithDebug(true))
+		b.baseURL = bybitapi.TESTNET
+		b.publicWSURL = publicSpotWSTestnet
+		b.privateWSURL = privateWSTestnet
+		clientOptions = append(clientOptions, bybitapi.WithDebug(true))
 	case exchange.TradingModeLive:
 	default:
 		return nil, fmt.Errorf("unsupported trading mode: %s", tradingMode)
 	}
 
-	client := bybitapi.NewBybitHttpClient(apiKey, apiSecret, clientOptions...)
+	for _, opt := range opts {
+		opt(b)
+	}
+	if b.baseURL != "" {
+		clientOptions = append(clientOptions, bybitapi.WithBaseURL(b.baseURL))
+	}
+
+	b.client = bybitapi.NewBybitHttpClient(apiKey, apiSecret, clientOptions...)
 
-	return &bybit{
-		client: client,
-	}, nil
+	return b, nil
 }
 
 func (b *bybit) Name() string {
 	return "bybit"
 }
+
+func (b *bybit) Account() string {
+	return b.Name() + ":" + b.client.APIKey
+}
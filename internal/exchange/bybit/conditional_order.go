@@ -0,0 +1,61 @@
+package bybit
+
+import (
+	"cb_grok/internal/exchange"
+	"context"
+	"fmt"
+)
+
+// spotTPSLFilter marks spot take-profit/stop-loss orders. They do not lock the balance until triggered,
+// so both legs of a position can be placed for the full quantity.
+const spotTPSLFilter = "tpslOrder"
+
+func (b *bybit) PlaceSpotConditionalOrder(symbol string, orderSide exchange.OrderSide, baseQty float64, triggerPrice float64) (string, error) {
+	orderSideValue := GetBybitOrderSide(orderSide)
+	if orderSideValue == "" {
+		return "", fmt.Errorf("unsupported order side: %s", orderSide)
+	}
+
+	info, err := b.GetInstrumentInfo(symbol)
+	if err != nil {
+		return "", err
+	}
+
+	req := b.client.NewPlaceOrderService("spot", symbol, orderSideValue, "Market", info.FormatQty(baseQty)).
+		OrderFilter(spotTPSLFilter).
+		TriggerPrice(info.FormatPrice(triggerPrice))
+
+	orderResult, err := req.Do(context.Background())
+	if err != nil {
+		return "", err
+	}
+
+	result, err := ParseResponse(orderResult)
+	if err != nil {
+		return "", err
+	}
+
+	orderID, ok := result["orderId"].(string)
+	if !ok {
+		return "", fmt.Errorf("orderId not found in response")
+	}
+
+	return orderID, nil
+}
+
+func (b *bybit) CancelOrder(symbol string, orderId string) error {
+	params := map[string]interface{}{
+		"category": "spot",
+		"symbol":   symbol,
+		"orderId":  orderId,
+	}
+	response, err := b.client.NewUtaBybitServiceWithParams(params).CancelOrder(context.Background())
+	if err != nil {
+		return fmt.Errorf("failed to cancel order %s: %w", orderId, err)
+	}
+	_, err = ParseResponse(response)
+	if err != nil {
+		return fmt.Errorf("failed to cancel order %s: %w", orderId, err)
+	}
+	return nil
+}
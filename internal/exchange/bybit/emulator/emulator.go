@@ -0,0 +1,340 @@
+// Package emulator serves the subset of the Bybit v5 REST and websocket API the bybit adapter uses.
+// It is backed by an in-memory matching engine and a generated candle feed, so the adapter and
+// demo traders run end-to-end without network access. Point the adapter at it with
+// bybit.WithBaseURL, bybit.WithPublicWSURL and bybit.WithPrivateWSURL, or start cmd/bybit_emulator.
+package emulator
+
+import (
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SymbolConfig describes a spot market and the trading rules reported by instruments-info
+type SymbolConfig struct {
+	Symbol    string
+	BaseCoin  string
+	QuoteCoin string
+	// Price is the close of the last generated history candle
+	Price float64
+	// Volatility is the standard deviation of the log return per minute
+	Volatility float64
+	// Spread is the relative distance between bid and ask, market orders fill at the ask or the bid
+	Spread float64
+	// Depth is the base quantity a resting limit order fills per match, the rest keeps resting.
+	// Zero fills resting orders whole.
+	Depth float64
+
+	BasePrecision  float64
+	QuotePrecision float64
+	TickSize       float64
+	MinOrderQty    float64
+	MaxOrderQty    float64
+	MinOrderAmt    float64
+	MaxOrderAmt    float64
+}
+
+type Config struct {
+	Symbols []SymbolConfig
+	// Balances are the initial wallet balances by coin
+	Balances map[string]float64
+
+	// APIKey and APISecret are checked on signed requests. Any key is accepted when APIKey is empty.
+	APIKey    string
+	APISecret string
+
+	TakerFee float64
+	MakerFee float64
+
+	// StartTime is the emulated time of the first tick, default now
+	StartTime time.Time
+	// HistoryCandles is the number of 1m candles generated before StartTime
+	HistoryCandles int
+	// TickInterval is the wall time between ticks of the feed started by Start
+	TickInterval time.Duration
+	// TickStep is the emulated time a tick advances, a step larger than TickInterval speeds the market up
+	TickStep time.Duration
+	Seed     int64
+}
+
+// DefaultSymbol returns a market with the trading rules of Bybit spot BTCUSDT
+func DefaultSymbol(symbol string, price float64) SymbolConfig {
+	base, quote := splitSymbol(symbol)
+	return SymbolConfig{
+		Symbol:         symbol,
+		BaseCoin:       base,
+		QuoteCoin:      quote,
+		Price:          price,
+		Volatility:     0.001,
+		Spread:         0.0002,
+		BasePrecision:  0.000001,
+		QuotePrecision: 0.00000001,
+		TickSize:       0.01,
+		MinOrderQty:    0.000048,
+		MaxOrderQty:    71.73956243,
+		MinOrderAmt:    1,
+		MaxOrderAmt:    2000000,
+	}
+}
+
+func DefaultConfig() Config {
+	return Config{
+		Symbols:        []SymbolConfig{DefaultSymbol("BTCUSDT", 60000), DefaultSymbol("ETHUSDT", 3000)},
+		Balances:       map[string]float64{"USDT": 10000},
+		TakerFee:       0.001,
+		MakerFee:       0.001,
+		HistoryCandles: 5000,
+		TickInterval:   time.Second,
+		TickStep:       time.Second,
+		Seed:           1,
+	}
+}
+
+// splitSymbol splits a symbol by the known quote coins, f.e. BTCUSDT into BTC and USDT
+func splitSymbol(symbol string) (string, string) {
+	for _, quote := range []string{"USDT", "USDC", "BTC", "ETH", "EUR"} {
+		if strings.HasSuffix(symbol, quote) && len(symbol) > len(quote) {
+			return strings.TrimSuffix(symbol, quote), quote
+		}
+	}
+	return symbol, ""
+}
+
+type Emulator struct {
+	cfg Config
+
+	mu  sync.Mutex
+	rnd *rand.Rand
+	// now is the emulated time in milliseconds
+	now int64
+
+	markets  map[string]*market
+	balances map[string]*coinBalance
+
+	orders     map[string]*order
+	orderList  []*order
+	executions []execution
+	lastID     int64
+
+	clients       map[*wsClient]struct{}
+	lastMessageID int64
+	// pushed is the start of the candle last pushed on every kline topic
+	pushed map[string]int64
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// New generates the candle history of every market. The feed does not move until Start or Step is called.
+func New(cfg Config) (*Emulator, error) {
+	if len(cfg.Symbols) == 0 {
+		return nil, fmt.Errorf("emulator: no symbols configured")
+	}
+	if cfg.StartTime.IsZero() {
+		cfg.StartTime = time.Now()
+	}
+	if cfg.TickStep <= 0 {
+		cfg.TickStep = cfg.TickInterval
+	}
+
+	e := &Emulator{
+		cfg:      cfg,
+		rnd:      rand.New(rand.NewSource(cfg.Seed)),
+		now:      cfg.StartTime.UnixMilli(),
+		markets:  make(map[string]*market, len(cfg.Symbols)),
+		balances: make(map[string]*coinBalance),
+		orders:   make(map[string]*order),
+		clients:  make(map[*wsClient]struct{}),
+		pushed:   make(map[string]int64),
+	}
+
+	for _, sc := range cfg.Symbols {
+		if sc.Symbol == "" || sc.Price <= 0 {
+			return nil, fmt.Errorf("emulator: symbol %q requires a positive price", sc.Symbol)
+		}
+		if sc.BaseCoin == "" || sc.QuoteCoin == "" {
+			sc.BaseCoin, sc.QuoteCoin = splitSymbol(sc.Symbol)
+			if sc.QuoteCoin == "" {
+				return nil, fmt.Errorf("emulator: cannot derive the coins of %s", sc.Symbol)
+			}
+		}
+		e.markets[sc.Symbol] = newMarket(sc, e.now, cfg.HistoryCandles, e.rnd)
+	}
+	for coin, amount := range cfg.Balances {
+		e.balances[coin] = &coinBalance{wallet: amount}
+	}
+
+	return e, nil
+}
+
+// Handler serves the REST endpoints and the public and private streams
+func (e *Emulator) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v5/market/kline", e.handleKline)
+	mux.HandleFunc("/v5/market/instruments-info", e.handleInstrumentsInfo)
+	mux.HandleFunc("/v5/order/create", e.signed(e.handleCreateOrder))
+	mux.HandleFunc("/v5/order/amend", e.signed(e.handleAmendOrder))
+	mux.HandleFunc("/v5/order/cancel", e.signed(e.handleCancelOrder))
+	mux.HandleFunc("/v5/order/realtime", e.signed(e.handleOpenOrders))
+	mux.HandleFunc("/v5/order/history", e.signed(e.handleOrderHistory))
+	mux.HandleFunc("/v5/execution/list", e.signed(e.handleExecutionList))
+	mux.HandleFunc("/v5/account/wallet-balance", e.signed(e.handleWalletBalance))
+	mux.HandleFunc("/v5/public/spot", e.handlePublicWS)
+	mux.HandleFunc("/v5/private", e.handlePrivateWS)
+	return mux
+}
+
+// Start moves the market every TickInterval until Close
+func (e *Emulator) Start() {
+	if e.cfg.TickInterval <= 0 || e.stop != nil {
+		return
+	}
+	e.stop = make(chan struct{})
+	e.done = make(chan struct{})
+
+	go func() {
+		defer close(e.done)
+		ticker := time.NewTicker(e.cfg.TickInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-e.stop:
+				return
+			case <-ticker.C:
+				e.Step(e.cfg.TickStep)
+			}
+		}
+	}()
+}
+
+// Close stops the feed and disconnects the streams
+func (e *Emulator) Close() {
+	if e.stop != nil {
+		close(e.stop)
+		<-e.done
+		e.stop = nil
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for c := range e.clients {
+		c.close()
+	}
+}
+
+// Step advances the emulated time, moves the prices, matches resting orders and pushes stream updates
+func (e *Emulator) Step(d time.Duration) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	remaining := d.Milliseconds()
+	for remaining > 0 {
+		// candles are closed on minute boundaries
+		step := min(remaining, minuteMs-e.now%minuteMs)
+		e.now += step
+		remaining -= step
+
+		for _, m := range e.markets {
+			m.move(e.now, step, e.rnd)
+			e.matchOrders(m)
+		}
+		e.pushKlines()
+	}
+}
+
+// SetPrice moves the market of the symbol to the price at the current time, f.e. to trigger
+// conditional orders in tests
+func (e *Emulator) SetPrice(symbol string, price float64) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	m, ok := e.markets[symbol]
+	if !ok {
+		return fmt.Errorf("emulator: unknown symbol %s", symbol)
+	}
+	m.trade(e.now, price, 0)
+	e.matchOrders(m)
+	e.pushKlines()
+	return nil
+}
+
+// Price returns the last price of the symbol
+func (e *Emulator) Price(symbol string) float64 {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if m, ok := e.markets[symbol]; ok {
+		return m.price
+	}
+	return 0
+}
+
+// Balance returns the wallet balance of the coin, including the locked amount
+func (e *Emulator) Balance(coin string) float64 {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if b, ok := e.balances[coin]; ok {
+		return b.wallet
+	}
+	return 0
+}
+
+// Now returns the emulated time
+func (e *Emulator) Now() time.Time {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return time.UnixMilli(e.now)
+}
+
+// Subscribed reports whether a stream client is subscribed to the topic, f.e. for tests to wait for
+// the account stream before placing orders
+func (e *Emulator) Subscribed(topic string) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	for c := range e.clients {
+		if c.topics[topic] && !c.closed {
+			return true
+		}
+	}
+	return false
+}
+
+// Server is an emulator listening on a local port
+type Server struct {
+	*Emulator
+	http *httptest.Server
+
+	// URL is the REST endpoint
+	URL string
+}
+
+// NewServer starts an emulator on a local port and its feed when TickInterval is set
+func NewServer(cfg Config) (*Server, error) {
+	e, err := New(cfg)
+	if err != nil {
+		return nil, err
+	}
+	s := &Server{Emulator: e, http: httptest.NewServer(e.Handler())}
+	s.URL = s.http.URL
+	e.Start()
+	return s, nil
+}
+
+func (s *Server) PublicWSURL() string {
+	return "ws" + strings.TrimPrefix(s.URL, "http") + "/v5/public/spot"
+}
+
+func (s *Server) PrivateWSURL() string {
+	return "ws" + strings.TrimPrefix(s.URL, "http") + "/v5/private"
+}
+
+func (s *Server) Close() {
+	s.Emulator.Close()
+	s.http.Close()
+}
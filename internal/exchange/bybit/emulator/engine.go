@@ -0,0 +1,638 @@
+package emulator
+
+import (
+	"math"
+	"strconv"
+	"strings"
+)
+
+const (
+	statusNew             = "New"
+	statusPartiallyFilled = "PartiallyFilled"
+	statusFilled          = "Filled"
+	statusCancelled       = "Cancelled"
+	statusRejected        = "Rejected"
+	statusUntriggered     = "Untriggered"
+	statusTriggered       = "Triggered"
+	statusDeactivated     = "Deactivated"
+	// statusPartiallyFilledCanceled is a canceled spot order with some quantity executed
+	statusPartiallyFilledCanceled = "PartiallyFilledCanceled"
+
+	filterOrder = "Order"
+	filterTPSL  = "tpslOrder"
+
+	// firstOrderID keeps order ids in the range of the exchange ones
+	firstOrderID = 1800000000000000000
+)
+
+// apiError is answered with its retCode, like the exchange
+type apiError struct {
+	code int
+	msg  string
+}
+
+var (
+	errInvalidKey        = &apiError{10003, "API key is invalid."}
+	errInvalidSign       = &apiError{10004, "error sign! please check your signature generation algorithm."}
+	errTimestamp         = &apiError{10002, "invalid request, please check your server timestamp or recv_window param"}
+	errInsufficient      = &apiError{170131, "Insufficient balance."}
+	errOrderNotExists    = &apiError{170213, "Order does not exist."}
+	errQtyDecimals       = &apiError{170137, "Order quantity has too many decimals."}
+	errPriceDecimals     = &apiError{170134, "Order price has too many decimals."}
+	errQtyTooLow         = &apiError{170136, "Order quantity is lower than the minimum."}
+	errQtyTooHigh        = &apiError{170135, "Order quantity exceeded upper limit."}
+	errValueTooLow       = &apiError{170140, "Order value exceeded lower limit."}
+	errValueTooHigh      = &apiError{170139, "Order value exceeded upper limit."}
+	errSymbolInvalid     = &apiError{170121, "Invalid symbol."}
+	errNotModifiable     = &apiError{170192, "Order status does not allow amendment."}
+	errAccountTypeDenied = &apiError{10001, "accountType only support UNIFIED."}
+)
+
+func paramsError(field string) *apiError {
+	return &apiError{10001, "params error: " + field + " invalid"}
+}
+
+type coinBalance struct {
+	wallet float64
+	locked float64
+}
+
+func (b *coinBalance) available() float64 {
+	return b.wallet - b.locked
+}
+
+type order struct {
+	id          string
+	linkID      string
+	symbol      string
+	side        string
+	orderType   string
+	orderFilter string
+	timeInForce string
+	status      string
+
+	rejectReason string
+	// qty is in the quote coin for market buys sized by quote
+	qty          float64
+	quoteQty     bool
+	price        float64
+	triggerPrice float64
+	// triggerAbove fires the conditional order when the price rises to the trigger price
+	triggerAbove bool
+	takeProfit   float64
+	stopLoss     float64
+	// group links the take-profit and stop-loss legs attached to an order, one fired leg deactivates the others
+	group string
+
+	cumExecQty   float64
+	cumExecValue float64
+	cumExecFee   float64
+	feeCurrency  string
+
+	// locked is the balance held by a resting order, in the quote coin for buys and the base coin for sells
+	locked float64
+
+	createdTime int64
+	updatedTime int64
+}
+
+func (o *order) open() bool {
+	return o.status == statusNew || o.status == statusPartiallyFilled || o.status == statusUntriggered
+}
+
+type execution struct {
+	id          string
+	orderID     string
+	linkID      string
+	symbol      string
+	side        string
+	orderType   string
+	orderPrice  float64
+	orderQty    float64
+	price       float64
+	qty         float64
+	value       float64
+	fee         float64
+	feeRate     float64
+	feeCurrency string
+	maker       bool
+	time        int64
+}
+
+type placeRequest struct {
+	symbol       string
+	side         string
+	orderType    string
+	qty          string
+	price        string
+	triggerPrice string
+	timeInForce  string
+	orderFilter  string
+	orderLinkID  string
+	marketUnit   string
+	takeProfit   string
+	stopLoss     string
+}
+
+func (e *Emulator) balance(coin string) *coinBalance {
+	b, ok := e.balances[coin]
+	if !ok {
+		b = &coinBalance{}
+		e.balances[coin] = b
+	}
+	return b
+}
+
+func (e *Emulator) nextID() string {
+	e.lastID++
+	return strconv.FormatInt(firstOrderID+e.lastID, 10)
+}
+
+// placeOrder validates the order like the exchange, fills marketable orders and rests the others
+func (e *Emulator) placeOrder(req placeRequest) (*order, *apiError) {
+	m, ok := e.markets[req.symbol]
+	if !ok {
+		return nil, errSymbolInvalid
+	}
+	if req.side != "Buy" && req.side != "Sell" {
+		return nil, paramsError("side")
+	}
+	if req.orderType != "Market" && req.orderType != "Limit" {
+		return nil, paramsError("orderType")
+	}
+	if req.orderFilter == "" {
+		req.orderFilter = filterOrder
+	}
+	if req.orderFilter != filterOrder && req.orderFilter != filterTPSL {
+		return nil, paramsError("orderFilter")
+	}
+
+	o := &order{
+		id:          e.nextID(),
+		linkID:      req.orderLinkID,
+		symbol:      req.symbol,
+		side:        req.side,
+		orderType:   req.orderType,
+		orderFilter: req.orderFilter,
+		timeInForce: req.timeInForce,
+		status:      statusNew,
+		createdTime: e.now,
+		updatedTime: e.now,
+	}
+	if o.timeInForce == "" {
+		o.timeInForce = "GTC"
+		if o.orderType == "Market" {
+			o.timeInForce = "IOC"
+		}
+	}
+	// spot market buys are sized in the quote coin unless asked otherwise
+	o.quoteQty = o.orderType == "Market" && o.side == "Buy" && req.marketUnit != "baseCoin"
+
+	var err *apiError
+	qtyStep := m.cfg.BasePrecision
+	if o.quoteQty {
+		qtyStep = m.cfg.QuotePrecision
+	}
+	if o.qty, err = parseAmount(req.qty, qtyStep, "qty", errQtyDecimals); err != nil {
+		return nil, err
+	}
+	if o.orderType == "Limit" {
+		if o.price, err = parseAmount(req.price, m.cfg.TickSize, "price", errPriceDecimals); err != nil {
+			return nil, err
+		}
+	}
+	if o.orderFilter == filterTPSL {
+		if o.triggerPrice, err = parseAmount(req.triggerPrice, m.cfg.TickSize, "triggerPrice", errPriceDecimals); err != nil {
+			return nil, err
+		}
+		o.triggerAbove = o.triggerPrice > m.price
+		o.status = statusUntriggered
+	}
+	for _, tpsl := range []struct {
+		value string
+		dst   *float64
+		field string
+	}{{req.takeProfit, &o.takeProfit, "takeProfit"}, {req.stopLoss, &o.stopLoss, "stopLoss"}} {
+		if tpsl.value == "" {
+			continue
+		}
+		if *tpsl.dst, err = parseAmount(tpsl.value, m.cfg.TickSize, tpsl.field, errPriceDecimals); err != nil {
+			return nil, err
+		}
+	}
+
+	if err = e.checkLimits(m, o); err != nil {
+		return nil, err
+	}
+
+	if o.orderFilter != filterTPSL {
+		if err = e.lock(m, o); err != nil {
+			return nil, err
+		}
+	}
+
+	e.orders[o.id] = o
+	e.orderList = append(e.orderList, o)
+
+	switch {
+	case o.orderFilter == filterTPSL:
+		e.pushOrder(o)
+	case o.orderType == "Market":
+		e.fillMarket(m, o)
+	default:
+		e.placeLimit(m, o)
+	}
+
+	return o, nil
+}
+
+// checkLimits checks the lot size and the notional of an order
+func (e *Emulator) checkLimits(m *market, o *order) *apiError {
+	price := o.price
+	if o.orderFilter == filterTPSL {
+		price = o.triggerPrice
+	} else if o.orderType == "Market" {
+		price = m.price
+	}
+
+	baseQty, value := o.qty, o.qty*price
+	if o.quoteQty {
+		baseQty, value = o.qty/price, o.qty
+	}
+
+	if !o.quoteQty {
+		if m.cfg.MinOrderQty > 0 && baseQty < m.cfg.MinOrderQty-epsilon {
+			return errQtyTooLow
+		}
+		if m.cfg.MaxOrderQty > 0 && baseQty > m.cfg.MaxOrderQty+epsilon {
+			return errQtyTooHigh
+		}
+	}
+	if m.cfg.MinOrderAmt > 0 && value < m.cfg.MinOrderAmt-epsilon {
+		return errValueTooLow
+	}
+	if m.cfg.MaxOrderAmt > 0 && value > m.cfg.MaxOrderAmt+epsilon {
+		return errValueTooHigh
+	}
+	return nil
+}
+
+// lock holds the balance an order may spend
+func (e *Emulator) lock(m *market, o *order) *apiError {
+	var (
+		coin   string
+		amount float64
+	)
+	switch {
+	case o.side == "Sell":
+		coin, amount = m.cfg.BaseCoin, o.qty
+	case o.quoteQty:
+		coin, amount = m.cfg.QuoteCoin, o.qty
+	case o.orderType == "Market":
+		coin, amount = m.cfg.QuoteCoin, o.qty*m.ask()
+	default:
+		coin, amount = m.cfg.QuoteCoin, o.qty*o.price
+	}
+
+	b := e.balance(coin)
+	if b.available() < amount-epsilon {
+		return errInsufficient
+	}
+	b.locked += amount
+	o.locked = amount
+	return nil
+}
+
+// release frees the balance locked for qty of a partially filled limit order
+func (e *Emulator) release(m *market, o *order, qty float64) {
+	coin, amount := m.cfg.BaseCoin, qty
+	if o.side == "Buy" {
+		coin, amount = m.cfg.QuoteCoin, qty*o.price
+	}
+	amount = math.Min(amount, o.locked)
+	b := e.balance(coin)
+	b.locked = math.Max(b.locked-amount, 0)
+	o.locked -= amount
+}
+
+func (e *Emulator) unlock(m *market, o *order) {
+	if o.locked == 0 {
+		return
+	}
+	coin := m.cfg.QuoteCoin
+	if o.side == "Sell" {
+		coin = m.cfg.BaseCoin
+	}
+	b := e.balance(coin)
+	b.locked = math.Max(b.locked-o.locked, 0)
+	o.locked = 0
+}
+
+func (e *Emulator) fillMarket(m *market, o *order) {
+	if o.side == "Buy" {
+		price := m.ask()
+		qty := o.qty
+		if o.quoteQty {
+			// the executed quantity is a whole number of base steps, the rest of the quote amount is not spent
+			qty = floorToStep(o.qty/price, m.cfg.BasePrecision)
+		}
+		e.fill(m, o, price, qty, false)
+		return
+	}
+	e.fill(m, o, m.bid(), o.qty, false)
+}
+
+// placeLimit fills a marketable limit order as taker, cancels post-only orders that would take
+// liquidity and immediate orders that cannot fill, and rests the others
+func (e *Emulator) placeLimit(m *market, o *order) {
+	marketable := (o.side == "Buy" && o.price >= m.ask()) || (o.side == "Sell" && o.price <= m.bid())
+
+	switch {
+	case marketable && o.timeInForce == "PostOnly":
+		e.cancel(m, o, statusCancelled, "EC_PostOnlyWillTakeLiquidity")
+	case marketable:
+		price := m.ask()
+		if o.side == "Sell" {
+			price = m.bid()
+		}
+		e.fill(m, o, price, o.qty, false)
+	case o.timeInForce == "IOC" || o.timeInForce == "FOK":
+		e.cancel(m, o, statusCancelled, "EC_NoImmediateQtyToFill")
+	default:
+		e.pushOrder(o)
+	}
+}
+
+// fill executes qty of the order, market buys sized by quote are executed whole.
+// Buys pay the fee in the base coin, sells in the quote coin.
+func (e *Emulator) fill(m *market, o *order, price float64, qty float64, maker bool) {
+	filled := o.quoteQty || o.cumExecQty+qty >= o.qty-epsilon
+	if filled {
+		e.unlock(m, o)
+	} else {
+		e.release(m, o, qty)
+	}
+
+	rate := e.cfg.TakerFee
+	if maker {
+		rate = e.cfg.MakerFee
+	}
+
+	value := qty * price
+
+	base, quote := e.balance(m.cfg.BaseCoin), e.balance(m.cfg.QuoteCoin)
+	var fee float64
+	if o.side == "Buy" {
+		fee = qty * rate
+		o.feeCurrency = m.cfg.BaseCoin
+		quote.wallet -= value
+		base.wallet += qty - fee
+	} else {
+		fee = value * rate
+		o.feeCurrency = m.cfg.QuoteCoin
+		base.wallet -= qty
+		quote.wallet += value - fee
+	}
+
+	o.cumExecQty += qty
+	o.cumExecValue += value
+	o.cumExecFee += fee
+	o.status = statusFilled
+	if !filled {
+		o.status = statusPartiallyFilled
+	}
+	o.updatedTime = e.now
+
+	exec := execution{
+		id:          e.nextID(),
+		orderID:     o.id,
+		linkID:      o.linkID,
+		symbol:      o.symbol,
+		side:        o.side,
+		orderType:   o.orderType,
+		orderPrice:  o.price,
+		orderQty:    o.qty,
+		price:       price,
+		qty:         qty,
+		value:       value,
+		fee:         fee,
+		feeRate:     rate,
+		feeCurrency: o.feeCurrency,
+		maker:       maker,
+		time:        e.now,
+	}
+	e.executions = append(e.executions, exec)
+
+	e.pushOrder(o)
+	e.pushExecution(exec)
+	e.pushWallet(m.cfg.BaseCoin, m.cfg.QuoteCoin)
+
+	if filled {
+		e.attachExits(m, o, o.cumExecQty-o.cumExecFee)
+	}
+}
+
+// attachExits places the take-profit and stop-loss legs of a filled buy for the received quantity
+func (e *Emulator) attachExits(m *market, o *order, qty float64) {
+	if o.side != "Buy" || (o.takeProfit == 0 && o.stopLoss == 0) {
+		return
+	}
+	qty = floorToStep(qty, m.cfg.BasePrecision)
+	for _, trigger := range []float64{o.takeProfit, o.stopLoss} {
+		if trigger == 0 {
+			continue
+		}
+		leg := &order{
+			id:           e.nextID(),
+			symbol:       o.symbol,
+			side:         "Sell",
+			orderType:    "Market",
+			orderFilter:  filterTPSL,
+			timeInForce:  "IOC",
+			status:       statusUntriggered,
+			qty:          qty,
+			triggerPrice: trigger,
+			triggerAbove: trigger > m.price,
+			group:        o.id,
+			createdTime:  e.now,
+			updatedTime:  e.now,
+		}
+		e.orders[leg.id] = leg
+		e.orderList = append(e.orderList, leg)
+		e.pushOrder(leg)
+	}
+}
+
+func (e *Emulator) cancel(m *market, o *order, status string, reason string) {
+	e.unlock(m, o)
+	o.status = status
+	o.rejectReason = reason
+	o.updatedTime = e.now
+	e.pushOrder(o)
+}
+
+// cancelOrder cancels a resting order, conditional orders are deactivated
+func (e *Emulator) cancelOrder(symbol, orderID, linkID string) (*order, *apiError) {
+	o := e.findOrder(orderID, linkID)
+	if o == nil || (symbol != "" && o.symbol != symbol) || !o.open() {
+		return nil, errOrderNotExists
+	}
+	status := statusCancelled
+	switch {
+	case o.status == statusUntriggered:
+		status = statusDeactivated
+	case o.cumExecQty > 0:
+		status = statusPartiallyFilledCanceled
+	}
+	e.cancel(e.markets[o.symbol], o, status, "EC_PerCancelRequest")
+	return o, nil
+}
+
+// amendOrder changes the quantity, price or trigger price of a resting order
+func (e *Emulator) amendOrder(symbol, orderID, linkID, qty, price, triggerPrice string) (*order, *apiError) {
+	o := e.findOrder(orderID, linkID)
+	if o == nil || (symbol != "" && o.symbol != symbol) || !o.open() {
+		return nil, errOrderNotExists
+	}
+	m := e.markets[o.symbol]
+	if o.orderType != "Limit" && o.orderFilter != filterTPSL {
+		return nil, errNotModifiable
+	}
+
+	amended := *o
+	var err *apiError
+	if qty != "" {
+		if amended.qty, err = parseAmount(qty, m.cfg.BasePrecision, "qty", errQtyDecimals); err != nil {
+			return nil, err
+		}
+	}
+	if price != "" && o.orderType == "Limit" {
+		if amended.price, err = parseAmount(price, m.cfg.TickSize, "price", errPriceDecimals); err != nil {
+			return nil, err
+		}
+	}
+	if triggerPrice != "" && o.orderFilter == filterTPSL {
+		if amended.triggerPrice, err = parseAmount(triggerPrice, m.cfg.TickSize, "triggerPrice", errPriceDecimals); err != nil {
+			return nil, err
+		}
+		amended.triggerAbove = amended.triggerPrice > m.price
+	}
+	if err = e.checkLimits(m, &amended); err != nil {
+		return nil, err
+	}
+
+	if o.orderFilter != filterTPSL {
+		e.unlock(m, o)
+		if err = e.lock(m, &amended); err != nil {
+			_ = e.lock(m, o)
+			return nil, err
+		}
+	}
+	amended.updatedTime = e.now
+	*o = amended
+
+	if o.orderType == "Limit" && o.orderFilter != filterTPSL {
+		e.placeLimit(m, o)
+	} else {
+		e.pushOrder(o)
+	}
+	return o, nil
+}
+
+func (e *Emulator) findOrder(orderID, linkID string) *order {
+	if orderID != "" {
+		return e.orders[orderID]
+	}
+	if linkID == "" {
+		return nil
+	}
+	for _, o := range e.orderList {
+		if o.linkID == linkID {
+			return o
+		}
+	}
+	return nil
+}
+
+// matchOrders fills resting limit orders the price has reached and fires crossed conditional orders
+func (e *Emulator) matchOrders(m *market) {
+	for _, o := range e.orderList {
+		if o.symbol != m.cfg.Symbol || !o.open() {
+			continue
+		}
+
+		if o.status == statusUntriggered {
+			if (o.triggerAbove && m.price >= o.triggerPrice) || (!o.triggerAbove && m.price <= o.triggerPrice) {
+				e.trigger(m, o)
+			}
+			continue
+		}
+
+		if o.orderType == "Limit" && ((o.side == "Buy" && m.price <= o.price) || (o.side == "Sell" && m.price >= o.price)) {
+			qty := o.qty - o.cumExecQty
+			if m.cfg.Depth > 0 {
+				qty = math.Min(qty, m.cfg.Depth)
+			}
+			e.fill(m, o, o.price, qty, true)
+		}
+	}
+}
+
+// trigger executes a conditional order at market and deactivates the other legs of its group
+func (e *Emulator) trigger(m *market, o *order) {
+	o.status = statusTriggered
+	o.updatedTime = e.now
+
+	if err := e.lock(m, o); err != nil {
+		e.cancel(m, o, statusRejected, "EC_InsufficientBalance")
+	} else {
+		e.fillMarket(m, o)
+	}
+
+	if o.group == "" {
+		return
+	}
+	for _, leg := range e.orderList {
+		if leg != o && leg.group == o.group && leg.status == statusUntriggered {
+			e.cancel(m, leg, statusDeactivated, "EC_OtherLegTriggered")
+		}
+	}
+}
+
+const epsilon = 1e-9
+
+// parseAmount parses a positive amount that must be a multiple of the step
+func parseAmount(raw string, step float64, field string, decimalsErr *apiError) (float64, *apiError) {
+	value, err := strconv.ParseFloat(raw, 64)
+	if err != nil || value <= 0 || math.IsInf(value, 0) {
+		return 0, paramsError(field)
+	}
+	if step > 0 {
+		steps := value / step
+		if math.Abs(steps-math.Round(steps)) > 1e-6 {
+			return 0, decimalsErr
+		}
+	}
+	return value, nil
+}
+
+func floorToStep(value float64, step float64) float64 {
+	if step <= 0 {
+		return value
+	}
+	return roundDecimals(math.Floor(value/step+epsilon)*step, stepDecimals(step))
+}
+
+func roundDecimals(value float64, decimals int) float64 {
+	scale := math.Pow10(decimals)
+	return math.Round(value*scale) / scale
+}
+
+// stepDecimals returns the number of decimals of a step, f.e. 3 for 0.001
+func stepDecimals(step float64) int {
+	s := strconv.FormatFloat(step, 'f', -1, 64)
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		return len(s) - i - 1
+	}
+	return 0
+}
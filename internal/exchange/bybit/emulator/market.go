@@ -0,0 +1,225 @@
+package emulator
+
+import (
+	"cb_grok/pkg/models"
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"strconv"
+	"time"
+)
+
+const (
+	minuteMs = int64(time.Minute / time.Millisecond)
+	dayMs    = 24 * 60 * minuteMs
+	weekMs   = 7 * dayMs
+	// mondayMs is the start of the first week of the epoch, weekly candles start on Monday
+	mondayMs = 4 * dayMs
+
+	// historyTicks is the number of price moves a generated history candle is built from
+	historyTicks = 6
+)
+
+type market struct {
+	cfg   SymbolConfig
+	price float64
+	// candles are 1m candles ordered by time, the last one is forming
+	candles []models.OHLCV
+}
+
+// newMarket generates history candles of a random walk ending at the configured price before now
+func newMarket(cfg SymbolConfig, now int64, history int, rnd *rand.Rand) *market {
+	m := &market{cfg: cfg}
+
+	start := now - now%minuteMs - int64(history)*minuteMs
+	path := make([]float64, history*historyTicks+1)
+	path[len(path)-1] = cfg.Price
+	tickVolatility := cfg.Volatility / math.Sqrt(historyTicks)
+	for i := len(path) - 2; i >= 0; i-- {
+		path[i] = path[i+1] * math.Exp(-rnd.NormFloat64()*tickVolatility)
+	}
+
+	m.candles = make([]models.OHLCV, 0, history+1)
+	for i := 0; i < history; i++ {
+		ticks := path[i*historyTicks : (i+1)*historyTicks+1]
+		candle := models.OHLCV{
+			Timestamp: start + int64(i)*minuteMs,
+			Open:      m.round(ticks[0]),
+			High:      m.round(ticks[0]),
+			Low:       m.round(ticks[0]),
+			Close:     m.round(ticks[len(ticks)-1]),
+			Volume:    m.volume(rnd, minuteMs),
+		}
+		for _, p := range ticks {
+			candle.High = math.Max(candle.High, m.round(p))
+			candle.Low = math.Min(candle.Low, m.round(p))
+		}
+		m.candles = append(m.candles, candle)
+	}
+
+	m.price = m.round(cfg.Price)
+	m.candles = append(m.candles, flatCandle(start+int64(history)*minuteMs, m.price))
+	return m
+}
+
+func flatCandle(ts int64, price float64) models.OHLCV {
+	return models.OHLCV{Timestamp: ts, Open: price, High: price, Low: price, Close: price}
+}
+
+// move advances the random walk by a step ending at now
+func (m *market) move(now int64, step int64, rnd *rand.Rand) {
+	sigma := m.cfg.Volatility * math.Sqrt(float64(step)/float64(minuteMs))
+	price := m.price * math.Exp(rnd.NormFloat64()*sigma)
+	m.trade(now, price, m.volume(rnd, step))
+}
+
+// trade sets the last price at now. A trade at the end of a minute closes its candle.
+func (m *market) trade(now int64, price float64, volume float64) {
+	m.price = m.round(price)
+
+	// the update at the minute boundary belongs to the closing candle
+	last := &m.candles[len(m.candles)-1]
+	if now > last.Timestamp+minuteMs {
+		m.candles = append(m.candles, flatCandle(now-now%minuteMs, last.Close))
+		last = &m.candles[len(m.candles)-1]
+	}
+	last.Close = m.price
+	last.High = math.Max(last.High, m.price)
+	last.Low = math.Min(last.Low, m.price)
+	last.Volume += volume
+
+	if now%minuteMs == 0 && now == last.Timestamp+minuteMs {
+		m.candles = append(m.candles, flatCandle(now, m.price))
+	}
+}
+
+func (m *market) round(price float64) float64 {
+	if m.cfg.TickSize <= 0 {
+		return price
+	}
+	return roundDecimals(math.Max(math.Round(price/m.cfg.TickSize), 1)*m.cfg.TickSize, stepDecimals(m.cfg.TickSize))
+}
+
+func (m *market) volume(rnd *rand.Rand, step int64) float64 {
+	return roundDecimals(rnd.ExpFloat64()*float64(step)/float64(minuteMs), 6)
+}
+
+// ask and bid are the prices market orders fill at
+func (m *market) ask() float64 {
+	return m.round(m.price * (1 + m.cfg.Spread/2))
+}
+
+func (m *market) bid() float64 {
+	return m.round(m.price * (1 - m.cfg.Spread/2))
+}
+
+// klines aggregates 1m candles into candles of the interval starting within [start, end].
+// Like the exchange it returns the newest limit candles, newest first.
+func (m *market) klines(interval string, start, end int64, limit int) ([]models.OHLCV, error) {
+	from := int64(0)
+	if start > 0 {
+		var err error
+		from, err = intervalStart(interval, start)
+		if err != nil {
+			return nil, err
+		}
+	}
+	first := sort.Search(len(m.candles), func(i int) bool { return m.candles[i].Timestamp >= from })
+
+	var result []models.OHLCV
+	for _, c := range m.candles[first:] {
+		if c.Timestamp > end {
+			break
+		}
+		bucket, err := intervalStart(interval, c.Timestamp)
+		if err != nil {
+			return nil, err
+		}
+		if bucket < start || bucket > end {
+			continue
+		}
+		if n := len(result); n > 0 && result[n-1].Timestamp == bucket {
+			result[n-1] = mergeCandle(result[n-1], c)
+			continue
+		}
+		c.Timestamp = bucket
+		result = append(result, c)
+	}
+
+	if len(result) > limit {
+		result = result[len(result)-limit:]
+	}
+	for i, j := 0, len(result)-1; i < j; i, j = i+1, j-1 {
+		result[i], result[j] = result[j], result[i]
+	}
+	return result, nil
+}
+
+// candle returns the candle of the interval starting at start
+func (m *market) candle(interval string, start int64) (models.OHLCV, error) {
+	end, err := intervalEnd(interval, start)
+	if err != nil {
+		return models.OHLCV{}, err
+	}
+	candles, err := m.klines(interval, start, end-1, 1)
+	if err != nil {
+		return models.OHLCV{}, err
+	}
+	if len(candles) == 0 {
+		return models.OHLCV{}, fmt.Errorf("no candle at %d", start)
+	}
+	return candles[0], nil
+}
+
+func mergeCandle(a, b models.OHLCV) models.OHLCV {
+	a.High = math.Max(a.High, b.High)
+	a.Low = math.Min(a.Low, b.Low)
+	a.Close = b.Close
+	a.Volume += b.Volume
+	return a
+}
+
+// intervalStart returns the start of the candle of the interval containing ts
+func intervalStart(interval string, ts int64) (int64, error) {
+	switch interval {
+	case "D":
+		return ts - ts%dayMs, nil
+	case "W":
+		return ts - (ts-mondayMs)%weekMs, nil
+	case "M":
+		t := time.UnixMilli(ts).UTC()
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC).UnixMilli(), nil
+	}
+	minutes, err := intervalMinutes(interval)
+	if err != nil {
+		return 0, err
+	}
+	return ts - ts%(minutes*minuteMs), nil
+}
+
+// intervalEnd returns the start of the candle following the one starting at start
+func intervalEnd(interval string, start int64) (int64, error) {
+	switch interval {
+	case "D":
+		return start + dayMs, nil
+	case "W":
+		return start + weekMs, nil
+	case "M":
+		return time.UnixMilli(start).UTC().AddDate(0, 1, 0).UnixMilli(), nil
+	}
+	minutes, err := intervalMinutes(interval)
+	if err != nil {
+		return 0, err
+	}
+	return start + minutes*minuteMs, nil
+}
+
+func intervalMinutes(interval string) (int64, error) {
+	switch interval {
+	case "1", "3", "5", "15", "30", "60", "120", "240", "360", "720":
+		return strconv.ParseInt(interval, 10, 64)
+	default:
+		return 0, fmt.Errorf("invalid interval %q", interval)
+	}
+}
@@ -0,0 +1,592 @@
+package emulator
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultKlineLimit = 200
+	maxKlineLimit     = 1000
+	defaultOrderLimit = 20
+	maxOrderLimit     = 50
+	maxExecutionLimit = 100
+	defaultRecvWindow = 5000
+)
+
+// params holds the query of GET requests or the JSON body of POST requests
+type params map[string]string
+
+func readParams(r *http.Request) (params, *apiError) {
+	p := params{}
+	if r.Method != http.MethodPost {
+		for key, values := range r.URL.Query() {
+			p[key] = values[0]
+		}
+		return p, nil
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, paramsError("body")
+	}
+	if len(body) == 0 {
+		return p, nil
+	}
+	var raw map[string]interface{}
+	if err := json.Unmarshal(body, &raw); err != nil {
+		return nil, paramsError("body")
+	}
+	for key, value := range raw {
+		switch v := value.(type) {
+		case string:
+			p[key] = v
+		case float64:
+			p[key] = strconv.FormatFloat(v, 'f', -1, 64)
+		case bool:
+			p[key] = strconv.FormatBool(v)
+		}
+	}
+	return p, nil
+}
+
+func (p params) int(key string, def int) (int, *apiError) {
+	raw, ok := p[key]
+	if !ok || raw == "" {
+		return def, nil
+	}
+	v, err := strconv.Atoi(raw)
+	if err != nil {
+		return 0, paramsError(key)
+	}
+	return v, nil
+}
+
+func (p params) int64(key string) (int64, *apiError) {
+	raw, ok := p[key]
+	if !ok || raw == "" {
+		return 0, nil
+	}
+	v, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil {
+		return 0, paramsError(key)
+	}
+	return v, nil
+}
+
+// respond writes the envelope of the v5 API. Errors are answered with HTTP 200 and their retCode.
+func (e *Emulator) respond(w http.ResponseWriter, result interface{}, apiErr *apiError) {
+	resp := map[string]interface{}{
+		"retCode":    0,
+		"retMsg":     "OK",
+		"result":     result,
+		"retExtInfo": map[string]interface{}{},
+		"time":       time.Now().UnixMilli(),
+	}
+	if apiErr != nil {
+		resp["retCode"] = apiErr.code
+		resp["retMsg"] = apiErr.msg
+		resp["result"] = map[string]interface{}{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+// signed authenticates a request signed with the v5 HMAC scheme:
+// hex(HMAC_SHA256(secret, timestamp + apiKey + recvWindow + query or body))
+func (e *Emulator) signed(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		apiKey := r.Header.Get("X-BAPI-API-KEY")
+		if apiKey == "" || (e.cfg.APIKey != "" && apiKey != e.cfg.APIKey) {
+			e.respond(w, nil, errInvalidKey)
+			return
+		}
+
+		timestamp := r.Header.Get("X-BAPI-TIMESTAMP")
+		ts, err := strconv.ParseInt(timestamp, 10, 64)
+		if err != nil {
+			e.respond(w, nil, errTimestamp)
+			return
+		}
+		recvWindow := r.Header.Get("X-BAPI-RECV-WINDOW")
+		window, err := strconv.ParseInt(recvWindow, 10, 64)
+		if err != nil {
+			window = defaultRecvWindow
+		}
+		if now := time.Now().UnixMilli(); ts > now+1000 || now-ts > window {
+			e.respond(w, nil, errTimestamp)
+			return
+		}
+
+		payload := r.URL.RawQuery
+		if r.Method == http.MethodPost {
+			body, err := io.ReadAll(r.Body)
+			if err != nil {
+				e.respond(w, nil, paramsError("body"))
+				return
+			}
+			payload = string(body)
+			r.Body = io.NopCloser(strings.NewReader(payload))
+		}
+
+		if e.cfg.APIKey != "" {
+			mac := hmac.New(sha256.New, []byte(e.cfg.APISecret))
+			mac.Write([]byte(timestamp + apiKey + recvWindow + payload))
+			if !hmac.Equal([]byte(hex.EncodeToString(mac.Sum(nil))), []byte(r.Header.Get("X-BAPI-SIGN"))) {
+				e.respond(w, nil, errInvalidSign)
+				return
+			}
+		}
+
+		handler(w, r)
+	}
+}
+
+func (e *Emulator) handleKline(w http.ResponseWriter, r *http.Request) {
+	p, apiErr := readParams(r)
+	if apiErr != nil {
+		e.respond(w, nil, apiErr)
+		return
+	}
+	if p["category"] != "spot" {
+		e.respond(w, nil, paramsError("category"))
+		return
+	}
+	limit, apiErr := p.int("limit", defaultKlineLimit)
+	if apiErr != nil || limit <= 0 {
+		e.respond(w, nil, paramsError("limit"))
+		return
+	}
+	limit = min(limit, maxKlineLimit)
+	start, apiErr := p.int64("start")
+	if apiErr != nil {
+		e.respond(w, nil, apiErr)
+		return
+	}
+	end, apiErr := p.int64("end")
+	if apiErr != nil {
+		e.respond(w, nil, apiErr)
+		return
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	m, ok := e.markets[p["symbol"]]
+	if !ok {
+		e.respond(w, nil, errSymbolInvalid)
+		return
+	}
+	if end == 0 || end > e.now {
+		end = e.now
+	}
+	candles, err := m.klines(p["interval"], start, end, limit)
+	if err != nil {
+		e.respond(w, nil, paramsError("interval"))
+		return
+	}
+
+	list := make([][]string, 0, len(candles))
+	for _, c := range candles {
+		list = append(list, []string{
+			strconv.FormatInt(c.Timestamp, 10),
+			formatFloat(c.Open),
+			formatFloat(c.High),
+			formatFloat(c.Low),
+			formatFloat(c.Close),
+			formatFloat(c.Volume),
+			formatFloat(c.Volume * c.Close),
+		})
+	}
+	e.respond(w, map[string]interface{}{
+		"category": "spot",
+		"symbol":   m.cfg.Symbol,
+		"list":     list,
+	}, nil)
+}
+
+func (e *Emulator) handleInstrumentsInfo(w http.ResponseWriter, r *http.Request) {
+	p, apiErr := readParams(r)
+	if apiErr != nil {
+		e.respond(w, nil, apiErr)
+		return
+	}
+	if p["category"] != "spot" {
+		e.respond(w, nil, paramsError("category"))
+		return
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	symbols := make([]string, 0, len(e.markets))
+	for symbol := range e.markets {
+		if p["symbol"] == "" || p["symbol"] == symbol {
+			symbols = append(symbols, symbol)
+		}
+	}
+	sort.Strings(symbols)
+
+	list := make([]map[string]interface{}, 0, len(symbols))
+	for _, symbol := range symbols {
+		cfg := e.markets[symbol].cfg
+		list = append(list, map[string]interface{}{
+			"symbol":    cfg.Symbol,
+			"baseCoin":  cfg.BaseCoin,
+			"quoteCoin": cfg.QuoteCoin,
+			"status":    "Trading",
+			"lotSizeFilter": map[string]string{
+				"basePrecision":  formatFloat(cfg.BasePrecision),
+				"quotePrecision": formatFloat(cfg.QuotePrecision),
+				"minOrderQty":    formatFloat(cfg.MinOrderQty),
+				"maxOrderQty":    formatFloat(cfg.MaxOrderQty),
+				"minOrderAmt":    formatFloat(cfg.MinOrderAmt),
+				"maxOrderAmt":    formatFloat(cfg.MaxOrderAmt),
+			},
+			"priceFilter": map[string]string{
+				"tickSize": formatFloat(cfg.TickSize),
+			},
+		})
+	}
+	e.respond(w, map[string]interface{}{"category": "spot", "list": list}, nil)
+}
+
+func (e *Emulator) handleCreateOrder(w http.ResponseWriter, r *http.Request) {
+	p, apiErr := readParams(r)
+	if apiErr != nil {
+		e.respond(w, nil, apiErr)
+		return
+	}
+	if p["category"] != "spot" {
+		e.respond(w, nil, paramsError("category"))
+		return
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	o, apiErr := e.placeOrder(placeRequest{
+		symbol:       p["symbol"],
+		side:         p["side"],
+		orderType:    p["orderType"],
+		qty:          p["qty"],
+		price:        p["price"],
+		triggerPrice: p["triggerPrice"],
+		timeInForce:  p["timeInForce"],
+		orderFilter:  p["orderFilter"],
+		orderLinkID:  p["orderLinkId"],
+		marketUnit:   p["marketUnit"],
+		takeProfit:   p["takeProfit"],
+		stopLoss:     p["stopLoss"],
+	})
+	if apiErr != nil {
+		e.respond(w, nil, apiErr)
+		return
+	}
+	e.respond(w, map[string]string{"orderId": o.id, "orderLinkId": o.linkID}, nil)
+}
+
+func (e *Emulator) handleAmendOrder(w http.ResponseWriter, r *http.Request) {
+	p, apiErr := readParams(r)
+	if apiErr != nil {
+		e.respond(w, nil, apiErr)
+		return
+	}
+	if p["category"] != "spot" {
+		e.respond(w, nil, paramsError("category"))
+		return
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	o, apiErr := e.amendOrder(p["symbol"], p["orderId"], p["orderLinkId"], p["qty"], p["price"], p["triggerPrice"])
+	if apiErr != nil {
+		e.respond(w, nil, apiErr)
+		return
+	}
+	e.respond(w, map[string]string{"orderId": o.id, "orderLinkId": o.linkID}, nil)
+}
+
+func (e *Emulator) handleCancelOrder(w http.ResponseWriter, r *http.Request) {
+	p, apiErr := readParams(r)
+	if apiErr != nil {
+		e.respond(w, nil, apiErr)
+		return
+	}
+	if p["category"] != "spot" {
+		e.respond(w, nil, paramsError("category"))
+		return
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	o, apiErr := e.cancelOrder(p["symbol"], p["orderId"], p["orderLinkId"])
+	if apiErr != nil {
+		e.respond(w, nil, apiErr)
+		return
+	}
+	e.respond(w, map[string]string{"orderId": o.id, "orderLinkId": o.linkID}, nil)
+}
+
+// handleOpenOrders lists open orders. An order requested by id is returned in any status.
+func (e *Emulator) handleOpenOrders(w http.ResponseWriter, r *http.Request) {
+	e.listOrders(w, r, true)
+}
+
+// handleOrderHistory lists closed orders. An order requested by id is returned in any status.
+func (e *Emulator) handleOrderHistory(w http.ResponseWriter, r *http.Request) {
+	e.listOrders(w, r, false)
+}
+
+func (e *Emulator) listOrders(w http.ResponseWriter, r *http.Request, open bool) {
+	p, apiErr := readParams(r)
+	if apiErr != nil {
+		e.respond(w, nil, apiErr)
+		return
+	}
+	if p["category"] != "spot" {
+		e.respond(w, nil, paramsError("category"))
+		return
+	}
+	limit, apiErr := p.int("limit", defaultOrderLimit)
+	if apiErr != nil || limit <= 0 {
+		e.respond(w, nil, paramsError("limit"))
+		return
+	}
+	limit = min(limit, maxOrderLimit)
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	list := make([]map[string]interface{}, 0)
+	if p["orderId"] != "" || p["orderLinkId"] != "" {
+		if o := e.findOrder(p["orderId"], p["orderLinkId"]); o != nil {
+			list = append(list, e.renderOrder(o))
+		}
+	} else {
+		for i := len(e.orderList) - 1; i >= 0 && len(list) < limit; i-- {
+			o := e.orderList[i]
+			if o.open() != open || (p["symbol"] != "" && o.symbol != p["symbol"]) || (p["orderFilter"] != "" && o.orderFilter != p["orderFilter"]) {
+				continue
+			}
+			list = append(list, e.renderOrder(o))
+		}
+	}
+
+	e.respond(w, map[string]interface{}{
+		"category":       "spot",
+		"list":           list,
+		"nextPageCursor": "",
+	}, nil)
+}
+
+// handleExecutionList lists fills, newest first
+func (e *Emulator) handleExecutionList(w http.ResponseWriter, r *http.Request) {
+	p, apiErr := readParams(r)
+	if apiErr != nil {
+		e.respond(w, nil, apiErr)
+		return
+	}
+	if p["category"] != "spot" {
+		e.respond(w, nil, paramsError("category"))
+		return
+	}
+	limit, apiErr := p.int("limit", defaultOrderLimit)
+	if apiErr != nil || limit <= 0 {
+		e.respond(w, nil, paramsError("limit"))
+		return
+	}
+	limit = min(limit, maxExecutionLimit)
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	list := make([]map[string]interface{}, 0)
+	for i := len(e.executions) - 1; i >= 0 && len(list) < limit; i-- {
+		exec := e.executions[i]
+		if (p["orderId"] != "" && exec.orderID != p["orderId"]) || (p["orderLinkId"] != "" && exec.linkID != p["orderLinkId"]) || (p["symbol"] != "" && exec.symbol != p["symbol"]) {
+			continue
+		}
+		list = append(list, e.renderExecution(exec))
+	}
+
+	e.respond(w, map[string]interface{}{
+		"category":       "spot",
+		"list":           list,
+		"nextPageCursor": "",
+	}, nil)
+}
+
+func (e *Emulator) handleWalletBalance(w http.ResponseWriter, r *http.Request) {
+	p, apiErr := readParams(r)
+	if apiErr != nil {
+		e.respond(w, nil, apiErr)
+		return
+	}
+	if p["accountType"] != "UNIFIED" {
+		e.respond(w, nil, errAccountTypeDenied)
+		return
+	}
+
+	var coins []string
+	if p["coin"] != "" {
+		coins = strings.Split(p["coin"], ",")
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.respond(w, map[string]interface{}{"list": []interface{}{e.renderWallet(coins...)}}, nil)
+}
+
+func (e *Emulator) renderOrder(o *order) map[string]interface{} {
+	m := e.markets[o.symbol]
+
+	avgPrice := ""
+	if o.cumExecQty > 0 {
+		avgPrice = formatFloat(o.cumExecValue / o.cumExecQty)
+	}
+	leavesQty, leavesValue := 0.0, 0.0
+	if o.open() {
+		leavesQty = o.qty - o.cumExecQty
+		leavesValue = leavesQty * o.price
+	}
+
+	return map[string]interface{}{
+		"orderId":      o.id,
+		"orderLinkId":  o.linkID,
+		"symbol":       o.symbol,
+		"price":        formatFloat(o.price),
+		"qty":          formatFloat(o.qty),
+		"side":         o.side,
+		"orderStatus":  o.status,
+		"cancelType":   "UNKNOWN",
+		"rejectReason": rejectReason(o.rejectReason),
+		"avgPrice":     avgPrice,
+		"leavesQty":    formatFloat(leavesQty),
+		"leavesValue":  formatFloat(leavesValue),
+		"cumExecQty":   formatStep(o.cumExecQty, m.cfg.BasePrecision),
+		"cumExecValue": formatStep(o.cumExecValue, m.cfg.QuotePrecision),
+		"cumExecFee":   formatFloat(o.cumExecFee),
+		"timeInForce":  o.timeInForce,
+		"orderType":    o.orderType,
+		"orderFilter":  o.orderFilter,
+		"triggerPrice": formatFloat(o.triggerPrice),
+		"takeProfit":   formatFloat(o.takeProfit),
+		"stopLoss":     formatFloat(o.stopLoss),
+		"marketUnit":   marketUnit(o),
+		"createdTime":  strconv.FormatInt(o.createdTime, 10),
+		"updatedTime":  strconv.FormatInt(o.updatedTime, 10),
+	}
+}
+
+func (e *Emulator) renderExecution(exec execution) map[string]interface{} {
+	m := e.markets[exec.symbol]
+	return map[string]interface{}{
+		"symbol":      exec.symbol,
+		"orderId":     exec.orderID,
+		"orderLinkId": exec.linkID,
+		"side":        exec.side,
+		"orderType":   exec.orderType,
+		"orderPrice":  formatFloat(exec.orderPrice),
+		"orderQty":    formatFloat(exec.orderQty),
+		"execId":      exec.id,
+		"execPrice":   formatFloat(exec.price),
+		"execQty":     formatStep(exec.qty, m.cfg.BasePrecision),
+		"execValue":   formatStep(exec.value, m.cfg.QuotePrecision),
+		"execFee":     formatFloat(exec.fee),
+		"feeRate":     formatFloat(exec.feeRate),
+		"feeCurrency": exec.feeCurrency,
+		"execType":    "Trade",
+		"isMaker":     exec.maker,
+		"execTime":    strconv.FormatInt(exec.time, 10),
+	}
+}
+
+func (e *Emulator) renderWallet(coins ...string) map[string]interface{} {
+	if len(coins) == 0 {
+		for coin := range e.balances {
+			coins = append(coins, coin)
+		}
+		sort.Strings(coins)
+	}
+
+	var (
+		total float64
+		list  = make([]map[string]string, 0, len(coins))
+	)
+	for _, coin := range coins {
+		b := e.balance(coin)
+		usdValue := b.wallet * e.usdPrice(coin)
+		total += usdValue
+		list = append(list, map[string]string{
+			"coin":                coin,
+			"equity":              formatFloat(b.wallet),
+			"walletBalance":       formatFloat(b.wallet),
+			"locked":              formatFloat(b.locked),
+			"availableToWithdraw": formatFloat(b.available()),
+			"usdValue":            formatFloat(usdValue),
+			"unrealisedPnl":       "0",
+			"cumRealisedPnl":      "0",
+		})
+	}
+
+	return map[string]interface{}{
+		"accountType":           "UNIFIED",
+		"totalEquity":           formatFloat(total),
+		"totalWalletBalance":    formatFloat(total),
+		"totalAvailableBalance": formatFloat(total),
+		"coin":                  list,
+	}
+}
+
+// usdPrice values a coin by its USDT market, stablecoins at par
+func (e *Emulator) usdPrice(coin string) float64 {
+	switch coin {
+	case "USDT", "USDC", "USD":
+		return 1
+	}
+	if m, ok := e.markets[coin+"USDT"]; ok {
+		return m.price
+	}
+	return 0
+}
+
+func marketUnit(o *order) string {
+	if o.orderType != "Market" {
+		return ""
+	}
+	if o.quoteQty {
+		return "quoteCoin"
+	}
+	return "baseCoin"
+}
+
+func rejectReason(reason string) string {
+	if reason == "" {
+		return "EC_NoError"
+	}
+	return reason
+}
+
+// formatFloat drops the float error of amounts computed by the engine
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(roundDecimals(v, 10), 'f', -1, 64)
+}
+
+func formatStep(v float64, step float64) string {
+	if step <= 0 {
+		return formatFloat(v)
+	}
+	return strconv.FormatFloat(roundDecimals(v, stepDecimals(step)), 'f', -1, 64)
+}
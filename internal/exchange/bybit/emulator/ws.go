@@ -0,0 +1,336 @@
+package emulator
+
+import (
+	"cb_grok/pkg/models"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"github.com/gorilla/websocket"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	wsSendBuffer   = 256
+	wsWriteTimeout = 5 * time.Second
+)
+
+var upgrader = websocket.Upgrader{CheckOrigin: func(r *http.Request) bool { return true }}
+
+type wsClient struct {
+	conn    *websocket.Conn
+	send    chan []byte
+	private bool
+
+	// authed, topics and closed are guarded by the emulator mutex
+	authed bool
+	topics map[string]bool
+	closed bool
+}
+
+type wsRequest struct {
+	ReqID string        `json:"req_id"`
+	Op    string        `json:"op"`
+	Args  []interface{} `json:"args"`
+}
+
+func (c *wsClient) close() {
+	if !c.closed {
+		c.closed = true
+		close(c.send)
+	}
+}
+
+// write queues a message. A client that does not keep up is disconnected instead of blocking the feed.
+func (c *wsClient) write(message interface{}) {
+	if c.closed {
+		return
+	}
+	data, err := json.Marshal(message)
+	if err != nil {
+		return
+	}
+	select {
+	case c.send <- data:
+	default:
+		c.close()
+	}
+}
+
+func (c *wsClient) writeLoop() {
+	for data := range c.send {
+		_ = c.conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+		if err := c.conn.WriteMessage(websocket.TextMessage, data); err != nil {
+			break
+		}
+	}
+	_ = c.conn.Close()
+}
+
+func (e *Emulator) handlePublicWS(w http.ResponseWriter, r *http.Request) {
+	e.serveWS(w, r, false)
+}
+
+func (e *Emulator) handlePrivateWS(w http.ResponseWriter, r *http.Request) {
+	e.serveWS(w, r, true)
+}
+
+func (e *Emulator) serveWS(w http.ResponseWriter, r *http.Request, private bool) {
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	c := &wsClient{
+		conn:    conn,
+		send:    make(chan []byte, wsSendBuffer),
+		private: private,
+		topics:  make(map[string]bool),
+	}
+
+	e.mu.Lock()
+	e.clients[c] = struct{}{}
+	e.mu.Unlock()
+
+	go c.writeLoop()
+	defer func() {
+		e.mu.Lock()
+		delete(e.clients, c)
+		c.close()
+		e.mu.Unlock()
+	}()
+
+	for {
+		_, message, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		var req wsRequest
+		if err := json.Unmarshal(message, &req); err != nil {
+			continue
+		}
+
+		e.mu.Lock()
+		e.handleWSRequest(c, req)
+		e.mu.Unlock()
+	}
+}
+
+func (e *Emulator) handleWSRequest(c *wsClient, req wsRequest) {
+	switch req.Op {
+	case "ping":
+		if c.private {
+			c.write(map[string]interface{}{"req_id": req.ReqID, "op": "pong", "args": []string{strconv.FormatInt(time.Now().UnixMilli(), 10)}, "conn_id": ""})
+		} else {
+			c.write(map[string]interface{}{"success": true, "ret_msg": "pong", "conn_id": "", "req_id": req.ReqID, "op": "ping"})
+		}
+	case "auth":
+		ok := c.private && e.authenticate(req.Args)
+		c.authed = c.authed || ok
+		msg := ""
+		if !ok {
+			msg = "Request not authorized"
+		}
+		c.write(map[string]interface{}{"success": ok, "ret_msg": msg, "op": "auth", "conn_id": "", "req_id": req.ReqID})
+	case "subscribe":
+		var (
+			topics []string
+			msg    string
+		)
+		for _, arg := range req.Args {
+			topic, _ := arg.(string)
+			if err := e.checkTopic(c, topic); err != "" {
+				msg = err
+				break
+			}
+			topics = append(topics, topic)
+		}
+		if msg == "" {
+			for _, topic := range topics {
+				c.topics[topic] = true
+			}
+		}
+		c.write(map[string]interface{}{"success": msg == "", "ret_msg": msg, "op": "subscribe", "conn_id": "", "req_id": req.ReqID})
+		if msg == "" {
+			for _, topic := range topics {
+				e.pushSnapshot(c, topic)
+			}
+		}
+	case "unsubscribe":
+		for _, arg := range req.Args {
+			topic, _ := arg.(string)
+			delete(c.topics, topic)
+		}
+		c.write(map[string]interface{}{"success": true, "ret_msg": "", "op": "unsubscribe", "conn_id": "", "req_id": req.ReqID})
+	}
+}
+
+// authenticate checks the args of the auth op: api key, expires and hex(HMAC_SHA256(secret, "GET/realtime" + expires))
+func (e *Emulator) authenticate(args []interface{}) bool {
+	if len(args) != 3 {
+		return false
+	}
+	apiKey, _ := args[0].(string)
+	signature, _ := args[2].(string)
+	var expires string
+	switch v := args[1].(type) {
+	case float64:
+		expires = strconv.FormatFloat(v, 'f', -1, 64)
+	case string:
+		expires = v
+	}
+	expiresAt, err := strconv.ParseInt(expires, 10, 64)
+	if apiKey == "" || err != nil || expiresAt < time.Now().UnixMilli() {
+		return false
+	}
+	if e.cfg.APIKey == "" {
+		return true
+	}
+	mac := hmac.New(sha256.New, []byte(e.cfg.APISecret))
+	mac.Write([]byte("GET/realtime" + expires))
+	return apiKey == e.cfg.APIKey && hmac.Equal([]byte(hex.EncodeToString(mac.Sum(nil))), []byte(signature))
+}
+
+// checkTopic returns the error message of a topic the client cannot subscribe to
+func (e *Emulator) checkTopic(c *wsClient, topic string) string {
+	if c.private {
+		if !c.authed {
+			return "Request not authorized"
+		}
+		switch topic {
+		case "order", "order.spot", "execution", "execution.spot", "wallet":
+			return ""
+		}
+		return "error:handler not found,topic:" + topic
+	}
+
+	parts := strings.Split(topic, ".")
+	if len(parts) != 3 || parts[0] != "kline" {
+		return "error:handler not found,topic:" + topic
+	}
+	if _, err := intervalStart(parts[1], 0); err != nil {
+		return "error:handler not found,topic:" + topic
+	}
+	if _, ok := e.markets[parts[2]]; !ok {
+		return "error:handler not found,topic:" + topic
+	}
+	return ""
+}
+
+// pushSnapshot sends the current state of a topic right after the subscription
+func (e *Emulator) pushSnapshot(c *wsClient, topic string) {
+	switch {
+	case topic == "wallet":
+		c.write(e.privateMessage("wallet", e.renderWallet()))
+	case strings.HasPrefix(topic, "kline."):
+		parts := strings.Split(topic, ".")
+		m := e.markets[parts[2]]
+		start, _ := intervalStart(parts[1], e.now)
+		if candle, err := m.candle(parts[1], start); err == nil {
+			c.write(e.klineMessage(topic, parts[1], candle, false))
+		}
+	}
+}
+
+// pushKlines sends the forming candle of every subscribed kline topic. When the candle of a topic
+// has closed since the last push, it is sent confirmed first.
+func (e *Emulator) pushKlines() {
+	topics := make(map[string]bool)
+	for c := range e.clients {
+		for topic := range c.topics {
+			if strings.HasPrefix(topic, "kline.") {
+				topics[topic] = true
+			}
+		}
+	}
+
+	for topic := range topics {
+		parts := strings.Split(topic, ".")
+		interval, m := parts[1], e.markets[parts[2]]
+
+		// the update at the end of a candle still belongs to it
+		current, err := intervalStart(interval, e.now-1)
+		if err != nil {
+			continue
+		}
+		if pushed, ok := e.pushed[topic]; ok && pushed != current {
+			if candle, err := m.candle(interval, pushed); err == nil {
+				e.broadcast(topic, e.klineMessage(topic, interval, candle, true))
+			}
+		}
+		e.pushed[topic] = current
+
+		candle, err := m.candle(interval, current)
+		if err != nil {
+			continue
+		}
+		end, _ := intervalEnd(interval, current)
+		e.broadcast(topic, e.klineMessage(topic, interval, candle, e.now >= end))
+		if e.now >= end {
+			delete(e.pushed, topic)
+		}
+	}
+}
+
+func (e *Emulator) klineMessage(topic, interval string, candle models.OHLCV, confirm bool) map[string]interface{} {
+	end, _ := intervalEnd(interval, candle.Timestamp)
+	return map[string]interface{}{
+		"topic": topic,
+		"type":  "snapshot",
+		"ts":    e.now,
+		"data": []map[string]interface{}{{
+			"start":     candle.Timestamp,
+			"end":       end - 1,
+			"interval":  interval,
+			"open":      formatFloat(candle.Open),
+			"close":     formatFloat(candle.Close),
+			"high":      formatFloat(candle.High),
+			"low":       formatFloat(candle.Low),
+			"volume":    formatFloat(candle.Volume),
+			"turnover":  formatFloat(candle.Volume * candle.Close),
+			"confirm":   confirm,
+			"timestamp": e.now,
+		}},
+	}
+}
+
+func (e *Emulator) privateMessage(topic string, data ...interface{}) map[string]interface{} {
+	e.lastMessageID++
+	return map[string]interface{}{
+		"id":           strconv.FormatInt(e.lastMessageID, 10),
+		"topic":        topic,
+		"creationTime": e.now,
+		"data":         data,
+	}
+}
+
+func (e *Emulator) pushOrder(o *order) {
+	data := e.renderOrder(o)
+	data["category"] = "spot"
+	data["feeCurrency"] = o.feeCurrency
+	message := e.privateMessage("order", data)
+	e.broadcast("order", message)
+	e.broadcast("order.spot", message)
+}
+
+func (e *Emulator) pushExecution(exec execution) {
+	data := e.renderExecution(exec)
+	data["category"] = "spot"
+	message := e.privateMessage("execution", data)
+	e.broadcast("execution", message)
+	e.broadcast("execution.spot", message)
+}
+
+func (e *Emulator) pushWallet(coins ...string) {
+	e.broadcast("wallet", e.privateMessage("wallet", e.renderWallet(coins...)))
+}
+
+func (e *Emulator) broadcast(topic string, message interface{}) {
+	for c := range e.clients {
+		if c.topics[topic] {
+			c.write(message)
+		}
+	}
+}
@@ -2,41 +2,50 @@ package bybit
 
 import (
 	"cb_grok/internal/exchange"
-	"cb_grok/internal/utils"
 	"cb_grok/pkg/models"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	bybitapi "github.com/bybit-exchange/bybit.go.api"
+	bybitmodels "github.com/bybit-exchange/bybit.go.api/models"
 	"go.uber.org/zap"
-	"sort"
 	"strconv"
 )
 
+// klinesLimit is the maximum number of candles per request
+const klinesLimit = 1000
+
+// FetchSpotOHLCV returns the last total candles including the forming one, sorted ascending
 func (b *bybit) FetchSpotOHLCV(symbol string, timeframe exchange.Timeframe, total int) ([]models.OHLCV, error) {
-	timeframeValue := GetBybitTimeframe(timeframe)
-	if timeframeValue == "" {
-		return nil, fmt.Errorf("unsupported timeframe: %s", timeframe)
+	page, err := b.klinePage(symbol, timeframe, total)
+	if err != nil {
+		return nil, err
 	}
+	return exchange.FetchLast(page, timeframe, total, klinesLimit)
+}
 
-	limit := 1000
-	if total < limit {
-		limit = total
+// FetchSpotOHLCVRange returns the closed candles opened in [start, end) and the missing ones
+func (b *bybit) FetchSpotOHLCVRange(symbol string, timeframe exchange.Timeframe, start, end int64) (*exchange.CandleRange, error) {
+	page, err := b.klinePage(symbol, timeframe, 0)
+	if err != nil {
+		return nil, err
 	}
+	return exchange.FetchRange(page, timeframe, start, end, klinesLimit)
+}
 
-	var candles []models.OHLCV
-
-	since := int64(0)
+// klinePage requests one page of candles. Bybit returns the newest limit candles of [start, end].
+func (b *bybit) klinePage(symbol string, timeframe exchange.Timeframe, total int) (exchange.KlinePage, error) {
+	timeframeValue := GetBybitTimeframe(timeframe)
+	if timeframeValue == "" {
+		return nil, fmt.Errorf("unsupported timeframe: %s", timeframe)
+	}
 
-	for {
-		params := map[string]interface{}{"category": "spot", "symbol": symbol, "interval": timeframeValue, "limit": limit}
-		if since != 0 {
-			params["start"] = since
-		}
+	fetched := 0
+	return func(start, end int64, limit int) ([]models.OHLCV, error) {
+		params := map[string]interface{}{"category": "spot", "symbol": symbol, "interval": timeframeValue, "limit": limit, "start": start, "end": end}
 		response, err := b.client.NewUtaBybitServiceWithParams(params).GetMarketKline(context.Background())
 		if err != nil {
-			fmt.Println(err)
 			return nil, errors.New("failed to fetch ohlcv: " + err.Error())
 		}
 		_, err = ParseResponse(response)
@@ -52,82 +61,57 @@ func (b *bybit) FetchSpotOHLCV(symbol string, timeframe exchange.Timeframe, tota
 		if err != nil {
 			return nil, fmt.Errorf("failed to parse response: %w", err)
 		}
-		ohlcv := result.List
 
-		for _, r := range ohlcv {
-			ts, err := strconv.ParseInt(r.StartTime, 10, 64)
-			if err != nil {
-				return nil, fmt.Errorf("failed to parse timestamp: %w", err)
-			}
-			o, err := strconv.ParseFloat(r.OpenPrice, 64)
-			if err != nil {
-				return nil, fmt.Errorf("failed to parse decimal in ohlcv: %w", err)
-			}
-			h, err := strconv.ParseFloat(r.HighPrice, 64)
-			if err != nil {
-				return nil, fmt.Errorf("failed to parse decimal in ohlcv: %w", err)
-			}
-			l, err := strconv.ParseFloat(r.LowPrice, 64)
-			if err != nil {
-				return nil, fmt.Errorf("failed to parse decimal in ohlcv: %w", err)
-			}
-			c, err := strconv.ParseFloat(r.ClosePrice, 64)
+		candles := make([]models.OHLCV, 0, len(result.List))
+		for _, r := range result.List {
+			candle, err := parseKline(r)
 			if err != nil {
-				return nil, fmt.Errorf("failed to parse decimal in ohlcv: %w", err)
+				return nil, err
 			}
-			v, err := strconv.ParseFloat(r.Volume, 64)
-			if err != nil {
-				return nil, fmt.Errorf("failed to parse decimal in ohlcv: %w", err)
-			}
-			candles = append(candles, models.OHLCV{
-				Timestamp: ts,
-				Open:      o,
-				High:      h,
-				Low:       l,
-				Close:     c,
-				Volume:    v,
-			})
-		}
-
-		if len(result.List) < limit {
-			break
-		}
-
-		if len(candles) >= total {
-			break
+			candles = append(candles, candle)
 		}
 
-		startTimestamp, err := strconv.ParseInt(ohlcv[len(ohlcv)-1].StartTime, 10, 64)
-		if err != nil {
-			return nil, fmt.Errorf("failed to parse last candle timestamp: %w", err)
+		fetched += len(candles)
+		if total > 0 {
+			b.logger.Info(fmt.Sprintf("fetched ohlcv: %d/%d", fetched, total))
+		} else {
+			b.logger.Debug(fmt.Sprintf("fetched ohlcv: %d", fetched), zap.Int64("start", start), zap.Int64("end", end))
 		}
+		return candles, nil
+	}, nil
+}
 
-		since = startTimestamp - (int64(limit) * utils.TimeframeToMilliseconds(string(timeframe)))
-
-		zap.L().Info(fmt.Sprintf("fetched ohlcv: %d/%d", len(candles), total))
-
+func parseKline(r *bybitmodels.MarketKlineCandle) (models.OHLCV, error) {
+	ts, err := strconv.ParseInt(r.StartTime, 10, 64)
+	if err != nil {
+		return models.OHLCV{}, fmt.Errorf("failed to parse timestamp: %w", err)
 	}
-
-	// sort ASC
-	sort.Slice(candles, func(i, j int) bool {
-		return candles[i].Timestamp < candles[j].Timestamp
-	})
-
-	// deduplication
-	if len(candles) > 1 {
-		uniqueCandles := []models.OHLCV{candles[0]}
-		for i := 1; i < len(candles); i++ {
-			if candles[i].Timestamp != candles[i-1].Timestamp {
-				uniqueCandles = append(uniqueCandles, candles[i])
-			}
-		}
-		candles = uniqueCandles
+	o, err := strconv.ParseFloat(r.OpenPrice, 64)
+	if err != nil {
+		return models.OHLCV{}, fmt.Errorf("failed to parse decimal in ohlcv: %w", err)
 	}
-
-	// limit count to total
-	if len(candles) > total {
-		candles = candles[len(candles)-total:]
+	h, err := strconv.ParseFloat(r.HighPrice, 64)
+	if err != nil {
+		return models.OHLCV{}, fmt.Errorf("failed to parse decimal in ohlcv: %w", err)
 	}
-
-	return candles, nil
+	l, err := strconv.ParseFloat(r.LowPrice, 64)
+	if err != nil {
+		return models.OHLCV{}, fmt.Errorf("failed to parse decimal in ohlcv: %w", err)
+	}
+	c, err := strconv.ParseFloat(r.ClosePrice, 64)
+	if err != nil {
+		return models.OHLCV{}, fmt.Errorf("failed to parse decimal in ohlcv: %w", err)
+	}
+	v, err := strconv.ParseFloat(r.Volume, 64)
+	if err != nil {
+		return models.OHLCV{}, fmt.Errorf("failed to parse decimal in ohlcv: %w", err)
+	}
+	return models.OHLCV{
+		Timestamp: ts,
+		Open:      o,
+		High:      h,
+		Low:       l,
+		Close:     c,
+		Volume:    v,
+	}, nil
 }
@@ -0,0 +1,60 @@
+package bybit
+
+import (
+	"cb_grok/internal/exchange"
+	"context"
+	"encoding/json"
+	"fmt"
+	"go.uber.org/zap"
+)
+
+// executionPageLimit is the maximum page size of the execution list
+const executionPageLimit = 100
+
+func (b *bybit) GetOrderFills(orderId string) ([]exchange.Fill, error) {
+	var fills []exchange.Fill
+	cursor := ""
+	for {
+		params := map[string]interface{}{"orderId": orderId, "category": "spot", "limit": executionPageLimit}
+		if cursor != "" {
+			params["cursor"] = cursor
+		}
+		response, err := b.client.NewUtaBybitServiceWithParams(params).GetTradeHistory(context.Background())
+		if err != nil {
+			b.logger.Error("failed to get order executions", zap.String("orderId", orderId), zap.Error(err))
+			return nil, err
+		}
+		result, err := ParseResponse(response)
+		if err != nil {
+			return nil, err
+		}
+
+		var executions ExecutionList
+		resultBytes, err := json.Marshal(result)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal response: %w", err)
+		}
+		err = json.Unmarshal(resultBytes, &executions)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal response: %w", err)
+		}
+
+		for _, e := range executions.List {
+			if e.OrderId != orderId || (e.ExecType != "" && e.ExecType != "Trade") {
+				continue
+			}
+			fill, err := parseFill(e)
+			if err != nil {
+				return nil, err
+			}
+			fills = append(fills, fill)
+		}
+
+		if executions.NextPageCursor == "" || len(executions.List) < executionPageLimit {
+			break
+		}
+		cursor = executions.NextPageCursor
+	}
+
+	return fills, nil
+}
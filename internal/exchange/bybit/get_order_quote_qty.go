@@ -1,62 +1,34 @@
 package bybit
 
 import (
-	"context"
-	"encoding/json"
-	"fmt"
-	"go.uber.org/zap"
-	"strconv"
+	"cb_grok/internal/exchange"
+	"strings"
 )
 
+// GetOrderQuoteQty returns what the order received net of fees: the base quantity of a buy,
+// the quote amount of a sell. Only fees charged in the received coin are deducted.
 func (b *bybit) GetOrderQuoteQty(orderId string) (float64, error) {
-	params := map[string]interface{}{"orderId": orderId, "category": "spot"}
-	response, err := b.client.NewUtaBybitServiceWithParams(params).GetOrderHistory(context.Background())
+	fills, err := b.GetOrderFills(orderId)
 	if err != nil {
-		b.logger.Error("failed to get order info", zap.String("orderId", orderId), zap.Error(err))
 		return 0, err
 	}
-	result, err := ParseResponse(response)
-	if err != nil {
-		return 0, err
-	}
-
-	var orderList OrderList
-	resultBytes, err := json.Marshal(result)
-	if err != nil {
-		return 0, fmt.Errorf("failed to marshal response: %w", err)
-	}
-	fmt.Println(result)
-	//os.Exit(0)
-	err = json.Unmarshal(resultBytes, &orderList)
-	if err != nil {
-		return 0, fmt.Errorf("failed to unmarshal response: %w", err)
-	}
-
-	if len(orderList.List) != 1 {
-		return 0, fmt.Errorf("expected one order in response, got %d", len(orderList.List))
-	}
-
-	ord := orderList.List[0]
+	return receivedQty(fills), nil
+}
 
-	if ord.OrderId != orderId {
-		return 0, fmt.Errorf("orderId mismatch: expected %s, got %s", orderId, ord.OrderId)
-	}
+func receivedQty(fills []exchange.Fill) float64 {
 	var amount float64
-	if ord.Side == "Sell" {
-		amount, err = strconv.ParseFloat(ord.CumExecValue, 64)
-	} else {
-		amount, err = strconv.ParseFloat(ord.CumExecQty, 64)
-	}
-
-	if err != nil {
-		return 0, fmt.Errorf("failed to parse amount of exec %s: %w", orderId, err)
-	}
-
-	commission, err := strconv.ParseFloat(ord.CumExecFee, 64)
-
-	if err != nil {
-		return 0, fmt.Errorf("failed to parse fee of exec %s: %w", orderId, err)
-	}
-
-	return amount - commission, nil
+	for _, fill := range fills {
+		if fill.Side == exchange.OrderSideSell {
+			amount += fill.Value
+			if fill.FeeCurrency == "" || strings.HasSuffix(fill.Symbol, fill.FeeCurrency) {
+				amount -= fill.Fee
+			}
+		} else {
+			amount += fill.Qty
+			if fill.FeeCurrency == "" || strings.HasPrefix(fill.Symbol, fill.FeeCurrency) {
+				amount -= fill.Fee
+			}
+		}
+	}
+	return amount
 }
@@ -56,11 +56,13 @@ func ParseResponse(response *bybitapi.ServerResponse) (map[string]interface{}, e
 
 func ParseOrderStatus(status string) (order_model.OrderStatus, error) {
 	switch status {
-	case "New", "PartiallyFilled":
+	case "New", "Untriggered", "Triggered":
 		return order_model.OrderStatusPlaced, nil
+	case "PartiallyFilled":
+		return order_model.OrderStatusPartiallyFilled, nil
 	case "Filled":
 		return order_model.OrderStatusFilled, nil
-	case "Cancelled", "Rejected", "Deactivated":
+	case "Cancelled", "Rejected", "Deactivated", "PartiallyFilledCanceled":
 		return order_model.OrderStatusCanceled, nil
 	default:
 		return 0, fmt.Errorf("unknown order status: %s", status)
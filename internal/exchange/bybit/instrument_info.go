@@ -0,0 +1,87 @@
+package bybit
+
+import (
+	"cb_grok/internal/exchange"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// instrumentInfoTTL bounds how long the trading rules of a symbol are cached
+const instrumentInfoTTL = time.Hour
+
+type cachedInstrument struct {
+	info      exchange.InstrumentInfo
+	fetchedAt time.Time
+}
+
+func (b *bybit) GetInstrumentInfo(symbol string) (*exchange.InstrumentInfo, error) {
+	b.instrumentsMu.Lock()
+	defer b.instrumentsMu.Unlock()
+
+	if cached, ok := b.instruments[symbol]; ok && time.Since(cached.fetchedAt) < instrumentInfoTTL {
+		info := cached.info
+		return &info, nil
+	}
+
+	params := map[string]interface{}{"category": "spot", "symbol": symbol}
+	response, err := b.client.NewUtaBybitServiceWithParams(params).GetInstrumentInfo(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get instrument info of %s: %w", symbol, err)
+	}
+	result, err := ParseResponse(response)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get instrument info of %s: %w", symbol, err)
+	}
+
+	resultBytes, err := json.Marshal(result)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal response: %w", err)
+	}
+	var instruments InstrumentList
+	err = json.Unmarshal(resultBytes, &instruments)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal response: %w", err)
+	}
+	if len(instruments.List) != 1 {
+		return nil, fmt.Errorf("expected one instrument %s in response, got %d", symbol, len(instruments.List))
+	}
+
+	info, err := parseInstrument(instruments.List[0])
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse instrument info of %s: %w", symbol, err)
+	}
+
+	b.instruments[symbol] = cachedInstrument{info: info, fetchedAt: time.Now()}
+	return &info, nil
+}
+
+func parseInstrument(instrument Instrument) (exchange.InstrumentInfo, error) {
+	info := exchange.InstrumentInfo{Symbol: instrument.Symbol}
+
+	for _, field := range []struct {
+		value string
+		dst   *float64
+	}{
+		{instrument.LotSizeFilter.BasePrecision, &info.BasePrecision},
+		{instrument.LotSizeFilter.QuotePrecision, &info.QuotePrecision},
+		{instrument.LotSizeFilter.MinOrderQty, &info.MinOrderQty},
+		{instrument.LotSizeFilter.MaxOrderQty, &info.MaxOrderQty},
+		{instrument.LotSizeFilter.MinOrderAmt, &info.MinOrderAmt},
+		{instrument.LotSizeFilter.MaxOrderAmt, &info.MaxOrderAmt},
+		{instrument.PriceFilter.TickSize, &info.TickSize},
+	} {
+		if field.value == "" {
+			continue
+		}
+		value, err := strconv.ParseFloat(field.value, 64)
+		if err != nil {
+			return exchange.InstrumentInfo{}, err
+		}
+		*field.dst = value
+	}
+
+	return info, nil
+}
@@ -0,0 +1,76 @@
+package bybit
+
+import (
+	"cb_grok/internal/exchange"
+	"context"
+	"fmt"
+)
+
+func (b *bybit) PlaceSpotLimitOrder(symbol string, orderSide exchange.OrderSide, baseQty float64, price float64, timeInForce exchange.TimeInForce) (string, error) {
+	orderSideValue := GetBybitOrderSide(orderSide)
+	if orderSideValue == "" {
+		return "", fmt.Errorf("unsupported order side: %s", orderSide)
+	}
+	if timeInForce == "" {
+		timeInForce = exchange.TimeInForceGTC
+	}
+
+	info, err := b.GetInstrumentInfo(symbol)
+	if err != nil {
+		return "", err
+	}
+
+	req := b.client.NewPlaceOrderService("spot", symbol, orderSideValue, "Limit", info.FormatQty(baseQty)).
+		Price(info.FormatPrice(info.LimitPrice(orderSide, price))).
+		TimeInForce(string(timeInForce))
+
+	orderResult, err := req.Do(context.Background())
+	if err != nil {
+		return "", err
+	}
+
+	result, err := ParseResponse(orderResult)
+	if err != nil {
+		return "", err
+	}
+
+	orderID, ok := result["orderId"].(string)
+	if !ok {
+		return "", fmt.Errorf("orderId not found in response")
+	}
+
+	return orderID, nil
+}
+
+func (b *bybit) AmendOrder(symbol string, orderId string, baseQty *float64, price *float64) error {
+	if baseQty == nil && price == nil {
+		return nil
+	}
+
+	info, err := b.GetInstrumentInfo(symbol)
+	if err != nil {
+		return err
+	}
+
+	params := map[string]interface{}{
+		"category": "spot",
+		"symbol":   symbol,
+		"orderId":  orderId,
+	}
+	if baseQty != nil {
+		params["qty"] = info.FormatQty(*baseQty)
+	}
+	if price != nil {
+		params["price"] = info.FormatPrice(*price)
+	}
+
+	response, err := b.client.NewUtaBybitServiceWithParams(params).AmendOrder(context.Background())
+	if err != nil {
+		return fmt.Errorf("failed to amend order %s: %w", orderId, err)
+	}
+	_, err = ParseResponse(response)
+	if err != nil {
+		return fmt.Errorf("failed to amend order %s: %w", orderId, err)
+	}
+	return nil
+}
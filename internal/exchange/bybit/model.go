@@ -1,7 +1,11 @@
 package bybit
 
+import "encoding/json"
+
 type WSKlineMessage struct {
 	Success bool   `json:"success,omitempty"`
+	RetMsg  string `json:"ret_msg,omitempty"`
+	Op      string `json:"op,omitempty"`
 	Type    string `json:"type,omitempty"`
 	Topic   string `json:"topic,omitempty"`
 	Data    []struct {
@@ -81,3 +85,69 @@ type CoinBalance struct {
 	Locked              string `json:"locked"`
 	Coin                string `json:"coin"`
 }
+
+type InstrumentList struct {
+	List []Instrument `json:"list"`
+}
+
+type Instrument struct {
+	Symbol        string `json:"symbol"`
+	BaseCoin      string `json:"baseCoin"`
+	QuoteCoin     string `json:"quoteCoin"`
+	Status        string `json:"status"`
+	LotSizeFilter struct {
+		BasePrecision  string `json:"basePrecision"`
+		QuotePrecision string `json:"quotePrecision"`
+		MinOrderQty    string `json:"minOrderQty"`
+		MaxOrderQty    string `json:"maxOrderQty"`
+		MinOrderAmt    string `json:"minOrderAmt"`
+		MaxOrderAmt    string `json:"maxOrderAmt"`
+	} `json:"lotSizeFilter"`
+	PriceFilter struct {
+		TickSize string `json:"tickSize"`
+	} `json:"priceFilter"`
+}
+
+type WSPrivateMessage struct {
+	Success      bool            `json:"success,omitempty"`
+	RetMsg       string          `json:"ret_msg,omitempty"`
+	Op           string          `json:"op,omitempty"`
+	Topic        string          `json:"topic,omitempty"`
+	CreationTime int64           `json:"creationTime,omitempty"`
+	Data         json.RawMessage `json:"data,omitempty"`
+}
+
+type WSOrder struct {
+	Category     string `json:"category"`
+	OrderId      string `json:"orderId"`
+	Symbol       string `json:"symbol"`
+	Side         string `json:"side"`
+	OrderStatus  string `json:"orderStatus"`
+	CumExecQty   string `json:"cumExecQty"`
+	CumExecValue string `json:"cumExecValue"`
+	CumExecFee   string `json:"cumExecFee"`
+	FeeCurrency  string `json:"feeCurrency"`
+	UpdatedTime  string `json:"updatedTime"`
+}
+
+// ExecutionList is the result of the execution list, its items are shaped like the stream executions
+type ExecutionList struct {
+	List           []WSExecution `json:"list,omitempty"`
+	NextPageCursor string        `json:"nextPageCursor"`
+}
+
+type WSExecution struct {
+	Category    string `json:"category"`
+	Symbol      string `json:"symbol"`
+	OrderId     string `json:"orderId"`
+	Side        string `json:"side"`
+	ExecId      string `json:"execId"`
+	ExecPrice   string `json:"execPrice"`
+	ExecQty     string `json:"execQty"`
+	ExecValue   string `json:"execValue"`
+	ExecFee     string `json:"execFee"`
+	FeeCurrency string `json:"feeCurrency"`
+	ExecType    string `json:"execType"`
+	IsMaker     bool   `json:"isMaker"`
+	ExecTime    string `json:"execTime"`
+}
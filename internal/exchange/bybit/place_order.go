@@ -6,22 +6,30 @@ import (
 	"fmt"
 )
 
-func (b *bybit) PlaceSpotMarketOrder(symbol string, orderSide exchange.OrderSide, baseQty float64, takeProfit *float64, stopLoss *float64, precision int64) (string, error) {
+func (b *bybit) PlaceSpotMarketOrder(symbol string, orderSide exchange.OrderSide, baseQty float64, takeProfit *float64, stopLoss *float64) (string, error) {
 	orderSideValue := GetBybitOrderSide(orderSide)
 	if orderSideValue == "" {
 		return "", fmt.Errorf("unsupported order side: %s", orderSide)
 	}
 
-	qty := fmt.Sprintf("%.*f", precision, baseQty)
-	fmt.Println("qty:", baseQty, qty, precision)
+	info, err := b.GetInstrumentInfo(symbol)
+	if err != nil {
+		return "", err
+	}
+
+	// spot market buys are sized in the quote coin
+	qty := info.FormatQty(baseQty)
+	if orderSide == exchange.OrderSideBuy {
+		qty = info.FormatQuote(baseQty)
+	}
 
 	req := b.client.NewPlaceOrderService("spot", symbol, orderSideValue, "Market", qty)
 
 	if takeProfit != nil {
-		req = req.TakeProfit(fmt.Sprintf("%.2f", *takeProfit))
+		req = req.TakeProfit(info.FormatPrice(*takeProfit))
 	}
 	if stopLoss != nil {
-		req = req.StopLoss(fmt.Sprintf("%.2f", *stopLoss))
+		req = req.StopLoss(info.FormatPrice(*stopLoss))
 	}
 
 	orderResult, err := req.Do(context.Background())
@@ -0,0 +1,287 @@
+package bybit
+
+import (
+	"cb_grok/internal/exchange"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/gorilla/websocket"
+	"go.uber.org/zap"
+	"strconv"
+	"time"
+)
+
+const (
+	// wsAuthExpiry bounds the validity of the signature of the private stream login
+	wsAuthExpiry       = 10 * time.Second
+	accountBufferSize  = 100
+	accountTopicOrder  = "order"
+	accountTopicFill   = "execution"
+	accountTopicWallet = "wallet"
+)
+
+type accountStream struct {
+	b   *bybit
+	out chan exchange.AccountEvent
+}
+
+// SubscribeAccount streams spot order updates, fills and wallet balances from the private stream
+func (b *bybit) SubscribeAccount(ctx context.Context) (<-chan exchange.AccountEvent, error) {
+	if b.client.APIKey == "" || b.client.APISecret == "" {
+		return nil, fmt.Errorf("API key and secret are required for the account stream")
+	}
+
+	s := &accountStream{
+		b:   b,
+		out: make(chan exchange.AccountEvent, accountBufferSize),
+	}
+	stream := &exchange.WSStream{
+		Name:         "bybit: account stream",
+		Logger:       b.logger,
+		Connect:      s.connect,
+		Handle:       s.handle,
+		Reconnected:  s.reconnected,
+		ReadTimeout:  wsReadTimeout,
+		Ping:         wsPing,
+		PingInterval: wsPingInterval,
+	}
+
+	// the first connection fails fast, f.e. on invalid credentials
+	conn, err := s.connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	go func() {
+		defer close(s.out)
+		stream.Run(ctx, conn)
+	}()
+
+	return s.out, nil
+}
+
+// connect dials the private stream, logs in and subscribes to the account topics
+func (s *accountStream) connect(ctx context.Context) (*websocket.Conn, error) {
+	conn, _, err := websocket.DefaultDialer.DialContext(ctx, s.b.privateWSURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s: %w", s.b.privateWSURL, err)
+	}
+
+	expires := strconv.FormatInt(time.Now().Add(wsAuthExpiry).UnixMilli(), 10)
+	mac := hmac.New(sha256.New, []byte(s.b.client.APISecret))
+	mac.Write([]byte("GET/realtime" + expires))
+	signature := hex.EncodeToString(mac.Sum(nil))
+
+	requests := []struct {
+		op   string
+		args []interface{}
+	}{
+		{"auth", []interface{}{s.b.client.APIKey, expires, signature}},
+		{"subscribe", []interface{}{accountTopicOrder, accountTopicFill, accountTopicWallet}},
+	}
+	for _, req := range requests {
+		if err := s.request(conn, req.op, req.args); err != nil {
+			_ = conn.Close()
+			return nil, err
+		}
+	}
+
+	return conn, nil
+}
+
+// request sends an op and waits for its acknowledgement
+func (s *accountStream) request(conn *websocket.Conn, op string, args []interface{}) error {
+	err := conn.WriteJSON(map[string]interface{}{"op": op, "args": args})
+	if err != nil {
+		return fmt.Errorf("failed to send %s: %w", op, err)
+	}
+
+	_ = conn.SetReadDeadline(time.Now().Add(wsReadTimeout))
+	for {
+		msg, err := readPrivateMessage(conn)
+		if err != nil {
+			return fmt.Errorf("failed to %s: %w", op, err)
+		}
+		if msg.Op != op {
+			continue
+		}
+		if !msg.Success {
+			return fmt.Errorf("failed to %s: %s", op, msg.RetMsg)
+		}
+		return nil
+	}
+}
+
+// handle forwards the account events of a topic message to the channel
+func (s *accountStream) handle(ctx context.Context, message []byte) {
+	msg, err := decodePrivateMessage(message)
+	if err != nil {
+		s.b.logger.Error("bybit: account stream", zap.Error(err))
+		return
+	}
+
+	var events []exchange.AccountEvent
+	switch msg.Topic {
+	case accountTopicOrder:
+		events, err = parseOrderEvents(msg.Data)
+	case accountTopicFill:
+		events, err = parseFillEvents(msg.Data)
+	case accountTopicWallet:
+		events, err = parseWalletEvents(msg.Data)
+	default:
+		return
+	}
+	if err != nil {
+		s.b.logger.Error("bybit: cannot parse account event", zap.String("topic", msg.Topic), zap.Error(err))
+		return
+	}
+	for _, event := range events {
+		s.send(ctx, event)
+	}
+}
+
+// reconnected asks consumers to reconcile the events missed while disconnected
+func (s *accountStream) reconnected(ctx context.Context) {
+	s.send(ctx, exchange.AccountEvent{Reconnected: true})
+}
+
+func (s *accountStream) send(ctx context.Context, event exchange.AccountEvent) {
+	select {
+	case s.out <- event:
+	case <-ctx.Done():
+	}
+}
+
+func readPrivateMessage(conn *websocket.Conn) (*WSPrivateMessage, error) {
+	_, message, err := conn.ReadMessage()
+	if err != nil {
+		return nil, err
+	}
+	return decodePrivateMessage(message)
+}
+
+func decodePrivateMessage(message []byte) (*WSPrivateMessage, error) {
+	var msg WSPrivateMessage
+	if err := json.Unmarshal(message, &msg); err != nil {
+		return nil, fmt.Errorf("cannot parse received message: %w", err)
+	}
+	return &msg, nil
+}
+
+func parseOrderEvents(data json.RawMessage) ([]exchange.AccountEvent, error) {
+	var orders []WSOrder
+	if err := json.Unmarshal(data, &orders); err != nil {
+		return nil, err
+	}
+
+	events := make([]exchange.AccountEvent, 0, len(orders))
+	for _, o := range orders {
+		if o.Category != "spot" {
+			continue
+		}
+		status, err := ParseOrderStatus(o.OrderStatus)
+		if err != nil {
+			return nil, err
+		}
+		update := exchange.OrderUpdate{
+			OrderID:     o.OrderId,
+			Symbol:      o.Symbol,
+			Side:        parseOrderSide(o.Side),
+			Status:      status,
+			FeeCurrency: o.FeeCurrency,
+		}
+		values, err := parseDecimals(o.CumExecQty, o.CumExecValue, o.CumExecFee, o.UpdatedTime)
+		if err != nil {
+			return nil, fmt.Errorf("order %s: %w", o.OrderId, err)
+		}
+		update.CumExecQty, update.CumExecValue, update.CumExecFee, update.UpdatedAt = values[0], values[1], values[2], int64(values[3])
+		events = append(events, exchange.AccountEvent{Order: &update})
+	}
+	return events, nil
+}
+
+func parseFillEvents(data json.RawMessage) ([]exchange.AccountEvent, error) {
+	var executions []WSExecution
+	if err := json.Unmarshal(data, &executions); err != nil {
+		return nil, err
+	}
+
+	events := make([]exchange.AccountEvent, 0, len(executions))
+	for _, e := range executions {
+		// funding and settlement records are not fills
+		if e.Category != "spot" || e.ExecType != "Trade" {
+			continue
+		}
+		fill, err := parseFill(e)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, exchange.AccountEvent{Fill: &fill})
+	}
+	return events, nil
+}
+
+func parseFill(e WSExecution) (exchange.Fill, error) {
+	fill := exchange.Fill{
+		ID:          e.ExecId,
+		OrderID:     e.OrderId,
+		Symbol:      e.Symbol,
+		Side:        parseOrderSide(e.Side),
+		FeeCurrency: e.FeeCurrency,
+		IsMaker:     e.IsMaker,
+	}
+	values, err := parseDecimals(e.ExecPrice, e.ExecQty, e.ExecValue, e.ExecFee, e.ExecTime)
+	if err != nil {
+		return exchange.Fill{}, fmt.Errorf("execution %s: %w", e.ExecId, err)
+	}
+	fill.Price, fill.Qty, fill.Value, fill.Fee, fill.Time = values[0], values[1], values[2], values[3], int64(values[4])
+	return fill, nil
+}
+
+func parseWalletEvents(data json.RawMessage) ([]exchange.AccountEvent, error) {
+	var wallets []WalletBalance
+	if err := json.Unmarshal(data, &wallets); err != nil {
+		return nil, err
+	}
+
+	var balances []exchange.WalletBalance
+	for _, w := range wallets {
+		for _, c := range w.Coin {
+			values, err := parseDecimals(c.WalletBalance, c.Locked)
+			if err != nil {
+				return nil, fmt.Errorf("coin %s: %w", c.Coin, err)
+			}
+			balances = append(balances, exchange.WalletBalance{Coin: c.Coin, Balance: values[0], Locked: values[1]})
+		}
+	}
+	if len(balances) == 0 {
+		return nil, nil
+	}
+	return []exchange.AccountEvent{{Wallet: balances}}, nil
+}
+
+func parseOrderSide(side string) exchange.OrderSide {
+	if side == "Sell" {
+		return exchange.OrderSideSell
+	}
+	return exchange.OrderSideBuy
+}
+
+// parseDecimals parses the decimal strings of a stream message, empty strings are zero
+func parseDecimals(raw ...string) ([]float64, error) {
+	values := make([]float64, len(raw))
+	for i, r := range raw {
+		if r == "" {
+			continue
+		}
+		v, err := strconv.ParseFloat(r, 64)
+		if err != nil {
+			return nil, errors.New("failed to parse decimal: " + err.Error())
+		}
+		values[i] = v
+	}
+	return values, nil
+}
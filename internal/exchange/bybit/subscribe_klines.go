@@ -0,0 +1,214 @@
+package bybit
+
+import (
+	"cb_grok/internal/exchange"
+	"cb_grok/pkg/models"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/gorilla/websocket"
+	"go.uber.org/zap"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	wsPingInterval  = 20 * time.Second
+	wsReadTimeout   = 2 * wsPingInterval
+	klineBufferSize = 100
+)
+
+// wsPing keeps the public and private streams alive, the server does not ping
+var wsPing = map[string]string{"op": "ping"}
+
+type klineStream struct {
+	b         *bybit
+	symbol    string
+	timeframe exchange.Timeframe
+	topic     string
+	out       chan exchange.Kline
+
+	// lastConfirmed is the start of the last closed candle sent to the channel
+	lastConfirmed int64
+}
+
+func (b *bybit) SubscribeKlines(ctx context.Context, symbol string, timeframe exchange.Timeframe) (<-chan exchange.Kline, error) {
+	interval := GetBybitTimeframe(timeframe)
+	if interval == "" {
+		return nil, fmt.Errorf("unsupported timeframe: %s", timeframe)
+	}
+
+	s := &klineStream{
+		b:         b,
+		symbol:    symbol,
+		timeframe: timeframe,
+		topic:     fmt.Sprintf("kline.%s.%s", interval, strings.ReplaceAll(symbol, "/", "")),
+		out:       make(chan exchange.Kline, klineBufferSize),
+	}
+	stream := &exchange.WSStream{
+		Name:         "bybit: kline stream",
+		Logger:       b.logger.With(zap.String("topic", s.topic)),
+		Connect:      s.connect,
+		Handle:       s.handle,
+		Reconnected:  s.reconnected,
+		ReadTimeout:  wsReadTimeout,
+		Ping:         wsPing,
+		PingInterval: wsPingInterval,
+	}
+
+	// the first connection fails fast, f.e. on an unknown symbol
+	conn, err := s.connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	go func() {
+		defer close(s.out)
+		stream.Run(ctx, conn)
+	}()
+
+	return s.out, nil
+}
+
+// connect dials the public stream and waits for the subscription to be acknowledged
+func (s *klineStream) connect(ctx context.Context) (*websocket.Conn, error) {
+	conn, _, err := websocket.DefaultDialer.DialContext(ctx, s.b.publicWSURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s: %w", s.b.publicWSURL, err)
+	}
+
+	err = conn.WriteJSON(map[string]interface{}{"op": "subscribe", "args": []string{s.topic}})
+	if err != nil {
+		_ = conn.Close()
+		return nil, fmt.Errorf("failed to subscribe to %s: %w", s.topic, err)
+	}
+
+	_ = conn.SetReadDeadline(time.Now().Add(wsReadTimeout))
+	for {
+		_, message, err := conn.ReadMessage()
+		if err != nil {
+			_ = conn.Close()
+			return nil, fmt.Errorf("failed to subscribe to %s: %w", s.topic, err)
+		}
+		msg, err := decodeKlineMessage(message)
+		if err != nil {
+			_ = conn.Close()
+			return nil, fmt.Errorf("failed to subscribe to %s: %w", s.topic, err)
+		}
+		if msg.Op != "subscribe" {
+			continue
+		}
+		if !msg.Success {
+			_ = conn.Close()
+			return nil, fmt.Errorf("failed to subscribe to %s: %s", s.topic, msg.RetMsg)
+		}
+		return conn, nil
+	}
+}
+
+// handle forwards the klines of a topic message to the channel
+func (s *klineStream) handle(ctx context.Context, message []byte) {
+	msg, err := decodeKlineMessage(message)
+	if err != nil {
+		s.b.logger.Error("bybit: kline stream", zap.String("topic", s.topic), zap.Error(err))
+		return
+	}
+	if msg.Topic != s.topic {
+		return
+	}
+
+	for _, data := range msg.Data {
+		candle, err := parseWSKline(data.Start, data.Open, data.High, data.Low, data.Close, data.Volume)
+		if err != nil {
+			s.b.logger.Error("bybit: cannot parse kline", zap.String("topic", s.topic), zap.Error(err))
+			continue
+		}
+		s.emit(ctx, candle, data.Confirm)
+	}
+}
+
+func (s *klineStream) reconnected(ctx context.Context) {
+	if err := s.backfill(ctx); err != nil {
+		s.b.logger.Error("bybit: kline stream backfill", zap.String("topic", s.topic), zap.Error(err))
+	}
+}
+
+// backfill sends the candles closed since the last confirmed one, so consumers do not miss bars on reconnect
+func (s *klineStream) backfill(ctx context.Context) error {
+	if s.lastConfirmed == 0 {
+		return nil
+	}
+	tf, err := s.timeframe.Parse()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UnixMilli()
+	missed := 1
+	for open := tf.Next(s.lastConfirmed); open <= now; open = tf.Next(open) {
+		missed++
+	}
+	candles, err := s.b.FetchSpotOHLCV(s.symbol, s.timeframe, missed)
+	if err != nil {
+		return err
+	}
+
+	backfilled := 0
+	for _, candle := range candles {
+		// the candle in progress comes with the stream
+		if candle.Timestamp <= s.lastConfirmed || tf.Next(candle.Timestamp) > now {
+			continue
+		}
+		s.emit(ctx, candle, true)
+		backfilled++
+	}
+	s.b.logger.Info("bybit: kline stream backfilled", zap.String("topic", s.topic), zap.Int("candles", backfilled))
+
+	return nil
+}
+
+func (s *klineStream) emit(ctx context.Context, candle models.OHLCV, confirm bool) {
+	if candle.Timestamp < s.lastConfirmed || (confirm && candle.Timestamp == s.lastConfirmed) {
+		return
+	}
+	if confirm {
+		s.lastConfirmed = candle.Timestamp
+	}
+	select {
+	case s.out <- exchange.Kline{
+		Symbol:    s.symbol,
+		Timeframe: s.timeframe,
+		OHLCV:     candle,
+		Confirm:   confirm,
+	}:
+	case <-ctx.Done():
+	}
+}
+
+func decodeKlineMessage(message []byte) (*WSKlineMessage, error) {
+	var msg WSKlineMessage
+	if err := json.Unmarshal(message, &msg); err != nil {
+		return nil, fmt.Errorf("cannot parse received message: %w", err)
+	}
+	return &msg, nil
+}
+
+func parseWSKline(start int64, open, high, low, close, volume string) (models.OHLCV, error) {
+	values := make([]float64, 0, 5)
+	for _, raw := range []string{open, high, low, close, volume} {
+		v, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return models.OHLCV{}, errors.New("failed to parse decimal in kline: " + err.Error())
+		}
+		values = append(values, v)
+	}
+	return models.OHLCV{
+		Timestamp: start,
+		Open:      values[0],
+		High:      values[1],
+		Low:       values[2],
+		Close:     values[3],
+		Volume:    values[4],
+	}, nil
+}
@@ -7,6 +7,16 @@ var (
 	OrderSideSell OrderSide = "sell"
 )
 
+type TimeInForce string
+
+var (
+	TimeInForceGTC TimeInForce = "GTC"
+	TimeInForceIOC TimeInForce = "IOC"
+	TimeInForceFOK TimeInForce = "FOK"
+	// TimeInForcePostOnly rejects the order if it would fill immediately, so it always pays maker fees
+	TimeInForcePostOnly TimeInForce = "PostOnly"
+)
+
 type TradingMode string
 
 var (
@@ -3,13 +3,43 @@ package exchange
 import (
 	"cb_grok/internal/order/model"
 	"cb_grok/pkg/models"
+	"context"
 )
 
 type Exchange interface {
 	Name() string
+	// Account identifies the exchange account the adapter trades on. Adapters of the same account share order sync.
+	Account() string
 	FetchSpotOHLCV(symbol string, timeframe Timeframe, total int) ([]models.OHLCV, error)
-	PlaceSpotMarketOrder(symbol string, orderSide OrderSide, baseQty float64, takeProfit *float64, stopLoss *float64, precision int64) (string, error)
+	// FetchSpotOHLCVRange returns the closed candles opened in [start, end), unix milliseconds,
+	// and reports the candles the exchange has no data for
+	FetchSpotOHLCVRange(symbol string, timeframe Timeframe, start, end int64) (*CandleRange, error)
+	// GetInstrumentInfo returns the lot size, tick size and notional limits of a spot symbol.
+	// Order quantities and prices are formatted with them.
+	GetInstrumentInfo(symbol string) (*InstrumentInfo, error)
+	// PlaceSpotMarketOrder places a market order, buy quantity is the quote amount to spend, sell quantity the base amount
+	PlaceSpotMarketOrder(symbol string, orderSide OrderSide, baseQty float64, takeProfit *float64, stopLoss *float64) (string, error)
+	// PlaceSpotConditionalOrder places a market order that the exchange submits once the price reaches triggerPrice
+	PlaceSpotConditionalOrder(symbol string, orderSide OrderSide, baseQty float64, triggerPrice float64) (string, error)
+	// PlaceSpotLimitOrder places a limit order for baseQty base units
+	PlaceSpotLimitOrder(symbol string, orderSide OrderSide, baseQty float64, price float64, timeInForce TimeInForce) (string, error)
+	CancelOrder(symbol string, orderId string) error
+	// AmendOrder changes the quantity and/or price of an open limit order, nil values are kept
+	AmendOrder(symbol string, orderId string, baseQty *float64, price *float64) error
 	GetOrderStatus(orderId string) (order_model.OrderStatus, error)
 	GetOrderQuoteQty(orderId string) (float64, error)
+	// GetOrderFills returns the executions of an order with the fee and the coin it was charged in
+	GetOrderFills(orderId string) ([]Fill, error)
 	GetAvailableSpotWalletBalance(coin string) (float64, error)
+	// SubscribeKlines streams candle updates of the symbol until ctx is done, then closes the channel.
+	// The stream reconnects on its own and backfills the candles closed while it was disconnected
+	SubscribeKlines(ctx context.Context, symbol string, timeframe Timeframe) (<-chan Kline, error)
+}
+
+// OCOPlacer is implemented by exchanges that lock the balance of a resting conditional order, the
+// take-profit and stop-loss of a position only fit into it together as a one-cancels-the-other list
+type OCOPlacer interface {
+	// PlaceSpotOCOOrder places a take-profit and a stop-loss market order for the same baseQty,
+	// the exchange cancels the other one once either fires
+	PlaceSpotOCOOrder(symbol string, orderSide OrderSide, baseQty float64, takeProfit float64, stopLoss float64) (takeProfitID string, stopLossID string, err error)
 }
@@ -0,0 +1,129 @@
+package exchange
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrOrderBelowMinimum = errors.New("order is below the exchange minimum")
+	ErrOrderAboveMaximum = errors.New("order is above the exchange maximum")
+)
+
+// InstrumentInfo holds the trading rules of a spot symbol. Zero values mean no restriction.
+type InstrumentInfo struct {
+	Symbol string
+	// BasePrecision is the quantity step of base amounts, QuotePrecision of quote amounts
+	BasePrecision  float64
+	QuotePrecision float64
+	TickSize       float64
+	MinOrderQty    float64
+	MaxOrderQty    float64
+	// MinOrderAmt and MaxOrderAmt bound the notional value of an order in quote currency
+	MinOrderAmt float64
+	MaxOrderAmt float64
+}
+
+// FloorQty rounds a base quantity down to the base precision, so it never exceeds the balance
+func (i InstrumentInfo) FloorQty(qty float64) float64 {
+	return floorToStep(qty, i.BasePrecision)
+}
+
+// FloorQuote rounds a quote amount down to the quote precision
+func (i InstrumentInfo) FloorQuote(amount float64) float64 {
+	return floorToStep(amount, i.QuotePrecision)
+}
+
+// RoundPrice rounds a price to the nearest tick
+func (i InstrumentInfo) RoundPrice(price float64) float64 {
+	if i.TickSize <= 0 {
+		return price
+	}
+	return roundDecimals(math.Round(price/i.TickSize)*i.TickSize, stepDecimals(i.TickSize))
+}
+
+// LimitPrice rounds a limit price away from the market: buys down, sells up,
+// so rounding never turns a passive order into a marketable one
+func (i InstrumentInfo) LimitPrice(side OrderSide, price float64) float64 {
+	if i.TickSize <= 0 {
+		return price
+	}
+	ticks := price / i.TickSize
+	if side == OrderSideBuy {
+		ticks = math.Floor(ticks + stepEpsilon)
+	} else {
+		ticks = math.Ceil(ticks - stepEpsilon)
+	}
+	return roundDecimals(ticks*i.TickSize, stepDecimals(i.TickSize))
+}
+
+func (i InstrumentInfo) FormatQty(qty float64) string {
+	return formatStep(i.FloorQty(qty), i.BasePrecision)
+}
+
+func (i InstrumentInfo) FormatQuote(amount float64) string {
+	return formatStep(i.FloorQuote(amount), i.QuotePrecision)
+}
+
+func (i InstrumentInfo) FormatPrice(price float64) string {
+	return formatStep(i.RoundPrice(price), i.TickSize)
+}
+
+// ValidateQty checks a base quantity against the lot size. The notional is checked as well when price is known.
+func (i InstrumentInfo) ValidateQty(qty float64, price float64) error {
+	if qty <= 0 || (i.MinOrderQty > 0 && qty < i.MinOrderQty) {
+		return fmt.Errorf("%w: %s qty %v < %v", ErrOrderBelowMinimum, i.Symbol, qty, i.MinOrderQty)
+	}
+	if i.MaxOrderQty > 0 && qty > i.MaxOrderQty {
+		return fmt.Errorf("%w: %s qty %v > %v", ErrOrderAboveMaximum, i.Symbol, qty, i.MaxOrderQty)
+	}
+	if price > 0 {
+		return i.ValidateNotional(qty * price)
+	}
+	return nil
+}
+
+// ValidateNotional checks the quote value of an order
+func (i InstrumentInfo) ValidateNotional(amount float64) error {
+	if amount <= 0 || (i.MinOrderAmt > 0 && amount < i.MinOrderAmt) {
+		return fmt.Errorf("%w: %s notional %v < %v", ErrOrderBelowMinimum, i.Symbol, amount, i.MinOrderAmt)
+	}
+	if i.MaxOrderAmt > 0 && amount > i.MaxOrderAmt {
+		return fmt.Errorf("%w: %s notional %v > %v", ErrOrderAboveMaximum, i.Symbol, amount, i.MaxOrderAmt)
+	}
+	return nil
+}
+
+// stepEpsilon keeps values that are a whole number of steps from being rounded one step off by float error
+const stepEpsilon = 1e-9
+
+func floorToStep(value float64, step float64) float64 {
+	if step <= 0 {
+		return value
+	}
+	return roundDecimals(math.Floor(value/step+stepEpsilon)*step, stepDecimals(step))
+}
+
+func formatStep(value float64, step float64) string {
+	if step <= 0 {
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	}
+	return strconv.FormatFloat(value, 'f', stepDecimals(step), 64)
+}
+
+// stepDecimals returns the number of decimals of a step, f.e. 3 for 0.001
+func stepDecimals(step float64) int {
+	s := strconv.FormatFloat(step, 'f', -1, 64)
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		return len(s) - i - 1
+	}
+	return 0
+}
+
+func roundDecimals(value float64, decimals int) float64 {
+	scale := math.Pow10(decimals)
+	return math.Round(value*scale) / scale
+}
@@ -0,0 +1,12 @@
+package exchange
+
+import "cb_grok/pkg/models"
+
+// Kline is a candle update received from a market data stream
+type Kline struct {
+	Symbol    string
+	Timeframe Timeframe
+	models.OHLCV
+	// Confirm is set once the candle is closed. Until then the same candle is sent again on every update
+	Confirm bool
+}
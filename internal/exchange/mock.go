@@ -3,19 +3,42 @@ package exchange
 import (
 	"cb_grok/internal/order/model"
 	"cb_grok/pkg/models"
+	"context"
+	"time"
 )
 
 type mock struct {
+	history  []models.OHLCV
+	feed     []models.OHLCV
+	interval time.Duration
 }
 
 func NewMockExchange() Exchange {
 	return &mock{}
 }
+
+// NewMockMarketExchange returns a mock that serves history from FetchSpotOHLCV and streams
+// the feed candles from SubscribeKlines one per interval, so it can drive the live trader
+func NewMockMarketExchange(history []models.OHLCV, feed []models.OHLCV, interval time.Duration) Exchange {
+	return &mock{
+		history:  history,
+		feed:     feed,
+		interval: interval,
+	}
+}
+
 func (m *mock) Name() string {
 	return "mock"
 }
 
+func (m *mock) Account() string {
+	return "mock"
+}
+
 func (m *mock) FetchSpotOHLCV(symbol string, timeframe Timeframe, total int) ([]models.OHLCV, error) {
+	if len(m.history) > 0 {
+		return append([]models.OHLCV(nil), m.history[max(len(m.history)-total, 0):]...), nil
+	}
 	return []models.OHLCV{
 		{
 			Timestamp: 0,
@@ -27,9 +50,69 @@ func (m *mock) FetchSpotOHLCV(symbol string, timeframe Timeframe, total int) ([]
 		},
 	}, nil
 }
-func (m *mock) PlaceSpotMarketOrder(symbol string, orderSide OrderSide, baseQty float64, takeProfit *float64, stopLoss *float64, precision int64) (string, error) {
+
+// FetchSpotOHLCVRange serves the history candles of the range
+func (m *mock) FetchSpotOHLCVRange(symbol string, timeframe Timeframe, start, end int64) (*CandleRange, error) {
+	return FetchRange(func(start, end int64, limit int) ([]models.OHLCV, error) {
+		var candles []models.OHLCV
+		for _, candle := range m.history {
+			if candle.Timestamp >= start && candle.Timestamp <= end && len(candles) < limit {
+				candles = append(candles, candle)
+			}
+		}
+		return candles, nil
+	}, timeframe, start, end, len(m.history)+1)
+}
+
+// SubscribeKlines sends every feed candle as an update in progress and then as a closed candle.
+// The channel is closed once the feed is exhausted or ctx is done.
+func (m *mock) SubscribeKlines(ctx context.Context, symbol string, timeframe Timeframe) (<-chan Kline, error) {
+	out := make(chan Kline)
+	go func() {
+		defer close(out)
+		for _, candle := range m.feed {
+			if m.interval > 0 {
+				select {
+				case <-time.After(m.interval):
+				case <-ctx.Done():
+					return
+				}
+			}
+			for _, confirm := range []bool{false, true} {
+				select {
+				case out <- Kline{Symbol: symbol, Timeframe: timeframe, OHLCV: candle, Confirm: confirm}:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return out, nil
+}
+
+func (m *mock) GetInstrumentInfo(symbol string) (*InstrumentInfo, error) {
+	return &InstrumentInfo{
+		Symbol:         symbol,
+		BasePrecision:  0.000001,
+		QuotePrecision: 0.00000001,
+		TickSize:       0.01,
+	}, nil
+}
+func (m *mock) PlaceSpotMarketOrder(symbol string, orderSide OrderSide, baseQty float64, takeProfit *float64, stopLoss *float64) (string, error) {
 	return "mock-order-id", nil
 }
+func (m *mock) PlaceSpotConditionalOrder(symbol string, orderSide OrderSide, baseQty float64, triggerPrice float64) (string, error) {
+	return "mock-conditional-order-id", nil
+}
+func (m *mock) PlaceSpotLimitOrder(symbol string, orderSide OrderSide, baseQty float64, price float64, timeInForce TimeInForce) (string, error) {
+	return "mock-limit-order-id", nil
+}
+func (m *mock) CancelOrder(symbol string, orderId string) error {
+	return nil
+}
+func (m *mock) AmendOrder(symbol string, orderId string, baseQty *float64, price *float64) error {
+	return nil
+}
 func (m *mock) GetOrderStatus(orderId string) (order_model.OrderStatus, error) {
 	return order_model.OrderStatusFilled, nil
 }
@@ -37,6 +120,10 @@ func (m *mock) GetAvailableSpotWalletBalance(coin string) (float64, error) {
 	return 1000.0, nil
 }
 
+func (m *mock) GetOrderFills(orderId string) ([]Fill, error) {
+	return nil, nil
+}
+
 func (m *mock) GetOrderQuoteQty(orderId string) (float64, error) {
 	//TODO implement me
 	panic("implement me")
@@ -0,0 +1,213 @@
+package exchange
+
+import (
+	"cb_grok/internal/timeframe"
+	"cb_grok/pkg/models"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// CandleRange holds the closed candles of a time range and the candles the exchange did not return
+type CandleRange struct {
+	Candles []models.OHLCV
+	Gaps    []Gap
+}
+
+// Gap is a run of missing candles, Start and End are the open times of the first and the last of them
+type Gap struct {
+	Start int64
+	End   int64
+}
+
+// Missing returns the number of missing candles
+func (r CandleRange) Missing(t Timeframe) int {
+	tf, err := t.Parse()
+	if err != nil {
+		return 0
+	}
+	missing := 0
+	for _, gap := range r.Gaps {
+		for open := gap.Start; open <= gap.End; open = tf.Next(open) {
+			missing++
+		}
+	}
+	return missing
+}
+
+// KlinePage requests the candles opened in [start, end], at most limit of them, in any order
+type KlinePage func(start, end int64, limit int) ([]models.OHLCV, error)
+
+// FetchRange pages forward through the candles opened in [start, end) in windows of limit candles.
+// Windows are derived from the range rather than from the returned candles, so gaps do not shift
+// the pagination and the same range always issues the same requests. Candles that have not closed
+// yet are left out, missing candles are reported as gaps.
+func FetchRange(page KlinePage, t Timeframe, start, end int64, limit int) (*CandleRange, error) {
+	return fetchRange(page, t, start, end, limit, time.Now().UnixMilli())
+}
+
+// fetchRange is FetchRange with the candles closed by now
+func fetchRange(page KlinePage, t Timeframe, start, end int64, limit int, now int64) (*CandleRange, error) {
+	if end <= start {
+		return nil, fmt.Errorf("invalid range: end %d is not after start %d", end, start)
+	}
+	tf, err := t.Parse()
+	if err != nil {
+		return nil, err
+	}
+	first := firstOpen(tf, start)
+
+	candles, err := fetchWindows(page, tf, first, end, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	closed := candles[:0]
+	for _, candle := range candles {
+		if candle.Timestamp >= first && candle.Timestamp < end && tf.Next(candle.Timestamp) <= now {
+			closed = append(closed, candle)
+		}
+	}
+
+	return &CandleRange{Candles: closed, Gaps: findGaps(tf, closed, first, end, now)}, nil
+}
+
+// FindGaps returns the runs of closed candles opened in [start, end) that are absent from candles,
+// which must be sorted ascending
+func FindGaps(t Timeframe, candles []models.OHLCV, start, end int64) ([]Gap, error) {
+	tf, err := t.Parse()
+	if err != nil {
+		return nil, err
+	}
+	return findGaps(tf, candles, firstOpen(tf, start), end, time.Now().UnixMilli()), nil
+}
+
+// findGaps returns the gaps of the candles opened in [first, end) and closed by now
+func findGaps(tf timeframe.Timeframe, candles []models.OHLCV, first, end int64, now int64) []Gap {
+	var gaps []Gap
+	i := 0
+	for open := first; open < end && tf.Next(open) <= now; open = tf.Next(open) {
+		for i < len(candles) && candles[i].Timestamp < open {
+			i++
+		}
+		if i < len(candles) && candles[i].Timestamp == open {
+			i++
+			continue
+		}
+		if n := len(gaps); n > 0 && tf.Next(gaps[n-1].End) == open {
+			gaps[n-1].End = open
+		} else {
+			gaps = append(gaps, Gap{Start: open, End: open})
+		}
+	}
+
+	return gaps
+}
+
+// LastClosedRange returns the range [start, end) holding the last total closed candles
+func LastClosedRange(t Timeframe, total int) (int64, int64, error) {
+	return lastClosedRange(t, total, time.Now().UnixMilli())
+}
+
+// lastClosedRange is LastClosedRange with the candles closed by now
+func lastClosedRange(t Timeframe, total int, now int64) (int64, int64, error) {
+	tf, err := t.Parse()
+	if err != nil {
+		return 0, 0, err
+	}
+	end := tf.Open(now)
+	start := end
+	for i := 0; i < total; i++ {
+		start = tf.Prev(start)
+	}
+	return start, end, nil
+}
+
+// FetchLast returns the last total candles including the forming one, sorted ascending
+func FetchLast(page KlinePage, t Timeframe, total int, limit int) ([]models.OHLCV, error) {
+	return fetchLast(page, t, total, limit, time.Now().UnixMilli())
+}
+
+// fetchLast is FetchLast with the candle forming at now as the last one
+func fetchLast(page KlinePage, t Timeframe, total int, limit int, now int64) ([]models.OHLCV, error) {
+	if total <= 0 {
+		return nil, nil
+	}
+	tf, err := t.Parse()
+	if err != nil {
+		return nil, err
+	}
+	last := tf.Open(now)
+	first := last
+	for i := 1; i < total; i++ {
+		first = tf.Prev(first)
+	}
+
+	candles, err := fetchWindows(page, tf, first, last+1, limit)
+	if err != nil {
+		return nil, err
+	}
+	if len(candles) > total {
+		candles = candles[len(candles)-total:]
+	}
+	return candles, nil
+}
+
+// fetchWindows requests [first, end) window by window and returns the candles sorted and deduplicated
+func fetchWindows(page KlinePage, tf timeframe.Timeframe, first, end int64, limit int) ([]models.OHLCV, error) {
+	var candles []models.OHLCV
+	for cursor := first; cursor < end; {
+		windowEnd := cursor
+		for i := 1; i < limit && tf.Next(windowEnd) < end; i++ {
+			windowEnd = tf.Next(windowEnd)
+		}
+
+		batch, err := page(cursor, windowEnd, limit)
+		if err != nil {
+			return nil, err
+		}
+		candles = append(candles, batch...)
+
+		cursor = tf.Next(windowEnd)
+	}
+
+	sort.Slice(candles, func(i, j int) bool {
+		return candles[i].Timestamp < candles[j].Timestamp
+	})
+
+	// deduplication
+	if len(candles) > 1 {
+		uniqueCandles := []models.OHLCV{candles[0]}
+		for i := 1; i < len(candles); i++ {
+			if candles[i].Timestamp != candles[i-1].Timestamp {
+				uniqueCandles = append(uniqueCandles, candles[i])
+			}
+		}
+		candles = uniqueCandles
+	}
+
+	return candles, nil
+}
+
+// Parse returns the calendar-aware timeframe, custom ones like 2h or 3d included
+func (t Timeframe) Parse() (timeframe.Timeframe, error) {
+	return timeframe.Parse(string(t))
+}
+
+// Native reports whether the timeframe is one of the declared exchange timeframes
+func (t Timeframe) Native() bool {
+	switch t {
+	case Timeframe1m, Timeframe5m, Timeframe15m, Timeframe30m, Timeframe1h, Timeframe4h, Timeframe1d, Timeframe1w, Timeframe1M:
+		return true
+	}
+	return false
+}
+
+// firstOpen returns the open time of the first candle opened at or after ts
+func firstOpen(tf timeframe.Timeframe, ts int64) int64 {
+	open := tf.Open(ts)
+	if open < ts {
+		open = tf.Next(open)
+	}
+	return open
+}
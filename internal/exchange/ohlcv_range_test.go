@@ -0,0 +1,291 @@
+package exchange
+
+import (
+	"cb_grok/pkg/models"
+	"errors"
+	"sort"
+	"testing"
+	"time"
+)
+
+var rangeStart = time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC).UnixMilli()
+
+// hour returns the open time of the i-th hourly candle of the range
+func hour(i int) int64 {
+	return rangeStart + int64(i)*time.Hour.Milliseconds()
+}
+
+// pagedExchange serves hourly candles the way Bybit does: the newest limit candles opened in
+// [start, end], newest first. Candles in missing are never returned.
+type pagedExchange struct {
+	candles  []models.OHLCV
+	requests [][2]int64
+}
+
+func newPagedExchange(n int, missing ...int) *pagedExchange {
+	skip := make(map[int]bool, len(missing))
+	for _, i := range missing {
+		skip[i] = true
+	}
+	e := &pagedExchange{}
+	for i := 0; i < n; i++ {
+		if !skip[i] {
+			e.candles = append(e.candles, models.OHLCV{Timestamp: hour(i), Close: float64(i)})
+		}
+	}
+	return e
+}
+
+func (e *pagedExchange) page(start, end int64, limit int) ([]models.OHLCV, error) {
+	e.requests = append(e.requests, [2]int64{start, end})
+	var batch []models.OHLCV
+	for _, c := range e.candles {
+		if c.Timestamp >= start && c.Timestamp <= end {
+			batch = append(batch, c)
+		}
+	}
+	if len(batch) > limit {
+		batch = batch[len(batch)-limit:]
+	}
+	sort.Slice(batch, func(i, j int) bool { return batch[i].Timestamp > batch[j].Timestamp })
+	return batch, nil
+}
+
+// hourOf is the inverse of hour
+func hourOf(ts int64) int {
+	return int((ts - rangeStart) / time.Hour.Milliseconds())
+}
+
+// openHours returns the hours of the candles
+func openHours(candles []models.OHLCV) []int {
+	hours := make([]int, len(candles))
+	for i, c := range candles {
+		hours[i] = hourOf(c.Timestamp)
+	}
+	return hours
+}
+
+func hourRange(from, to int) []int {
+	var hours []int
+	for i := from; i <= to; i++ {
+		hours = append(hours, i)
+	}
+	return hours
+}
+
+func sameInts(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestFetchRange(t *testing.T) {
+	tests := []struct {
+		name         string
+		exchange     *pagedExchange
+		start, end   int64
+		now          int64
+		limit        int
+		wantCandles  []int
+		wantGaps     [][2]int
+		wantRequests [][2]int
+	}{
+		{
+			name:         "pages at the limit",
+			exchange:     newPagedExchange(25),
+			start:        hour(0),
+			end:          hour(25),
+			now:          hour(100),
+			limit:        10,
+			wantCandles:  hourRange(0, 24),
+			wantRequests: [][2]int{{0, 9}, {10, 19}, {20, 24}},
+		},
+		{
+			// the windows do not shift around the holes, the holes on both sides of a page boundary form one gap
+			name:         "holes are reported as gaps",
+			exchange:     newPagedExchange(25, 0, 9, 10, 17),
+			start:        hour(0),
+			end:          hour(25),
+			now:          hour(100),
+			limit:        10,
+			wantCandles:  []int{1, 2, 3, 4, 5, 6, 7, 8, 11, 12, 13, 14, 15, 16, 18, 19, 20, 21, 22, 23, 24},
+			wantGaps:     [][2]int{{0, 0}, {9, 10}, {17, 17}},
+			wantRequests: [][2]int{{0, 9}, {10, 19}, {20, 24}},
+		},
+		{
+			name:         "a missing page is one gap",
+			exchange:     newPagedExchange(30, hourRange(10, 19)...),
+			start:        hour(0),
+			end:          hour(30),
+			now:          hour(100),
+			limit:        10,
+			wantCandles:  append(hourRange(0, 9), hourRange(20, 29)...),
+			wantGaps:     [][2]int{{10, 19}},
+			wantRequests: [][2]int{{0, 9}, {10, 19}, {20, 29}},
+		},
+		{
+			name:         "the range starts at the next open",
+			exchange:     newPagedExchange(10),
+			start:        hour(2) + time.Minute.Milliseconds(),
+			end:          hour(8),
+			now:          hour(100),
+			limit:        4,
+			wantCandles:  hourRange(3, 7),
+			wantRequests: [][2]int{{3, 6}, {7, 7}},
+		},
+		{
+			// the exchange already has the forming candle 20, the candles after it are not gaps
+			name:         "the forming candle is left out",
+			exchange:     newPagedExchange(21),
+			start:        hour(0),
+			end:          hour(25),
+			now:          hour(20) + 30*time.Minute.Milliseconds(),
+			limit:        10,
+			wantCandles:  hourRange(0, 19),
+			wantRequests: [][2]int{{0, 9}, {10, 19}, {20, 24}},
+		},
+		{
+			name:        "the candle closing at now is closed",
+			exchange:    newPagedExchange(21, 19),
+			start:       hour(0),
+			end:         hour(25),
+			now:         hour(20),
+			limit:       10,
+			wantCandles: hourRange(0, 18),
+			wantGaps:    [][2]int{{19, 19}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := fetchRange(tt.exchange.page, Timeframe1h, tt.start, tt.end, tt.limit, tt.now)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := openHours(result.Candles); !sameInts(got, tt.wantCandles) {
+				t.Errorf("candles = %v, want %v", got, tt.wantCandles)
+			}
+
+			var gaps [][2]int
+			wantMissing := 0
+			for _, gap := range result.Gaps {
+				gaps = append(gaps, [2]int{hourOf(gap.Start), hourOf(gap.End)})
+			}
+			for _, gap := range tt.wantGaps {
+				wantMissing += gap[1] - gap[0] + 1
+			}
+			if len(gaps) != len(tt.wantGaps) {
+				t.Fatalf("gaps = %v, want %v", gaps, tt.wantGaps)
+			}
+			for i := range gaps {
+				if gaps[i] != tt.wantGaps[i] {
+					t.Fatalf("gaps = %v, want %v", gaps, tt.wantGaps)
+				}
+			}
+			if missing := result.Missing(Timeframe1h); missing != wantMissing {
+				t.Errorf("missing = %d, want %d", missing, wantMissing)
+			}
+
+			if tt.wantRequests == nil {
+				return
+			}
+			if len(tt.exchange.requests) != len(tt.wantRequests) {
+				t.Fatalf("requests = %d, want %v", len(tt.exchange.requests), tt.wantRequests)
+			}
+			for i, want := range tt.wantRequests {
+				if got := tt.exchange.requests[i]; got != [2]int64{hour(want[0]), hour(want[1])} {
+					t.Errorf("request %d = hours [%d, %d], want %v", i, hourOf(got[0]), hourOf(got[1]), want)
+				}
+			}
+		})
+	}
+}
+
+func TestFetchRangeErrors(t *testing.T) {
+	if _, err := fetchRange(newPagedExchange(10).page, Timeframe1h, hour(5), hour(5), 10, hour(100)); err == nil {
+		t.Error("empty range accepted")
+	}
+
+	failure := errors.New("rate limited")
+	calls := 0
+	page := func(start, end int64, limit int) ([]models.OHLCV, error) {
+		if calls++; calls == 2 {
+			return nil, failure
+		}
+		return newPagedExchange(30).page(start, end, limit)
+	}
+	if _, err := fetchRange(page, Timeframe1h, hour(0), hour(30), 10, hour(100)); !errors.Is(err, failure) {
+		t.Errorf("err = %v, want the page error", err)
+	}
+}
+
+func TestLastClosedRange(t *testing.T) {
+	tests := []struct {
+		name       string
+		tf         Timeframe
+		total      int
+		now        time.Time
+		start, end time.Time
+	}{
+		{
+			name: "hours", tf: Timeframe1h, total: 3,
+			now:   time.Date(2025, 1, 1, 5, 30, 0, 0, time.UTC),
+			start: time.Date(2025, 1, 1, 2, 0, 0, 0, time.UTC), end: time.Date(2025, 1, 1, 5, 0, 0, 0, time.UTC),
+		},
+		{
+			// the candle opened at now is forming
+			name: "now at an open", tf: Timeframe1h, total: 3,
+			now:   time.Date(2025, 1, 1, 5, 0, 0, 0, time.UTC),
+			start: time.Date(2025, 1, 1, 2, 0, 0, 0, time.UTC), end: time.Date(2025, 1, 1, 5, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "no candles", tf: Timeframe1h, total: 0,
+			now:   time.Date(2025, 1, 1, 5, 30, 0, 0, time.UTC),
+			start: time.Date(2025, 1, 1, 5, 0, 0, 0, time.UTC), end: time.Date(2025, 1, 1, 5, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "weeks across the year", tf: Timeframe1w, total: 2,
+			now:   time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC),
+			start: time.Date(2024, 12, 16, 0, 0, 0, 0, time.UTC), end: time.Date(2024, 12, 30, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "months", tf: Timeframe1M, total: 2,
+			now:   time.Date(2025, 3, 15, 0, 0, 0, 0, time.UTC),
+			start: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC), end: time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start, end, err := lastClosedRange(tt.tf, tt.total, tt.now.UnixMilli())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if start != tt.start.UnixMilli() || end != tt.end.UnixMilli() {
+				t.Errorf("range = [%s, %s), want [%s, %s)",
+					time.UnixMilli(start).UTC(), time.UnixMilli(end).UTC(), tt.start, tt.end)
+			}
+		})
+	}
+}
+
+func TestFetchLast(t *testing.T) {
+	exchange := newPagedExchange(25, 20)
+
+	// the last 12 candles include the forming one, the hole is not filled from older candles
+	candles, err := fetchLast(exchange.page, Timeframe1h, 12, 5, hour(24)+30*time.Minute.Milliseconds())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []int{13, 14, 15, 16, 17, 18, 19, 21, 22, 23, 24}; !sameInts(openHours(candles), want) {
+		t.Errorf("candles = %v, want %v", openHours(candles), want)
+	}
+	if len(exchange.requests) != 3 {
+		t.Errorf("requests = %d, want 3 pages of 5", len(exchange.requests))
+	}
+}
@@ -0,0 +1,55 @@
+// Package venue builds exchange adapters by name, so commands can switch between venues with a flag
+package venue
+
+import (
+	"cb_grok/config"
+	"cb_grok/internal/exchange"
+	"cb_grok/internal/exchange/binance"
+	"cb_grok/internal/exchange/bybit"
+	"fmt"
+)
+
+const (
+	Bybit   = "bybit"
+	Binance = "binance"
+)
+
+// Names lists the supported venues
+func Names() []string {
+	return []string{Bybit, Binance}
+}
+
+// New returns the adapter of the venue with the credentials from the config
+func New(name string, cfg *config.Config, tradingMode exchange.TradingMode) (exchange.Exchange, error) {
+	switch name {
+	case "", Bybit:
+		var opts []bybit.Option
+		if cfg.Bybit.BaseURL != "" {
+			opts = append(opts, bybit.WithBaseURL(cfg.Bybit.BaseURL))
+		}
+		if cfg.Bybit.PublicWSURL != "" {
+			opts = append(opts, bybit.WithPublicWSURL(cfg.Bybit.PublicWSURL))
+		}
+		if cfg.Bybit.PrivateWSURL != "" {
+			opts = append(opts, bybit.WithPrivateWSURL(cfg.Bybit.PrivateWSURL))
+		}
+		return bybit.NewBybit(cfg.Bybit.APIKey, cfg.Bybit.APISecret, tradingMode, opts...)
+	case Binance:
+		return binance.NewBinance(cfg.Binance.APIKey, cfg.Binance.APISecret, tradingMode)
+	default:
+		return nil, fmt.Errorf("unknown exchange %q", name)
+	}
+}
+
+// NewPublic returns the adapter of the venue for market data only
+func NewPublic(name string) (exchange.Exchange, error) {
+	return New(name, &config.Config{}, exchange.TradingModeLive)
+}
+
+// SandboxMode is the trading mode demo traders run in: bybit demo trading or the Binance spot testnet
+func SandboxMode(name string) exchange.TradingMode {
+	if name == Binance {
+		return exchange.TradingModeTestnet
+	}
+	return exchange.TradingModeDemo
+}
@@ -0,0 +1,104 @@
+package exchange
+
+import (
+	"context"
+	"github.com/gorilla/websocket"
+	"go.uber.org/zap"
+	"time"
+)
+
+const (
+	wsReconnectMinWait = time.Second
+	wsReconnectMaxWait = 30 * time.Second
+)
+
+// WSStream keeps a websocket stream open until its context is done, reconnecting with a backoff.
+// Venues provide the subscription handshake and the decoding of the messages.
+type WSStream struct {
+	// Name prefixes the log messages, f.e. "bybit: kline stream"
+	Name   string
+	Logger *zap.Logger
+	// Connect dials the stream and subscribes to it
+	Connect func(ctx context.Context) (*websocket.Conn, error)
+	// Handle decodes a received message, it is called from the reading goroutine only
+	Handle func(ctx context.Context, message []byte)
+	// Reconnected runs once the stream is back after a disconnect, f.e. to backfill missed messages
+	Reconnected func(ctx context.Context)
+	// ReadTimeout breaks a connection that received nothing, pings and pongs included, for that long
+	ReadTimeout time.Duration
+	// Ping is sent as JSON every PingInterval, venues whose server pings leave it nil
+	Ping         interface{}
+	PingInterval time.Duration
+}
+
+// Run reads conn and the connections that replace it until ctx is done
+func (s *WSStream) Run(ctx context.Context, conn *websocket.Conn) {
+	wait := wsReconnectMinWait
+	for {
+		err := s.read(ctx, conn)
+		_ = conn.Close()
+		if ctx.Err() != nil {
+			s.Logger.Info(s.Name + " closed")
+			return
+		}
+		s.Logger.Warn(s.Name+" disconnected", zap.Error(err))
+
+		for {
+			select {
+			case <-ctx.Done():
+				s.Logger.Info(s.Name + " closed")
+				return
+			case <-time.After(wait):
+			}
+			conn, err = s.Connect(ctx)
+			if err == nil {
+				break
+			}
+			s.Logger.Error(s.Name+" reconnect", zap.Error(err))
+			wait = min(wait*2, wsReconnectMaxWait)
+		}
+		wait = wsReconnectMinWait
+		s.Logger.Info(s.Name + " reconnected")
+
+		if s.Reconnected != nil {
+			s.Reconnected(ctx)
+		}
+	}
+}
+
+// read hands messages to Handle until the connection fails or ctx is done
+func (s *WSStream) read(ctx context.Context, conn *websocket.Conn) error {
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		var ping <-chan time.Time
+		if s.Ping != nil {
+			ticker := time.NewTicker(s.PingInterval)
+			defer ticker.Stop()
+			ping = ticker.C
+		}
+		for {
+			select {
+			case <-done:
+				return
+			case <-ctx.Done():
+				// unblocks the pending read
+				_ = conn.Close()
+				return
+			case <-ping:
+				if err := conn.WriteJSON(s.Ping); err != nil {
+					return
+				}
+			}
+		}
+	}()
+
+	for {
+		_ = conn.SetReadDeadline(time.Now().Add(s.ReadTimeout))
+		_, message, err := conn.ReadMessage()
+		if err != nil {
+			return err
+		}
+		s.Handle(ctx, message)
+	}
+}
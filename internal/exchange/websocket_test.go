@@ -0,0 +1,97 @@
+package exchange
+
+import (
+	"context"
+	"github.com/gorilla/websocket"
+	"go.uber.org/zap"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// wsTestServer sends the number of the connection and closes every connection but the third
+func wsTestServer(t *testing.T) (string, *atomic.Int32) {
+	t.Helper()
+	var connections atomic.Int32
+	upgrader := websocket.Upgrader{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		n := connections.Add(1)
+		_ = conn.WriteMessage(websocket.TextMessage, []byte{byte('0' + n)})
+		if n < 3 {
+			return
+		}
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return "ws" + strings.TrimPrefix(srv.URL, "http"), &connections
+}
+
+func TestWSStreamReconnectsUntilCanceled(t *testing.T) {
+	url, connections := wsTestServer(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	messages := make(chan string, 10)
+	var reconnects atomic.Int32
+	s := &WSStream{
+		Name:   "test stream",
+		Logger: zap.NewNop(),
+		Connect: func(ctx context.Context) (*websocket.Conn, error) {
+			conn, _, err := websocket.DefaultDialer.DialContext(ctx, url, nil)
+			return conn, err
+		},
+		Handle: func(ctx context.Context, message []byte) {
+			messages <- string(message)
+		},
+		Reconnected: func(ctx context.Context) {
+			reconnects.Add(1)
+		},
+		ReadTimeout: time.Minute,
+	}
+
+	conn, err := s.Connect(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		s.Run(ctx, conn)
+	}()
+
+	for _, want := range []string{"1", "2", "3"} {
+		select {
+		case got := <-messages:
+			if got != want {
+				t.Fatalf("message = %s, want %s", got, want)
+			}
+		case <-time.After(10 * time.Second):
+			t.Fatalf("no message %s", want)
+		}
+	}
+	if got := reconnects.Load(); got != 2 {
+		t.Errorf("reconnects = %d, want 2", got)
+	}
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("stream not stopped by its context")
+	}
+	if got := connections.Load(); got != 3 {
+		t.Errorf("connections = %d, want 3", got)
+	}
+}
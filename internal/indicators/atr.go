@@ -3,6 +3,7 @@ package indicators
 import (
 	"cb_grok/pkg/models"
 	"github.com/cinar/indicator"
+	"math"
 )
 
 func CalculateATR(candles []models.OHLCV, period int) []float64 {
@@ -17,3 +18,19 @@ func CalculateATR(candles []models.OHLCV, period int) []float64 {
 	_, atr := indicator.Atr(period, highs, lows, closes) // Игнорируем tr
 	return atr
 }
+
+// ATR is the average true range updated one candle at a time. Like CalculateATR it averages the range
+// of each candle alone, without the previous close.
+type ATR struct {
+	average *movingAverage
+}
+
+func NewATR(period int) *ATR {
+	return &ATR{average: newMovingAverage(period)}
+}
+
+// Update adds a candle and returns the average range
+func (a *ATR) Update(candle models.OHLCV) float64 {
+	tr := math.Max(candle.High-candle.Low, math.Max(candle.High-candle.Close, candle.Close-candle.Low))
+	return a.average.update(tr)
+}
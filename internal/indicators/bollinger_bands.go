@@ -55,3 +55,38 @@ func CalculateBollingerBands(candles []models.OHLCV, period int, stdDevMultiplie
 	return upper, middle, lower
 
 }
+
+// BollingerBands are the bands of closes updated one candle at a time. The middle band matches
+// CalculateBollingerBands, the bands are NaN until period candles were seen.
+type BollingerBands struct {
+	stdDevMultiplier float64
+	middle           *movingAverage
+	closes           *window
+}
+
+func NewBollingerBands(period int, stdDevMultiplier float64) *BollingerBands {
+	return &BollingerBands{
+		stdDevMultiplier: stdDevMultiplier,
+		middle:           newMovingAverage(period),
+		closes:           newWindow(period),
+	}
+}
+
+// Update adds a candle and returns the upper, middle and lower bands
+func (b *BollingerBands) Update(candle models.OHLCV) (float64, float64, float64) {
+	middle := b.middle.update(candle.Close)
+	b.closes.push(candle.Close)
+	if !b.closes.full() {
+		return math.NaN(), middle, math.NaN()
+	}
+
+	n := float64(len(b.closes.values))
+	mean := b.closes.sum / n
+	variance := (b.closes.squares / n) - (mean * mean)
+	if variance < 0 {
+		variance = 0
+	}
+	stdDev := math.Sqrt(variance)
+
+	return middle + b.stdDevMultiplier*stdDev, middle, middle - b.stdDevMultiplier*stdDev
+}
@@ -13,3 +13,39 @@ func CalculateEMA(candles []models.OHLCV, period int) []float64 {
 	ema := indicator.Ema(period, closes)
 	return ema
 }
+
+// EMA is the exponential moving average of closes updated one candle at a time.
+// Like CalculateEMA it starts at the first close.
+type EMA struct {
+	average expAverage
+}
+
+func NewEMA(period int) *EMA {
+	return &EMA{average: newExpAverage(period)}
+}
+
+// Update adds a candle and returns the average
+func (e *EMA) Update(candle models.OHLCV) float64 {
+	return e.average.update(candle.Close)
+}
+
+// expAverage is indicator.Ema over a stream of values
+type expAverage struct {
+	k       float64
+	value   float64
+	started bool
+}
+
+func newExpAverage(period int) expAverage {
+	return expAverage{k: float64(2) / float64(1+period)}
+}
+
+func (e *expAverage) update(value float64) float64 {
+	if !e.started {
+		e.value = value
+		e.started = true
+		return e.value
+	}
+	e.value = (value * e.k) + (e.value * float64(1-e.k))
+	return e.value
+}
@@ -0,0 +1,156 @@
+package indicators_test
+
+import (
+	"cb_grok/internal/indicators"
+	"cb_grok/pkg/models"
+	"math"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+// testCandles returns a fixed random walk of 1m candles
+func testCandles(n int) []models.OHLCV {
+	rnd := rand.New(rand.NewSource(7))
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC).UnixMilli()
+
+	candles := make([]models.OHLCV, n)
+	price := 40000.0
+	for i := range candles {
+		open := price
+		price *= math.Exp(rnd.NormFloat64() * 0.002)
+		wick := price * 0.001 * rnd.Float64()
+		candles[i] = models.OHLCV{
+			Timestamp: start + int64(i)*time.Minute.Milliseconds(),
+			Open:      open,
+			High:      math.Max(open, price) + wick,
+			Low:       math.Min(open, price) - wick,
+			Close:     price,
+			Volume:    1 + rnd.Float64()*100,
+		}
+	}
+	// a flat stretch gives a zero range to the stochastic oscillator
+	flat := candles[n-200].Close
+	for i := n - 200; i < n-180; i++ {
+		candles[i].Open, candles[i].High, candles[i].Low, candles[i].Close = flat, flat, flat, flat
+	}
+	return candles
+}
+
+// equal compares with a tolerance relative to the magnitude, NaN equals NaN
+func equal(a, b float64) bool {
+	if math.IsNaN(a) || math.IsNaN(b) {
+		return math.IsNaN(a) && math.IsNaN(b)
+	}
+	return math.Abs(a-b) <= 1e-9*math.Max(1, math.Max(math.Abs(a), math.Abs(b)))
+}
+
+func TestStreamsMatchBatch(t *testing.T) {
+	const last = 300
+	candles := testCandles(1000)
+
+	tests := []struct {
+		name string
+		// batch returns the series of every output of the indicator over all candles
+		batch func([]models.OHLCV) [][]float64
+		// stream returns a function giving the outputs for the next candle
+		stream func() func(models.OHLCV) []float64
+	}{
+		{
+			name:  "sma",
+			batch: func(c []models.OHLCV) [][]float64 { return [][]float64{indicators.CalculateSMA(c, 20)} },
+			stream: func() func(models.OHLCV) []float64 {
+				s := indicators.NewSMA(20)
+				return func(c models.OHLCV) []float64 { return []float64{s.Update(c)} }
+			},
+		},
+		{
+			name:  "ema",
+			batch: func(c []models.OHLCV) [][]float64 { return [][]float64{indicators.CalculateEMA(c, 50)} },
+			stream: func() func(models.OHLCV) []float64 {
+				s := indicators.NewEMA(50)
+				return func(c models.OHLCV) []float64 { return []float64{s.Update(c)} }
+			},
+		},
+		{
+			name:  "rsi",
+			batch: func(c []models.OHLCV) [][]float64 { return [][]float64{indicators.CalculateRSI(c, 14)} },
+			stream: func() func(models.OHLCV) []float64 {
+				s := indicators.NewRSI(14)
+				return func(c models.OHLCV) []float64 { return []float64{s.Update(c)} }
+			},
+		},
+		{
+			name:  "atr",
+			batch: func(c []models.OHLCV) [][]float64 { return [][]float64{indicators.CalculateATR(c, 14)} },
+			stream: func() func(models.OHLCV) []float64 {
+				s := indicators.NewATR(14)
+				return func(c models.OHLCV) []float64 { return []float64{s.Update(c)} }
+			},
+		},
+		{
+			name: "macd",
+			batch: func(c []models.OHLCV) [][]float64 {
+				macd, signal := indicators.CalculateMACD(c, indicators.DefaultMACDShortPeriod, indicators.DefaultMACDLongPeriod, indicators.DefaultMACDSignalPeriod)
+				return [][]float64{macd, signal}
+			},
+			stream: func() func(models.OHLCV) []float64 {
+				s := indicators.NewMACD(indicators.DefaultMACDShortPeriod, indicators.DefaultMACDLongPeriod, indicators.DefaultMACDSignalPeriod)
+				return func(c models.OHLCV) []float64 {
+					macd, signal := s.Update(c)
+					return []float64{macd, signal}
+				}
+			},
+		},
+		{
+			name: "bollinger bands",
+			batch: func(c []models.OHLCV) [][]float64 {
+				upper, middle, lower := indicators.CalculateBollingerBands(c, 20, 2)
+				return [][]float64{upper, middle, lower}
+			},
+			stream: func() func(models.OHLCV) []float64 {
+				s := indicators.NewBollingerBands(20, 2)
+				return func(c models.OHLCV) []float64 {
+					upper, middle, lower := s.Update(c)
+					return []float64{upper, middle, lower}
+				}
+			},
+		},
+		{
+			name: "stochastic oscillator",
+			batch: func(c []models.OHLCV) [][]float64 {
+				k, d := indicators.CalculateStochasticOscillator(c, 14, 3)
+				return [][]float64{k, d}
+			},
+			stream: func() func(models.OHLCV) []float64 {
+				s := indicators.NewStochasticOscillator(14, 3)
+				return func(c models.OHLCV) []float64 {
+					k, d := s.Update(c)
+					return []float64{k, d}
+				}
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			batch := tt.batch(candles)
+			update := tt.stream()
+
+			var streamed [][]float64
+			for _, c := range candles {
+				streamed = append(streamed, update(c))
+			}
+
+			for output, series := range batch {
+				if len(series) != len(candles) {
+					t.Fatalf("output %d: batch length = %d, want %d", output, len(series), len(candles))
+				}
+				for i := len(candles) - last; i < len(candles); i++ {
+					if !equal(streamed[i][output], series[i]) {
+						t.Fatalf("output %d candle %d: stream = %v, batch = %v", output, i, streamed[i][output], series[i])
+					}
+				}
+			}
+		})
+	}
+}
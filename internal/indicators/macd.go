@@ -13,3 +13,31 @@ func CalculateMACD(candles []models.OHLCV, shortPeriod, longPeriod, signalPeriod
 	macd, signal := indicator.Macd(closes) // Возвращаем только macd и signal
 	return macd, signal
 }
+
+// The periods of indicator.Macd, which CalculateMACD uses whatever periods it is given
+const (
+	DefaultMACDShortPeriod  = 12
+	DefaultMACDLongPeriod   = 26
+	DefaultMACDSignalPeriod = 9
+)
+
+// MACD is the moving average convergence divergence of closes updated one candle at a time
+type MACD struct {
+	short  expAverage
+	long   expAverage
+	signal expAverage
+}
+
+func NewMACD(shortPeriod, longPeriod, signalPeriod int) *MACD {
+	return &MACD{
+		short:  newExpAverage(shortPeriod),
+		long:   newExpAverage(longPeriod),
+		signal: newExpAverage(signalPeriod),
+	}
+}
+
+// Update adds a candle and returns the MACD and its signal line
+func (m *MACD) Update(candle models.OHLCV) (float64, float64) {
+	macd := m.short.update(candle.Close) - m.long.update(candle.Close)
+	return macd, m.signal.update(macd)
+}
@@ -13,3 +13,53 @@ func CalculateRSI(candles []models.OHLCV, period int) []float64 {
 	_, rsi := indicator.RsiPeriod(period, closes) // Используем RsiPeriod, игнорируем rs
 	return rsi
 }
+
+// RSI is the relative strength index of closes updated one candle at a time, matching CalculateRSI
+type RSI struct {
+	gains   wilderAverage
+	losses  wilderAverage
+	prev    float64
+	started bool
+}
+
+func NewRSI(period int) *RSI {
+	return &RSI{gains: wilderAverage{period: period}, losses: wilderAverage{period: period}}
+}
+
+// Update adds a candle and returns the index
+func (r *RSI) Update(candle models.OHLCV) float64 {
+	var gain, loss float64
+	if r.started {
+		difference := candle.Close - r.prev
+		if difference > 0 {
+			gain = difference
+		} else {
+			loss = -difference
+		}
+	}
+	r.prev = candle.Close
+	r.started = true
+
+	rs := r.gains.update(gain) / r.losses.update(loss)
+	return 100 - (100 / (1 + rs))
+}
+
+// wilderAverage is indicator.Rma over a stream of values
+type wilderAverage struct {
+	period int
+	count  int
+	value  float64
+	sum    float64
+}
+
+func (w *wilderAverage) update(value float64) float64 {
+	if w.count < w.period {
+		w.sum += value
+		w.count++
+		w.value = w.sum / float64(w.count)
+		return w.value
+	}
+	w.sum = (w.value * float64(w.period-1)) + value
+	w.value = w.sum / float64(w.period)
+	return w.value
+}
@@ -30,3 +30,43 @@ func calculateSMAFromK(kValues []float64, period int) []float64 {
 	}
 	return sma
 }
+
+// SMA is the simple moving average of closes updated one candle at a time. It matches CalculateSMA,
+// averaging the candles seen while there are fewer than period.
+type SMA struct {
+	average *movingAverage
+}
+
+func NewSMA(period int) *SMA {
+	return &SMA{average: newMovingAverage(period)}
+}
+
+// Update adds a candle and returns the average
+func (s *SMA) Update(candle models.OHLCV) float64 {
+	return s.average.update(candle.Close)
+}
+
+// movingAverage is indicator.Sma over a stream of values, with the same order of operations
+type movingAverage struct {
+	values []float64
+	count  int
+	sum    float64
+}
+
+func newMovingAverage(period int) *movingAverage {
+	return &movingAverage{values: make([]float64, max(period, 1))}
+}
+
+func (m *movingAverage) update(value float64) float64 {
+	period := len(m.values)
+	i := m.count % period
+	count := m.count + 1
+	m.sum += value
+	if m.count >= period {
+		m.sum -= m.values[i]
+		count = period
+	}
+	m.values[i] = value
+	m.count++
+	return m.sum / float64(count)
+}
@@ -39,3 +39,45 @@ func CalculateStochasticOscillator(candles []models.OHLCV, kPeriod int, dPeriod
 
 	return kValues, dValues
 }
+
+// StochasticOscillator is %K and %D updated one candle at a time. Like CalculateStochasticOscillator
+// both are 0 until their periods are filled.
+type StochasticOscillator struct {
+	kPeriod int
+	count   int
+	highest *extremum
+	lowest  *extremum
+	k       *window
+}
+
+func NewStochasticOscillator(kPeriod int, dPeriod int) *StochasticOscillator {
+	return &StochasticOscillator{
+		kPeriod: kPeriod,
+		highest: newExtremum(kPeriod, true),
+		lowest:  newExtremum(kPeriod, false),
+		k:       newWindow(dPeriod),
+	}
+}
+
+// Update adds a candle and returns %K and %D
+func (s *StochasticOscillator) Update(candle models.OHLCV) (float64, float64) {
+	i := s.count
+	s.count++
+	high := s.highest.push(i, candle.High)
+	low := s.lowest.push(i, candle.Low)
+
+	k := 0.0
+	if i >= s.kPeriod-1 {
+		if high == low { // Избегаем деления на ноль
+			k = 50.0
+		} else {
+			k = (candle.Close - low) / (high - low) * 100
+		}
+	}
+
+	s.k.push(k)
+	if !s.k.full() {
+		return k, 0
+	}
+	return k, s.k.sum / float64(len(s.k.values))
+}
@@ -0,0 +1,91 @@
+package indicators
+
+// window holds the last values of a series with their running sum and sum of squares.
+// The sums are recomputed from the values once per window length to bound rounding drift.
+type window struct {
+	values    []float64
+	count     int
+	sum       float64
+	squares   float64
+	sinceSync int
+}
+
+func newWindow(size int) *window {
+	return &window{values: make([]float64, max(size, 1))}
+}
+
+func (w *window) push(value float64) {
+	size := len(w.values)
+	i := w.count % size
+	if w.count >= size {
+		old := w.values[i]
+		w.sum -= old
+		w.squares -= old * old
+	}
+	w.values[i] = value
+	w.sum += value
+	w.squares += value * value
+	w.count++
+
+	w.sinceSync++
+	if w.sinceSync >= size {
+		w.resync()
+	}
+}
+
+// full reports whether the window holds as many values as its length
+func (w *window) full() bool {
+	return w.count >= len(w.values)
+}
+
+// resync sums the values again from the oldest one
+func (w *window) resync() {
+	size := len(w.values)
+	n, start := w.count, 0
+	if w.full() {
+		n, start = size, w.count%size
+	}
+	w.sum, w.squares = 0, 0
+	for j := 0; j < n; j++ {
+		value := w.values[(start+j)%size]
+		w.sum += value
+		w.squares += value * value
+	}
+	w.sinceSync = 0
+}
+
+// extremum is the highest or lowest of the last values, kept in a monotonic queue
+type extremum struct {
+	period  int
+	highest bool
+	index   []int
+	values  []float64
+	head    int
+}
+
+func newExtremum(period int, highest bool) *extremum {
+	return &extremum{period: max(period, 1), highest: highest}
+}
+
+// push adds the value at position i and returns the extremum of the values in (i-period, i]
+func (e *extremum) push(i int, value float64) float64 {
+	for n := len(e.values); n > e.head; n-- {
+		last := e.values[n-1]
+		if (e.highest && last > value) || (!e.highest && last < value) {
+			break
+		}
+		e.index, e.values = e.index[:n-1], e.values[:n-1]
+	}
+	e.index = append(e.index, i)
+	e.values = append(e.values, value)
+
+	for e.index[e.head] <= i-e.period {
+		e.head++
+	}
+	if e.head > len(e.values)/2 {
+		e.index = append(e.index[:0], e.index[e.head:]...)
+		e.values = append(e.values[:0], e.values[e.head:]...)
+		e.head = 0
+	}
+	return e.values[e.head]
+}
@@ -3,21 +3,33 @@ package launcher
 import (
 	"cb_grok/config"
 	"cb_grok/internal/candle"
+	"cb_grok/internal/candle/quality"
 	"cb_grok/internal/exchange"
-	"cb_grok/internal/exchange/bybit"
+	"cb_grok/internal/exchange/venue"
 	"cb_grok/internal/metrics"
 	"cb_grok/internal/order"
+	"cb_grok/internal/order/paper"
 	stageModel "cb_grok/internal/stage/model"
 	"cb_grok/internal/strategy"
 	"cb_grok/internal/symbol"
 	"cb_grok/internal/telegram"
+	"cb_grok/internal/timeframe"
 	"cb_grok/internal/trader"
 	"cb_grok/pkg/postgres"
+	"context"
 	"fmt"
 	"go.uber.org/zap"
 )
 
+const (
+	simulationCommission      = 0.001  // 0.1%
+	simulationSlippagePercent = 0.001  // 0.1%
+	simulationSpread          = 0.0002 // 0.02%
+)
+
+// Launch runs the active traders of the stage, their order sync stops once ctx is done
 func Launch(
+	ctx context.Context,
 	traderStage stageModel.StageStatus,
 	log *zap.Logger,
 	tg *telegram.TelegramService,
@@ -41,29 +53,58 @@ func Launch(
 		activeStrategy, err := strategyRepo.GetStrategy(activeTrader.StrategyID)
 		if err != nil {
 			log.Error("Failed to load active strategy", zap.Error(err))
+			continue
+		}
+		str, err := strategy.New(activeStrategy.Type, activeStrategy.Params)
+		if err != nil {
+			log.Error("Failed to build active strategy", zap.Int64("trader_id", activeTrader.ID), zap.Error(err))
+			continue
 		}
 		var activeExchange exchange.Exchange
 
 		switch traderStage {
 		case stageModel.StageDemo:
-			activeExchange, err = bybit.NewBybit(cfg.Bybit.APIKey, cfg.Bybit.APISecret, exchange.TradingModeDemo)
+			activeExchange, err = venue.New(cfg.Trader.Exchange, cfg, venue.SandboxMode(cfg.Trader.Exchange))
 		default:
 			activeExchange = exchange.NewMockExchange()
 		}
 		if err != nil {
 			log.Error("Failed to load active exchange", zap.Error(err))
 		}
-		newTrader := trader.NewTrader(log, tg, orderUC, candleRepo)
+		traderOrderUC := orderUC
+		if traderStage == stageModel.StageSimulation {
+			// simulated traders fill in memory and never touch public.order
+			traderOrderUC = paper.New(paper.Settings{
+				Commission:      simulationCommission,
+				SlippagePercent: simulationSlippagePercent,
+				Spread:          simulationSpread,
+			}, activeTrader.InitQty)
+		}
+		newTrader := trader.NewTrader(log, tg, traderOrderUC, candleRepo)
 		activeSymbol, err := symbolRepo.GetSymbolByID(activeTrader.SymbolID)
 		if err != nil {
 			log.Error("Failed to load active symbol", zap.Error(err))
 		}
+		settings := trader.DefaultSettings()
+		settings.IntrabarExits = *cfg.Trader.IntrabarExits
+		settings.PassiveEntries = cfg.Trader.PassiveEntries
+		settings.EntryTimeoutCandles = cfg.Trader.EntryTimeoutCandles
+		settings.Window = trader.WindowFor(cfg.Trader.WindowCandles, str)
+		if err := trader.ValidateWindow(settings.Window, str); err != nil {
+			log.Error("Failed to load trader settings", zap.Int64("trader_id", activeTrader.ID), zap.Error(err))
+			continue
+		}
+		settings.Quality, err = quality.NewOptions(cfg.Candles.Quality, timeframe.Timeframe{})
+		if err != nil {
+			log.Error("Failed to load candle quality options", zap.Error(err))
+		}
 		newTrader.Setup(trader.Params{
+			Context:        ctx,
 			Symbol:         *activeSymbol,
 			StrategyModel:  activeStrategy,
 			Exchange:       activeExchange,
-			Strategy:       strategy.NewLinearBiasStrategy(),
-			Settings:       nil,
+			Strategy:       str,
+			Settings:       &settings,
 			InitialCapital: activeTrader.InitQty,
 			Model:          activeTrader,
 		})
@@ -87,5 +128,6 @@ func Launch(
 		}
 		fmt.Println("TRADER SET", activeTrader.ID)
 	}
-	select {}
+	<-ctx.Done()
+	return nil
 }
@@ -1,11 +1,89 @@
 package model
 
+import (
+	"cb_grok/internal/candle/dataset"
+	"cb_grok/internal/candle/quality"
+	"encoding/json"
+	"time"
+)
+
 type RunOptimizeParams struct {
-	Symbol       string
-	Timeframe    string
+	Symbol    string
+	Timeframe string
+	// Exchange is the venue candles are fetched from, default bybit
+	Exchange     string
 	TrainSetDays int
 	ValSetDays   int
+	// From and To pin the candle history to a fixed range instead of the days before now,
+	// zero From means unset. The validation set takes the candles after the training set.
+	From time.Time
+	To   time.Time
+	// DataFile reads the candle history from a CSV or Parquet file instead of the exchange
+	DataFile    string
+	DataOptions dataset.Options
+	// QualityPolicy overrides the candles.quality policy repairing bad candles before the studies
+	QualityPolicy quality.Policy
 
 	Trials  int
 	Workers int
+
+	// StrategyType is the registry name of the strategy to optimize
+	StrategyType string
+	// SearchSpaceFile optionally overrides the declared search space (YAML or JSON)
+	SearchSpaceFile string
+
+	// WalkForward re-optimizes on rolling train/validation windows over HistoryDays,
+	// shifting both windows by StepDays per fold. A StepDays shorter than ValSetDays overlaps
+	// the validation windows: the OOS curve keeps only the first StepDays of every fold but the last,
+	// the per-fold metrics still cover the whole validation window.
+	WalkForward bool
+	StepDays    int
+	HistoryDays int
+
+	// MultiObjective samples the search space at random and reports the Pareto front, the trials
+	// not dominated in return, max drawdown and trade count, instead of a single best trial.
+	// It is a random search filtered afterwards, the sampler does not optimize the objectives.
+	MultiObjective bool
+
+	// StudyName identifies the persisted study. An existing study is resumed,
+	// walk-forward folds are stored as <StudyName>_fold_<N>
+	StudyName string
+	// Promote inserts the best params into a new strategy row
+	Promote bool
+}
+
+type Study struct {
+	ID                 int64     `db:"id"`
+	Name               string    `db:"name"`
+	Symbol             string    `db:"symbol"`
+	Timeframe          string    `db:"timeframe"`
+	StrategyType       string    `db:"strategy_type"`
+	Direction          string    `db:"direction"`
+	PromotedStrategyID *int64    `db:"promoted_strategy_id"`
+	CreatedAt          time.Time `db:"created_at"`
+}
+
+type Trial struct {
+	ID      int64   `db:"id"`
+	StudyID int64   `db:"study_id"`
+	Number  int     `db:"number"`
+	State   string  `db:"state"`
+	Value   float64 `db:"value"`
+	// Params are the strategy params, InternalParams and Distributions are the goptuna representation
+	Params         json.RawMessage `db:"params"`
+	InternalParams json.RawMessage `db:"internal_params"`
+	Distributions  json.RawMessage `db:"distributions"`
+	Metrics        json.RawMessage `db:"metrics"`
+	StartedAt      time.Time       `db:"started_at"`
+	CompletedAt    *time.Time      `db:"completed_at"`
+}
+
+// TrialMetrics are the backtest results of a trial on the train set
+type TrialMetrics struct {
+	Return       float64 `json:"return"`
+	SharpeRatio  float64 `json:"sharpe_ratio"`
+	MaxDrawdown  float64 `json:"max_drawdown"`
+	WinRate      float64 `json:"win_rate"`
+	FinalCapital float64 `json:"final_capital"`
+	Orders       int     `json:"orders"`
 }
@@ -1,167 +1,69 @@
 package optimize
 
 import (
+	"cb_grok/internal/backtest"
+	optimizeModel "cb_grok/internal/optimize/model"
+	"cb_grok/internal/strategy"
 	strategyModel "cb_grok/internal/strategy/model"
 	"cb_grok/pkg/models"
+	"encoding/json"
+	"fmt"
 	"github.com/c-bata/goptuna"
 	"go.uber.org/zap"
 )
 
 type objectiveParams struct {
-	symbol               string
-	candles              []models.OHLCV
-	setDays              int
-	timePeriodMultiplier float64
+	symbol       string
+	candles      []models.OHLCV
+	setDays      int
+	strategyType string
+	searchSpace  strategyModel.SearchSpace
+	// multiObjective makes the trial value the return, the other objectives are kept in the trial metrics
+	multiObjective bool
 }
 
 func (o *optimize) objective(params objectiveParams) func(trial goptuna.Trial) (float64, error) {
 	return func(trial goptuna.Trial) (float64, error) {
-		maShortPeriod, err := trial.SuggestStepInt("ma_short_period", 5, 30*int(params.timePeriodMultiplier), int(params.timePeriodMultiplier))
-		if err != nil {
-			return 0, err
-		}
-		maLongPeriod, err := trial.SuggestStepInt("ma_long_period", 20, 100*int(params.timePeriodMultiplier), int(params.timePeriodMultiplier))
-		if err != nil {
-			return 0, err
-		}
-		rsiPeriod, err := trial.SuggestStepInt("rsi_period", 5, 20*int(params.timePeriodMultiplier), int(params.timePeriodMultiplier))
-		if err != nil {
-			return 0, err
-		}
-		atrPeriod, err := trial.SuggestStepInt("atr_period", 5, 20*int(params.timePeriodMultiplier), int(params.timePeriodMultiplier))
-		if err != nil {
-			return 0, err
-		}
-		buyRsiThreshold, err := trial.SuggestFloat("buy_rsi_threshold", 10, 40)
-		if err != nil {
-			return 0, err
-		}
-		sellRsiThreshold, err := trial.SuggestFloat("sell_rsi_threshold", 60, 90)
-		if err != nil {
-			return 0, err
-		}
-		emaShortPeriod, err := trial.SuggestStepInt("ema_short_period", 10, 50*int(params.timePeriodMultiplier), int(params.timePeriodMultiplier))
-		if err != nil {
-			return 0, err
-		}
-		emaLongPeriod, err := trial.SuggestStepInt("ema_long_period", 50, 200*int(params.timePeriodMultiplier), int(params.timePeriodMultiplier))
-		if err != nil {
-			return 0, err
-		}
-		atrThreshold, err := trial.SuggestFloat("atr_threshold", 0.0, 2.0)
-		if err != nil {
-			return 0, err
-		}
-		macdShortPeriod, err := trial.SuggestStepInt("macd_short_period", 5, 15*int(params.timePeriodMultiplier), int(params.timePeriodMultiplier))
-		if err != nil {
-			return 0, err
-		}
-		macdLongPeriod, err := trial.SuggestStepInt("macd_long_period", 20, 50*int(params.timePeriodMultiplier), int(params.timePeriodMultiplier))
-		if err != nil {
-			return 0, err
-		}
-		macdSignalPeriod, err := trial.SuggestStepInt("macd_signal_period", 5, 15*int(params.timePeriodMultiplier), int(params.timePeriodMultiplier))
-		if err != nil {
-			return 0, err
-		}
-
-		buySignalThreshold, err := trial.SuggestFloat("buy_signal_threshold", 0.1, 0.9)
+		values, err := suggestParams(trial, params.searchSpace)
 		if err != nil {
 			return 0, err
 		}
 
-		sellSignalThreshold, err := trial.SuggestFloat("sell_signal_threshold", -0.9, -0.1)
+		rawParams, err := json.Marshal(values)
 		if err != nil {
-			return 0, err
+			return 0, fmt.Errorf("marshal trial params: %w", err)
 		}
 
-		emaWeight, err := trial.SuggestFloat("ema_weight", 0, 1)
+		str, err := strategy.New(params.strategyType, rawParams)
 		if err != nil {
 			return 0, err
 		}
 
-		trendWeight, err := trial.SuggestFloat("trend_weight", 0, 1)
+		trainBTResult, err := o.bt.Run(params.candles, str)
 		if err != nil {
 			return 0, err
 		}
 
-		rsiWeight, err := trial.SuggestFloat("rsi_weight", 0, 1)
-		if err != nil {
-			return 0, err
-		}
-
-		macdWeight, err := trial.SuggestFloat("macd_weight", 0, 1)
-		if err != nil {
-			return 0, err
-		}
-
-		bollingerPeriod, err := trial.SuggestStepInt("bollinger_period", 10, 50*int(params.timePeriodMultiplier), int(params.timePeriodMultiplier))
-		if err != nil {
-			return 0, err
-		}
-		bollingerStdDev, err := trial.SuggestFloat("bollinger_std_dev", 1.0, 3.0)
-		if err != nil {
-			return 0, err
-		}
-
-		bbWeight, err := trial.SuggestFloat("bb_weight", 0, 1)
-		if err != nil {
-			return 0, err
-		}
-
-		//bollingerPeriod := int(0)
-		//bollingerStdDev := float64(0)
-		//bbWeight := 0.0
-
-		stochasticKPeriod, err := trial.SuggestStepInt("stochastic_k_period", 5, 20*int(params.timePeriodMultiplier), int(params.timePeriodMultiplier))
-		if err != nil {
-			return 0, err
-		}
-		stochasticDPeriod, err := trial.SuggestStepInt("stochastic_d_period", 3, 10*int(params.timePeriodMultiplier), int(params.timePeriodMultiplier))
-		if err != nil {
-			return 0, err
-		}
-		stochasticWeight, err := trial.SuggestFloat("stochastic_weight", 0, 1)
-		if err != nil {
-			return 0, err
+		combinedSharpe := trainBTResult.SharpeRatio *
+			(1 - trainBTResult.MaxDrawdown/150) * // Снизить штраф за просадку
+			min(float64(len(trainBTResult.Orders))/(float64(params.setDays)*1.5), 1) * // Поощрять больше сделок
+			(trainBTResult.WinRate / 100.0) // Учитывать win rate
+		metrics := optimizeModel.TrialMetrics{
+			Return:       backtestReturn(trainBTResult),
+			SharpeRatio:  trainBTResult.SharpeRatio,
+			MaxDrawdown:  trainBTResult.MaxDrawdown,
+			WinRate:      trainBTResult.WinRate,
+			FinalCapital: trainBTResult.FinalCapital,
+			Orders:       len(trainBTResult.Orders),
 		}
-
-		strategyParams := strategyModel.StrategyParams{
-			MAShortPeriod:       maShortPeriod,
-			MALongPeriod:        maLongPeriod,
-			RSIPeriod:           rsiPeriod,
-			ATRPeriod:           atrPeriod,
-			BuyRSIThreshold:     buyRsiThreshold,
-			SellRSIThreshold:    sellRsiThreshold,
-			EMAShortPeriod:      emaShortPeriod,
-			EMALongPeriod:       emaLongPeriod,
-			ATRThreshold:        atrThreshold,
-			MACDShortPeriod:     macdShortPeriod,
-			MACDLongPeriod:      macdLongPeriod,
-			MACDSignalPeriod:    macdSignalPeriod,
-			EMAWeight:           emaWeight,
-			TrendWeight:         trendWeight,
-			RSIWeight:           rsiWeight,
-			MACDWeight:          macdWeight,
-			BuySignalThreshold:  buySignalThreshold,
-			SellSignalThreshold: sellSignalThreshold,
-			BollingerPeriod:     bollingerPeriod,
-			BollingerStdDev:     bollingerStdDev,
-			BBWeight:            bbWeight,
-			StochasticDPeriod:   stochasticDPeriod,
-			StochasticKPeriod:   stochasticKPeriod,
-			StochasticWeight:    stochasticWeight,
+		rawMetrics, err := json.Marshal(metrics)
+		if err == nil {
+			err = trial.SetUserAttr(metricsUserAttr, string(rawMetrics))
 		}
-
-		trainBTResult, err := o.bt.Run(params.candles, strategyParams)
 		if err != nil {
-			return 0, err
+			o.log.Error("optimize: store trial metrics", zap.Int("trial", trial.ID), zap.Error(err))
 		}
 
-		combinedSharpe := trainBTResult.SharpeRatio *
-			(1 - trainBTResult.MaxDrawdown/150) * // Снизить штраф за просадку
-			min(float64(len(trainBTResult.Orders))/(float64(params.setDays)*1.5), 1) * // Поощрять больше сделок
-			(trainBTResult.WinRate / 100.0) // Учитывать win rate
 		o.log.Info("Trial result",
 			zap.Int("trial", trial.ID),
 			zap.Float64("combined_sharpe", combinedSharpe),
@@ -171,6 +73,18 @@ func (o *optimize) objective(params objectiveParams) func(trial goptuna.Trial) (
 			zap.Float64("Final capital", trainBTResult.FinalCapital),
 		)
 
+		if params.multiObjective {
+			return metrics.Return, nil
+		}
 		return combinedSharpe, nil
 	}
 }
+
+// backtestReturn is the total return of the backtest in percent
+func backtestReturn(result *backtest.BacktestResult) float64 {
+	if result == nil || result.TradeState == nil || result.TradeState.GetInitialCapital() == 0 {
+		return 0
+	}
+	initial := result.TradeState.GetInitialCapital()
+	return (result.FinalCapital - initial) / initial * 100
+}
@@ -4,14 +4,21 @@ import (
 	"bytes"
 	"cb_grok/config"
 	"cb_grok/internal/backtest"
+	"cb_grok/internal/candle"
+	"cb_grok/internal/candle/dataset"
+	candleProvider "cb_grok/internal/candle/provider"
+	"cb_grok/internal/candle/quality"
 	"cb_grok/internal/exchange"
-	"cb_grok/internal/exchange/bybit"
+	"cb_grok/internal/exchange/venue"
 	optimizeModel "cb_grok/internal/optimize/model"
+	"cb_grok/internal/strategy"
 	strategyModel "cb_grok/internal/strategy/model"
+	"cb_grok/internal/symbol"
 	"cb_grok/internal/telegram"
-	"cb_grok/internal/utils"
+	"cb_grok/pkg/models"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/c-bata/goptuna"
 	"github.com/c-bata/goptuna/tpe"
@@ -26,45 +33,136 @@ type Optimize interface {
 	Run(params optimizeModel.RunOptimizeParams) error
 }
 type optimize struct {
-	log *zap.Logger
-	bt  backtest.Backtest
-	tg  *telegram.TelegramService
-	cfg *config.Config
+	log          *zap.Logger
+	bt           backtest.Backtest
+	tg           *telegram.TelegramService
+	cfg          *config.Config
+	repo         Repository
+	strategyRepo strategy.Repository
+	symbolRepo   symbol.Repository
+	candleRepo   candle.Repository
 }
 
-func NewOptimize(log *zap.Logger, bt backtest.Backtest, tg *telegram.TelegramService, cfg *config.Config) Optimize {
+func NewOptimize(
+	log *zap.Logger,
+	bt backtest.Backtest,
+	tg *telegram.TelegramService,
+	cfg *config.Config,
+	repo Repository,
+	strategyRepo strategy.Repository,
+	symbolRepo symbol.Repository,
+	candleRepo candle.Repository,
+) Optimize {
 	return &optimize{
-		log: log,
-		bt:  bt,
-		tg:  tg,
-		cfg: cfg,
+		log:          log,
+		bt:           bt,
+		tg:           tg,
+		cfg:          cfg,
+		repo:         repo,
+		strategyRepo: strategyRepo,
+		symbolRepo:   symbolRepo,
+		candleRepo:   candleRepo,
 	}
 }
 
 func (o *optimize) Run(params optimizeModel.RunOptimizeParams) error {
-	ex, err := bybit.NewBybit("", "", "live")
+	ex, err := venue.NewPublic(params.Exchange)
 	if err != nil {
-		o.log.Error("optimize: initialize Binance exchange", zap.Error(err))
+		o.log.Error("optimize: initialize exchange", zap.String("exchange", params.Exchange), zap.Error(err))
 		return err
 	}
+	cache := candleProvider.New(o.candleRepo, ex, o.log)
 
-	timeframeSec := utils.TimeframeToMilliseconds(params.Timeframe) / 1000
-	timePeriodMultiplier := float64(60 * 60 / timeframeSec)
-	candlesPerDay := (24 * 60 * 60) / int(timeframeSec)
+	tf, err := exchange.Timeframe(params.Timeframe).Parse()
+	if err != nil {
+		return err
+	}
+	timePeriodMultiplier := float64(time.Hour) / float64(tf.Duration())
+
+	if params.StrategyType == "" {
+		params.StrategyType = strategy.LinearBiasType
+	}
+	if params.StudyName == "" {
+		params.StudyName = defaultStudyName(params)
+	}
+	searchSpace, err := o.searchSpace(params, timePeriodMultiplier)
+	if err != nil {
+		return err
+	}
 
-	candlesTotal := (params.ValSetDays + params.TrainSetDays) * candlesPerDay
+	historyDays := params.ValSetDays + params.TrainSetDays
+	if params.WalkForward {
+		historyDays = max(historyDays, params.HistoryDays)
+	}
+	candlesTotal := tf.Candles(historyDays)
 
-	candles, err := ex.FetchSpotOHLCV(params.Symbol, exchange.Timeframe(params.Timeframe), candlesTotal)
+	var candles []models.OHLCV
+	switch {
+	case params.DataFile != "":
+		candles, err = dataset.Load(params.DataFile, params.DataOptions)
+		if err != nil {
+			o.log.Error("optimize: load data file", zap.Error(err))
+			return err
+		}
+		if params.From.IsZero() {
+			candles = dataset.Last(candles, candlesTotal)
+		} else {
+			candles = dataset.Between(candles, params.From.UnixMilli(), params.To.UnixMilli())
+		}
+	case params.From.IsZero():
+		candles, err = cache.FetchSpotOHLCV(params.Symbol, exchange.Timeframe(params.Timeframe), candlesTotal)
+		if err != nil {
+			o.log.Error("optimize: fetch ohlcv", zap.Error(err))
+			return err
+		}
+	default:
+		candleRange, err := cache.FetchSpotOHLCVRange(params.Symbol, exchange.Timeframe(params.Timeframe), params.From.UnixMilli(), params.To.UnixMilli())
+		if err != nil {
+			o.log.Error("optimize: fetch ohlcv range", zap.Error(err))
+			return err
+		}
+		for _, gap := range candleRange.Gaps {
+			o.log.Warn("optimize: missing candles", zap.Time("from", time.UnixMilli(gap.Start).UTC()), zap.Time("to", time.UnixMilli(gap.End).UTC()))
+		}
+		candles = candleRange.Candles
+	}
 
+	qualityOptions, err := quality.NewOptions(o.cfg.Candles.Quality, tf)
 	if err != nil {
-		o.log.Error("optimize: fetch ohlcv", zap.Error(err))
+		return err
+	}
+	if params.QualityPolicy != "" {
+		qualityOptions.Policy = params.QualityPolicy
+	}
+	candles, report, err := quality.Repair(fmt.Sprintf("%s %s", params.Symbol, params.Timeframe), candles, qualityOptions)
+	report.Log(o.log)
+	if err != nil {
+		o.log.Error("optimize: validate ohlcv", zap.Error(err))
 		return err
 	}
 
 	o.log.Info("optimize: ohlcv data", zap.Int("length", len(candles)))
 
-	trainCandlesCount := params.TrainSetDays * candlesPerDay
-	valCandlesCount := params.ValSetDays * candlesPerDay
+	if params.WalkForward && params.MultiObjective {
+		return fmt.Errorf("multi-objective mode does not support walk-forward")
+	}
+	if params.MultiObjective && params.Promote {
+		return fmt.Errorf("multi-objective mode has no single best trial to promote, pick one from the Pareto front")
+	}
+
+	if params.WalkForward {
+		best, err := o.runWalkForward(params, candles, tf, searchSpace)
+		if err != nil {
+			return err
+		}
+		if err = o.promote(params, best); err != nil {
+			return err
+		}
+		return best.persistError()
+	}
+
+	trainCandlesCount := tf.Candles(params.TrainSetDays)
+	valCandlesCount := tf.Candles(params.ValSetDays)
 
 	if (valCandlesCount + trainCandlesCount) > len(candles) {
 		return fmt.Errorf("summary sets is larger than the available data")
@@ -78,60 +176,18 @@ func (o *optimize) Run(params optimizeModel.RunOptimizeParams) error {
 		zap.Int("val_candles", len(valCandles)),
 	)
 
-	study, err := goptuna.CreateStudy(
-		"strategy_1",
-		goptuna.StudyOptionDirection(goptuna.StudyDirectionMaximize),
-		goptuna.StudyOptionSampler(tpe.NewSampler()),
-	)
-	if err != nil {
-		o.log.Error("optimize: create study", zap.Error(err))
-		return err
-	}
-
-	eg, ctx := errgroup.WithContext(context.Background())
-	study.WithContext(ctx)
-
-	for i := 0; i < params.Workers; i++ {
-		eg.Go(func() error {
-			return study.Optimize(o.objective(objectiveParams{
-				symbol:               params.Symbol,
-				candles:              trainCandles,
-				setDays:              params.TrainSetDays,
-				timePeriodMultiplier: timePeriodMultiplier,
-			}), params.Trials/params.Workers)
-		})
+	if params.MultiObjective {
+		return o.runMultiObjective(params, trainCandles, valCandles, searchSpace)
 	}
 
-	if err = eg.Wait(); err != nil {
-		o.log.Error("Optimize error %v", zap.Error(err))
-		return err
-	}
-
-	bestParams, err := study.GetBestParams()
+	best, err := o.runStudy(params.StudyName, params, trainCandles, params.TrainSetDays, searchSpace)
 	if err != nil {
-		o.log.Error("optimize: get best params", zap.Error(err))
-		return err
-	}
-	combinedSharpRatio, err := study.GetBestValue()
-	if err != nil {
-		o.log.Error("optimize: get best value", zap.Error(err))
-		return err
-	}
-
-	b, err := json.Marshal(bestParams)
-	if err != nil {
-		o.log.Error("optimize: best params marshal", zap.Error(err))
-		return err
-	}
-
-	var bestStrategyParams strategyModel.StrategyParams
-	err = json.Unmarshal(b, &bestStrategyParams)
-	if err != nil {
-		o.log.Error("optimize: best params marshal", zap.Error(err))
 		return err
 	}
+	bestStrategy := best.strategy
+	combinedSharpRatio := best.value
 
-	valBTResult, err := o.bt.Run(valCandles, bestStrategyParams)
+	valBTResult, err := o.bt.Run(valCandles, bestStrategy)
 	if err != nil {
 		o.log.Error("optimize: final validation backtest", zap.Error(err))
 		return err
@@ -151,8 +207,8 @@ func (o *optimize) Run(params optimizeModel.RunOptimizeParams) error {
 		zap.Float64("validation_win_rate", valBTResult.WinRate))
 
 	result := fmt.Sprintf(
-		"Символ: %s\nTrials: %d\nTimeframe: %s\nКоличество дней на валидации: %d\nКоличество сделок: %d\nCombined Sharpe Ratio: %.2f\nValidation Sharpe Ratio: %.2f\nИтоговый капитал: %.2f\nМаксимальная просадка: %.2f%%\nWin Rate: %.2f%%\n",
-		params.Symbol, params.Trials, params.Timeframe, params.ValSetDays, orderCount, combinedSharpRatio, valBTResult.SharpeRatio, valBTResult.FinalCapital, valBTResult.MaxDrawdown, valBTResult.WinRate)
+		"Символ: %s\nСтратегия: %s\nTrials: %d\nTimeframe: %s\nКоличество дней на валидации: %d\nКоличество сделок: %d\nCombined Sharpe Ratio: %.2f\nValidation Sharpe Ratio: %.2f\nИтоговый капитал: %.2f\nМаксимальная просадка: %.2f%%\nWin Rate: %.2f%%\n",
+		params.Symbol, params.StrategyType, params.Trials, params.Timeframe, params.ValSetDays, orderCount, combinedSharpRatio, valBTResult.SharpeRatio, valBTResult.FinalCapital, valBTResult.MaxDrawdown, valBTResult.WinRate)
 
 	buff := &bytes.Buffer{}
 	w := struct2csv.NewWriter(buff)
@@ -191,6 +247,183 @@ func (o *optimize) Run(params optimizeModel.RunOptimizeParams) error {
 		o.log.Error("report: send to telegram", zap.Error(err))
 	}
 
+	if err = o.promote(params, best); err != nil {
+		return err
+	}
+	return best.persistError()
+}
+
+// searchSpace resolves the search space of the optimized strategy with file overrides applied
+func (o *optimize) searchSpace(params optimizeModel.RunOptimizeParams, timePeriodMultiplier float64) (strategyModel.SearchSpace, error) {
+	searchSpace, err := strategy.SearchSpace(params.StrategyType)
+	if err != nil {
+		o.log.Error("optimize: get search space", zap.Error(err))
+		return nil, err
+	}
+	if params.SearchSpaceFile != "" {
+		searchSpace, err = applySearchSpaceOverrides(searchSpace, params.SearchSpaceFile)
+		if err != nil {
+			o.log.Error("optimize: apply search space overrides", zap.Error(err))
+			return nil, err
+		}
+	}
+	return scaleSearchSpace(searchSpace, timePeriodMultiplier), nil
+}
+
+type studyResult struct {
+	studyID  int64
+	strategy strategy.Strategy
+	params   json.RawMessage
+	value    float64
+	// trials holds every trial of the study, including the restored ones
+	trials []goptuna.FrozenTrial
+	// persistErrors holds the trials that were not stored, a resumed study does not see them
+	persistErrors []error
+}
+
+// persistError reports the trials that were not stored, nil when all of them were
+func (r *studyResult) persistError() error {
+	if len(r.persistErrors) == 0 {
+		return nil
+	}
+	return fmt.Errorf("failed to persist %d trials: %w", len(r.persistErrors), errors.Join(r.persistErrors...))
+}
+
+// runStudy optimizes the strategy on the given candles and returns the best trial.
+// Trials are persisted as they finish, a study that already exists is resumed.
+func (o *optimize) runStudy(studyName string, params optimizeModel.RunOptimizeParams, candles []models.OHLCV, setDays int, searchSpace strategyModel.SearchSpace) (*studyResult, error) {
+	stored, err := o.loadOrCreateStudy(studyName, params)
+	if err != nil {
+		return nil, err
+	}
+
+	// TPE would chase the return only, the Pareto front needs the search space explored evenly
+	var sampler goptuna.Sampler = tpe.NewSampler()
+	if params.MultiObjective {
+		sampler = goptuna.NewRandomSampler()
+	}
+
+	notify := make(chan goptuna.FrozenTrial, max(params.Workers, 1))
+	study, err := goptuna.CreateStudy(
+		studyName,
+		goptuna.StudyOptionDirection(goptuna.StudyDirectionMaximize),
+		goptuna.StudyOptionSampler(sampler),
+		goptuna.StudyOptionTrialNotifyChannel(notify),
+	)
+	if err != nil {
+		o.log.Error("optimize: create study", zap.Error(err))
+		return nil, err
+	}
+
+	restored, err := o.restoreTrials(study, stored)
+	if err != nil {
+		return nil, err
+	}
+	o.log.Info("optimize: study opened", zap.String("study", studyName), zap.Int("restored_trials", restored))
+
+	var persistErrors []error
+	persisted := make(chan struct{})
+	go func() {
+		persistErrors = o.persistTrials(stored, searchSpace, notify)
+		close(persisted)
+	}()
+
+	eg, ctx := errgroup.WithContext(context.Background())
+	study.WithContext(ctx)
+
+	for i := 0; i < params.Workers; i++ {
+		eg.Go(func() error {
+			return study.Optimize(o.objective(objectiveParams{
+				symbol:         params.Symbol,
+				candles:        candles,
+				setDays:        setDays,
+				strategyType:   params.StrategyType,
+				searchSpace:    searchSpace,
+				multiObjective: params.MultiObjective,
+			}), params.Trials/params.Workers)
+		})
+	}
+
+	err = eg.Wait()
+	close(notify)
+	<-persisted
+	if err != nil {
+		o.log.Error("Optimize error %v", zap.Error(err))
+		return nil, err
+	}
+
+	bestParams, err := study.GetBestParams()
+	if err != nil {
+		o.log.Error("optimize: get best params", zap.Error(err))
+		return nil, err
+	}
+	bestValue, err := study.GetBestValue()
+	if err != nil {
+		o.log.Error("optimize: get best value", zap.Error(err))
+		return nil, err
+	}
+
+	b, err := json.Marshal(normalizeParams(searchSpace, bestParams))
+	if err != nil {
+		o.log.Error("optimize: best params marshal", zap.Error(err))
+		return nil, err
+	}
+
+	bestStrategy, err := strategy.New(params.StrategyType, b)
+	if err != nil {
+		o.log.Error("optimize: build best strategy", zap.Error(err))
+		return nil, err
+	}
+
+	trials, err := study.GetTrials()
+	if err != nil {
+		o.log.Error("optimize: get trials", zap.Error(err))
+		return nil, err
+	}
+
+	return &studyResult{
+		studyID:  stored.ID,
+		strategy: bestStrategy,
+		params:   b,
+		value:    bestValue,
+		trials:   trials,
+
+		persistErrors: persistErrors,
+	}, nil
+}
+
+// promote stores the best params as a new strategy row and links it to the study
+func (o *optimize) promote(params optimizeModel.RunOptimizeParams, best *studyResult) error {
+	if !params.Promote || best == nil {
+		return nil
+	}
+
+	sym, err := o.symbolRepo.GetSymbolByCode(params.Symbol)
+	if err != nil {
+		o.log.Error("optimize: promote: get symbol", zap.String("symbol", params.Symbol), zap.Error(err))
+		return err
+	}
+
+	entity := &strategyModel.Strategy{
+		SymbolID:  int(sym.ID),
+		Type:      params.StrategyType,
+		Params:    best.params,
+		TimeFrame: params.Timeframe,
+	}
+	if err = o.strategyRepo.InsertStrategy(entity); err != nil {
+		o.log.Error("optimize: promote: insert strategy", zap.Error(err))
+		return err
+	}
+	if err = o.repo.SetPromotedStrategy(best.studyID, int64(entity.ID)); err != nil {
+		o.log.Error("optimize: promote: link strategy to study", zap.Error(err))
+		return err
+	}
+
+	o.log.Info("optimize: best trial promoted",
+		zap.Int64("study_id", best.studyID),
+		zap.Int("strategy_id", entity.ID),
+		zap.Float64("value", best.value),
+	)
 	return nil
 }
 
@@ -0,0 +1,230 @@
+package optimize
+
+import (
+	"bytes"
+	"cb_grok/internal/backtest"
+	optimizeModel "cb_grok/internal/optimize/model"
+	"cb_grok/internal/strategy"
+	strategyModel "cb_grok/internal/strategy/model"
+	"cb_grok/pkg/models"
+	"encoding/json"
+	"fmt"
+	"github.com/c-bata/goptuna"
+	"github.com/dnlo/struct2csv"
+	"github.com/go-echarts/go-echarts/v2/charts"
+	"github.com/go-echarts/go-echarts/v2/components"
+	"github.com/go-echarts/go-echarts/v2/opts"
+	"go.uber.org/zap"
+	"sort"
+	"strings"
+	"time"
+)
+
+// paretoPoint is a completed trial scored by the three objectives:
+// return and trade count are maximized, max drawdown is minimized
+type paretoPoint struct {
+	number  int
+	params  json.RawMessage
+	metrics optimizeModel.TrialMetrics
+
+	validation *backtest.BacktestResult
+}
+
+// dominates reports whether p is not worse than q in every objective and better in at least one
+func (p paretoPoint) dominates(q paretoPoint) bool {
+	if p.metrics.Return < q.metrics.Return || p.metrics.MaxDrawdown > q.metrics.MaxDrawdown || p.metrics.Orders < q.metrics.Orders {
+		return false
+	}
+	return p.metrics.Return > q.metrics.Return || p.metrics.MaxDrawdown < q.metrics.MaxDrawdown || p.metrics.Orders > q.metrics.Orders
+}
+
+// paretoPoints converts completed trials with recorded metrics into points
+func paretoPoints(trials []goptuna.FrozenTrial, searchSpace strategyModel.SearchSpace) ([]paretoPoint, error) {
+	points := make([]paretoPoint, 0, len(trials))
+	for _, trial := range trials {
+		if trial.State != goptuna.TrialStateComplete {
+			continue
+		}
+		rawMetrics, ok := trial.UserAttrs[metricsUserAttr]
+		if !ok {
+			continue
+		}
+
+		var metrics optimizeModel.TrialMetrics
+		if err := json.Unmarshal([]byte(rawMetrics), &metrics); err != nil {
+			return nil, fmt.Errorf("trial %d: unmarshal metrics: %w", trial.Number, err)
+		}
+		params, err := json.Marshal(normalizeParams(searchSpace, trial.Params))
+		if err != nil {
+			return nil, fmt.Errorf("trial %d: marshal params: %w", trial.Number, err)
+		}
+
+		points = append(points, paretoPoint{
+			number:  trial.Number,
+			params:  params,
+			metrics: metrics,
+		})
+	}
+	return points, nil
+}
+
+// paretoFront returns the non-dominated points ordered by return descending
+func paretoFront(points []paretoPoint) []paretoPoint {
+	var front []paretoPoint
+	for i, p := range points {
+		dominated := false
+		for j, q := range points {
+			if i != j && q.dominates(p) {
+				dominated = true
+				break
+			}
+		}
+		if !dominated {
+			front = append(front, p)
+		}
+	}
+
+	sort.Slice(front, func(i, j int) bool {
+		if front[i].metrics.Return != front[j].metrics.Return {
+			return front[i].metrics.Return > front[j].metrics.Return
+		}
+		return front[i].number < front[j].number
+	})
+	return front
+}
+
+// runMultiObjective samples the search space, keeps the Pareto front of the train trials
+// and validates every point of the front
+func (o *optimize) runMultiObjective(params optimizeModel.RunOptimizeParams, trainCandles, valCandles []models.OHLCV, searchSpace strategyModel.SearchSpace) error {
+	study, err := o.runStudy(params.StudyName, params, trainCandles, params.TrainSetDays, searchSpace)
+	if err != nil {
+		return err
+	}
+
+	points, err := paretoPoints(study.trials, searchSpace)
+	if err != nil {
+		o.log.Error("optimize: collect pareto points", zap.Error(err))
+		return err
+	}
+	front := paretoFront(points)
+
+	for i := range front {
+		str, err := strategy.New(params.StrategyType, front[i].params)
+		if err != nil {
+			o.log.Error("optimize: build pareto strategy", zap.Int("trial", front[i].number), zap.Error(err))
+			return err
+		}
+		front[i].validation, err = o.bt.Run(valCandles, str)
+		if err != nil {
+			o.log.Error("optimize: pareto validation backtest", zap.Int("trial", front[i].number), zap.Error(err))
+		}
+	}
+
+	o.log.Info("multi-objective optimization completed",
+		zap.Int("trials", len(points)),
+		zap.Int("pareto_front", len(front)),
+	)
+
+	o.reportParetoFront(params, points, front)
+	return study.persistError()
+}
+
+func (o *optimize) reportParetoFront(params optimizeModel.RunOptimizeParams, points, front []paretoPoint) {
+	var table strings.Builder
+	fmt.Fprintf(&table, "#trial | Return | DD | Trades | Val Return | Val DD\n")
+	for _, p := range front {
+		fmt.Fprintf(&table, "#%d | %.2f%% | %.2f%% | %d", p.number, p.metrics.Return, p.metrics.MaxDrawdown, p.metrics.Orders)
+		if p.validation != nil {
+			fmt.Fprintf(&table, " | %.2f%% | %.2f%%\n", backtestReturn(p.validation), p.validation.MaxDrawdown)
+		} else {
+			fmt.Fprintf(&table, " | - | -\n")
+		}
+	}
+
+	result := fmt.Sprintf(
+		"Парето-фронт\n\nСимвол: %s\nСтратегия: %s\nStudy: %s\nTimeframe: %s\nTrials: %d\nТочек на фронте: %d\n\n%s",
+		params.Symbol, params.StrategyType, params.StudyName, params.Timeframe, len(points), len(front), table.String())
+
+	buff := &bytes.Buffer{}
+	w := struct2csv.NewWriter(buff)
+	err := w.Write([]string{"trial", "return", "max_drawdown", "orders", "sharpe", "win_rate", "val_return", "val_max_drawdown", "val_orders", "params"})
+	if err != nil {
+		o.log.Error("report: write col names", zap.Error(err))
+	}
+	for _, p := range front {
+		row := []string{
+			fmt.Sprint(p.number),
+			fmt.Sprint(p.metrics.Return),
+			fmt.Sprint(p.metrics.MaxDrawdown),
+			fmt.Sprint(p.metrics.Orders),
+			fmt.Sprint(p.metrics.SharpeRatio),
+			fmt.Sprint(p.metrics.WinRate),
+		}
+		if p.validation != nil {
+			row = append(row,
+				fmt.Sprint(backtestReturn(p.validation)),
+				fmt.Sprint(p.validation.MaxDrawdown),
+				fmt.Sprint(len(p.validation.Orders)),
+			)
+		} else {
+			row = append(row, "", "", "")
+		}
+		row = append(row, string(p.params))
+		err = w.Write(row)
+		if err != nil {
+			o.log.Error("report: write structs", zap.Error(err))
+		}
+	}
+	w.Flush()
+
+	err = o.tg.SendFile(buff, "csv", result)
+	if err != nil {
+		o.log.Error("report: send to telegram", zap.Error(err))
+	}
+
+	time.Sleep(1000 * time.Millisecond)
+	chartBuff, err := paretoChart(points, front)
+	if err != nil {
+		o.log.Error("report: generate charts", zap.Error(err))
+		return
+	}
+	err = o.tg.SendFile(chartBuff, "html", "Парето-фронт: доходность / просадка")
+	if err != nil {
+		o.log.Error("report: send to telegram", zap.Error(err))
+	}
+}
+
+// paretoChart plots return against max drawdown, the Pareto front is highlighted
+func paretoChart(points, front []paretoPoint) (*bytes.Buffer, error) {
+	scatter := charts.NewScatter()
+	scatter.SetGlobalOptions(
+		charts.WithTitleOpts(opts.Title{Title: "Pareto front", Subtitle: "return / max drawdown, trades in the point name"}),
+		charts.WithTooltipOpts(opts.Tooltip{Show: opts.Bool(true), Trigger: "item"}),
+		charts.WithXAxisOpts(opts.XAxis{Type: "value", Name: "Max drawdown, %"}),
+		charts.WithYAxisOpts(opts.YAxis{Type: "value", Name: "Return, %", Scale: opts.Bool(true)}),
+	)
+
+	toData := func(points []paretoPoint, symbolSize int) []opts.ScatterData {
+		data := make([]opts.ScatterData, 0, len(points))
+		for _, p := range points {
+			data = append(data, opts.ScatterData{
+				Name:       fmt.Sprintf("#%d, trades %d", p.number, p.metrics.Orders),
+				Value:      []interface{}{p.metrics.MaxDrawdown, p.metrics.Return},
+				SymbolSize: symbolSize,
+			})
+		}
+		return data
+	}
+
+	scatter.AddSeries("Trials", toData(points, 6))
+	scatter.AddSeries("Pareto front", toData(front, 12))
+
+	page := components.NewPage()
+	page.AddCharts(scatter)
+
+	buff := &bytes.Buffer{}
+	if err := page.Render(buff); err != nil {
+		return nil, err
+	}
+	return buff, nil
+}
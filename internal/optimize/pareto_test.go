@@ -0,0 +1,131 @@
+package optimize
+
+import (
+	optimizeModel "cb_grok/internal/optimize/model"
+	"github.com/c-bata/goptuna"
+	"testing"
+)
+
+func point(number int, ret, dd float64, orders int) paretoPoint {
+	return paretoPoint{number: number, metrics: optimizeModel.TrialMetrics{Return: ret, MaxDrawdown: dd, Orders: orders}}
+}
+
+func TestDominates(t *testing.T) {
+	tests := []struct {
+		name string
+		p, q paretoPoint
+		want bool
+	}{
+		{name: "better in every objective", p: point(0, 20, 5, 30), q: point(1, 10, 10, 20), want: true},
+		{name: "higher return only", p: point(0, 20, 10, 20), q: point(1, 10, 10, 20), want: true},
+		// the drawdown is minimized
+		{name: "lower drawdown only", p: point(0, 10, 5, 20), q: point(1, 10, 10, 20), want: true},
+		{name: "higher drawdown only", p: point(0, 10, 15, 20), q: point(1, 10, 10, 20), want: false},
+		{name: "more trades only", p: point(0, 10, 10, 30), q: point(1, 10, 10, 20), want: true},
+		{name: "equal points", p: point(0, 10, 10, 20), q: point(1, 10, 10, 20), want: false},
+		{name: "trade-off between return and drawdown", p: point(0, 20, 15, 20), q: point(1, 10, 10, 20), want: false},
+		{name: "trade-off between drawdown and trades", p: point(0, 10, 5, 10), q: point(1, 10, 10, 20), want: false},
+		{name: "worse in every objective", p: point(0, 10, 10, 20), q: point(1, 20, 5, 30), want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.p.dominates(tt.q); got != tt.want {
+				t.Errorf("dominates = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParetoFront(t *testing.T) {
+	tests := []struct {
+		name   string
+		points []paretoPoint
+		want   []int
+	}{
+		{name: "no points"},
+		{name: "single point", points: []paretoPoint{point(0, 10, 10, 20)}, want: []int{0}},
+		{
+			name: "dominated points are dropped",
+			points: []paretoPoint{
+				point(0, 10, 10, 20),
+				point(1, 20, 5, 30),
+				point(2, 20, 6, 30),
+				point(3, 5, 1, 10),
+			},
+			want: []int{1, 3},
+		},
+		{
+			// equal points do not dominate each other, both stay and keep the trial order
+			name: "equal points are kept",
+			points: []paretoPoint{
+				point(4, 10, 10, 20),
+				point(2, 10, 10, 20),
+				point(3, 8, 10, 20),
+			},
+			want: []int{2, 4},
+		},
+		{
+			name: "trade-offs are ordered by return",
+			points: []paretoPoint{
+				point(0, 5, 2, 20),
+				point(1, 15, 20, 20),
+				point(2, 10, 10, 20),
+				point(3, 10, 10, 50),
+			},
+			want: []int{1, 3, 0},
+		},
+		{
+			// a higher drawdown for more trades is a trade-off, the tie on return is broken by the trial number
+			name: "same return, different drawdown",
+			points: []paretoPoint{
+				point(1, 10, 12, 40),
+				point(0, 10, 8, 20),
+			},
+			want: []int{0, 1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			front := paretoFront(tt.points)
+			var got []int
+			for _, p := range front {
+				got = append(got, p.number)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("front = %v, want %v", got, tt.want)
+			}
+			for i := range tt.want {
+				if got[i] != tt.want[i] {
+					t.Fatalf("front = %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestParetoPointsSkipsIncompleteTrials(t *testing.T) {
+	metrics := map[string]string{metricsUserAttr: `{"return": 12.5, "max_drawdown": 4, "orders": 7}`}
+	trials := []goptuna.FrozenTrial{
+		{Number: 0, State: goptuna.TrialStateComplete, UserAttrs: metrics},
+		{Number: 1, State: goptuna.TrialStateFail, UserAttrs: metrics},
+		{Number: 2, State: goptuna.TrialStatePruned, UserAttrs: metrics},
+		{Number: 3, State: goptuna.TrialStateComplete},
+		{Number: 4, State: goptuna.TrialStateComplete, UserAttrs: metrics},
+	}
+
+	points, err := paretoPoints(trials, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(points) != 2 || points[0].number != 0 || points[1].number != 4 {
+		t.Fatalf("points = %+v, want trials 0 and 4", points)
+	}
+	if want := (optimizeModel.TrialMetrics{Return: 12.5, MaxDrawdown: 4, Orders: 7}); points[0].metrics != want {
+		t.Errorf("metrics = %+v, want %+v", points[0].metrics, want)
+	}
+
+	trials[0].UserAttrs = map[string]string{metricsUserAttr: "{"}
+	if _, err := paretoPoints(trials, nil); err == nil {
+		t.Error("malformed metrics accepted")
+	}
+}
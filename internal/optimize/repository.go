@@ -0,0 +1,13 @@
+package optimize
+
+import optimizeModel "cb_grok/internal/optimize/model"
+
+type Repository interface {
+	// GetStudyByName returns nil when the study does not exist
+	GetStudyByName(name string) (*optimizeModel.Study, error)
+	InsertStudy(entity *optimizeModel.Study) error
+	SetPromotedStrategy(studyID int64, strategyID int64) error
+	InsertTrial(entity *optimizeModel.Trial) error
+	SetTrialNumber(id int64, number int) error
+	GetTrials(studyID int64) ([]optimizeModel.Trial, error)
+}
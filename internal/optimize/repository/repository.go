@@ -0,0 +1,121 @@
+package repository
+
+import (
+	"cb_grok/internal/optimize"
+	optimizeModel "cb_grok/internal/optimize/model"
+	"cb_grok/pkg/postgres"
+	"fmt"
+)
+
+type repo struct {
+	db postgres.Postgres
+}
+
+func New(db postgres.Postgres) optimize.Repository {
+	return &repo{
+		db: db,
+	}
+}
+
+func (r *repo) GetStudyByName(name string) (*optimizeModel.Study, error) {
+	var result []optimizeModel.Study
+	query := `
+		SELECT id, name, symbol, timeframe, strategy_type, direction, promoted_strategy_id, created_at
+		FROM public.optimize_study
+		WHERE name = $1
+	`
+	err := r.db.Select(&result, query, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get study by name: %w", err)
+	}
+	if len(result) == 0 {
+		return nil, nil
+	}
+	return &result[0], nil
+}
+
+func (r *repo) InsertStudy(entity *optimizeModel.Study) error {
+	query := `
+		INSERT INTO public.optimize_study (
+			name, symbol, timeframe, strategy_type, direction
+		) VALUES ($1, $2, $3, $4, $5)
+		RETURNING id
+	`
+	err := r.db.Get(&entity.ID, query,
+		entity.Name,
+		entity.Symbol,
+		entity.Timeframe,
+		entity.StrategyType,
+		entity.Direction,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to insert study: %w", err)
+	}
+	return nil
+}
+
+func (r *repo) SetPromotedStrategy(studyID int64, strategyID int64) error {
+	query := `
+		UPDATE public.optimize_study
+		SET promoted_strategy_id = $2
+		WHERE id = $1
+	`
+	_, err := r.db.Exec(query, studyID, strategyID)
+	if err != nil {
+		return fmt.Errorf("failed to set promoted strategy: %w", err)
+	}
+	return nil
+}
+
+func (r *repo) InsertTrial(entity *optimizeModel.Trial) error {
+	query := `
+		INSERT INTO public.optimize_trial (
+			study_id, number, state, value, params, internal_params, distributions, metrics, started_at, completed_at
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		RETURNING id
+	`
+	err := r.db.Get(&entity.ID, query,
+		entity.StudyID,
+		entity.Number,
+		entity.State,
+		entity.Value,
+		entity.Params,
+		entity.InternalParams,
+		entity.Distributions,
+		entity.Metrics,
+		entity.StartedAt,
+		entity.CompletedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to insert trial: %w", err)
+	}
+	return nil
+}
+
+func (r *repo) SetTrialNumber(id int64, number int) error {
+	query := `
+		UPDATE public.optimize_trial
+		SET number = $2
+		WHERE id = $1
+	`
+	_, err := r.db.Exec(query, id, number)
+	if err != nil {
+		return fmt.Errorf("failed to set trial number: %w", err)
+	}
+	return nil
+}
+
+func (r *repo) GetTrials(studyID int64) ([]optimizeModel.Trial, error) {
+	var result []optimizeModel.Trial
+	query := `
+		SELECT id, study_id, number, state, value, params, internal_params, distributions, metrics, started_at, completed_at
+		FROM public.optimize_trial
+		WHERE study_id = $1
+		ORDER BY number ASC
+	`
+	err := r.db.Select(&result, query, studyID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get trials: %w", err)
+	}
+	return result, nil
+}
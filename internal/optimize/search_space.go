@@ -0,0 +1,157 @@
+package optimize
+
+import (
+	strategyModel "cb_grok/internal/strategy/model"
+	"fmt"
+	"github.com/c-bata/goptuna"
+	"gopkg.in/yaml.v3"
+	"math"
+	"os"
+	"strconv"
+)
+
+// scaleSearchSpace applies the timeframe multiplier to params declared as TimeframeScaled
+func scaleSearchSpace(space strategyModel.SearchSpace, timePeriodMultiplier float64) strategyModel.SearchSpace {
+	multiplier := max(math.Round(timePeriodMultiplier), 1)
+
+	scaled := make(strategyModel.SearchSpace, len(space))
+	for i, p := range space {
+		if p.Kind == strategyModel.ParamKindInt && p.TimeframeScaled {
+			p.High *= multiplier
+			p.Step = max(p.Step, 1) * multiplier
+		}
+		scaled[i] = p
+	}
+	return scaled
+}
+
+// suggestParams samples every param of the search space from the trial
+func suggestParams(trial goptuna.Trial, space strategyModel.SearchSpace) (map[string]interface{}, error) {
+	values := make(map[string]interface{}, len(space))
+
+	for _, p := range space {
+		var (
+			value interface{}
+			err   error
+		)
+
+		switch p.Kind {
+		case strategyModel.ParamKindInt:
+			switch {
+			case p.Log:
+				var v float64
+				v, err = trial.SuggestLogFloat(p.Name, p.Low, p.High)
+				value = int(math.Round(v))
+			case p.Step > 1:
+				value, err = trial.SuggestStepInt(p.Name, int(p.Low), int(p.High), int(p.Step))
+			default:
+				value, err = trial.SuggestInt(p.Name, int(p.Low), int(p.High))
+			}
+		case strategyModel.ParamKindFloat:
+			switch {
+			case p.Log:
+				value, err = trial.SuggestLogFloat(p.Name, p.Low, p.High)
+			case p.Step > 0:
+				value, err = trial.SuggestDiscreteFloat(p.Name, p.Low, p.High, p.Step)
+			default:
+				value, err = trial.SuggestFloat(p.Name, p.Low, p.High)
+			}
+		case strategyModel.ParamKindCategorical:
+			var choice string
+			choice, err = trial.SuggestCategorical(p.Name, p.Choices)
+			value = parseChoice(choice)
+		default:
+			err = fmt.Errorf("unknown param type %q", p.Kind)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("suggest %s: %w", p.Name, err)
+		}
+
+		values[p.Name] = value
+	}
+
+	return values, nil
+}
+
+// normalizeParams converts goptuna external param values (e.g. from GetBestParams)
+// back to the JSON types expected by the strategy params
+func normalizeParams(space strategyModel.SearchSpace, params map[string]interface{}) map[string]interface{} {
+	result := make(map[string]interface{}, len(params))
+	for name, value := range params {
+		result[name] = value
+	}
+
+	for _, p := range space {
+		value, ok := result[p.Name]
+		if !ok {
+			continue
+		}
+
+		switch p.Kind {
+		case strategyModel.ParamKindInt:
+			if v, ok := value.(float64); ok {
+				result[p.Name] = int(math.Round(v))
+			}
+		case strategyModel.ParamKindCategorical:
+			if v, ok := value.(string); ok {
+				result[p.Name] = parseChoice(v)
+			}
+		}
+	}
+
+	return result
+}
+
+// parseChoice lets categorical choices carry numbers and booleans
+func parseChoice(choice string) interface{} {
+	if v, err := strconv.ParseFloat(choice, 64); err == nil {
+		return v
+	}
+	if v, err := strconv.ParseBool(choice); err == nil {
+		return v
+	}
+	return choice
+}
+
+// applySearchSpaceOverrides merges a YAML or JSON file into the declared search space.
+// The file maps param names to the fields to override, f.e.
+//
+//	ma_short_period: {low: 5, high: 50}
+//	bollinger_std_dev: {low: 1.5, high: 2.5, step: 0.1}
+func applySearchSpaceOverrides(space strategyModel.SearchSpace, path string) (strategyModel.SearchSpace, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read search space file: %w", err)
+	}
+
+	// JSON is a subset of YAML, so a single decoder handles both formats
+	var overrides map[string]yaml.Node
+	if err := yaml.Unmarshal(data, &overrides); err != nil {
+		return nil, fmt.Errorf("failed to parse search space file: %w", err)
+	}
+
+	result := make(strategyModel.SearchSpace, len(space))
+	copy(result, space)
+
+	index := make(map[string]int, len(result))
+	for i, p := range result {
+		index[p.Name] = i
+	}
+
+	for name, node := range overrides {
+		i, ok := index[name]
+		if !ok {
+			return nil, fmt.Errorf("search space file: unknown param %s", name)
+		}
+		if err := node.Decode(&result[i]); err != nil {
+			return nil, fmt.Errorf("search space file: param %s: %w", name, err)
+		}
+		result[i].Name = name
+	}
+
+	if err := result.Validate(); err != nil {
+		return nil, fmt.Errorf("search space file: %w", err)
+	}
+
+	return result, nil
+}
@@ -0,0 +1,219 @@
+package optimize
+
+import (
+	optimizeModel "cb_grok/internal/optimize/model"
+	strategyModel "cb_grok/internal/strategy/model"
+	"encoding/json"
+	"fmt"
+	"github.com/c-bata/goptuna"
+	"go.uber.org/zap"
+	"time"
+)
+
+// metricsUserAttr is the trial user attribute holding the JSON encoded optimizeModel.TrialMetrics
+const metricsUserAttr = "metrics"
+
+// defaultStudyName names a study that was not named explicitly
+func defaultStudyName(params optimizeModel.RunOptimizeParams) string {
+	return fmt.Sprintf("%s_%s_%s_%s", params.Symbol, params.Timeframe, params.StrategyType, time.Now().Format("20060102_150405"))
+}
+
+// loadOrCreateStudy returns the stored study with the given name or creates it.
+// A resumed study must optimize the same strategy on the same market.
+func (o *optimize) loadOrCreateStudy(name string, params optimizeModel.RunOptimizeParams) (*optimizeModel.Study, error) {
+	stored, err := o.repo.GetStudyByName(name)
+	if err != nil {
+		o.log.Error("optimize: get study", zap.String("study", name), zap.Error(err))
+		return nil, err
+	}
+
+	if stored != nil {
+		if stored.Symbol != params.Symbol || stored.Timeframe != params.Timeframe || stored.StrategyType != params.StrategyType {
+			return nil, fmt.Errorf("study %s optimizes %s %s on %s, can not resume it with %s %s on %s",
+				name, stored.StrategyType, stored.Symbol, stored.Timeframe, params.StrategyType, params.Symbol, params.Timeframe)
+		}
+		return stored, nil
+	}
+
+	stored = &optimizeModel.Study{
+		Name:         name,
+		Symbol:       params.Symbol,
+		Timeframe:    params.Timeframe,
+		StrategyType: params.StrategyType,
+		Direction:    string(goptuna.StudyDirectionMaximize),
+	}
+	if err := o.repo.InsertStudy(stored); err != nil {
+		o.log.Error("optimize: insert study", zap.String("study", name), zap.Error(err))
+		return nil, err
+	}
+	return stored, nil
+}
+
+// restoreTrials replays the stored trials into the study storage, so the sampler
+// continues from the history of previous runs. The storage numbers trials by their position,
+// so stored numbers left with gaps by unpersisted trials are compacted to match it,
+// otherwise new trials would be numbered onto stored ones.
+func (o *optimize) restoreTrials(study *goptuna.Study, stored *optimizeModel.Study) (int, error) {
+	trials, err := o.repo.GetTrials(stored.ID)
+	if err != nil {
+		o.log.Error("optimize: get trials", zap.String("study", stored.Name), zap.Error(err))
+		return 0, err
+	}
+
+	for i, t := range trials {
+		if t.Number != i {
+			// trials are ordered by number, so number i is free once the previous ones are compacted
+			if err = o.repo.SetTrialNumber(t.ID, i); err != nil {
+				o.log.Error("optimize: renumber trial", zap.String("study", stored.Name), zap.Int("trial", t.Number), zap.Error(err))
+				return 0, err
+			}
+			o.log.Warn("optimize: trial renumbered", zap.String("study", stored.Name), zap.Int("from", t.Number), zap.Int("to", i))
+			t.Number = i
+		}
+
+		frozen, err := toFrozenTrial(t)
+		if err != nil {
+			return 0, fmt.Errorf("restore trial %d of study %s: %w", t.Number, stored.Name, err)
+		}
+		if _, err = study.Storage.CloneTrial(study.ID, frozen); err != nil {
+			return 0, fmt.Errorf("restore trial %d of study %s: %w", t.Number, stored.Name, err)
+		}
+	}
+
+	return len(trials), nil
+}
+
+// persistTrials stores every finished trial received from the study notifications.
+// It returns the errors of the trials that were not stored.
+func (o *optimize) persistTrials(stored *optimizeModel.Study, searchSpace strategyModel.SearchSpace, notify <-chan goptuna.FrozenTrial) []error {
+	var errs []error
+	for frozen := range notify {
+		trial, err := fromFrozenTrial(stored.ID, searchSpace, frozen)
+		if err == nil {
+			err = o.repo.InsertTrial(trial)
+		}
+		if err != nil {
+			o.log.Error("optimize: persist trial",
+				zap.String("study", stored.Name),
+				zap.Int("trial", frozen.Number),
+				zap.Error(err),
+			)
+			errs = append(errs, fmt.Errorf("trial %d of study %s: %w", frozen.Number, stored.Name, err))
+		}
+	}
+	return errs
+}
+
+func fromFrozenTrial(studyID int64, searchSpace strategyModel.SearchSpace, frozen goptuna.FrozenTrial) (*optimizeModel.Trial, error) {
+	params, err := json.Marshal(normalizeParams(searchSpace, frozen.Params))
+	if err != nil {
+		return nil, fmt.Errorf("marshal params: %w", err)
+	}
+	internalParams, err := json.Marshal(frozen.InternalParams)
+	if err != nil {
+		return nil, fmt.Errorf("marshal internal params: %w", err)
+	}
+
+	distributions := make(map[string]json.RawMessage, len(frozen.Distributions))
+	for name, distribution := range frozen.Distributions {
+		distributions[name], err = goptuna.DistributionToJSON(distribution)
+		if err != nil {
+			return nil, fmt.Errorf("marshal distribution of %s: %w", name, err)
+		}
+	}
+	rawDistributions, err := json.Marshal(distributions)
+	if err != nil {
+		return nil, fmt.Errorf("marshal distributions: %w", err)
+	}
+
+	trial := &optimizeModel.Trial{
+		StudyID:        studyID,
+		Number:         frozen.Number,
+		State:          frozen.State.String(),
+		Value:          frozen.Value,
+		Params:         params,
+		InternalParams: internalParams,
+		Distributions:  rawDistributions,
+		StartedAt:      frozen.DatetimeStart,
+	}
+	if metrics := frozen.UserAttrs[metricsUserAttr]; metrics != "" {
+		trial.Metrics = json.RawMessage(metrics)
+	}
+	if !frozen.DatetimeComplete.IsZero() {
+		completedAt := frozen.DatetimeComplete
+		trial.CompletedAt = &completedAt
+	}
+
+	return trial, nil
+}
+
+func toFrozenTrial(t optimizeModel.Trial) (goptuna.FrozenTrial, error) {
+	state, err := parseTrialState(t.State)
+	if err != nil {
+		return goptuna.FrozenTrial{}, err
+	}
+
+	var internalParams map[string]float64
+	if err := json.Unmarshal(t.InternalParams, &internalParams); err != nil {
+		return goptuna.FrozenTrial{}, fmt.Errorf("unmarshal internal params: %w", err)
+	}
+	var rawDistributions map[string]json.RawMessage
+	if err := json.Unmarshal(t.Distributions, &rawDistributions); err != nil {
+		return goptuna.FrozenTrial{}, fmt.Errorf("unmarshal distributions: %w", err)
+	}
+
+	distributions := make(map[string]interface{}, len(rawDistributions))
+	params := make(map[string]interface{}, len(internalParams))
+	for name, raw := range rawDistributions {
+		distribution, err := goptuna.JSONToDistribution(raw)
+		if err != nil {
+			return goptuna.FrozenTrial{}, fmt.Errorf("unmarshal distribution of %s: %w", name, err)
+		}
+		distributions[name] = distribution
+
+		ir, ok := internalParams[name]
+		if !ok {
+			continue
+		}
+		params[name], err = goptuna.ToExternalRepresentation(distribution, ir)
+		if err != nil {
+			return goptuna.FrozenTrial{}, fmt.Errorf("param %s: %w", name, err)
+		}
+	}
+
+	frozen := goptuna.FrozenTrial{
+		Number:             t.Number,
+		State:              state,
+		Value:              t.Value,
+		IntermediateValues: map[int]float64{},
+		DatetimeStart:      t.StartedAt,
+		InternalParams:     internalParams,
+		Params:             params,
+		Distributions:      distributions,
+		UserAttrs:          map[string]string{},
+		SystemAttrs:        map[string]string{},
+	}
+	if len(t.Metrics) > 0 {
+		frozen.UserAttrs[metricsUserAttr] = string(t.Metrics)
+	}
+	if t.CompletedAt != nil {
+		frozen.DatetimeComplete = *t.CompletedAt
+	}
+
+	return frozen, nil
+}
+
+func parseTrialState(s string) (goptuna.TrialState, error) {
+	for _, state := range []goptuna.TrialState{
+		goptuna.TrialStateRunning,
+		goptuna.TrialStateComplete,
+		goptuna.TrialStatePruned,
+		goptuna.TrialStateFail,
+		goptuna.TrialStateWaiting,
+	} {
+		if state.String() == s {
+			return state, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown trial state %q", s)
+}
@@ -0,0 +1,150 @@
+package optimize
+
+import (
+	optimizeModel "cb_grok/internal/optimize/model"
+	"context"
+	"fmt"
+	"github.com/c-bata/goptuna"
+	"go.uber.org/zap"
+	"sort"
+	"testing"
+)
+
+// trialRepo keeps the trials of one study and rejects duplicate numbers like UNIQUE(study_id, number)
+type trialRepo struct {
+	Repository
+	trials []optimizeModel.Trial
+	nextID int64
+}
+
+func (r *trialRepo) InsertTrial(entity *optimizeModel.Trial) error {
+	for _, t := range r.trials {
+		if t.Number == entity.Number {
+			return fmt.Errorf("duplicate trial number %d", entity.Number)
+		}
+	}
+	r.nextID++
+	entity.ID = r.nextID
+	r.trials = append(r.trials, *entity)
+	return nil
+}
+
+func (r *trialRepo) SetTrialNumber(id int64, number int) error {
+	for _, t := range r.trials {
+		if t.Number == number && t.ID != id {
+			return fmt.Errorf("duplicate trial number %d", number)
+		}
+	}
+	for i := range r.trials {
+		if r.trials[i].ID == id {
+			r.trials[i].Number = number
+		}
+	}
+	return nil
+}
+
+func (r *trialRepo) GetTrials(int64) ([]optimizeModel.Trial, error) {
+	trials := append([]optimizeModel.Trial(nil), r.trials...)
+	sort.Slice(trials, func(i, j int) bool { return trials[i].Number < trials[j].Number })
+	return trials, nil
+}
+
+func (r *trialRepo) numbers() []int {
+	trials, _ := r.GetTrials(0)
+	numbers := make([]int, 0, len(trials))
+	for _, t := range trials {
+		numbers = append(numbers, t.Number)
+	}
+	return numbers
+}
+
+// runTrials optimizes x on [0, 1] for n trials, restoring the stored trials first and persisting the new ones
+func runTrials(t *testing.T, o *optimize, stored *optimizeModel.Study, n int) (int, []error) {
+	t.Helper()
+
+	notify := make(chan goptuna.FrozenTrial, 1)
+	study, err := goptuna.CreateStudy(stored.Name,
+		goptuna.StudyOptionDirection(goptuna.StudyDirectionMaximize),
+		goptuna.StudyOptionSampler(goptuna.NewRandomSampler()),
+		goptuna.StudyOptionTrialNotifyChannel(notify),
+		goptuna.StudyOptionLogger(nil),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	restored, err := o.restoreTrials(study, stored)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var persistErrors []error
+	persisted := make(chan struct{})
+	go func() {
+		persistErrors = o.persistTrials(stored, nil, notify)
+		close(persisted)
+	}()
+
+	study.WithContext(context.Background())
+	err = study.Optimize(func(trial goptuna.Trial) (float64, error) {
+		return trial.SuggestFloat("x", 0, 1)
+	}, n)
+	close(notify)
+	<-persisted
+	if err != nil {
+		t.Fatal(err)
+	}
+	return restored, persistErrors
+}
+
+func TestResumeStudyCompactsTrialNumbers(t *testing.T) {
+	repo := &trialRepo{}
+	o := &optimize{log: zap.NewNop(), repo: repo}
+	stored := &optimizeModel.Study{ID: 1, Name: "resume"}
+
+	if _, errs := runTrials(t, o, stored, 4); len(errs) != 0 {
+		t.Fatalf("persist errors = %v", errs)
+	}
+
+	// trial 1 failed to persist in the first run
+	repo.trials = append(repo.trials[:1], repo.trials[2:]...)
+
+	restored, errs := runTrials(t, o, stored, 2)
+	if restored != 3 {
+		t.Errorf("restored = %d, want 3", restored)
+	}
+	if len(errs) != 0 {
+		t.Errorf("persist errors = %v", errs)
+	}
+	if got, want := fmt.Sprint(repo.numbers()), "[0 1 2 3 4]"; got != want {
+		t.Errorf("stored numbers = %s, want %s", got, want)
+	}
+}
+
+func TestPersistTrialsReportsFailures(t *testing.T) {
+	repo := &trialRepo{}
+	o := &optimize{log: zap.NewNop(), repo: repo}
+	stored := &optimizeModel.Study{ID: 1, Name: "failing"}
+
+	// number 1 is taken by a trial the storage does not know about
+	repo.trials = append(repo.trials, optimizeModel.Trial{ID: 100, Number: 1})
+	repo.nextID = 100
+	o.repo = &skipGetTrials{repo}
+
+	_, errs := runTrials(t, o, stored, 3)
+	if len(errs) != 1 {
+		t.Fatalf("persist errors = %v, want one", errs)
+	}
+	result := &studyResult{persistErrors: errs}
+	if result.persistError() == nil {
+		t.Error("unstored trials not reported")
+	}
+}
+
+// skipGetTrials hides the stored trials from the restore
+type skipGetTrials struct {
+	*trialRepo
+}
+
+func (r *skipGetTrials) GetTrials(int64) ([]optimizeModel.Trial, error) {
+	return nil, nil
+}
@@ -0,0 +1,332 @@
+package optimize
+
+import (
+	"bytes"
+	"cb_grok/internal/backtest"
+	optimizeModel "cb_grok/internal/optimize/model"
+	strategyModel "cb_grok/internal/strategy/model"
+	"cb_grok/internal/timeframe"
+	"cb_grok/internal/trader"
+	"cb_grok/pkg/models"
+	"encoding/json"
+	"fmt"
+	"github.com/dnlo/struct2csv"
+	"github.com/go-echarts/go-echarts/v2/charts"
+	"github.com/go-echarts/go-echarts/v2/components"
+	"github.com/go-echarts/go-echarts/v2/opts"
+	"go.uber.org/zap"
+	"strings"
+	"time"
+)
+
+type walkForwardFold struct {
+	index      int
+	trainFrom  int64
+	valFrom    int64
+	valTo      int64
+	params     json.RawMessage
+	trainValue float64
+	result     *backtest.BacktestResult
+	err        error
+}
+
+func (f walkForwardFold) returnPercent() float64 {
+	return backtestReturn(f.result)
+}
+
+// runWalkForward re-optimizes the strategy on rolling windows and validates every fold on the
+// candles right after its train window. Validation equity curves are stitched into one out-of-sample curve.
+// It returns the best trial of the latest fold.
+func (o *optimize) runWalkForward(params optimizeModel.RunOptimizeParams, candles []models.OHLCV, tf timeframe.Timeframe, searchSpace strategyModel.SearchSpace) (*studyResult, error) {
+	if params.StepDays <= 0 {
+		params.StepDays = params.ValSetDays
+	}
+
+	trainCount := tf.Candles(params.TrainSetDays)
+	valCount := tf.Candles(params.ValSetDays)
+	stepCount := tf.Candles(params.StepDays)
+
+	if trainCount <= 0 || valCount <= 0 {
+		return nil, fmt.Errorf("walk-forward requires positive train and validation sets")
+	}
+	if trainCount+valCount > len(candles) {
+		return nil, fmt.Errorf("summary sets is larger than the available data")
+	}
+
+	var (
+		folds         []walkForwardFold
+		latest        *studyResult
+		persistErrors []error
+	)
+	for _, window := range walkForwardWindows(len(candles), trainCount, valCount, stepCount) {
+		trainCandles := candles[window.trainStart:window.valStart]
+		valCandles := candles[window.valStart:window.valEnd]
+
+		fold := walkForwardFold{
+			index:     len(folds) + 1,
+			trainFrom: trainCandles[0].Timestamp,
+			valFrom:   valCandles[0].Timestamp,
+			valTo:     valCandles[len(valCandles)-1].Timestamp,
+		}
+
+		o.log.Info("walk-forward: optimizing fold",
+			zap.Int("fold", fold.index),
+			zap.Time("train_from", time.UnixMilli(fold.trainFrom)),
+			zap.Time("val_from", time.UnixMilli(fold.valFrom)),
+			zap.Time("val_to", time.UnixMilli(fold.valTo)),
+		)
+
+		best, err := o.runStudy(fmt.Sprintf("%s_fold_%d", params.StudyName, fold.index), params, trainCandles, params.TrainSetDays, searchSpace)
+		if err != nil {
+			return nil, err
+		}
+		latest = best
+		persistErrors = append(persistErrors, best.persistErrors...)
+		fold.params = best.params
+		fold.trainValue = best.value
+
+		fold.result, fold.err = o.bt.Run(valCandles, best.strategy)
+		if fold.err != nil {
+			o.log.Error("walk-forward: fold validation backtest", zap.Int("fold", fold.index), zap.Error(fold.err))
+		} else {
+			o.log.Info("walk-forward: fold validated",
+				zap.Int("fold", fold.index),
+				zap.Float64("train_value", fold.trainValue),
+				zap.Float64("validation_sharpe_ratio", fold.result.SharpeRatio),
+				zap.Float64("validation_max_drawdown", fold.result.MaxDrawdown),
+				zap.Float64("validation_return", fold.returnPercent()),
+				zap.Int("validation_orders", len(fold.result.Orders)),
+			)
+		}
+
+		folds = append(folds, fold)
+	}
+
+	equity, initialCapital := stitchEquity(folds)
+	o.reportWalkForward(params, folds, equity, initialCapital)
+
+	// the latest fold reports the unstored trials of every fold
+	latest.persistErrors = persistErrors
+	return latest, nil
+}
+
+// walkForwardWindow holds the candle indexes of a fold, the train set is [trainStart, valStart)
+// and the validation set is [valStart, valEnd)
+type walkForwardWindow struct {
+	trainStart int
+	valStart   int
+	valEnd     int
+}
+
+// walkForwardWindows splits total candles into folds shifted by stepCount candles.
+// Candles after the last complete validation set are left out.
+func walkForwardWindows(total, trainCount, valCount, stepCount int) []walkForwardWindow {
+	if trainCount <= 0 || valCount <= 0 || stepCount <= 0 {
+		return nil
+	}
+
+	var windows []walkForwardWindow
+	for start := 0; start+trainCount+valCount <= total; start += stepCount {
+		windows = append(windows, walkForwardWindow{
+			trainStart: start,
+			valStart:   start + trainCount,
+			valEnd:     start + trainCount + valCount,
+		})
+	}
+	return windows
+}
+
+// stitchEquity chains the validation equity curves of all folds. Every fold starts from the capital
+// the previous one ended with, overlapping validation windows are cut at the start of the next fold.
+// With a step shorter than the validation set the stitched curve therefore covers only the first
+// step of every fold but the last, while the fold metrics cover the whole validation set.
+// It returns the curve and the capital it starts from.
+func stitchEquity(folds []walkForwardFold) ([]trader.PortfolioValue, float64) {
+	var (
+		stitched       []trader.PortfolioValue
+		capital        float64
+		initialCapital float64
+	)
+
+	for i, fold := range folds {
+		if fold.result == nil || fold.result.TradeState == nil {
+			continue
+		}
+		state := fold.result.TradeState
+		initial := state.GetInitialCapital()
+		if initial == 0 {
+			continue
+		}
+		if initialCapital == 0 {
+			initialCapital = initial
+			capital = initial
+		}
+
+		cutoff := int64(-1)
+		if i+1 < len(folds) {
+			cutoff = folds[i+1].valFrom
+		}
+
+		scale := capital / initial
+		for _, v := range state.GetPortfolioValues() {
+			if cutoff >= 0 && v.Timestamp >= cutoff {
+				break
+			}
+			stitched = append(stitched, trader.PortfolioValue{
+				Timestamp: v.Timestamp,
+				Value:     v.Value * scale,
+			})
+		}
+		if len(stitched) > 0 {
+			capital = stitched[len(stitched)-1].Value
+		}
+	}
+
+	return stitched, initialCapital
+}
+
+// equityMaxDrawdown returns the largest peak-to-trough decline of the curve in percent
+func equityMaxDrawdown(equity []trader.PortfolioValue) float64 {
+	if len(equity) == 0 {
+		return 0
+	}
+
+	peak := equity[0].Value
+	maxDrawdown := 0.0
+	for _, v := range equity {
+		if v.Value > peak {
+			peak = v.Value
+		}
+		if peak > 0 {
+			maxDrawdown = max(maxDrawdown, (peak-v.Value)/peak*100)
+		}
+	}
+	return maxDrawdown
+}
+
+func (o *optimize) reportWalkForward(params optimizeModel.RunOptimizeParams, folds []walkForwardFold, equity []trader.PortfolioValue, initialCapital float64) {
+	const dateLayout = "2006-01-02"
+
+	var (
+		summary    strings.Builder
+		profitable int
+	)
+	for _, fold := range folds {
+		if fold.err != nil {
+			fmt.Fprintf(&summary, "#%d %s..%s: ошибка валидации\n", fold.index,
+				time.UnixMilli(fold.valFrom).Format(dateLayout), time.UnixMilli(fold.valTo).Format(dateLayout))
+			continue
+		}
+		if fold.returnPercent() > 0 {
+			profitable++
+		}
+		fmt.Fprintf(&summary, "#%d %s..%s: Sharpe %.2f, DD %.2f%%, Return %.2f%%, Trades %d\n", fold.index,
+			time.UnixMilli(fold.valFrom).Format(dateLayout), time.UnixMilli(fold.valTo).Format(dateLayout),
+			fold.result.SharpeRatio, fold.result.MaxDrawdown, fold.returnPercent(), len(fold.result.Orders))
+	}
+
+	totalReturn := 0.0
+	if len(equity) > 0 && initialCapital != 0 {
+		totalReturn = (equity[len(equity)-1].Value - initialCapital) / initialCapital * 100
+	}
+
+	if params.StepDays < params.ValSetDays {
+		fmt.Fprintf(&summary, "\nШаг короче валидации: метрики фолдов считаются по всей валидации, OOS кривая берёт из фолда только первые %d дн.\n", params.StepDays)
+	}
+
+	result := fmt.Sprintf(
+		"Walk-forward\n\nСимвол: %s\nСтратегия: %s\nTrials: %d\nTimeframe: %s\nTrain/Val/Step дней: %d/%d/%d\nФолдов: %d\nПрибыльных фолдов: %d\nOOS доходность: %.2f%%\nOOS максимальная просадка: %.2f%%\n\n%s",
+		params.Symbol, params.StrategyType, params.Trials, params.Timeframe, params.TrainSetDays, params.ValSetDays, params.StepDays,
+		len(folds), profitable, totalReturn, equityMaxDrawdown(equity), summary.String())
+
+	o.log.Info("walk-forward completed",
+		zap.Int("folds", len(folds)),
+		zap.Int("profitable_folds", profitable),
+		zap.Float64("oos_return", totalReturn),
+		zap.Float64("oos_max_drawdown", equityMaxDrawdown(equity)),
+	)
+
+	buff := &bytes.Buffer{}
+	w := struct2csv.NewWriter(buff)
+	err := w.Write([]string{"fold", "train_from", "val_from", "val_to", "train_value", "val_sharpe", "val_max_drawdown", "val_win_rate", "val_return", "val_orders", "params"})
+	if err != nil {
+		o.log.Error("report: write col names", zap.Error(err))
+	}
+	for _, fold := range folds {
+		row := []string{
+			fmt.Sprint(fold.index),
+			time.UnixMilli(fold.trainFrom).String(),
+			time.UnixMilli(fold.valFrom).String(),
+			time.UnixMilli(fold.valTo).String(),
+			fmt.Sprint(fold.trainValue),
+		}
+		if fold.result != nil {
+			row = append(row,
+				fmt.Sprint(fold.result.SharpeRatio),
+				fmt.Sprint(fold.result.MaxDrawdown),
+				fmt.Sprint(fold.result.WinRate),
+				fmt.Sprint(fold.returnPercent()),
+				fmt.Sprint(len(fold.result.Orders)),
+			)
+		} else {
+			row = append(row, "", "", "", "", "")
+		}
+		row = append(row, string(fold.params))
+		err = w.Write(row)
+		if err != nil {
+			o.log.Error("report: write structs", zap.Error(err))
+		}
+	}
+	w.Flush()
+
+	err = o.tg.SendFile(buff, "csv", result)
+	if err != nil {
+		o.log.Error("report: send to telegram", zap.Error(err))
+	}
+
+	if len(equity) == 0 {
+		return
+	}
+
+	time.Sleep(1000 * time.Millisecond)
+	chartBuff, err := equityChart("Walk-forward out-of-sample equity", equity)
+	if err != nil {
+		o.log.Error("report: generate charts", zap.Error(err))
+		return
+	}
+	err = o.tg.SendFile(chartBuff, "html", "Walk-forward OOS equity")
+	if err != nil {
+		o.log.Error("report: send to telegram", zap.Error(err))
+	}
+}
+
+func equityChart(title string, equity []trader.PortfolioValue) (*bytes.Buffer, error) {
+	line := charts.NewLine()
+	line.SetGlobalOptions(
+		charts.WithTitleOpts(opts.Title{Title: title}),
+		charts.WithYAxisOpts(opts.YAxis{
+			Scale: opts.Bool(true),
+		}),
+		charts.WithDataZoomOpts(opts.DataZoom{
+			Start: 0,
+			End:   100,
+		}),
+	)
+
+	x := make([]string, 0, len(equity))
+	y := make([]opts.LineData, 0, len(equity))
+	for _, v := range equity {
+		x = append(x, time.UnixMilli(v.Timestamp).Format("2006-01-02 15:04"))
+		y = append(y, opts.LineData{Value: v.Value})
+	}
+	line.SetXAxis(x).AddSeries("Equity", y)
+
+	page := components.NewPage()
+	page.AddCharts(line)
+
+	buff := &bytes.Buffer{}
+	if err := page.Render(buff); err != nil {
+		return nil, err
+	}
+	return buff, nil
+}
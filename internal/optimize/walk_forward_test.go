@@ -0,0 +1,174 @@
+package optimize
+
+import (
+	"cb_grok/internal/backtest"
+	"cb_grok/internal/trader"
+	"math"
+	"reflect"
+	"testing"
+)
+
+// curveState is a trade state holding only the capital and the equity curve stitchEquity reads
+type curveState struct {
+	trader.State
+	initialCapital float64
+	values         []trader.PortfolioValue
+}
+
+func (s curveState) GetInitialCapital() float64 {
+	return s.initialCapital
+}
+
+func (s curveState) GetPortfolioValues() []trader.PortfolioValue {
+	return s.values
+}
+
+func curveFold(valFrom int64, initialCapital float64, values ...float64) walkForwardFold {
+	curve := make([]trader.PortfolioValue, 0, len(values))
+	for i, v := range values {
+		curve = append(curve, trader.PortfolioValue{Timestamp: valFrom + int64(i), Value: v})
+	}
+	return walkForwardFold{
+		valFrom: valFrom,
+		result: &backtest.BacktestResult{
+			TradeState: curveState{initialCapital: initialCapital, values: curve},
+		},
+	}
+}
+
+func TestWalkForwardWindows(t *testing.T) {
+	tests := []struct {
+		name                    string
+		total, train, val, step int
+		want                    []walkForwardWindow
+	}{
+		{
+			name:  "step equal to validation",
+			total: 10, train: 4, val: 2, step: 2,
+			want: []walkForwardWindow{{0, 4, 6}, {2, 6, 8}, {4, 8, 10}},
+		},
+		{
+			name:  "incomplete last validation set is dropped",
+			total: 11, train: 4, val: 3, step: 3,
+			want: []walkForwardWindow{{0, 4, 7}, {3, 7, 10}},
+		},
+		{
+			name:  "step shorter than validation overlaps",
+			total: 9, train: 4, val: 3, step: 1,
+			want: []walkForwardWindow{{0, 4, 7}, {1, 5, 8}, {2, 6, 9}},
+		},
+		{
+			name:  "step longer than validation skips candles",
+			total: 12, train: 4, val: 2, step: 5,
+			want: []walkForwardWindow{{0, 4, 6}, {5, 9, 11}},
+		},
+		{
+			name:  "not enough candles",
+			total: 5, train: 4, val: 2, step: 2,
+		},
+		{
+			name:  "zero step",
+			total: 10, train: 4, val: 2, step: 0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := walkForwardWindows(tt.total, tt.train, tt.val, tt.step)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("windows = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStitchEquity(t *testing.T) {
+	tests := []struct {
+		name        string
+		folds       []walkForwardFold
+		want        []float64
+		wantInitial float64
+	}{
+		{
+			name: "folds continue from the previous capital",
+			folds: []walkForwardFold{
+				curveFold(0, 100, 110, 120),
+				curveFold(2, 100, 90, 150),
+			},
+			// the second fold is scaled by 120/100
+			want:        []float64{110, 120, 108, 180},
+			wantInitial: 100,
+		},
+		{
+			name: "overlapping validation is cut at the next fold",
+			folds: []walkForwardFold{
+				curveFold(0, 100, 110, 120, 130),
+				curveFold(1, 100, 200, 50),
+			},
+			// the first fold keeps only its value before timestamp 1, the second starts from 110
+			want:        []float64{110, 220, 55},
+			wantInitial: 100,
+		},
+		{
+			name: "failed and empty folds are skipped",
+			folds: []walkForwardFold{
+				{valFrom: 0},
+				curveFold(2, 0, 10),
+				curveFold(4, 200, 210, 220),
+				curveFold(6, 100, 50),
+			},
+			want:        []float64{210, 220, 110},
+			wantInitial: 200,
+		},
+		{
+			name:  "no folds",
+			folds: nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			equity, initial := stitchEquity(tt.folds)
+			if initial != tt.wantInitial {
+				t.Errorf("initial capital = %v, want %v", initial, tt.wantInitial)
+			}
+			if len(equity) != len(tt.want) {
+				t.Fatalf("equity = %v, want values %v", equity, tt.want)
+			}
+			for i := range tt.want {
+				if math.Abs(equity[i].Value-tt.want[i]) > 1e-9 {
+					t.Errorf("value %d = %v, want %v", i, equity[i].Value, tt.want[i])
+				}
+				if i > 0 && equity[i].Timestamp <= equity[i-1].Timestamp {
+					t.Errorf("timestamp %d = %d is not after %d", i, equity[i].Timestamp, equity[i-1].Timestamp)
+				}
+			}
+		})
+	}
+}
+
+func TestEquityMaxDrawdown(t *testing.T) {
+	curve := func(values ...float64) []trader.PortfolioValue {
+		equity := make([]trader.PortfolioValue, 0, len(values))
+		for i, v := range values {
+			equity = append(equity, trader.PortfolioValue{Timestamp: int64(i), Value: v})
+		}
+		return equity
+	}
+
+	tests := []struct {
+		name   string
+		equity []trader.PortfolioValue
+		want   float64
+	}{
+		{name: "empty", equity: nil, want: 0},
+		{name: "rising", equity: curve(100, 110, 120), want: 0},
+		{name: "deepest drop wins", equity: curve(100, 80, 120, 90, 130), want: 25},
+		{name: "drop from the first value", equity: curve(100, 60, 70), want: 40},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := equityMaxDrawdown(tt.equity); math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("max drawdown = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,75 @@
+package order_model
+
+import "time"
+
+// Execution represents the order_execution table, a single fill of an order
+type Execution struct {
+	ID      int64   `db:"id"`
+	OrderID int64   `db:"order_id"`
+	ExtID   string  `db:"ext_id"`
+	Price   float64 `db:"price"`
+	// Qty is the executed base quantity and Value its quote value, both before fees
+	Qty   float64 `db:"qty"`
+	Value float64 `db:"value"`
+	// Fee is charged in FeeCurrency, on spot usually the received coin
+	Fee         float64   `db:"fee"`
+	FeeCurrency string    `db:"fee_currency"`
+	IsMaker     bool      `db:"is_maker"`
+	ExecutedAt  time.Time `db:"executed_at"`
+}
+
+// Fills sums the executions of an order
+type Fills struct {
+	Qty   float64
+	Value float64
+	// Spent is the quote amount of buys and the base amount of sells, fees charged in that coin included
+	Spent float64
+	// Received is the base amount of buys and the quote amount of sells, fees charged in that coin excluded
+	Received float64
+	// Fees holds the fee totals per coin
+	Fees map[string]float64
+	// QuoteFee values the base and quote fees in quote at the execution price. Fees in a third coin,
+	// f.e. BNB on Binance, reduce neither side and are not valued.
+	QuoteFee float64
+}
+
+// SumExecutions sums the executions of an order of the base/quote symbol. A fee is deducted from the coin
+// it was charged in, fees without a known coin are deducted from the received coin.
+func SumExecutions(side OrderSide, executions []Execution, base, quote string) Fills {
+	fills := Fills{Fees: make(map[string]float64)}
+	spentCoin, receivedCoin := quote, base
+	if side == OrderSideSell {
+		spentCoin, receivedCoin = base, quote
+	}
+
+	for _, e := range executions {
+		fills.Qty += e.Qty
+		fills.Value += e.Value
+		if e.Fee != 0 {
+			fills.Fees[e.FeeCurrency] += e.Fee
+		}
+
+		spent, received := e.Value, e.Qty
+		if side == OrderSideSell {
+			spent, received = e.Qty, e.Value
+		}
+		var receivedFee, spentFee float64
+		switch e.FeeCurrency {
+		case receivedCoin, "":
+			receivedFee = e.Fee
+		case spentCoin:
+			spentFee = e.Fee
+		}
+		fills.Spent += spent + spentFee
+		fills.Received += received - receivedFee
+
+		switch {
+		case e.FeeCurrency == quote && quote != "", e.FeeCurrency == "" && side == OrderSideSell:
+			fills.QuoteFee += e.Fee
+		case e.FeeCurrency == base || e.FeeCurrency == "":
+			fills.QuoteFee += e.Fee * e.Price
+		}
+	}
+
+	return fills
+}
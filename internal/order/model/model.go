@@ -20,6 +20,23 @@ type Order struct {
 	TakeProfitPrice *float64   `db:"tp_price"`
 	StopLossPrice   *float64   `db:"sl_price"`
 	TraderID        int64      `db:"trader_id"`
+	// ParentID links an exchange-side take-profit or stop-loss order to its entry order
+	ParentID *int64 `db:"parent_id"`
+	// Price is the limit price, nil for market orders
+	Price       *float64 `db:"price"`
+	TimeInForce *string  `db:"time_in_force"`
+	// Fee is the commission of the executed quantity in FeeCurrency
+	Fee         *float64 `db:"fee"`
+	FeeCurrency *string  `db:"fee_currency"`
+}
+
+// IsOpen reports whether the order can still be filled, amended or canceled
+func (o Order) IsOpen() bool {
+	switch OrderStatus(o.StatusID) {
+	case OrderStatusNew, OrderStatusPlaced, OrderStatusPartiallyFilled:
+		return true
+	}
+	return false
 }
 
 // Exchange represents the exchange table
@@ -29,10 +46,11 @@ type Exchange struct {
 }
 
 type Symbol struct {
-	ID    int64  `db:"id"`
-	Code  string `db:"code"`
-	Base  string `db:"base"`
-	Quote string `db:"quote"`
+	ID       int64  `db:"id"`
+	Code     string `db:"code"`
+	Base     string `db:"base"`
+	Quote    string `db:"quote"`
+	Decimals int64  `db:"decimals"`
 }
 
 type OrderStatus int64
@@ -42,6 +60,8 @@ const (
 	OrderStatusPlaced   OrderStatus = 2
 	OrderStatusFilled   OrderStatus = 3
 	OrderStatusCanceled OrderStatus = 4
+	// OrderStatusPartiallyFilled is an open limit order with some quantity executed
+	OrderStatusPartiallyFilled OrderStatus = 5
 )
 
 const (
@@ -49,7 +69,10 @@ const (
 )
 
 const (
-	OrderTypeMarket int64 = 1
+	OrderTypeMarket     int64 = 1
+	OrderTypeTakeProfit int64 = 2
+	OrderTypeStopLoss   int64 = 3
+	OrderTypeLimit      int64 = 4
 )
 
 type OrderSide int64
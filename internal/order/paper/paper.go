@@ -0,0 +1,407 @@
+package paper
+
+import (
+	"cb_grok/internal/exchange"
+	"cb_grok/internal/order"
+	order_model "cb_grok/internal/order/model"
+	symbolModel "cb_grok/internal/symbol/model"
+	"cb_grok/pkg/models"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/samber/lo"
+	"strings"
+	"sync"
+	"time"
+)
+
+// balanceTolerance absorbs float rounding when a whole balance is spent
+const balanceTolerance = 1e-9
+
+type Settings struct {
+	Commission      float64
+	SlippagePercent float64
+	Spread          float64
+}
+
+// Broker is an in-memory order usecase. Market orders are filled immediately at the
+// last observed candle close, resting limit orders against the following candles,
+// so backtests and simulations do not need a database or an exchange.
+type Broker interface {
+	order.Order
+	order.PriceObserver
+
+	GetBalances() (cash float64, base float64)
+	GetOrders(traderID int64) []order_model.Order
+}
+
+type broker struct {
+	mu sync.Mutex
+
+	settings Settings
+	ex       exchange.Exchange
+
+	cash float64
+	base float64
+
+	lastPrice     float64
+	lastTimestamp int64
+
+	nextID     int64
+	orders     map[int64][]order_model.Order
+	executions map[int64][]order_model.Execution
+	symbols    map[int64]symbolModel.Symbol
+}
+
+func New(settings Settings, initialCash float64) Broker {
+	return &broker{
+		settings:   settings,
+		cash:       initialCash,
+		orders:     make(map[int64][]order_model.Order),
+		executions: make(map[int64][]order_model.Execution),
+		symbols:    make(map[int64]symbolModel.Symbol),
+	}
+}
+
+func (b *broker) Init(ctx context.Context, traderID int64, ex exchange.Exchange) {
+	b.ex = ex
+}
+
+func (b *broker) ObservePrice(candle models.OHLCV) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.lastPrice = candle.Close
+	b.lastTimestamp = candle.Timestamp
+
+	b.fillRestingOrders(candle)
+}
+
+// CreateSpotMarketOrder follows the bybit spot semantics used by the trader:
+// buy quantity is the quote amount to spend, sell quantity is the base amount.
+// QuoteQty of the stored order holds the amount received net of commission.
+func (b *broker) CreateSpotMarketOrder(symbol symbolModel.Symbol, side exchange.OrderSide, baseQty float64, takeProfit *float64, stopLoss *float64, traderID int64) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	ord, err := b.newOrder(symbol, side, baseQty, takeProfit, stopLoss, traderID)
+	if err != nil {
+		return err
+	}
+	ord.TypeID = order_model.OrderTypeMarket
+
+	if err := b.fill(&ord, b.marketFillPrice(side), false); err != nil {
+		return err
+	}
+	b.orders[traderID] = append(b.orders[traderID], ord)
+
+	return nil
+}
+
+// CreateSpotLimitOrder places a limit order with the quantity semantics of CreateSpotMarketOrder.
+// Marketable orders fill right away at the market price capped by the limit, except post-only
+// orders which are canceled instead. Resting orders fill at the limit price once a candle trades through it.
+func (b *broker) CreateSpotLimitOrder(symbol symbolModel.Symbol, side exchange.OrderSide, baseQty float64, price float64, timeInForce exchange.TimeInForce, takeProfit *float64, stopLoss *float64, traderID int64) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if price <= 0 {
+		return fmt.Errorf("paper: invalid limit price %f", price)
+	}
+	if timeInForce == "" {
+		timeInForce = exchange.TimeInForceGTC
+	}
+
+	ord, err := b.newOrder(symbol, side, baseQty, takeProfit, stopLoss, traderID)
+	if err != nil {
+		return err
+	}
+	ord.TypeID = order_model.OrderTypeLimit
+	ord.Price = lo.ToPtr(price)
+	ord.TimeInForce = lo.ToPtr(string(timeInForce))
+
+	switch {
+	case b.marketable(ord, price) && timeInForce == exchange.TimeInForcePostOnly:
+		ord.StatusID = int64(order_model.OrderStatusCanceled)
+	case b.marketable(ord, price):
+		fillPrice := b.marketFillPrice(side)
+		if side == exchange.OrderSideBuy {
+			fillPrice = min(fillPrice, price)
+		} else {
+			fillPrice = max(fillPrice, price)
+		}
+		if err := b.fill(&ord, fillPrice, false); err != nil {
+			return err
+		}
+	case timeInForce == exchange.TimeInForceIOC || timeInForce == exchange.TimeInForceFOK:
+		ord.StatusID = int64(order_model.OrderStatusCanceled)
+	default:
+		ord.StatusID = int64(order_model.OrderStatusPlaced)
+	}
+	b.orders[traderID] = append(b.orders[traderID], ord)
+
+	return nil
+}
+
+func (b *broker) CancelOrder(orderID int64) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	ord := b.findOrder(orderID)
+	if ord == nil {
+		return fmt.Errorf("paper: order %d not found", orderID)
+	}
+	if ord.IsOpen() {
+		ord.StatusID = int64(order_model.OrderStatusCanceled)
+		ord.UpdatedAt = lo.ToPtr(time.UnixMilli(b.lastTimestamp))
+	}
+	return nil
+}
+
+func (b *broker) AmendOrder(orderID int64, baseQty *float64, price *float64) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	ord := b.findOrder(orderID)
+	if ord == nil {
+		return fmt.Errorf("paper: order %d not found", orderID)
+	}
+	if ord.TypeID != order_model.OrderTypeLimit || !ord.IsOpen() {
+		return fmt.Errorf("paper: order %d is not an open limit order", orderID)
+	}
+	if baseQty != nil && *baseQty <= 0 {
+		return fmt.Errorf("paper: invalid order qty %f", *baseQty)
+	}
+	if price != nil {
+		if *price <= 0 {
+			return fmt.Errorf("paper: invalid limit price %f", *price)
+		}
+		if lo.FromPtr(ord.TimeInForce) == string(exchange.TimeInForcePostOnly) && b.marketable(*ord, *price) {
+			return fmt.Errorf("paper: post-only order %d would take liquidity at %f", orderID, *price)
+		}
+		ord.Price = lo.ToPtr(*price)
+	}
+	if baseQty != nil {
+		ord.BaseQty = lo.ToPtr(*baseQty)
+	}
+	ord.UpdatedAt = lo.ToPtr(time.UnixMilli(b.lastTimestamp))
+
+	return nil
+}
+
+func (b *broker) newOrder(symbol symbolModel.Symbol, side exchange.OrderSide, baseQty float64, takeProfit *float64, stopLoss *float64, traderID int64) (order_model.Order, error) {
+	if b.lastPrice <= 0 {
+		return order_model.Order{}, errors.New("paper: no market price observed")
+	}
+	if baseQty <= 0 {
+		return order_model.Order{}, fmt.Errorf("paper: invalid order qty %f", baseQty)
+	}
+
+	var sideID order_model.OrderSide
+	switch side {
+	case exchange.OrderSideBuy:
+		if baseQty > b.cash*(1+balanceTolerance) {
+			return order_model.Order{}, fmt.Errorf("paper: insufficient quote balance: %f < %f", b.cash, baseQty)
+		}
+		sideID = order_model.OrderSideBuy
+	case exchange.OrderSideSell:
+		if baseQty > b.base*(1+balanceTolerance) {
+			return order_model.Order{}, fmt.Errorf("paper: insufficient base balance: %f < %f", b.base, baseQty)
+		}
+		sideID = order_model.OrderSideSell
+	default:
+		return order_model.Order{}, fmt.Errorf("paper: unsupported order side: %s", side)
+	}
+
+	b.nextID++
+	createdAt := time.UnixMilli(b.lastTimestamp)
+	b.symbols[symbol.ID] = symbol
+
+	return order_model.Order{
+		ID:              b.nextID,
+		SymbolID:        symbol.ID,
+		SideID:          int64(sideID),
+		StatusID:        int64(order_model.OrderStatusNew),
+		BaseQty:         lo.ToPtr(baseQty),
+		ExtID:           fmt.Sprintf("paper-%d", b.nextID),
+		CreatedAt:       createdAt,
+		UpdatedAt:       lo.ToPtr(createdAt),
+		TakeProfitPrice: takeProfit,
+		StopLossPrice:   stopLoss,
+		TraderID:        traderID,
+	}, nil
+}
+
+// fill executes the order at fillPrice in a single execution and moves the balances.
+// The commission is charged in the received coin like on bybit spot.
+func (b *broker) fill(ord *order_model.Order, fillPrice float64, maker bool) error {
+	qty := lo.FromPtr(ord.BaseQty)
+	symbol := b.symbols[ord.SymbolID]
+
+	execution := order_model.Execution{
+		OrderID:    ord.ID,
+		ExtID:      fmt.Sprintf("%s-%d", ord.ExtID, len(b.executions[ord.ID])+1),
+		Price:      fillPrice,
+		IsMaker:    maker,
+		ExecutedAt: time.UnixMilli(b.lastTimestamp),
+	}
+	var received float64
+	if ord.SideID == int64(order_model.OrderSideBuy) {
+		if qty > b.cash*(1+balanceTolerance) {
+			return fmt.Errorf("paper: insufficient quote balance: %f < %f", b.cash, qty)
+		}
+		filled := qty / fillPrice
+		execution.Qty, execution.Value = filled, qty
+		execution.Fee, execution.FeeCurrency = filled*b.settings.Commission, symbol.Base
+		received = filled - execution.Fee
+
+		b.cash = max(b.cash-qty, 0)
+		b.base += received
+	} else {
+		if qty > b.base*(1+balanceTolerance) {
+			return fmt.Errorf("paper: insufficient base balance: %f < %f", b.base, qty)
+		}
+		value := qty * fillPrice
+		execution.Qty, execution.Value = qty, value
+		execution.Fee, execution.FeeCurrency = value*b.settings.Commission, symbol.Quote
+		received = value - execution.Fee
+
+		b.base = max(b.base-qty, 0)
+		b.cash += received
+	}
+	b.executions[ord.ID] = append(b.executions[ord.ID], execution)
+	ord.Fee, ord.FeeCurrency = lo.ToPtr(execution.Fee), lo.ToPtr(execution.FeeCurrency)
+
+	ord.StatusID = int64(order_model.OrderStatusFilled)
+	ord.QuoteQty = lo.ToPtr(received)
+	ord.UpdatedAt = lo.ToPtr(time.UnixMilli(b.lastTimestamp))
+	return nil
+}
+
+func (b *broker) marketFillPrice(side exchange.OrderSide) float64 {
+	if side == exchange.OrderSideBuy {
+		return b.lastPrice * (1 + b.settings.SlippagePercent + b.settings.Spread/2)
+	}
+	return b.lastPrice * (1 - b.settings.SlippagePercent - b.settings.Spread/2)
+}
+
+// marketable reports whether a limit order at price would trade against the last price right away
+func (b *broker) marketable(ord order_model.Order, price float64) bool {
+	if ord.SideID == int64(order_model.OrderSideBuy) {
+		return price > b.lastPrice
+	}
+	return price < b.lastPrice
+}
+
+// fillRestingOrders fills open limit orders the candle traded through. Gaps fill at the open.
+func (b *broker) fillRestingOrders(candle models.OHLCV) {
+	for traderID := range b.orders {
+		orders := b.orders[traderID]
+		for i := range orders {
+			ord := &orders[i]
+			if ord.TypeID != order_model.OrderTypeLimit || !ord.IsOpen() || ord.Price == nil {
+				continue
+			}
+
+			price := *ord.Price
+			var fillPrice float64
+			switch {
+			case ord.SideID == int64(order_model.OrderSideBuy) && candle.Low <= price:
+				fillPrice = min(price, candle.Open)
+			case ord.SideID == int64(order_model.OrderSideSell) && candle.High >= price:
+				fillPrice = max(price, candle.Open)
+			default:
+				continue
+			}
+
+			if err := b.fill(ord, fillPrice, true); err != nil {
+				ord.StatusID = int64(order_model.OrderStatusCanceled)
+				ord.UpdatedAt = lo.ToPtr(time.UnixMilli(b.lastTimestamp))
+			}
+		}
+	}
+}
+
+func (b *broker) findOrder(orderID int64) *order_model.Order {
+	for traderID := range b.orders {
+		orders := b.orders[traderID]
+		for i := range orders {
+			if orders[i].ID == orderID {
+				return &orders[i]
+			}
+		}
+	}
+	return nil
+}
+
+// SyncOrders is a no-op: paper orders are filled synchronously.
+func (b *broker) SyncOrders(ctx context.Context) {}
+
+func (b *broker) GetActiveOrders(ctx context.Context) ([]order_model.Order, error) {
+	return nil, nil
+}
+
+func (b *broker) GetSymbolByCode(code string) (*order_model.Symbol, error) {
+	parts := strings.Split(code, "/")
+	if len(parts) != 2 {
+		return &order_model.Symbol{Code: code}, nil
+	}
+	return &order_model.Symbol{Code: code, Base: parts[0], Quote: parts[1]}, nil
+}
+
+func (b *broker) GetLastOrder(traderID int64) (*order_model.Order, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	// canceled orders that were never filled do not change the position
+	orders := b.orders[traderID]
+	for i := len(orders) - 1; i >= 0; i-- {
+		if orders[i].StatusID == int64(order_model.OrderStatusCanceled) && orders[i].QuoteQty == nil {
+			continue
+		}
+		last := orders[i]
+		return &last, nil
+	}
+	return nil, nil
+}
+
+func (b *broker) GetFilledOrders(traderID int64, afterID int64) ([]order_model.Order, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	// orders are kept in ID order, the scan stops at the booked ones
+	orders := b.orders[traderID]
+	start := len(orders)
+	for start > 0 && orders[start-1].ID > afterID {
+		start--
+	}
+
+	var filled []order_model.Order
+	for _, ord := range orders[start:] {
+		if ord.StatusID == int64(order_model.OrderStatusFilled) {
+			filled = append(filled, ord)
+		}
+	}
+	return filled, nil
+}
+
+func (b *broker) GetOrderExecutions(orderID int64) ([]order_model.Execution, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return append([]order_model.Execution(nil), b.executions[orderID]...), nil
+}
+
+func (b *broker) GetBalances() (float64, float64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.cash, b.base
+}
+
+func (b *broker) GetOrders(traderID int64) []order_model.Order {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return append([]order_model.Order(nil), b.orders[traderID]...)
+}
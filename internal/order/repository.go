@@ -7,9 +7,19 @@ type Repository interface {
 	InsertOrder(order *order_model.Order) error
 	UpdateOrderStatus(orderID int64, statusID int) error
 	UpdateOrderQuoteQty(orderID int64, quoteQty float64) error
+	UpdateOrderPriceQty(orderID int64, baseQty *float64, price *float64) error
+	UpdateOrderFee(orderID int64, fee float64, feeCurrency string) error
+	GetOrderByID(orderID int64) (*order_model.Order, error)
+	GetOrderByExtID(extID string) (*order_model.Order, error)
 	GetActiveOrders() ([]order_model.Order, error)
 	GetLastOrder(traderID int64) (*order_model.Order, error)
+	// GetFilledOrders returns the filled orders of the trader with IDs above afterID, oldest first
+	GetFilledOrders(traderID int64, afterID int64) ([]order_model.Order, error)
+	GetChildOrders(parentID int64) ([]order_model.Order, error)
+	InsertExecutions(executions []order_model.Execution) error
+	GetOrderExecutions(orderID int64) ([]order_model.Execution, error)
 	GetExchangeByName(name string) (*order_model.Exchange, error)
 	UpdateOrderExtID(orderID int64, extID string) error
 	GetSymbolByCode(code string) (*order_model.Symbol, error)
+	GetSymbolByID(id int64) (*order_model.Symbol, error)
 }
@@ -22,8 +22,9 @@ func (r *repo) InsertOrder(order *order_model.Order) error {
 	query := `
 		INSERT INTO public.order (
 			symbol_id, exch_id, type_id, side_id, status_id, 
-			base_qty, quote_qty, ext_id, created_at, updated_at, tp_price, sl_price, trader_id
-		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+			base_qty, quote_qty, ext_id, created_at, updated_at, tp_price, sl_price, trader_id, parent_id,
+			price, time_in_force
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16)
 		RETURNING id
 	`
 	var id int64
@@ -41,6 +42,9 @@ func (r *repo) InsertOrder(order *order_model.Order) error {
 		order.TakeProfitPrice,
 		order.StopLossPrice,
 		order.TraderID,
+		order.ParentID,
+		order.Price,
+		order.TimeInForce,
 	)
 	if err != nil {
 		return fmt.Errorf("failed to insert order: %w", err)
@@ -85,30 +89,113 @@ func (r *repo) UpdateOrderQuoteQty(orderID int64, quoteQty float64) error {
 	return nil
 }
 
+func (r *repo) UpdateOrderPriceQty(orderID int64, baseQty *float64, price *float64) error {
+	query := `
+		UPDATE public.order 
+		SET base_qty = COALESCE($1, base_qty), price = COALESCE($2, price), updated_at = CURRENT_TIMESTAMP
+		WHERE id = $3
+		RETURNING id
+	`
+	var id int64
+	err := r.db.Get(&id, query, baseQty, price, orderID)
+	if err != nil {
+		return fmt.Errorf("failed to update order price and qty: %w", err)
+	}
+	if id == 0 {
+		return errors.New("order not found")
+	}
+	return nil
+}
+
+func (r *repo) UpdateOrderFee(orderID int64, fee float64, feeCurrency string) error {
+	query := `
+		UPDATE public.order 
+		SET fee = $1, fee_currency = $2, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $3
+		RETURNING id
+	`
+	var id int64
+	err := r.db.Get(&id, query, fee, feeCurrency, orderID)
+	if err != nil {
+		return fmt.Errorf("failed to update order fee: %w", err)
+	}
+	if id == 0 {
+		return errors.New("order not found")
+	}
+	return nil
+}
+
+func (r *repo) GetOrderByID(orderID int64) (*order_model.Order, error) {
+	var orders []order_model.Order
+	query := `
+		SELECT o.id, o.symbol_id, o.exch_id, o.type_id, o.side_id, o.status_id,
+			o.base_qty, o.quote_qty, o.ext_id, o.created_at, o.updated_at, o.tp_price, o.sl_price, o.trader_id, o.parent_id,
+			o.price, o.time_in_force, o.fee, o.fee_currency
+		FROM public.order o
+		WHERE o.id = $1
+	`
+	err := r.db.Select(&orders, query, orderID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get order by id: %w", err)
+	}
+	if len(orders) == 0 {
+		return nil, errors.New("order not found")
+	}
+	return &orders[0], nil
+}
+
+// GetOrderByExtID returns the order with the exchange order id, nil when there is none
+func (r *repo) GetOrderByExtID(extID string) (*order_model.Order, error) {
+	var orders []order_model.Order
+	query := `
+		SELECT o.id, o.symbol_id, o.exch_id, o.type_id, o.side_id, o.status_id,
+			o.base_qty, o.quote_qty, o.ext_id, o.created_at, o.updated_at, o.tp_price, o.sl_price, o.trader_id, o.parent_id,
+			o.price, o.time_in_force, o.fee, o.fee_currency
+		FROM public.order o
+		WHERE o.ext_id = $1
+		ORDER BY o.id DESC LIMIT 1
+	`
+	err := r.db.Select(&orders, query, extID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get order by ext id: %w", err)
+	}
+	if len(orders) == 0 {
+		return nil, nil
+	}
+	return &orders[0], nil
+}
+
 func (r *repo) GetActiveOrders() ([]order_model.Order, error) {
 	var orders []order_model.Order
 	query := `
 		SELECT o.id, o.symbol_id, o.exch_id, o.type_id, o.side_id, o.status_id,
-			o.base_qty, o.quote_qty, o.ext_id, o.created_at, o.updated_at, o.tp_price, o.sl_price, o.trader_id
+			o.base_qty, o.quote_qty, o.ext_id, o.created_at, o.updated_at, o.tp_price, o.sl_price, o.trader_id, o.parent_id,
+			o.price, o.time_in_force, o.fee, o.fee_currency
 		FROM public.order o
 		JOIN public.order_status os ON o.status_id = os.id
-		WHERE os.code IN ('new', 'placed') or quote_qty is NULL
+		WHERE os.code IN ('new', 'placed', 'partially_filled') or (quote_qty is NULL AND o.status_id <> $1)
 	`
-	err := r.db.Select(&orders, query)
+	err := r.db.Select(&orders, query, int64(order_model.OrderStatusCanceled))
 	if err != nil {
 		return nil, fmt.Errorf("failed to get active orders: %w", err)
 	}
 	return orders, nil
 }
 
+// GetLastOrder returns the last entry or exit of the trader. Exchange-side exit orders
+// only count once they are filled, canceled orders that were never filled are skipped.
 func (r *repo) GetLastOrder(traderID int64) (*order_model.Order, error) {
 	var orders []order_model.Order
 	query := `
 		SELECT o.id, o.symbol_id, o.exch_id, o.type_id, o.side_id, o.status_id,
-			o.base_qty, o.quote_qty, o.ext_id, o.created_at, o.updated_at, o.tp_price, o.sl_price
-		FROM public.order o where trader_id=$1 ORDER BY o.id desc LIMIT 1
+			o.base_qty, o.quote_qty, o.ext_id, o.created_at, o.updated_at, o.tp_price, o.sl_price, o.trader_id, o.parent_id,
+			o.price, o.time_in_force, o.fee, o.fee_currency
+		FROM public.order o
+		WHERE trader_id=$1 AND (o.parent_id IS NULL OR o.status_id = $2)
+			AND (o.status_id <> $3 OR o.quote_qty IS NOT NULL)
+		ORDER BY o.id desc LIMIT 1
 	`
-	err := r.db.Select(&orders, query, traderID)
+	err := r.db.Select(&orders, query, traderID, int64(order_model.OrderStatusFilled), int64(order_model.OrderStatusCanceled))
 	if err != nil {
 		return nil, fmt.Errorf("failed to get active orders: %w", err)
 	}
@@ -119,6 +206,82 @@ func (r *repo) GetLastOrder(traderID int64) (*order_model.Order, error) {
 	return &orders[0], nil
 }
 
+func (r *repo) GetFilledOrders(traderID int64, afterID int64) ([]order_model.Order, error) {
+	var orders []order_model.Order
+	query := `
+		SELECT o.id, o.symbol_id, o.exch_id, o.type_id, o.side_id, o.status_id,
+			o.base_qty, o.quote_qty, o.ext_id, o.created_at, o.updated_at, o.tp_price, o.sl_price, o.trader_id, o.parent_id,
+			o.price, o.time_in_force, o.fee, o.fee_currency
+		FROM public.order o
+		WHERE trader_id=$1 AND o.id > $2 AND o.status_id = $3
+		ORDER BY o.id ASC
+	`
+	err := r.db.Select(&orders, query, traderID, afterID, int64(order_model.OrderStatusFilled))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get filled orders: %w", err)
+	}
+	return orders, nil
+}
+
+func (r *repo) GetChildOrders(parentID int64) ([]order_model.Order, error) {
+	var orders []order_model.Order
+	query := `
+		SELECT o.id, o.symbol_id, o.exch_id, o.type_id, o.side_id, o.status_id,
+			o.base_qty, o.quote_qty, o.ext_id, o.created_at, o.updated_at, o.tp_price, o.sl_price, o.trader_id, o.parent_id,
+			o.price, o.time_in_force, o.fee, o.fee_currency
+		FROM public.order o
+		WHERE o.parent_id = $1
+		ORDER BY o.id
+	`
+	err := r.db.Select(&orders, query, parentID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get child orders: %w", err)
+	}
+	return orders, nil
+}
+
+// InsertExecutions stores fills of orders, fills that are already stored are skipped
+func (r *repo) InsertExecutions(executions []order_model.Execution) error {
+	query := `
+		INSERT INTO public.order_execution (
+			order_id, ext_id, price, qty, value, fee, fee_currency, is_maker, executed_at
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		ON CONFLICT (order_id, ext_id) DO NOTHING
+	`
+	for _, e := range executions {
+		_, err := r.db.Exec(query,
+			e.OrderID,
+			e.ExtID,
+			e.Price,
+			e.Qty,
+			e.Value,
+			e.Fee,
+			e.FeeCurrency,
+			e.IsMaker,
+			e.ExecutedAt,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to insert execution %s: %w", e.ExtID, err)
+		}
+	}
+	return nil
+}
+
+func (r *repo) GetOrderExecutions(orderID int64) ([]order_model.Execution, error) {
+	var executions []order_model.Execution
+	query := `
+		SELECT id, order_id, ext_id, price, qty, value, fee, fee_currency, is_maker, executed_at
+		FROM public.order_execution
+		WHERE order_id = $1
+		ORDER BY executed_at, id
+	`
+	err := r.db.Select(&executions, query, orderID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get order executions: %w", err)
+	}
+	return executions, nil
+}
+
 func (r *repo) GetExchangeByName(name string) (*order_model.Exchange, error) {
 	var result []order_model.Exchange
 	query := `
@@ -157,7 +320,7 @@ func (r *repo) UpdateOrderExtID(orderID int64, extID string) error {
 func (r *repo) GetSymbolByCode(code string) (*order_model.Symbol, error) {
 	var result []order_model.Symbol
 	query := `
-		SELECT id, code, base, quote 
+		SELECT id, code, base, quote, decimals
 		FROM public.symbol 
 		WHERE code = $1
 	`
@@ -170,3 +333,20 @@ func (r *repo) GetSymbolByCode(code string) (*order_model.Symbol, error) {
 	}
 	return &result[0], nil
 }
+
+func (r *repo) GetSymbolByID(id int64) (*order_model.Symbol, error) {
+	var result []order_model.Symbol
+	query := `
+		SELECT id, code, base, quote, decimals
+		FROM public.symbol 
+		WHERE id = $1
+	`
+	err := r.db.Select(&result, query, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get symbol by id: %w", err)
+	}
+	if len(result) == 0 {
+		return nil, errors.New("symbol not found")
+	}
+	return &result[0], nil
+}
@@ -4,16 +4,47 @@ import (
 	"cb_grok/internal/exchange"
 	order_model "cb_grok/internal/order/model"
 	symbolModel "cb_grok/internal/symbol/model"
+	"cb_grok/pkg/models"
 	"context"
+	"errors"
 )
 
 // Order interface definition
 type Order interface {
-	Init(ex exchange.Exchange)
+	// Init sets the exchange the trader trades on and keeps its orders in sync until ctx is done
+	Init(ctx context.Context, traderID int64, ex exchange.Exchange)
 
 	CreateSpotMarketOrder(symbol symbolModel.Symbol, side exchange.OrderSide, baseQty float64, takeProfit *float64, stopLoss *float64, traderID int64) error
+	// CreateSpotLimitOrder places a limit order with the same quantity semantics as CreateSpotMarketOrder:
+	// buy quantity is the quote amount to spend at the limit price, sell quantity is the base amount
+	CreateSpotLimitOrder(symbol symbolModel.Symbol, side exchange.OrderSide, baseQty float64, price float64, timeInForce exchange.TimeInForce, takeProfit *float64, stopLoss *float64, traderID int64) error
+	CancelOrder(orderID int64) error
+	// AmendOrder changes the quantity and/or price of an open limit order, nil values are kept
+	AmendOrder(orderID int64, baseQty *float64, price *float64) error
 	SyncOrders(ctx context.Context)
 	GetActiveOrders(ctx context.Context) ([]order_model.Order, error)
 	GetSymbolByCode(code string) (*order_model.Symbol, error)
 	GetLastOrder(traderID int64) (*order_model.Order, error)
+	// GetFilledOrders returns the filled orders of the trader with IDs above afterID, oldest first.
+	// An entry and its exchange-side exit may both fill before the trader sees the last order.
+	GetFilledOrders(traderID int64, afterID int64) ([]order_model.Order, error)
+	// GetOrderExecutions returns the fills of an order, the position and PnL of the trader are booked from them
+	GetOrderExecutions(orderID int64) ([]order_model.Execution, error)
 }
+
+// PriceObserver is implemented by order usecases that fill orders against the
+// last seen candle instead of a real exchange (paper trading, backtests).
+type PriceObserver interface {
+	ObservePrice(candle models.OHLCV)
+}
+
+// ExchangeExits is implemented by order usecases that can protect entries with exchange-side
+// take-profit and stop-loss orders. The trader leaves the exits resting on the exchange to it and
+// checks the others by itself, f.e. a leg the exchange rejected.
+type ExchangeExits interface {
+	// ExchangeExits reports whether the take-profit and the stop-loss of the entry rest on the exchange
+	ExchangeExits(entry order_model.Order) (takeProfit bool, stopLoss bool)
+}
+
+// ErrPositionClosed is returned for an exit when an exchange-side exit order has already closed the position
+var ErrPositionClosed = errors.New("position is already closed by an exchange-side exit order")
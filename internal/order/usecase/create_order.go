@@ -2,6 +2,7 @@ package usecase
 
 import (
 	"cb_grok/internal/exchange"
+	"cb_grok/internal/order"
 	"cb_grok/internal/order/model"
 	symbolModel "cb_grok/internal/symbol/model"
 	"errors"
@@ -11,32 +12,73 @@ import (
 )
 
 func (u *orderUC) CreateSpotMarketOrder(symbol symbolModel.Symbol, side exchange.OrderSide, baseQty float64, takeProfit *float64, stopLoss *float64, traderID int64) error {
-	if u.ex == nil {
-		return errors.New("exchange not set")
+	ex, err := u.exchange(traderID)
+	if err != nil {
+		return err
+	}
+	info, err := u.instrumentInfo(ex, symbol.Code)
+	if err != nil {
+		return err
+	}
+	baseQty, err = marketQty(info, side, baseQty)
+	if err != nil {
+		u.log.Error("order rejected", zap.String("symbol", symbol.Code), zap.Error(err))
+		return err
 	}
 
-	exch, err := u.repo.GetExchangeByName(u.ex.Name())
+	ord, err := u.newOrder(ex, symbol, side, baseQty, roundPrice(info, takeProfit), roundPrice(info, stopLoss), traderID)
 	if err != nil {
-		u.log.Error("failed to get exchange by name", zap.Error(err))
 		return err
 	}
+	ord.TypeID = order_model.OrderTypeMarket
+
+	err = u.repo.InsertOrder(ord)
+	if err != nil {
+		return err
+	}
+
+	orderId, err := ex.PlaceSpotMarketOrder(symbol.Code, side, baseQty, nil, nil)
+	if err != nil {
+		u.log.Error("create order failed", zap.Error(err))
+		if updErr := u.repo.UpdateOrderStatus(ord.ID, int(order_model.OrderStatusCanceled)); updErr != nil {
+			u.log.Error("failed to cancel unplaced order", zap.Int64("order_id", ord.ID), zap.Error(updErr))
+		}
+		return err
+	}
+
+	return u.setExtID(ord, orderId)
+}
+
+// newOrder prepares the database row of a new entry or exit. Exchange-side exits of the
+// position are canceled before a sell, so the position can be sold by the trader.
+func (u *orderUC) newOrder(ex exchange.Exchange, symbol symbolModel.Symbol, side exchange.OrderSide, baseQty float64, takeProfit *float64, stopLoss *float64, traderID int64) (*order_model.Order, error) {
+	exch, err := u.repo.GetExchangeByName(ex.Name())
+	if err != nil {
+		u.log.Error("failed to get exchange by name", zap.Error(err))
+		return nil, err
+	}
 
 	sideId := int64(1)
 	if side == exchange.OrderSideSell {
 		sideId = int64(2)
 	}
 
+	if side == exchange.OrderSideSell && u.exchangeExits {
+		if err := u.releaseExitOrders(ex, traderID); err != nil {
+			u.log.Error("failed to cancel exit orders", zap.Int64("trader_id", traderID), zap.Error(err))
+			return nil, err
+		}
+	}
+
 	symbolValue, err := u.repo.GetSymbolByCode(symbol.Code)
 	if err != nil {
 		u.log.Error("failed to get symbol by code", zap.Error(err))
-		return err
+		return nil, err
 	}
 
-	// Insert order into database
-	ord := &order_model.Order{
+	return &order_model.Order{
 		ExchangeID:      exch.ID,
 		SymbolID:        symbolValue.ID,
-		TypeID:          order_model.OrderTypeMarket,
 		SideID:          sideId,
 		StatusID:        int64(order_model.OrderStatusNew),
 		BaseQty:         lo.ToPtr(baseQty),
@@ -47,27 +89,39 @@ func (u *orderUC) CreateSpotMarketOrder(symbol symbolModel.Symbol, side exchange
 		TakeProfitPrice: takeProfit,
 		StopLossPrice:   stopLoss,
 		TraderID:        traderID,
-	}
-	err = u.repo.InsertOrder(ord)
-	if err != nil {
-		return err
-	}
+	}, nil
+}
 
-	orderId, err := u.ex.PlaceSpotMarketOrder(symbol.Code, side, baseQty, nil, nil, symbol.Decimals)
-	if err != nil {
-		u.log.Error("create order failed", zap.Error(err))
-		return err
-	}
+func (u *orderUC) setExtID(ord *order_model.Order, orderId string) error {
 	if orderId == "" {
 		u.log.Error("create order failed: order ID is empty")
 		return errors.New("order ID is empty")
 	}
 
-	err = u.repo.UpdateOrderExtID(ord.ID, orderId)
+	err := u.repo.UpdateOrderExtID(ord.ID, orderId)
 	if err != nil {
 		u.log.Error("failed to update order external ID", zap.Int64("order_id", ord.ID), zap.Error(err))
 		return err
 	}
+	ord.ExtID = orderId
 
 	return nil
 }
+
+// releaseExitOrders cancels the exchange-side exits of the open position, so the position can be sold by signal
+func (u *orderUC) releaseExitOrders(ex exchange.Exchange, traderID int64) error {
+	entry, err := u.repo.GetLastOrder(traderID)
+	if err != nil {
+		return err
+	}
+	if entry == nil {
+		return nil
+	}
+	if entry.ParentID != nil {
+		return order.ErrPositionClosed
+	}
+	if entry.SideID != int64(order_model.OrderSideBuy) {
+		return nil
+	}
+	return u.cancelExitOrders(ex, *entry)
+}
@@ -0,0 +1,423 @@
+package usecase_test
+
+import (
+	"cb_grok/internal/exchange"
+	"cb_grok/internal/exchange/bybit"
+	"cb_grok/internal/exchange/bybit/emulator"
+	"cb_grok/internal/order"
+	order_model "cb_grok/internal/order/model"
+	"cb_grok/internal/order/usecase"
+	symbolModel "cb_grok/internal/symbol/model"
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/samber/lo"
+	"go.uber.org/zap"
+)
+
+const (
+	testTraderID = 7
+	testPrice    = 60000.0
+)
+
+var testSymbol = symbolModel.Symbol{ID: 1, Code: "BTCUSDT", Base: "BTC", Quote: "USDT"}
+
+// memRepo keeps orders and executions in memory with the semantics of the postgres repository
+type memRepo struct {
+	mu         sync.Mutex
+	orders     []*order_model.Order
+	executions []order_model.Execution
+}
+
+func (r *memRepo) InsertOrder(order *order_model.Order) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	order.ID = int64(len(r.orders) + 1)
+	stored := *order
+	r.orders = append(r.orders, &stored)
+	return nil
+}
+
+func (r *memRepo) update(orderID int64, apply func(o *order_model.Order)) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if orderID < 1 || int(orderID) > len(r.orders) {
+		return fmt.Errorf("order %d not found", orderID)
+	}
+	apply(r.orders[orderID-1])
+	return nil
+}
+
+func (r *memRepo) UpdateOrderStatus(orderID int64, statusID int) error {
+	return r.update(orderID, func(o *order_model.Order) { o.StatusID = int64(statusID) })
+}
+
+func (r *memRepo) UpdateOrderQuoteQty(orderID int64, quoteQty float64) error {
+	return r.update(orderID, func(o *order_model.Order) { o.QuoteQty = lo.ToPtr(quoteQty) })
+}
+
+func (r *memRepo) UpdateOrderPriceQty(orderID int64, baseQty *float64, price *float64) error {
+	return r.update(orderID, func(o *order_model.Order) {
+		if baseQty != nil {
+			o.BaseQty = lo.ToPtr(*baseQty)
+		}
+		if price != nil {
+			o.Price = lo.ToPtr(*price)
+		}
+	})
+}
+
+func (r *memRepo) UpdateOrderFee(orderID int64, fee float64, feeCurrency string) error {
+	return r.update(orderID, func(o *order_model.Order) {
+		o.Fee, o.FeeCurrency = lo.ToPtr(fee), lo.ToPtr(feeCurrency)
+	})
+}
+
+func (r *memRepo) UpdateOrderExtID(orderID int64, extID string) error {
+	return r.update(orderID, func(o *order_model.Order) { o.ExtID = extID })
+}
+
+func (r *memRepo) find(match func(o order_model.Order) bool) []order_model.Order {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var orders []order_model.Order
+	for _, o := range r.orders {
+		if match(*o) {
+			orders = append(orders, *o)
+		}
+	}
+	return orders
+}
+
+func (r *memRepo) GetOrderByID(orderID int64) (*order_model.Order, error) {
+	orders := r.find(func(o order_model.Order) bool { return o.ID == orderID })
+	if len(orders) == 0 {
+		return nil, fmt.Errorf("order %d not found", orderID)
+	}
+	return &orders[0], nil
+}
+
+func (r *memRepo) GetOrderByExtID(extID string) (*order_model.Order, error) {
+	orders := r.find(func(o order_model.Order) bool { return o.ExtID == extID })
+	if len(orders) == 0 {
+		return nil, nil
+	}
+	return &orders[0], nil
+}
+
+func (r *memRepo) GetActiveOrders() ([]order_model.Order, error) {
+	return r.find(func(o order_model.Order) bool {
+		return o.IsOpen() || (o.QuoteQty == nil && o.StatusID != int64(order_model.OrderStatusCanceled))
+	}), nil
+}
+
+func (r *memRepo) GetLastOrder(traderID int64) (*order_model.Order, error) {
+	orders := r.find(func(o order_model.Order) bool {
+		return o.TraderID == traderID &&
+			(o.ParentID == nil || o.StatusID == int64(order_model.OrderStatusFilled)) &&
+			(o.StatusID != int64(order_model.OrderStatusCanceled) || o.QuoteQty != nil)
+	})
+	if len(orders) == 0 {
+		return nil, nil
+	}
+	return &orders[len(orders)-1], nil
+}
+
+func (r *memRepo) GetFilledOrders(traderID int64, afterID int64) ([]order_model.Order, error) {
+	return r.find(func(o order_model.Order) bool {
+		return o.TraderID == traderID && o.ID > afterID && o.StatusID == int64(order_model.OrderStatusFilled)
+	}), nil
+}
+
+func (r *memRepo) GetChildOrders(parentID int64) ([]order_model.Order, error) {
+	return r.find(func(o order_model.Order) bool { return o.ParentID != nil && *o.ParentID == parentID }), nil
+}
+
+func (r *memRepo) InsertExecutions(executions []order_model.Execution) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, e := range executions {
+		stored := false
+		for _, s := range r.executions {
+			stored = stored || (s.OrderID == e.OrderID && s.ExtID == e.ExtID)
+		}
+		if !stored {
+			e.ID = int64(len(r.executions) + 1)
+			r.executions = append(r.executions, e)
+		}
+	}
+	return nil
+}
+
+func (r *memRepo) GetOrderExecutions(orderID int64) ([]order_model.Execution, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var executions []order_model.Execution
+	for _, e := range r.executions {
+		if e.OrderID == orderID {
+			executions = append(executions, e)
+		}
+	}
+	sort.SliceStable(executions, func(i, j int) bool { return executions[i].ExecutedAt.Before(executions[j].ExecutedAt) })
+	return executions, nil
+}
+
+func (r *memRepo) GetExchangeByName(name string) (*order_model.Exchange, error) {
+	return &order_model.Exchange{ID: 1, Name: name}, nil
+}
+
+func (r *memRepo) GetSymbolByCode(code string) (*order_model.Symbol, error) {
+	return r.GetSymbolByID(testSymbol.ID)
+}
+
+func (r *memRepo) GetSymbolByID(id int64) (*order_model.Symbol, error) {
+	return &order_model.Symbol{ID: testSymbol.ID, Code: testSymbol.Code, Base: testSymbol.Base, Quote: testSymbol.Quote}, nil
+}
+
+// emulatorSetup starts an emulator holding 10000 USDT without a running feed, the tests move it candle by candle
+func emulatorSetup(t *testing.T, depth float64) (*emulator.Server, *memRepo, order.Order) {
+	t.Helper()
+
+	cfg := emulator.DefaultConfig()
+	market := emulator.DefaultSymbol(testSymbol.Code, testPrice)
+	market.Depth = depth
+	cfg.Symbols = []emulator.SymbolConfig{market}
+	cfg.APIKey, cfg.APISecret = "key", "secret"
+	cfg.StartTime = time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	cfg.HistoryCandles = 60
+	cfg.TickInterval = 0
+	srv, err := emulator.NewServer(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(srv.Close)
+
+	ex, err := bybit.NewBybit(cfg.APIKey, cfg.APISecret, exchange.TradingModeLive,
+		bybit.WithBaseURL(srv.URL), bybit.WithPublicWSURL(srv.PublicWSURL()), bybit.WithPrivateWSURL(srv.PrivateWSURL()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	repo := &memRepo{}
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	uc := usecase.New(repo, zap.NewNop(), usecase.WithExchangeExits(true))
+	uc.Init(ctx, testTraderID, ex)
+
+	// orders are booked from the account stream
+	deadline := time.Now().Add(5 * time.Second)
+	for !srv.Subscribed("order") {
+		if time.Now().After(deadline) {
+			t.Fatal("the order usecase did not subscribe to the account stream")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	return srv, repo, uc
+}
+
+// waitFor polls the booked orders until cond holds, the account stream books them asynchronously
+func waitFor(t *testing.T, repo *memRepo, what string, cond func(orders []order_model.Order) bool) []order_model.Order {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		orders := repo.find(func(order_model.Order) bool { return true })
+		if cond(orders) {
+			return orders
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s, booked orders: %s", what, formatOrders(orders))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func formatOrders(orders []order_model.Order) string {
+	s := ""
+	for _, o := range orders {
+		s += fmt.Sprintf("\n  id=%d type=%d side=%d status=%d base=%v quote=%v ext=%s parent=%v",
+			o.ID, o.TypeID, o.SideID, o.StatusID, lo.FromPtr(o.BaseQty), lo.FromPtr(o.QuoteQty), o.ExtID, lo.FromPtr(o.ParentID))
+	}
+	return s
+}
+
+func hasStatus(o order_model.Order, status order_model.OrderStatus) bool {
+	return o.StatusID == int64(status)
+}
+
+func TestMarketEntryExitsOnEmulator(t *testing.T) {
+	srv, repo, uc := emulatorSetup(t, 0)
+
+	price := srv.Price(testSymbol.Code)
+	takeProfit, stopLoss := price*1.05, price*0.95
+	if err := uc.CreateSpotMarketOrder(testSymbol, exchange.OrderSideBuy, 1000, &takeProfit, &stopLoss, testTraderID); err != nil {
+		t.Fatal(err)
+	}
+
+	// the filled entry is booked with what it received and protected by both exit legs
+	orders := waitFor(t, repo, "entry fill and exit legs", func(orders []order_model.Order) bool {
+		return len(orders) == 3 && orders[0].QuoteQty != nil && orders[1].ExtID != "" && orders[2].ExtID != ""
+	})
+	entry, tp, sl := orders[0], orders[1], orders[2]
+	if !hasStatus(entry, order_model.OrderStatusFilled) {
+		t.Errorf("entry status = %d, want filled", entry.StatusID)
+	}
+	received := *entry.QuoteQty
+	if want := srv.Balance(testSymbol.Base); math.Abs(received-want) > 1e-12 {
+		t.Errorf("entry received = %v, want the wallet balance %v", received, want)
+	}
+	executions, _ := repo.GetOrderExecutions(entry.ID)
+	if len(executions) != 1 || executions[0].FeeCurrency != "BTC" {
+		t.Errorf("entry executions = %+v, want one fill charged in BTC", executions)
+	}
+	if entry.Fee == nil || *entry.Fee != executions[0].Fee {
+		t.Errorf("entry fee = %v, want %v", lo.FromPtr(entry.Fee), executions[0].Fee)
+	}
+	for _, leg := range []struct {
+		order  order_model.Order
+		typeID int64
+	}{{tp, order_model.OrderTypeTakeProfit}, {sl, order_model.OrderTypeStopLoss}} {
+		if leg.order.TypeID != leg.typeID || lo.FromPtr(leg.order.ParentID) != entry.ID || !leg.order.IsOpen() {
+			t.Errorf("exit leg = %+v, want an open type %d leg of the entry", leg.order, leg.typeID)
+		}
+		// the legs sell what the entry received, floored to the lot step
+		if qty := lo.FromPtr(leg.order.BaseQty); qty > received || received-qty >= 0.000001 {
+			t.Errorf("exit leg qty = %v, received %v", qty, received)
+		}
+	}
+
+	// a short candle series within the exits leaves them resting
+	for i := 0; i < 5; i++ {
+		srv.Step(time.Minute)
+		if p := srv.Price(testSymbol.Code); p >= takeProfit || p <= stopLoss {
+			t.Fatalf("the series left the exit range at %v", p)
+		}
+	}
+	for _, o := range repo.find(func(o order_model.Order) bool { return o.ParentID != nil }) {
+		if !o.IsOpen() {
+			t.Fatalf("exit leg %d closed within the exit range", o.ID)
+		}
+	}
+
+	// the take-profit fires, the exchange deactivates the stop-loss and both are booked
+	if err := srv.SetPrice(testSymbol.Code, takeProfit+10); err != nil {
+		t.Fatal(err)
+	}
+	orders = waitFor(t, repo, "take-profit fill", func(orders []order_model.Order) bool {
+		return hasStatus(orders[1], order_model.OrderStatusFilled) && orders[1].QuoteQty != nil &&
+			hasStatus(orders[2], order_model.OrderStatusCanceled)
+	})
+	if got := *orders[1].QuoteQty; got < lo.FromPtr(orders[1].BaseQty)*takeProfit*0.99 {
+		t.Errorf("take-profit received %v USDT, want about %v", got, lo.FromPtr(orders[1].BaseQty)*takeProfit)
+	}
+	if dust := srv.Balance(testSymbol.Base); dust >= 0.000001 {
+		t.Errorf("BTC left after the take-profit = %v, want dust below the lot step", dust)
+	}
+	last, _ := repo.GetLastOrder(testTraderID)
+	if last == nil || last.ID != orders[1].ID {
+		t.Errorf("last order = %+v, want the take-profit", last)
+	}
+}
+
+func TestRejectedMarketOrderIsCanceled(t *testing.T) {
+	_, repo, uc := emulatorSetup(t, 0)
+
+	// the wallet holds 10000 USDT, the exchange rejects the order
+	if err := uc.CreateSpotMarketOrder(testSymbol, exchange.OrderSideBuy, 20000, nil, nil, testTraderID); err == nil {
+		t.Fatal("market order over the balance placed")
+	}
+	orders := repo.find(func(order_model.Order) bool { return true })
+	if len(orders) != 1 || !hasStatus(orders[0], order_model.OrderStatusCanceled) || orders[0].ExtID != "" {
+		t.Fatalf("booked orders: %s, want one canceled unplaced order", formatOrders(orders))
+	}
+	if last, _ := repo.GetLastOrder(testTraderID); last != nil && last.IsOpen() {
+		t.Errorf("last order = %+v, want no open order", last)
+	}
+}
+
+func TestPartiallyFilledLimitEntryOnEmulator(t *testing.T) {
+	// resting orders fill 0.005 BTC per match
+	srv, repo, uc := emulatorSetup(t, 0.005)
+
+	price := srv.Price(testSymbol.Code)
+	limitPrice := math.Round(price*0.99*100) / 100
+	takeProfit, stopLoss := price*1.05, price*0.95
+	// 1200 USDT buy about 0.02 BTC at the limit price
+	if err := uc.CreateSpotLimitOrder(testSymbol, exchange.OrderSideBuy, 1200, limitPrice, exchange.TimeInForceGTC, &takeProfit, &stopLoss, testTraderID); err != nil {
+		t.Fatal(err)
+	}
+	orders := waitFor(t, repo, "resting entry", func(orders []order_model.Order) bool {
+		return len(orders) == 1 && orders[0].ExtID != ""
+	})
+	if !orders[0].IsOpen() {
+		t.Fatalf("entry status = %d, want resting", orders[0].StatusID)
+	}
+
+	// two matches at the limit price fill 0.01 BTC of it
+	if err := srv.SetPrice(testSymbol.Code, limitPrice-5); err != nil {
+		t.Fatal(err)
+	}
+	if err := srv.SetPrice(testSymbol.Code, limitPrice-10); err != nil {
+		t.Fatal(err)
+	}
+	entry := waitFor(t, repo, "partial fill", func(orders []order_model.Order) bool {
+		return hasStatus(orders[0], order_model.OrderStatusPartiallyFilled)
+	})[0]
+	if entry.QuoteQty != nil {
+		t.Errorf("partially filled entry booked as received %v", *entry.QuoteQty)
+	}
+
+	// the timed out entry is canceled and booked with its executed part, the exits protect that part
+	if err := uc.CancelOrder(entry.ID); err != nil {
+		t.Fatal(err)
+	}
+	orders = waitFor(t, repo, "partial entry and exit legs", func(orders []order_model.Order) bool {
+		return len(orders) == 3 && orders[1].ExtID != "" && orders[2].ExtID != ""
+	})
+	entry = orders[0]
+	if !hasStatus(entry, order_model.OrderStatusFilled) || entry.QuoteQty == nil {
+		t.Fatalf("canceled entry = %+v, want booked as filled", entry)
+	}
+	executions, _ := repo.GetOrderExecutions(entry.ID)
+	if len(executions) != 2 {
+		t.Errorf("entry executions = %d, want 2", len(executions))
+	}
+	fills := order_model.SumExecutions(order_model.OrderSideBuy, executions, testSymbol.Base, testSymbol.Quote)
+	if math.Abs(fills.Qty-0.01) > 1e-12 {
+		t.Errorf("executed qty = %v, want 0.01", fills.Qty)
+	}
+	if math.Abs(*entry.QuoteQty-fills.Received) > 1e-12 || math.Abs(*entry.BaseQty-fills.Spent) > 1e-9 {
+		t.Errorf("entry booked spent %v received %v, want %v and %v", *entry.BaseQty, *entry.QuoteQty, fills.Spent, fills.Received)
+	}
+	if want := srv.Balance(testSymbol.Base); math.Abs(*entry.QuoteQty-want) > 1e-12 {
+		t.Errorf("entry received = %v, want the wallet balance %v", *entry.QuoteQty, want)
+	}
+	for _, leg := range orders[1:] {
+		if qty := lo.FromPtr(leg.BaseQty); qty > *entry.QuoteQty || *entry.QuoteQty-qty >= 0.000001 {
+			t.Errorf("exit leg qty = %v, received %v", qty, *entry.QuoteQty)
+		}
+	}
+
+	// the stop-loss sells the executed part
+	if err := srv.SetPrice(testSymbol.Code, stopLoss-10); err != nil {
+		t.Fatal(err)
+	}
+	sl := waitFor(t, repo, "stop-loss fill", func(orders []order_model.Order) bool {
+		return hasStatus(orders[2], order_model.OrderStatusFilled) && orders[2].QuoteQty != nil &&
+			hasStatus(orders[1], order_model.OrderStatusCanceled)
+	})[2]
+	// the wallet matches the booked spend and proceeds
+	if usdt, want := srv.Balance(testSymbol.Quote), 10000-fills.Spent+*sl.QuoteQty; math.Abs(usdt-want) > 1e-6 {
+		t.Errorf("USDT after the stop-loss = %v, want %v", usdt, want)
+	}
+}
@@ -0,0 +1,236 @@
+package usecase
+
+import (
+	"cb_grok/internal/exchange"
+	"cb_grok/internal/order"
+	order_model "cb_grok/internal/order/model"
+	"fmt"
+	"github.com/samber/lo"
+	"go.uber.org/zap"
+	"time"
+)
+
+// placeExitOrders protects a filled entry with exchange-side take-profit and stop-loss orders
+// for the received base quantity. Entries that already have exit orders are skipped. Exchanges
+// locking the balance of conditional orders get both legs as one OCO order list.
+func (u *orderUC) placeExitOrders(ex exchange.Exchange, entry order_model.Order, qty float64) error {
+	if !u.exchangeExits || entry.ParentID != nil || entry.SideID != int64(order_model.OrderSideBuy) {
+		return nil
+	}
+	if entry.TakeProfitPrice == nil && entry.StopLossPrice == nil {
+		return nil
+	}
+
+	legs, err := u.repo.GetChildOrders(entry.ID)
+	if err != nil {
+		return err
+	}
+	if len(legs) > 0 {
+		return nil
+	}
+
+	symbol, err := u.repo.GetSymbolByID(entry.SymbolID)
+	if err != nil {
+		return err
+	}
+	info, err := u.instrumentInfo(ex, symbol.Code)
+	if err != nil {
+		return err
+	}
+	// never sell more than was received
+	qty = info.FloorQty(qty)
+
+	var exits []exitLeg
+	for _, leg := range []struct {
+		typeID       int64
+		triggerPrice *float64
+	}{
+		{typeID: order_model.OrderTypeTakeProfit, triggerPrice: entry.TakeProfitPrice},
+		{typeID: order_model.OrderTypeStopLoss, triggerPrice: entry.StopLossPrice},
+	} {
+		if leg.triggerPrice == nil {
+			continue
+		}
+		triggerPrice := info.RoundPrice(*leg.triggerPrice)
+		if err := info.ValidateQty(qty, triggerPrice); err != nil {
+			return fmt.Errorf("exit order for entry %d rejected: %w", entry.ID, err)
+		}
+
+		ord := &order_model.Order{
+			ExchangeID:      entry.ExchangeID,
+			SymbolID:        entry.SymbolID,
+			TypeID:          leg.typeID,
+			SideID:          int64(order_model.OrderSideSell),
+			StatusID:        int64(order_model.OrderStatusNew),
+			BaseQty:         lo.ToPtr(qty),
+			CreatedAt:       time.Now(),
+			TakeProfitPrice: entry.TakeProfitPrice,
+			StopLossPrice:   entry.StopLossPrice,
+			TraderID:        entry.TraderID,
+			ParentID:        lo.ToPtr(entry.ID),
+		}
+		err = u.repo.InsertOrder(ord)
+		if err != nil {
+			return err
+		}
+		exits = append(exits, exitLeg{order: ord, triggerPrice: triggerPrice})
+	}
+
+	if oco, ok := ex.(exchange.OCOPlacer); ok && len(exits) == 2 {
+		takeProfitID, stopLossID, err := oco.PlaceSpotOCOOrder(symbol.Code, exchange.OrderSideSell, qty, exits[0].triggerPrice, exits[1].triggerPrice)
+		if err != nil {
+			u.cancelUnplacedExitOrders(exits)
+			return fmt.Errorf("failed to place exit orders for entry %d: %w", entry.ID, err)
+		}
+		exits[0].extID, exits[1].extID = takeProfitID, stopLossID
+		for _, exit := range exits {
+			if err := u.bindExitOrder(entry, exit, qty); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	for i, exit := range exits {
+		exit.extID, err = ex.PlaceSpotConditionalOrder(symbol.Code, exchange.OrderSideSell, qty, exit.triggerPrice)
+		if err != nil {
+			// the trader checks the exits left without an order by itself
+			u.cancelUnplacedExitOrders(exits[i:])
+			return fmt.Errorf("failed to place exit order for entry %d: %w", entry.ID, err)
+		}
+		if err := u.bindExitOrder(entry, exit, qty); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// exitLeg is an exit order of an entry being placed
+type exitLeg struct {
+	order        *order_model.Order
+	triggerPrice float64
+	extID        string
+}
+
+// bindExitOrder stores the exchange id of a placed exit order
+func (u *orderUC) bindExitOrder(entry order_model.Order, exit exitLeg, qty float64) error {
+	if err := u.repo.UpdateOrderExtID(exit.order.ID, exit.extID); err != nil {
+		return err
+	}
+	u.log.Info("exit order placed",
+		zap.Int64("entry_id", entry.ID),
+		zap.Int64("order_type", exit.order.TypeID),
+		zap.String("ext_id", exit.extID),
+		zap.Float64("trigger_price", exit.triggerPrice),
+		zap.Float64("qty", qty),
+	)
+	return nil
+}
+
+func (u *orderUC) cancelUnplacedExitOrders(exits []exitLeg) {
+	for _, exit := range exits {
+		if err := u.repo.UpdateOrderStatus(exit.order.ID, int(order_model.OrderStatusCanceled)); err != nil {
+			u.log.Error("failed to cancel unplaced exit order", zap.Int64("order_id", exit.order.ID), zap.Error(err))
+		}
+	}
+}
+
+// ExchangeExits reports whether the take-profit and the stop-loss of the entry rest on the exchange
+func (u *orderUC) ExchangeExits(entry order_model.Order) (bool, bool) {
+	if !u.exchangeExits {
+		return false, false
+	}
+	legs, err := u.repo.GetChildOrders(entry.ID)
+	if err != nil {
+		u.log.Error("failed to get exit orders", zap.Int64("entry_id", entry.ID), zap.Error(err))
+		return false, false
+	}
+
+	var takeProfit, stopLoss bool
+	for _, leg := range legs {
+		if leg.ExtID == "" || !leg.IsOpen() {
+			continue
+		}
+		switch leg.TypeID {
+		case order_model.OrderTypeTakeProfit:
+			takeProfit = true
+		case order_model.OrderTypeStopLoss:
+			stopLoss = true
+		}
+	}
+	return takeProfit, stopLoss
+}
+
+// cancelExitOrders cancels the exit orders of the entry before the trader exits by itself.
+// It returns order.ErrPositionClosed when one of them has already been filled.
+func (u *orderUC) cancelExitOrders(ex exchange.Exchange, entry order_model.Order) error {
+	legs, err := u.repo.GetChildOrders(entry.ID)
+	if err != nil {
+		return err
+	}
+	for _, leg := range legs {
+		if leg.StatusID == int64(order_model.OrderStatusFilled) {
+			return order.ErrPositionClosed
+		}
+		if err := u.cancelExitOrder(ex, leg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// cancelSiblingExitOrders cancels the other exit orders of the entry once one of them fired
+func (u *orderUC) cancelSiblingExitOrders(ex exchange.Exchange, fired order_model.Order) {
+	legs, err := u.repo.GetChildOrders(*fired.ParentID)
+	if err != nil {
+		u.log.Error("failed to get exit orders", zap.Int64("entry_id", *fired.ParentID), zap.Error(err))
+		return
+	}
+
+	u.log.Info("exit order fired",
+		zap.Int64("entry_id", *fired.ParentID),
+		zap.Int64("order_type", fired.TypeID),
+		zap.String("ext_id", fired.ExtID),
+	)
+
+	for _, leg := range legs {
+		if leg.ID == fired.ID {
+			continue
+		}
+		if err := u.cancelExitOrder(ex, leg); err != nil {
+			u.log.Error("failed to cancel exit order", zap.Int64("order_id", leg.ID), zap.Error(err))
+		}
+	}
+}
+
+func (u *orderUC) cancelExitOrder(ex exchange.Exchange, leg order_model.Order) error {
+	if leg.StatusID == int64(order_model.OrderStatusCanceled) || leg.StatusID == int64(order_model.OrderStatusFilled) {
+		return nil
+	}
+
+	if leg.ExtID != "" {
+		symbol, err := u.repo.GetSymbolByID(leg.SymbolID)
+		if err != nil {
+			return err
+		}
+		err = ex.CancelOrder(symbol.Code, leg.ExtID)
+		if err != nil {
+			// the leg may have fired in the meantime, or been canceled along with its OCO sibling
+			status, statusErr := ex.GetOrderStatus(leg.ExtID)
+			switch {
+			case statusErr != nil:
+				return err
+			case status == order_model.OrderStatusFilled:
+				if err := u.repo.UpdateOrderStatus(leg.ID, int(status)); err != nil {
+					return err
+				}
+				return order.ErrPositionClosed
+			case status != order_model.OrderStatusCanceled:
+				return err
+			}
+		}
+	}
+
+	return u.repo.UpdateOrderStatus(leg.ID, int(order_model.OrderStatusCanceled))
+}
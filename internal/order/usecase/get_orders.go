@@ -16,3 +16,11 @@ func (u *orderUC) GetActiveOrders(ctx context.Context) ([]order_model.Order, err
 func (u *orderUC) GetLastOrder(traderID int64) (*order_model.Order, error) {
 	return u.repo.GetLastOrder(traderID)
 }
+
+func (u *orderUC) GetFilledOrders(traderID int64, afterID int64) ([]order_model.Order, error) {
+	return u.repo.GetFilledOrders(traderID, afterID)
+}
+
+func (u *orderUC) GetOrderExecutions(orderID int64) ([]order_model.Execution, error) {
+	return u.repo.GetOrderExecutions(orderID)
+}
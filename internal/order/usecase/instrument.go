@@ -0,0 +1,55 @@
+package usecase
+
+import (
+	"cb_grok/internal/exchange"
+	"fmt"
+	"github.com/samber/lo"
+)
+
+func (u *orderUC) instrumentInfo(ex exchange.Exchange, symbol string) (*exchange.InstrumentInfo, error) {
+	info, err := ex.GetInstrumentInfo(symbol)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get instrument info: %w", err)
+	}
+	return info, nil
+}
+
+// marketQty rounds the quantity of a market order down to the instrument precision and rejects orders
+// below the exchange minimum. Buy quantity is the quote amount to spend, sell quantity the base amount.
+func marketQty(info *exchange.InstrumentInfo, side exchange.OrderSide, qty float64) (float64, error) {
+	if side == exchange.OrderSideBuy {
+		qty = info.FloorQuote(qty)
+		return qty, info.ValidateNotional(qty)
+	}
+	qty = info.FloorQty(qty)
+	return qty, info.ValidateQty(qty, 0)
+}
+
+// limitOrder returns the base quantity and price of a limit order as placed on the exchange.
+// A buy spends qty of the quote currency at the limit price.
+func limitOrder(info *exchange.InstrumentInfo, side exchange.OrderSide, qty float64, price float64) (float64, float64, error) {
+	price = info.LimitPrice(side, price)
+	if price <= 0 {
+		return 0, 0, fmt.Errorf("%w: %s limit price rounds to zero", exchange.ErrOrderBelowMinimum, info.Symbol)
+	}
+	if side == exchange.OrderSideBuy {
+		qty /= price
+	}
+	qty = info.FloorQty(qty)
+	return qty, price, info.ValidateQty(qty, price)
+}
+
+// orderQty converts the base quantity placed on the exchange back to the quantity stored with the order
+func orderQty(side exchange.OrderSide, baseQty float64, price float64) float64 {
+	if side == exchange.OrderSideBuy {
+		return baseQty * price
+	}
+	return baseQty
+}
+
+func roundPrice(info *exchange.InstrumentInfo, price *float64) *float64 {
+	if price == nil {
+		return nil
+	}
+	return lo.ToPtr(info.RoundPrice(*price))
+}
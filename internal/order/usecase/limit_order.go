@@ -0,0 +1,217 @@
+package usecase
+
+import (
+	"cb_grok/internal/exchange"
+	"cb_grok/internal/order/model"
+	symbolModel "cb_grok/internal/symbol/model"
+	"fmt"
+	"github.com/samber/lo"
+	"go.uber.org/zap"
+)
+
+func (u *orderUC) CreateSpotLimitOrder(symbol symbolModel.Symbol, side exchange.OrderSide, baseQty float64, price float64, timeInForce exchange.TimeInForce, takeProfit *float64, stopLoss *float64, traderID int64) error {
+	if price <= 0 {
+		return fmt.Errorf("invalid limit price %f", price)
+	}
+	if timeInForce == "" {
+		timeInForce = exchange.TimeInForceGTC
+	}
+
+	ex, err := u.exchange(traderID)
+	if err != nil {
+		return err
+	}
+	info, err := u.instrumentInfo(ex, symbol.Code)
+	if err != nil {
+		return err
+	}
+	exchangeQty, price, err := limitOrder(info, side, baseQty, price)
+	if err != nil {
+		u.log.Error("order rejected", zap.String("symbol", symbol.Code), zap.Error(err))
+		return err
+	}
+
+	ord, err := u.newOrder(ex, symbol, side, orderQty(side, exchangeQty, price), roundPrice(info, takeProfit), roundPrice(info, stopLoss), traderID)
+	if err != nil {
+		return err
+	}
+	ord.TypeID = order_model.OrderTypeLimit
+	ord.Price = lo.ToPtr(price)
+	ord.TimeInForce = lo.ToPtr(string(timeInForce))
+
+	err = u.repo.InsertOrder(ord)
+	if err != nil {
+		return err
+	}
+
+	orderId, err := ex.PlaceSpotLimitOrder(symbol.Code, side, exchangeQty, price, timeInForce)
+	if err != nil {
+		u.log.Error("create limit order failed", zap.Error(err))
+		if updErr := u.repo.UpdateOrderStatus(ord.ID, int(order_model.OrderStatusCanceled)); updErr != nil {
+			u.log.Error("failed to cancel unplaced order", zap.Int64("order_id", ord.ID), zap.Error(updErr))
+		}
+		return err
+	}
+
+	return u.setExtID(ord, orderId)
+}
+
+func (u *orderUC) CancelOrder(orderID int64) error {
+	ord, err := u.repo.GetOrderByID(orderID)
+	if err != nil {
+		return err
+	}
+	if !ord.IsOpen() {
+		return nil
+	}
+	ex, err := u.exchange(ord.TraderID)
+	if err != nil {
+		return err
+	}
+
+	if ord.ExtID != "" {
+		symbol, err := u.repo.GetSymbolByID(ord.SymbolID)
+		if err != nil {
+			return err
+		}
+		err = ex.CancelOrder(symbol.Code, ord.ExtID)
+		if err != nil {
+			// the order may have been filled in the meantime
+			status, statusErr := ex.GetOrderStatus(ord.ExtID)
+			if statusErr == nil && status == order_model.OrderStatusFilled {
+				return fmt.Errorf("order %d is already filled: %w", ord.ID, err)
+			}
+			return fmt.Errorf("failed to cancel order %d: %w", ord.ID, err)
+		}
+	}
+
+	u.orderMu.Lock()
+	defer u.orderMu.Unlock()
+
+	// the account stream may have booked the cancel already
+	ord, err = u.repo.GetOrderByID(orderID)
+	if err != nil {
+		return err
+	}
+	if !ord.IsOpen() {
+		return nil
+	}
+	err = u.repo.UpdateOrderStatus(ord.ID, int(order_model.OrderStatusCanceled))
+	if err != nil {
+		return err
+	}
+	u.onOrderCanceled(ex, *ord, exchangeQty(ex, ord.ExtID))
+
+	return nil
+}
+
+func (u *orderUC) AmendOrder(orderID int64, baseQty *float64, price *float64) error {
+	ord, err := u.repo.GetOrderByID(orderID)
+	if err != nil {
+		return err
+	}
+	if ord.TypeID != order_model.OrderTypeLimit || ord.Price == nil {
+		return fmt.Errorf("order %d is not a limit order", ord.ID)
+	}
+	if !ord.IsOpen() {
+		return fmt.Errorf("order %d is not open", ord.ID)
+	}
+	if price != nil && *price <= 0 {
+		return fmt.Errorf("invalid limit price %f", *price)
+	}
+
+	newQty := lo.FromPtr(ord.BaseQty)
+	if baseQty != nil {
+		newQty = *baseQty
+	}
+	newPrice := *ord.Price
+	if price != nil {
+		newPrice = *price
+	}
+
+	ex, err := u.exchange(ord.TraderID)
+	if err != nil {
+		return err
+	}
+	symbol, err := u.repo.GetSymbolByID(ord.SymbolID)
+	if err != nil {
+		return err
+	}
+	info, err := u.instrumentInfo(ex, symbol.Code)
+	if err != nil {
+		return err
+	}
+
+	side := exchange.OrderSideSell
+	if ord.SideID == int64(order_model.OrderSideBuy) {
+		side = exchange.OrderSideBuy
+	}
+	exchangeQty, newPrice, err := limitOrder(info, side, newQty, newPrice)
+	if err != nil {
+		return err
+	}
+
+	// a buy keeps spending the same quote amount, so a new price changes the base quantity as well
+	var amendQty, amendPrice, storedQty *float64
+	if baseQty != nil || (price != nil && side == exchange.OrderSideBuy) {
+		amendQty = lo.ToPtr(exchangeQty)
+		storedQty = lo.ToPtr(orderQty(side, exchangeQty, newPrice))
+	}
+	if price != nil {
+		amendPrice = lo.ToPtr(newPrice)
+	}
+
+	err = ex.AmendOrder(symbol.Code, ord.ExtID, amendQty, amendPrice)
+	if err != nil {
+		return err
+	}
+
+	return u.repo.UpdateOrderPriceQty(ord.ID, storedQty, amendPrice)
+}
+
+// onOrderCanceled books the executed part of a canceled limit order, so the position it opened
+// or closed is not lost. Without executions amounts are approximated from the limit price.
+func (u *orderUC) onOrderCanceled(ex exchange.Exchange, ord order_model.Order, receivedQty receivedQty) {
+	if ord.TypeID != order_model.OrderTypeLimit || ord.Price == nil || ord.ExtID == "" {
+		return
+	}
+
+	var executed, received float64
+	if fills, ok := u.settle(ex, ord); ok {
+		executed, received = fills.Spent, fills.Received
+	} else {
+		var err error
+		received, err = receivedQty()
+		if err != nil {
+			u.log.Error("failed to get executed qty of canceled order", zap.Int64("order_id", ord.ID), zap.Error(err))
+			return
+		}
+		executed = received / *ord.Price
+		if ord.SideID == int64(order_model.OrderSideBuy) {
+			executed = received * *ord.Price
+		}
+	}
+	if received <= 0 {
+		return
+	}
+
+	u.log.Info("canceled order was partially filled",
+		zap.Int64("order_id", ord.ID),
+		zap.Float64("executed_qty", executed),
+		zap.Float64("received_qty", received),
+	)
+
+	if err := u.repo.UpdateOrderPriceQty(ord.ID, lo.ToPtr(executed), nil); err != nil {
+		u.log.Error("failed to book partial fill", zap.Int64("order_id", ord.ID), zap.Error(err))
+		return
+	}
+	if err := u.repo.UpdateOrderQuoteQty(ord.ID, received); err != nil {
+		u.log.Error("failed to book partial fill", zap.Int64("order_id", ord.ID), zap.Error(err))
+		return
+	}
+	if err := u.repo.UpdateOrderStatus(ord.ID, int(order_model.OrderStatusFilled)); err != nil {
+		u.log.Error("failed to book partial fill", zap.Int64("order_id", ord.ID), zap.Error(err))
+		return
+	}
+	u.onOrderFilled(ex, ord, received)
+}
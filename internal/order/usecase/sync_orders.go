@@ -1,80 +1,334 @@
 package usecase
 
 import (
+	"cb_grok/internal/exchange"
 	order_model "cb_grok/internal/order/model"
 	"context"
-	"fmt"
 	"go.uber.org/zap"
+	"sync"
 	"time"
 )
 
+const (
+	// pollInterval is the reconciliation interval of exchanges without an account stream
+	pollInterval = 5 * time.Second
+	// reconcileInterval is the fallback reconciliation interval while the account stream is up
+	reconcileInterval = time.Minute
+	// pendingRetryInterval retries updates of orders that are not bound to their exchange id yet,
+	// f.e. market orders filled before PlaceSpotMarketOrder returned
+	pendingRetryInterval = time.Second
+	pendingUpdateTTL     = time.Minute
+)
+
+// receivedQty returns what an order received net of fees, see exchange.Exchange.GetOrderQuoteQty
+type receivedQty func() (float64, error)
+
+type pendingUpdate struct {
+	update   exchange.OrderUpdate
+	received time.Time
+}
+
+// SyncOrders keeps the orders of the exchanges set by Init in sync until ctx is done
 func (u *orderUC) SyncOrders(ctx context.Context) {
-	if u.ex == nil {
+	u.exMu.RLock()
+	exchanges := make([]exchange.Exchange, 0, len(u.exchanges))
+	for _, ex := range u.exchanges {
+		exchanges = append(exchanges, ex)
+	}
+	u.exMu.RUnlock()
+
+	var wg sync.WaitGroup
+	for _, ex := range exchanges {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			u.syncAccount(ctx, ex)
+		}()
+	}
+	wg.Wait()
+}
+
+// syncAccount applies the account stream of the exchange when it has one and reconciles active orders
+// by polling. Only one loop runs per exchange account, traders sharing an account share it.
+func (u *orderUC) syncAccount(ctx context.Context, ex exchange.Exchange) {
+	account := ex.Account()
+	u.syncMu.Lock()
+	if u.syncing[account] {
+		u.syncMu.Unlock()
 		return
 	}
+	u.syncing[account] = true
+	u.syncMu.Unlock()
+	defer func() {
+		u.syncMu.Lock()
+		delete(u.syncing, account)
+		u.syncMu.Unlock()
+	}()
+
+	var events <-chan exchange.AccountEvent
+	interval := pollInterval
+	if streamer, ok := ex.(exchange.AccountStreamer); ok {
+		stream, err := streamer.SubscribeAccount(ctx)
+		if err != nil {
+			u.log.Error("failed to subscribe to account stream, polling orders", zap.String("exchange", ex.Name()), zap.Error(err))
+		} else {
+			events = stream
+			interval = reconcileInterval
+			// updates sent before the subscription are not replayed
+			u.reconcileOrders(ex)
+		}
+	}
 
-	ticker := time.NewTicker(5 * time.Second)
-	defer ticker.Stop()
+	reconcile := time.NewTicker(interval)
+	defer reconcile.Stop()
+	retry := time.NewTicker(pendingRetryInterval)
+	defer retry.Stop()
 
+	pending := make(map[string]pendingUpdate)
 	for {
 		select {
 		case <-ctx.Done():
 			return
-		case <-ticker.C:
-			// Get active orders from database
-			orders, err := u.repo.GetActiveOrders()
-			if err != nil {
-				// TODO: Implement proper error logging
+		case event, ok := <-events:
+			if !ok {
+				u.log.Warn("account stream closed, polling orders", zap.String("exchange", ex.Name()))
+				events = nil
+				reconcile.Reset(pollInterval)
 				continue
 			}
-
-			for _, order := range orders {
-				exchangeStatus, err := u.ex.GetOrderStatus(order.ExtID)
-				if err != nil {
-					u.log.Error("failed to get order info", zap.String("order_id", order.ExtID), zap.Error(err))
-					continue
+			u.handleAccountEvent(ex, event, pending)
+		case <-retry.C:
+			for extID, p := range pending {
+				if u.applyOrderUpdate(ex, p.update) || time.Since(p.received) > pendingUpdateTTL {
+					delete(pending, extID)
 				}
-				if int64(exchangeStatus) != order.StatusID {
-					u.log.Info(fmt.Sprintf("STARTED ORDER SYNCING %s", order.ExtID))
-					err := u.repo.UpdateOrderStatus(order.ID, int(exchangeStatus))
-					if err != nil {
-						u.log.Error("failed to update order status", zap.String("order_id", order.ExtID), zap.Error(err))
-						continue
-					}
-					if int64(exchangeStatus) == int64(order_model.OrderStatusFilled) {
-						u.log.Info(fmt.Sprintf("ORDER FILLED %s", order.ExtID))
-						quoteQty, err := u.ex.GetOrderQuoteQty(order.ExtID)
-						if err != nil {
-							u.log.Error("failed to update order quoteQty", zap.String("order_id", order.ExtID), zap.Error(err))
-							continue
-						}
-						err = u.repo.UpdateOrderQuoteQty(order.ID, quoteQty)
-						if err != nil {
-							u.log.Error("failed to update order quoteQty", zap.String("order_id", order.ExtID), zap.Error(err))
-							continue
-						}
-					}
+			}
+		case <-reconcile.C:
+			u.reconcileOrders(ex)
+		}
+	}
+}
 
-				}
-				if order.QuoteQty == nil {
-					u.log.Warn(fmt.Sprintf("order_id %s order_quotQty %v order_status %d", order.ExtID, order.QuoteQty, exchangeStatus))
-				} else {
-					u.log.Warn(fmt.Sprintf("order_id %s order_quotQty %f order_status %d", order.ExtID, *order.QuoteQty, exchangeStatus))
-				}
-				if int64(exchangeStatus) == int64(order_model.OrderStatusFilled) && order.QuoteQty == nil {
-					u.log.Info(fmt.Sprintf("ORDER MISSED FILLED %s", order.ExtID))
-					quoteQty, err := u.ex.GetOrderQuoteQty(order.ExtID)
-					if err != nil {
-						u.log.Error("failed to update order quoteQty", zap.String("order_id", order.ExtID), zap.Error(err))
-						continue
-					}
-					err = u.repo.UpdateOrderQuoteQty(order.ID, quoteQty)
-					if err != nil {
-						u.log.Error("failed to update order quoteQty", zap.String("order_id", order.ExtID), zap.Error(err))
-						continue
-					}
-				}
+func (u *orderUC) handleAccountEvent(ex exchange.Exchange, event exchange.AccountEvent, pending map[string]pendingUpdate) {
+	switch {
+	case event.Reconnected:
+		// updates sent while the stream was down are lost
+		u.reconcileOrders(ex)
+	case event.Order != nil:
+		if !u.applyOrderUpdate(ex, *event.Order) {
+			pending[event.Order.OrderID] = pendingUpdate{update: *event.Order, received: time.Now()}
+		}
+	case event.Fill != nil:
+		u.recordFill(*event.Fill)
+	case event.Wallet != nil:
+		for _, balance := range event.Wallet {
+			u.log.Debug("wallet balance", zap.String("coin", balance.Coin), zap.Float64("balance", balance.Balance), zap.Float64("locked", balance.Locked))
+		}
+	}
+}
+
+// applyOrderUpdate books a pushed order update. It returns false when the order is not bound to its exchange id yet.
+func (u *orderUC) applyOrderUpdate(ex exchange.Exchange, update exchange.OrderUpdate) bool {
+	ord, err := u.repo.GetOrderByExtID(update.OrderID)
+	if err != nil {
+		// reconciliation picks the order up
+		u.log.Error("failed to get order of update", zap.String("order_id", update.OrderID), zap.Error(err))
+		return true
+	}
+	if ord == nil {
+		return false
+	}
+
+	if update.CumExecFee > 0 {
+		u.updateFee(*ord, update)
+	}
+	u.applyStatus(ex, *ord, update.Status, func() (float64, error) {
+		return update.ReceivedQty(), nil
+	})
+	return true
+}
+
+func (u *orderUC) updateFee(ord order_model.Order, update exchange.OrderUpdate) {
+	feeCurrency := update.FeeCurrency
+	if feeCurrency == "" {
+		// spot fees are charged in the received coin
+		symbol, err := u.repo.GetSymbolByID(ord.SymbolID)
+		if err != nil {
+			u.log.Error("failed to get symbol of order", zap.Int64("order_id", ord.ID), zap.Error(err))
+			return
+		}
+		feeCurrency = symbol.Base
+		if update.Side == exchange.OrderSideSell {
+			feeCurrency = symbol.Quote
+		}
+	}
+	if err := u.repo.UpdateOrderFee(ord.ID, update.CumExecFee, feeCurrency); err != nil {
+		u.log.Error("failed to update order fee", zap.Int64("order_id", ord.ID), zap.Error(err))
+	}
+}
+
+// reconcileOrders polls the status of the active orders of the exchange
+func (u *orderUC) reconcileOrders(ex exchange.Exchange) {
+	exch, err := u.repo.GetExchangeByName(ex.Name())
+	if err != nil {
+		u.log.Error("failed to get exchange by name", zap.String("exchange", ex.Name()), zap.Error(err))
+		return
+	}
+	orders, err := u.repo.GetActiveOrders()
+	if err != nil {
+		u.log.Error("failed to get active orders", zap.Error(err))
+		return
+	}
+
+	for _, ord := range orders {
+		if ord.ExchangeID != exch.ID || ord.ExtID == "" {
+			continue
+		}
+		status, err := ex.GetOrderStatus(ord.ExtID)
+		if err != nil {
+			u.log.Error("failed to get order info", zap.String("order_id", ord.ExtID), zap.Error(err))
+			continue
+		}
+		u.applyStatus(ex, ord, status, exchangeQty(ex, ord.ExtID))
+	}
+}
+
+// applyStatus books a status change of an order, and the received quantity of filled orders
+// that have not been booked yet
+func (u *orderUC) applyStatus(ex exchange.Exchange, ord order_model.Order, status order_model.OrderStatus, received receivedQty) {
+	u.orderMu.Lock()
+	defer u.orderMu.Unlock()
+
+	// the order may have been booked by the trader or the other sync path meanwhile
+	current, err := u.repo.GetOrderByID(ord.ID)
+	if err != nil {
+		u.log.Error("failed to get order", zap.Int64("order_id", ord.ID), zap.Error(err))
+		return
+	}
+
+	if int64(status) != current.StatusID {
+		u.log.Info("order status changed", zap.String("order_id", current.ExtID), zap.Int64("from", current.StatusID), zap.Int64("to", int64(status)))
+		err := u.repo.UpdateOrderStatus(current.ID, int(status))
+		if err != nil {
+			u.log.Error("failed to update order status", zap.String("order_id", current.ExtID), zap.Error(err))
+			return
+		}
+		switch status {
+		case order_model.OrderStatusCanceled:
+			u.onOrderCanceled(ex, *current, received)
+		case order_model.OrderStatusFilled:
+			u.bookFill(ex, *current, received)
+		}
+		return
+	}
+
+	if status == order_model.OrderStatusFilled && current.QuoteQty == nil {
+		u.log.Info("filled order misses its received qty", zap.String("order_id", current.ExtID))
+		u.bookFill(ex, *current, received)
+	}
+}
+
+// bookFill books what a filled order received, summed from its executions when the exchange reports them
+func (u *orderUC) bookFill(ex exchange.Exchange, ord order_model.Order, received receivedQty) {
+	var quoteQty float64
+	if fills, ok := u.settle(ex, ord); ok {
+		quoteQty = fills.Received
+	} else {
+		var err error
+		quoteQty, err = received()
+		if err != nil {
+			u.log.Error("failed to get order quoteQty", zap.String("order_id", ord.ExtID), zap.Error(err))
+			return
+		}
+	}
+	err := u.repo.UpdateOrderQuoteQty(ord.ID, quoteQty)
+	if err != nil {
+		u.log.Error("failed to update order quoteQty", zap.String("order_id", ord.ExtID), zap.Error(err))
+		return
+	}
+	u.onOrderFilled(ex, ord, quoteQty)
+}
+
+// settle stores the executions of the order reported by the exchange and sums them.
+// It returns false when there are none, f.e. on exchanges without execution records.
+func (u *orderUC) settle(ex exchange.Exchange, ord order_model.Order) (order_model.Fills, bool) {
+	fills, err := ex.GetOrderFills(ord.ExtID)
+	if err != nil {
+		u.log.Error("failed to get order fills", zap.String("order_id", ord.ExtID), zap.Error(err))
+	}
+	if len(fills) == 0 {
+		return order_model.Fills{}, false
+	}
+
+	executions := make([]order_model.Execution, 0, len(fills))
+	for _, fill := range fills {
+		executions = append(executions, newExecution(ord.ID, fill))
+	}
+	if err := u.repo.InsertExecutions(executions); err != nil {
+		u.log.Error("failed to store order executions", zap.Int64("order_id", ord.ID), zap.Error(err))
+	}
+
+	symbol, err := u.repo.GetSymbolByID(ord.SymbolID)
+	if err != nil {
+		u.log.Error("failed to get symbol of order", zap.Int64("order_id", ord.ID), zap.Error(err))
+		return order_model.Fills{}, false
+	}
+	summed := order_model.SumExecutions(order_model.OrderSide(ord.SideID), executions, symbol.Base, symbol.Quote)
+	if len(summed.Fees) == 1 {
+		for coin, fee := range summed.Fees {
+			if err := u.repo.UpdateOrderFee(ord.ID, fee, coin); err != nil {
+				u.log.Error("failed to update order fee", zap.Int64("order_id", ord.ID), zap.Error(err))
 			}
 		}
 	}
+	return summed, true
+}
+
+// recordFill stores a pushed fill, fills of orders not bound to their exchange id yet are stored once the order is booked
+func (u *orderUC) recordFill(fill exchange.Fill) {
+	ord, err := u.repo.GetOrderByExtID(fill.OrderID)
+	if err != nil {
+		u.log.Error("failed to get order of fill", zap.String("order_id", fill.OrderID), zap.Error(err))
+		return
+	}
+	if ord == nil {
+		return
+	}
+	if err := u.repo.InsertExecutions([]order_model.Execution{newExecution(ord.ID, fill)}); err != nil {
+		u.log.Error("failed to store order execution", zap.Int64("order_id", ord.ID), zap.Error(err))
+	}
+}
+
+func newExecution(orderID int64, fill exchange.Fill) order_model.Execution {
+	return order_model.Execution{
+		OrderID:     orderID,
+		ExtID:       fill.ID,
+		Price:       fill.Price,
+		Qty:         fill.Qty,
+		Value:       fill.Value,
+		Fee:         fill.Fee,
+		FeeCurrency: fill.FeeCurrency,
+		IsMaker:     fill.IsMaker,
+		ExecutedAt:  time.UnixMilli(fill.Time),
+	}
+}
+
+func exchangeQty(ex exchange.Exchange, extID string) receivedQty {
+	return func() (float64, error) {
+		return ex.GetOrderQuoteQty(extID)
+	}
+}
+
+// onOrderFilled protects filled entries with exit orders and reconciles the exit orders once one of them fired
+func (u *orderUC) onOrderFilled(ex exchange.Exchange, ord order_model.Order, quoteQty float64) {
+	if !u.exchangeExits {
+		return
+	}
+	if ord.ParentID != nil {
+		u.cancelSiblingExitOrders(ex, ord)
+		return
+	}
+	if err := u.placeExitOrders(ex, ord, quoteQty); err != nil {
+		u.log.Error("failed to place exit orders", zap.Int64("entry_id", ord.ID), zap.Error(err))
+	}
 }
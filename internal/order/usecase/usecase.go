@@ -4,24 +4,67 @@ import (
 	"cb_grok/internal/exchange"
 	"cb_grok/internal/order"
 	"context"
+	"fmt"
 	"go.uber.org/zap"
+	"sync"
 )
 
 type orderUC struct {
 	repo order.Repository
-	ex   exchange.Exchange
 	log  *zap.Logger
+
+	// exchanges holds the exchange of every trader set by Init
+	exMu      sync.RWMutex
+	exchanges map[int64]exchange.Exchange
+
+	exchangeExits bool
+
+	// syncing holds the accounts with a running sync loop
+	syncMu  sync.Mutex
+	syncing map[string]bool
+	// orderMu serializes the booking of order status changes
+	orderMu sync.Mutex
+}
+
+type Option func(u *orderUC)
+
+// WithExchangeExits protects filled entries with exchange-side take-profit and stop-loss orders
+func WithExchangeExits(enabled bool) Option {
+	return func(u *orderUC) {
+		u.exchangeExits = enabled
+	}
 }
 
-func New(repo order.Repository, log *zap.Logger) order.Order {
-	return &orderUC{
-		repo: repo,
-		log:  log,
+func New(repo order.Repository, log *zap.Logger, opts ...Option) order.Order {
+	u := &orderUC{
+		repo:      repo,
+		log:       log,
+		exchanges: make(map[int64]exchange.Exchange),
+		syncing:   make(map[string]bool),
+	}
+	for _, opt := range opts {
+		opt(u)
 	}
+	return u
+}
+
+func (u *orderUC) Init(ctx context.Context, traderID int64, ex exchange.Exchange) {
+	u.exMu.Lock()
+	u.exchanges[traderID] = ex
+	u.exMu.Unlock()
+
+	// every trader brings its own exchange, sync loops are deduplicated per account
+	go u.syncAccount(ctx, ex)
 }
 
-func (u *orderUC) Init(ex exchange.Exchange) {
-	u.ex = ex
+// exchange returns the exchange the trader was set up with
+func (u *orderUC) exchange(traderID int64) (exchange.Exchange, error) {
+	u.exMu.RLock()
+	defer u.exMu.RUnlock()
 
-	go u.SyncOrders(context.Background())
+	ex, ok := u.exchanges[traderID]
+	if !ok || ex == nil {
+		return nil, fmt.Errorf("exchange of trader %d not set", traderID)
+	}
+	return ex, nil
 }
@@ -1,9 +0,0 @@
-package strategy
-
-import (
-	"go.uber.org/fx"
-)
-
-var Module = fx.Module("strategy",
-	fx.Provide(NewLinearBiasStrategy),
-)
@@ -4,18 +4,79 @@ import (
 	"cb_grok/internal/indicators"
 	"cb_grok/internal/strategy/model"
 	"cb_grok/pkg/models"
+	"encoding/json"
+	"fmt"
 	"go.uber.org/zap"
 	"math"
 )
 
-type LinearBiasStrategy struct{}
+const LinearBiasType = "linear_bias"
 
-func NewLinearBiasStrategy() Strategy {
-	return &LinearBiasStrategy{}
+func init() {
+	Register(LinearBiasType, Definition{
+		New: func(raw json.RawMessage) (Strategy, error) {
+			var params model.LinearBiasParams
+			if len(raw) > 0 {
+				if err := json.Unmarshal(raw, &params); err != nil {
+					return nil, fmt.Errorf("failed to unmarshal linear bias params: %w", err)
+				}
+			}
+			return NewLinearBiasStrategy(params), nil
+		},
+		SearchSpace: linearBiasSearchSpace,
+	})
 }
 
-func (s *LinearBiasStrategy) ApplyIndicators(candles []models.OHLCV, params model.StrategyParams) []models.AppliedOHLCV {
-	requiredCandles := max(params.MALongPeriod, params.EMALongPeriod, params.MACDLongPeriod, params.BollingerPeriod, params.StochasticKPeriod)
+var linearBiasSearchSpace = model.SearchSpace{
+	{Name: "ma_short_period", Kind: model.ParamKindInt, Low: 5, High: 30, Step: 1, TimeframeScaled: true},
+	{Name: "ma_long_period", Kind: model.ParamKindInt, Low: 20, High: 100, Step: 1, TimeframeScaled: true},
+	{Name: "rsi_period", Kind: model.ParamKindInt, Low: 5, High: 20, Step: 1, TimeframeScaled: true},
+	{Name: "atr_period", Kind: model.ParamKindInt, Low: 5, High: 20, Step: 1, TimeframeScaled: true},
+	{Name: "buy_rsi_threshold", Kind: model.ParamKindFloat, Low: 10, High: 40},
+	{Name: "sell_rsi_threshold", Kind: model.ParamKindFloat, Low: 60, High: 90},
+	{Name: "ema_short_period", Kind: model.ParamKindInt, Low: 10, High: 50, Step: 1, TimeframeScaled: true},
+	{Name: "ema_long_period", Kind: model.ParamKindInt, Low: 50, High: 200, Step: 1, TimeframeScaled: true},
+	{Name: "atr_threshold", Kind: model.ParamKindFloat, Low: 0, High: 2},
+	{Name: "macd_short_period", Kind: model.ParamKindInt, Low: 5, High: 15, Step: 1, TimeframeScaled: true},
+	{Name: "macd_long_period", Kind: model.ParamKindInt, Low: 20, High: 50, Step: 1, TimeframeScaled: true},
+	{Name: "macd_signal_period", Kind: model.ParamKindInt, Low: 5, High: 15, Step: 1, TimeframeScaled: true},
+	{Name: "buy_signal_threshold", Kind: model.ParamKindFloat, Low: 0.1, High: 0.9},
+	{Name: "sell_signal_threshold", Kind: model.ParamKindFloat, Low: -0.9, High: -0.1},
+	{Name: "ema_weight", Kind: model.ParamKindFloat, Low: 0, High: 1},
+	{Name: "trend_weight", Kind: model.ParamKindFloat, Low: 0, High: 1},
+	{Name: "rsi_weight", Kind: model.ParamKindFloat, Low: 0, High: 1},
+	{Name: "macd_weight", Kind: model.ParamKindFloat, Low: 0, High: 1},
+	{Name: "bollinger_period", Kind: model.ParamKindInt, Low: 10, High: 50, Step: 1, TimeframeScaled: true},
+	{Name: "bollinger_std_dev", Kind: model.ParamKindFloat, Low: 1, High: 3},
+	{Name: "bb_weight", Kind: model.ParamKindFloat, Low: 0, High: 1},
+	{Name: "stochastic_k_period", Kind: model.ParamKindInt, Low: 5, High: 20, Step: 1, TimeframeScaled: true},
+	{Name: "stochastic_d_period", Kind: model.ParamKindInt, Low: 3, High: 10, Step: 1, TimeframeScaled: true},
+	{Name: "stochastic_weight", Kind: model.ParamKindFloat, Low: 0, High: 1},
+}
+
+type LinearBiasStrategy struct {
+	params model.LinearBiasParams
+}
+
+func NewLinearBiasStrategy(params model.LinearBiasParams) Strategy {
+	return &LinearBiasStrategy{
+		params: params,
+	}
+}
+
+func (s *LinearBiasStrategy) Type() string {
+	return LinearBiasType
+}
+
+func (s *LinearBiasStrategy) Lookback() int {
+	params := s.params
+	return max(params.MALongPeriod, params.EMALongPeriod, params.MACDLongPeriod, params.BollingerPeriod, params.StochasticKPeriod)
+}
+
+func (s *LinearBiasStrategy) ApplyIndicators(candles []models.OHLCV) []models.AppliedOHLCV {
+	params := s.params
+
+	requiredCandles := s.Lookback()
 	if len(candles) < requiredCandles {
 		zap.S().Infof("strategy: required candles: %d", requiredCandles)
 		return nil
@@ -65,7 +126,71 @@ func (s *LinearBiasStrategy) ApplyIndicators(candles []models.OHLCV, params mode
 	return appliedCandles
 }
 
-func (s *LinearBiasStrategy) ApplySignals(candles []models.AppliedOHLCV, params model.StrategyParams) []models.AppliedOHLCV {
+type linearBiasStream struct {
+	params   model.LinearBiasParams
+	required int
+	count    int
+
+	atr        *indicators.ATR
+	shortMA    *indicators.SMA
+	longMA     *indicators.SMA
+	rsi        *indicators.RSI
+	emaShort   *indicators.EMA
+	emaLong    *indicators.EMA
+	macd       *indicators.MACD
+	bollinger  *indicators.BollingerBands
+	stochastic *indicators.StochasticOscillator
+}
+
+func (s *LinearBiasStrategy) NewStream() Stream {
+	params := s.params
+	return &linearBiasStream{
+		params:   params,
+		required: s.Lookback(),
+		atr:      indicators.NewATR(params.ATRPeriod),
+		shortMA:  indicators.NewSMA(params.MAShortPeriod),
+		longMA:   indicators.NewSMA(params.MALongPeriod),
+		rsi:      indicators.NewRSI(params.RSIPeriod),
+		emaShort: indicators.NewEMA(params.EMAShortPeriod),
+		emaLong:  indicators.NewEMA(params.EMALongPeriod),
+		// CalculateMACD ignores the MACD periods of the params
+		macd:       indicators.NewMACD(indicators.DefaultMACDShortPeriod, indicators.DefaultMACDLongPeriod, indicators.DefaultMACDSignalPeriod),
+		bollinger:  indicators.NewBollingerBands(params.BollingerPeriod, params.BollingerStdDev),
+		stochastic: indicators.NewStochasticOscillator(params.StochasticKPeriod, params.StochasticDPeriod),
+	}
+}
+
+func (s *linearBiasStream) Update(candle models.OHLCV) (models.AppliedOHLCV, bool) {
+	atr := s.atr.Update(candle)
+	emaShort := s.emaShort.Update(candle)
+	emaLong := s.emaLong.Update(candle)
+	macd, macdSignal := s.macd.Update(candle)
+	upperBB, _, lowerBB := s.bollinger.Update(candle)
+	stochasticK, stochasticD := s.stochastic.Update(candle)
+	s.count++
+
+	return models.AppliedOHLCV{
+		OHLCV:       candle,
+		ATR:         atr,
+		RSI:         s.rsi.Update(candle),
+		ShortMA:     s.shortMA.Update(candle),
+		LongMA:      s.longMA.Update(candle),
+		ShortEMA:    emaShort,
+		LongEMA:     emaLong,
+		Trend:       emaShort > emaLong,
+		Volatility:  atr > s.params.ATRThreshold,
+		MACD:        macd,
+		MACDSignal:  macdSignal,
+		UpperBB:     upperBB,
+		LowerBB:     lowerBB,
+		StochasticK: stochasticK,
+		StochasticD: stochasticD,
+	}, s.count >= s.required
+}
+
+func (s *LinearBiasStrategy) ApplySignals(candles []models.AppliedOHLCV) []models.AppliedOHLCV {
+	params := s.params
+
 	if len(candles) < 2 {
 		return candles
 	}
@@ -0,0 +1,135 @@
+package strategy_test
+
+import (
+	"cb_grok/internal/strategy"
+	"cb_grok/pkg/models"
+	"encoding/json"
+	"math"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+// testCandles returns a fixed random walk of 1m candles
+func testCandles(n int) []models.OHLCV {
+	rnd := rand.New(rand.NewSource(11))
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC).UnixMilli()
+
+	candles := make([]models.OHLCV, n)
+	price := 40000.0
+	for i := range candles {
+		open := price
+		price *= math.Exp(rnd.NormFloat64() * 0.002)
+		wick := price * 0.001 * rnd.Float64()
+		candles[i] = models.OHLCV{
+			Timestamp: start + int64(i)*time.Minute.Milliseconds(),
+			Open:      open,
+			High:      math.Max(open, price) + wick,
+			Low:       math.Min(open, price) - wick,
+			Close:     price,
+			Volume:    1 + rnd.Float64()*100,
+		}
+	}
+	return candles
+}
+
+func equal(a, b float64) bool {
+	if math.IsNaN(a) || math.IsNaN(b) {
+		return math.IsNaN(a) && math.IsNaN(b)
+	}
+	return math.Abs(a-b) <= 1e-9*math.Max(1, math.Max(math.Abs(a), math.Abs(b)))
+}
+
+func TestLinearBiasStreamMatchesApplyIndicators(t *testing.T) {
+	const last = 300
+	candles := testCandles(1200)
+
+	tests := []struct {
+		name   string
+		params map[string]interface{}
+	}{
+		{
+			name: "default periods",
+			params: map[string]interface{}{
+				"ma_short_period": 10, "ma_long_period": 50, "rsi_period": 14, "atr_period": 14,
+				"ema_short_period": 10, "ema_long_period": 50, "atr_threshold": 100,
+				"macd_short_period": 12, "macd_long_period": 26, "macd_signal_period": 9,
+				"bollinger_period": 20, "bollinger_std_dev": 2, "stochastic_k_period": 14, "stochastic_d_period": 3,
+			},
+		},
+		{
+			// the MACD periods are ignored by both, the lookback comes from the EMA
+			name: "long periods",
+			params: map[string]interface{}{
+				"ma_short_period": 30, "ma_long_period": 100, "rsi_period": 20, "atr_period": 5,
+				"ema_short_period": 50, "ema_long_period": 200, "atr_threshold": 110,
+				"macd_short_period": 5, "macd_long_period": 50, "macd_signal_period": 15,
+				"bollinger_period": 50, "bollinger_std_dev": 3, "stochastic_k_period": 20, "stochastic_d_period": 10,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			raw, err := json.Marshal(tt.params)
+			if err != nil {
+				t.Fatal(err)
+			}
+			str, err := strategy.New(strategy.LinearBiasType, raw)
+			if err != nil {
+				t.Fatal(err)
+			}
+			streaming, ok := str.(strategy.Streaming)
+			if !ok {
+				t.Fatal("linear bias strategy does not stream")
+			}
+
+			batch := str.ApplyIndicators(candles)
+			if len(batch) != len(candles) {
+				t.Fatalf("applied candles = %d, want %d", len(batch), len(candles))
+			}
+
+			lookback := str.Lookback()
+			if str.ApplyIndicators(candles[:lookback-1]) != nil || str.ApplyIndicators(candles[:lookback]) == nil {
+				t.Fatalf("ApplyIndicators does not start at the lookback %d", lookback)
+			}
+
+			stream := streaming.NewStream()
+			for i, c := range candles {
+				got, ready := stream.Update(c)
+				if wantReady := i+1 >= lookback; ready != wantReady {
+					t.Fatalf("candle %d: ready = %v, want %v", i, ready, wantReady)
+				}
+				if i < len(candles)-last {
+					continue
+				}
+
+				want := batch[i]
+				if got.OHLCV != want.OHLCV || got.Trend != want.Trend || got.Volatility != want.Volatility {
+					t.Fatalf("candle %d: stream = %+v, batch = %+v", i, got, want)
+				}
+				values := []struct {
+					name      string
+					got, want float64
+				}{
+					{"atr", got.ATR, want.ATR},
+					{"rsi", got.RSI, want.RSI},
+					{"short ma", got.ShortMA, want.ShortMA},
+					{"long ma", got.LongMA, want.LongMA},
+					{"short ema", got.ShortEMA, want.ShortEMA},
+					{"long ema", got.LongEMA, want.LongEMA},
+					{"macd", got.MACD, want.MACD},
+					{"macd signal", got.MACDSignal, want.MACDSignal},
+					{"upper bb", got.UpperBB, want.UpperBB},
+					{"lower bb", got.LowerBB, want.LowerBB},
+					{"stochastic k", got.StochasticK, want.StochasticK},
+					{"stochastic d", got.StochasticD, want.StochasticD},
+				}
+				for _, v := range values {
+					if !equal(v.got, v.want) {
+						t.Fatalf("candle %d: %s stream = %v, batch = %v", i, v.name, v.got, v.want)
+					}
+				}
+			}
+		})
+	}
+}
@@ -1,8 +1,14 @@
 package model
 
-import "time"
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
 
-type StrategyParams struct {
+// LinearBiasParams is the parameter schema of the linear_bias strategy
+type LinearBiasParams struct {
 	MAShortPeriod       int     `json:"ma_short_period"`
 	MALongPeriod        int     `json:"ma_long_period"`
 	RSIPeriod           int     `json:"rsi_period"`
@@ -39,9 +45,81 @@ type Signals struct {
 }
 
 type Strategy struct {
-	ID        int            `db:"id"`
-	CreatedAt time.Time      `db:"created_at"`
-	SymbolID  int            `db:"symbol_id"`
-	Params    StrategyParams `db:"params"`
-	TimeFrame string         `db:"timeframe"`
+	ID        int       `db:"id"`
+	CreatedAt time.Time `db:"created_at"`
+	SymbolID  int       `db:"symbol_id"`
+	// Type is the registry name of the strategy implementation, Params is decoded by it
+	Type      string          `db:"type"`
+	Params    json.RawMessage `db:"params"`
+	TimeFrame string          `db:"timeframe"`
+}
+
+type ParamKind string
+
+const (
+	ParamKindInt         ParamKind = "int"
+	ParamKindFloat       ParamKind = "float"
+	ParamKindCategorical ParamKind = "categorical"
+)
+
+// ParamSpec describes how the optimizer samples a single strategy parameter.
+// Name must match the JSON name of the field in the strategy params.
+type ParamSpec struct {
+	Name    string    `yaml:"name" json:"name"`
+	Kind    ParamKind `yaml:"type" json:"type"`
+	Low     float64   `yaml:"low" json:"low"`
+	High    float64   `yaml:"high" json:"high"`
+	Step    float64   `yaml:"step" json:"step"`
+	Log     bool      `yaml:"log" json:"log"`
+	Choices []string  `yaml:"choices" json:"choices"`
+	// TimeframeScaled multiplies High and Step of int params by the number of candles per hour,
+	// so periods keep the same wall-clock length on lower timeframes
+	TimeframeScaled bool `yaml:"timeframe_scaled" json:"timeframe_scaled"`
+}
+
+func (p ParamSpec) Validate() error {
+	if p.Name == "" {
+		return errors.New("param name is empty")
+	}
+
+	switch p.Kind {
+	case ParamKindInt, ParamKindFloat:
+		if p.Low > p.High {
+			return fmt.Errorf("param %s: low %v is greater than high %v", p.Name, p.Low, p.High)
+		}
+		if p.Step < 0 {
+			return fmt.Errorf("param %s: negative step %v", p.Name, p.Step)
+		}
+		if p.Log && p.Low <= 0 {
+			return fmt.Errorf("param %s: log scale requires positive low, got %v", p.Name, p.Low)
+		}
+		if p.Log && p.Step > 0 {
+			return fmt.Errorf("param %s: step is not supported with log scale", p.Name)
+		}
+	case ParamKindCategorical:
+		if len(p.Choices) == 0 {
+			return fmt.Errorf("param %s: categorical param requires choices", p.Name)
+		}
+	default:
+		return fmt.Errorf("param %s: unknown type %q", p.Name, p.Kind)
+	}
+
+	return nil
+}
+
+// SearchSpace is the list of parameters a strategy exposes to the optimizer
+type SearchSpace []ParamSpec
+
+func (s SearchSpace) Validate() error {
+	seen := make(map[string]struct{}, len(s))
+	for _, p := range s {
+		if err := p.Validate(); err != nil {
+			return err
+		}
+		if _, ok := seen[p.Name]; ok {
+			return fmt.Errorf("duplicate param %s", p.Name)
+		}
+		seen[p.Name] = struct{}{}
+	}
+	return nil
 }
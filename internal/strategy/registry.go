@@ -0,0 +1,90 @@
+package strategy
+
+import (
+	"cb_grok/internal/strategy/model"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Factory builds a strategy from its JSON encoded params
+type Factory func(params json.RawMessage) (Strategy, error)
+
+// Definition is everything the registry knows about a strategy type
+type Definition struct {
+	New Factory
+	// SearchSpace declares the params the optimizer may tune
+	SearchSpace model.SearchSpace
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]Definition)
+)
+
+// Register makes a strategy available by type name. It panics on duplicate names or an invalid
+// definition, registration is expected to happen from init functions.
+func Register(strategyType string, def Definition) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if def.New == nil {
+		panic("strategy: register nil factory for " + strategyType)
+	}
+	if err := def.SearchSpace.Validate(); err != nil {
+		panic(fmt.Sprintf("strategy: invalid search space for %s: %v", strategyType, err))
+	}
+	if _, ok := registry[strategyType]; ok {
+		panic("strategy: register called twice for " + strategyType)
+	}
+	registry[strategyType] = def
+}
+
+// New builds the strategy registered under strategyType with the given params
+func New(strategyType string, params json.RawMessage) (Strategy, error) {
+	registryMu.RLock()
+	def, ok := registry[strategyType]
+	registryMu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("unknown strategy type: %q", strategyType)
+	}
+
+	str, err := def.New(params)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build strategy %s: %w", strategyType, err)
+	}
+	return str, nil
+}
+
+// SearchSpace returns a copy of the search space declared by strategyType
+func SearchSpace(strategyType string) (model.SearchSpace, error) {
+	registryMu.RLock()
+	def, ok := registry[strategyType]
+	registryMu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("unknown strategy type: %q", strategyType)
+	}
+
+	space := make(model.SearchSpace, len(def.SearchSpace))
+	for i, p := range def.SearchSpace {
+		p.Choices = append([]string(nil), p.Choices...)
+		space[i] = p
+	}
+	return space, nil
+}
+
+// Types returns the registered strategy type names in alphabetical order
+func Types() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	types := make([]string, 0, len(registry))
+	for t := range registry {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	return types
+}
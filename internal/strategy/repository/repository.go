@@ -22,29 +22,32 @@ func New(db postgres.Postgres) strategy.Repository {
 func (r *repo) InsertStrategy(entity *strategyModel.Strategy) error {
 	query := `
 		INSERT INTO public.strategy (
-			symbol_id, params
-		) VALUES ($1, $2)
+			symbol_id, type, params, timeframe
+		) VALUES ($1, $2, $3, $4)
 		RETURNING id
 	`
 	var id int64
-	paramsString, err := json.Marshal(entity.Params)
-	if err != nil {
-		return fmt.Errorf("Failed to marshal strategy params%w", err)
+	params := entity.Params
+	if len(params) == 0 {
+		params = json.RawMessage("{}")
 	}
-	err = r.db.Get(&id, query,
+	err := r.db.Get(&id, query,
 		entity.SymbolID,
-		paramsString,
+		entity.Type,
+		params,
+		entity.TimeFrame,
 	)
 	if err != nil {
 		return fmt.Errorf("failed to insert strategy: %w", err)
 	}
+	entity.ID = int(id)
 	return nil
 }
 
 func (r *repo) GetStrategy(id int64) (*strategyModel.Strategy, error) {
 	var result []strategyModel.Strategy
 	query := `
-		SELECT id, symbol_id, created_at, params, timeframe
+		SELECT id, symbol_id, created_at, type, params, timeframe
 		FROM public.strategy 
 		WHERE id = $1
 	`
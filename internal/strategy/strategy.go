@@ -1,11 +1,29 @@
 package strategy
 
 import (
-	"cb_grok/internal/strategy/model"
 	"cb_grok/pkg/models"
 )
 
+// Strategy is a configured trading algorithm. Parameters are bound at construction, see Register and New.
 type Strategy interface {
-	ApplyIndicators(candles []models.OHLCV, params model.StrategyParams) []models.AppliedOHLCV
-	ApplySignals(appliedCandles []models.AppliedOHLCV, params model.StrategyParams) []models.AppliedOHLCV
+	Type() string
+	ApplyIndicators(candles []models.OHLCV) []models.AppliedOHLCV
+	// Lookback is the number of closed candles the indicators need, ApplyIndicators returns nil with fewer
+	Lookback() int
+	// ApplySignals sets the signal of each candle from the candles up to it, so applying it to a whole
+	// series gives the signals of applying it to every prefix
+	ApplySignals(appliedCandles []models.AppliedOHLCV) []models.AppliedOHLCV
+}
+
+// Streaming is implemented by strategies whose indicators can be updated one closed candle at a time,
+// giving the values ApplyIndicators gives over the whole history
+type Streaming interface {
+	NewStream() Stream
+}
+
+// Stream holds the indicator state of a strategy
+type Stream interface {
+	// Update applies the indicators to the next closed candle, ready is false while there are fewer
+	// candles than the longest period, when ApplyIndicators returns nil
+	Update(candle models.OHLCV) (applied models.AppliedOHLCV, ready bool)
 }
@@ -4,4 +4,5 @@ import symbolModel "cb_grok/internal/symbol/model"
 
 type Repository interface {
 	GetSymbolByID(id int64) (*symbolModel.Symbol, error)
+	GetSymbolByCode(code string) (*symbolModel.Symbol, error)
 }
@@ -29,6 +29,23 @@ func (r repo) GetSymbolByID(id int64) (*symbol_model.Symbol, error) {
 	return result[0], nil
 }
 
+func (r repo) GetSymbolByCode(code string) (*symbol_model.Symbol, error) {
+	var result []*symbol_model.Symbol
+	query := `
+		SELECT id, code, prod_id, base, quote, decimals
+		FROM public.symbol 
+		WHERE code = $1
+	`
+	err := r.db.Select(&result, query, code)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get symbol by code: %s %w ", code, err)
+	}
+	if len(result) == 0 {
+		return nil, errors.New("symbol not found")
+	}
+	return result[0], nil
+}
+
 func New(db postgres.Postgres) symbol.Repository {
 	return &repo{
 		db: db,
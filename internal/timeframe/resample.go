@@ -0,0 +1,60 @@
+package timeframe
+
+import (
+	"cb_grok/pkg/models"
+	"fmt"
+)
+
+// Resample aggregates candles of the timeframe from, sorted ascending, into candles of to: the first open,
+// the highest high, the lowest low, the last close and the summed volume. A bar missing any of its source
+// candles is partial, typically the first and the last bar of a series and the bar still forming.
+// Partial bars are dropped unless keepPartial is set. Repeated open times are counted once.
+func Resample(candles []models.OHLCV, from, to Timeframe, keepPartial bool) ([]models.OHLCV, error) {
+	if !from.Divides(to) {
+		return nil, fmt.Errorf("cannot resample %s candles into %s", from, to)
+	}
+
+	var (
+		result []models.OHLCV
+		count  int64
+		last   int64
+	)
+	closeBar := func() {
+		if n := len(result); n > 0 && !keepPartial && count < from.count(to, result[n-1].Timestamp) {
+			result = result[:n-1]
+		}
+	}
+
+	for i, candle := range candles {
+		if i > 0 && candle.Timestamp == last {
+			continue
+		}
+		last = candle.Timestamp
+
+		open := to.Open(candle.Timestamp)
+		n := len(result)
+		if n == 0 || result[n-1].Timestamp != open {
+			closeBar()
+			result = append(result, models.OHLCV{
+				Timestamp: open,
+				Open:      candle.Open,
+				High:      candle.High,
+				Low:       candle.Low,
+				Close:     candle.Close,
+				Volume:    candle.Volume,
+			})
+			count = 1
+			continue
+		}
+
+		bar := &result[n-1]
+		bar.High = max(bar.High, candle.High)
+		bar.Low = min(bar.Low, candle.Low)
+		bar.Close = candle.Close
+		bar.Volume += candle.Volume
+		count++
+	}
+	closeBar()
+
+	return result, nil
+}
@@ -0,0 +1,132 @@
+package timeframe_test
+
+import (
+	"cb_grok/internal/timeframe"
+	"cb_grok/pkg/models"
+	"testing"
+)
+
+// series returns candles of the timeframe from start on, skipping the positions in missing.
+// Candle i opens at i+1, closes at i+2, spans [i, i+3] and trades volume 1.
+func series(tf timeframe.Timeframe, start int64, n int, missing ...int) []models.OHLCV {
+	skip := make(map[int]bool, len(missing))
+	for _, i := range missing {
+		skip[i] = true
+	}
+
+	var candles []models.OHLCV
+	open := start
+	for i := 0; i < n; i++ {
+		if !skip[i] {
+			p := float64(i)
+			candles = append(candles, models.OHLCV{Timestamp: open, Open: p + 1, High: p + 3, Low: p, Close: p + 2, Volume: 1})
+		}
+		open = tf.Next(open)
+	}
+	return candles
+}
+
+func TestResample(t *testing.T) {
+	minute := timeframe.MustParse("1m")
+	day := timeframe.MustParse("1d")
+
+	tests := []struct {
+		name        string
+		candles     []models.OHLCV
+		from, to    string
+		keepPartial bool
+		want        []models.OHLCV
+	}{
+		{
+			name:    "complete bars",
+			candles: series(minute, ms(2024, 1, 1, 0, 0), 10),
+			from:    "1m", to: "5m",
+			want: []models.OHLCV{
+				{Timestamp: ms(2024, 1, 1, 0, 0), Open: 1, High: 7, Low: 0, Close: 6, Volume: 5},
+				{Timestamp: ms(2024, 1, 1, 0, 5), Open: 6, High: 12, Low: 5, Close: 11, Volume: 5},
+			},
+		},
+		{
+			name: "bars missing a source candle are dropped",
+			// the series starts mid-bar, misses 00:07 and ends mid-bar
+			candles: series(minute, ms(2024, 1, 1, 0, 3), 14, 4),
+			from:    "1m", to: "5m",
+			want: []models.OHLCV{
+				{Timestamp: ms(2024, 1, 1, 0, 10), Open: 8, High: 14, Low: 7, Close: 13, Volume: 5},
+			},
+		},
+		{
+			name:    "partial bars are kept on request",
+			candles: series(minute, ms(2024, 1, 1, 0, 3), 14, 4),
+			from:    "1m", to: "5m",
+			keepPartial: true,
+			want: []models.OHLCV{
+				{Timestamp: ms(2024, 1, 1, 0, 0), Open: 1, High: 4, Low: 0, Close: 3, Volume: 2},
+				{Timestamp: ms(2024, 1, 1, 0, 5), Open: 3, High: 9, Low: 2, Close: 8, Volume: 4},
+				{Timestamp: ms(2024, 1, 1, 0, 10), Open: 8, High: 14, Low: 7, Close: 13, Volume: 5},
+				{Timestamp: ms(2024, 1, 1, 0, 15), Open: 13, High: 16, Low: 12, Close: 15, Volume: 2},
+			},
+		},
+		{
+			name:    "repeated candles are counted once",
+			candles: duplicate(series(minute, ms(2024, 1, 1, 0, 0), 5), 2),
+			from:    "1m", to: "5m",
+			want: []models.OHLCV{
+				{Timestamp: ms(2024, 1, 1, 0, 0), Open: 1, High: 7, Low: 0, Close: 6, Volume: 5},
+			},
+		},
+		{
+			name: "weeks open on Monday",
+			// Wednesday 2025-01-01 to Sunday 2025-01-19, the first week misses Monday and Tuesday
+			candles: series(day, ms(2025, 1, 1, 0, 0), 19),
+			from:    "1d", to: "1w",
+			want: []models.OHLCV{
+				{Timestamp: ms(2025, 1, 6, 0, 0), Open: 6, High: 14, Low: 5, Close: 13, Volume: 7},
+				{Timestamp: ms(2025, 1, 13, 0, 0), Open: 13, High: 21, Low: 12, Close: 20, Volume: 7},
+			},
+		},
+		{
+			name: "months have their own length",
+			// January 2024 misses the 31st, the leap February is complete
+			candles: series(day, ms(2024, 1, 1, 0, 0), 60, 30),
+			from:    "1d", to: "1M",
+			want: []models.OHLCV{
+				{Timestamp: ms(2024, 2, 1, 0, 0), Open: 32, High: 62, Low: 31, Close: 61, Volume: 29},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := timeframe.Resample(tt.candles, timeframe.MustParse(tt.from), timeframe.MustParse(tt.to), tt.keepPartial)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("bars = %+v, want %+v", got, tt.want)
+			}
+			for i := range tt.want {
+				if got[i] != tt.want[i] {
+					t.Errorf("bar %d = %+v (%s), want %+v", i, got[i], format(got[i].Timestamp), tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestResampleRejectsUnalignedTimeframes(t *testing.T) {
+	candles := series(timeframe.MustParse("7m"), ms(2024, 1, 1, 0, 0), 20)
+	if _, err := timeframe.Resample(candles, timeframe.MustParse("7m"), timeframe.MustParse("1h"), false); err == nil {
+		t.Fatal("7m candles resampled into 1h")
+	}
+}
+
+// duplicate repeats every candle n times
+func duplicate(candles []models.OHLCV, n int) []models.OHLCV {
+	var result []models.OHLCV
+	for _, c := range candles {
+		for i := 0; i < n; i++ {
+			result = append(result, c)
+		}
+	}
+	return result
+}
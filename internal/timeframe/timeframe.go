@@ -0,0 +1,177 @@
+package timeframe
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+)
+
+// Unit is the unit of a timeframe, as written after its count
+type Unit byte
+
+const (
+	Minute Unit = 'm'
+	Hour   Unit = 'h'
+	Day    Unit = 'd'
+	Week   Unit = 'w'
+	Month  Unit = 'M'
+)
+
+const (
+	// week is the length of a week, weeks open on Monday
+	week = 7 * 24 * time.Hour
+	// firstMonday is the first Monday after the epoch, 1970-01-05, the origin of the week grid
+	firstMonday = 4 * 24 * time.Hour
+	// averageMonth is the mean Gregorian month, used where a month needs a fixed length
+	averageMonth = time.Duration(365.2425 / 12 * float64(24*time.Hour))
+)
+
+// Timeframe is the length of a candle: a number of minutes, hours, days, weeks or calendar months.
+// Candles open on a grid starting at the epoch, weeks on Monday and months on the 1st, in UTC.
+type Timeframe struct {
+	n    int64
+	unit Unit
+}
+
+// Parse parses a timeframe like 1m, 15m, 2h, 3d, 1w or 1M. A bare number is a number of minutes,
+// as Bybit writes intervals.
+func Parse(value string) (Timeframe, error) {
+	if value == "" {
+		return Timeframe{}, fmt.Errorf("empty timeframe")
+	}
+	if n, err := strconv.ParseInt(value, 10, 64); err == nil && n > 0 {
+		return Timeframe{n: n, unit: Minute}, nil
+	}
+
+	n, err := strconv.ParseInt(value[:len(value)-1], 10, 64)
+	if err != nil || n <= 0 {
+		return Timeframe{}, fmt.Errorf("unsupported timeframe: %s", value)
+	}
+	switch unit := Unit(value[len(value)-1]); unit {
+	case Minute, Hour, Day, Week, Month:
+		return Timeframe{n: n, unit: unit}, nil
+	}
+	return Timeframe{}, fmt.Errorf("unsupported timeframe: %s", value)
+}
+
+// MustParse parses a timeframe known to be valid
+func MustParse(value string) Timeframe {
+	t, err := Parse(value)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func (t Timeframe) String() string {
+	if t.IsZero() {
+		return ""
+	}
+	return fmt.Sprintf("%d%c", t.n, t.unit)
+}
+
+// IsZero reports whether the timeframe is unset
+func (t Timeframe) IsZero() bool {
+	return t.n == 0
+}
+
+// Calendar reports whether candles are calendar months, whose length varies
+func (t Timeframe) Calendar() bool {
+	return t.unit == Month
+}
+
+// Duration returns the length of a candle, the average Gregorian month for months.
+// Use Next for the exact end of a given candle.
+func (t Timeframe) Duration() time.Duration {
+	switch t.unit {
+	case Minute:
+		return time.Duration(t.n) * time.Minute
+	case Hour:
+		return time.Duration(t.n) * time.Hour
+	case Day:
+		return time.Duration(t.n) * 24 * time.Hour
+	case Week:
+		return time.Duration(t.n) * week
+	case Month:
+		return time.Duration(t.n) * averageMonth
+	}
+	return 0
+}
+
+// Candles returns the number of candles covering the days, rounded up
+func (t Timeframe) Candles(days int) int {
+	if days <= 0 || t.IsZero() {
+		return 0
+	}
+	return int(math.Ceil(float64(time.Duration(days)*24*time.Hour) / float64(t.Duration())))
+}
+
+// Open returns the open time of the candle containing ts
+func (t Timeframe) Open(ts int64) int64 {
+	switch t.unit {
+	case Month:
+		d := time.UnixMilli(ts).UTC()
+		months := int64(d.Year()-1970)*12 + int64(d.Month()-1)
+		months -= floorMod(months, t.n)
+		return time.Date(1970, time.Month(months+1), 1, 0, 0, 0, 0, time.UTC).UnixMilli()
+	case Week:
+		return ts - floorMod(ts-firstMonday.Milliseconds(), t.Duration().Milliseconds())
+	}
+	return ts - floorMod(ts, t.Duration().Milliseconds())
+}
+
+// Next returns the open time of the candle following the one opened at open
+func (t Timeframe) Next(open int64) int64 {
+	if t.unit == Month {
+		return time.UnixMilli(open).UTC().AddDate(0, int(t.n), 0).UnixMilli()
+	}
+	return open + t.Duration().Milliseconds()
+}
+
+// Prev returns the open time of the candle preceding the one opened at open
+func (t Timeframe) Prev(open int64) int64 {
+	if t.unit == Month {
+		return time.UnixMilli(open).UTC().AddDate(0, -int(t.n), 0).UnixMilli()
+	}
+	return open - t.Duration().Milliseconds()
+}
+
+// Divides reports whether every candle of coarse is made of whole candles of t
+func (t Timeframe) Divides(coarse Timeframe) bool {
+	if t.IsZero() || coarse.IsZero() {
+		return false
+	}
+	switch {
+	case t.unit == Month:
+		return coarse.unit == Month && coarse.n%t.n == 0
+	case t.unit == Week:
+		return coarse.unit == Week && coarse.n%t.n == 0
+	}
+
+	step := t.Duration().Milliseconds()
+	switch coarse.unit {
+	case Month:
+		// months open at midnight
+		return (24*time.Hour).Milliseconds()%step == 0
+	case Week:
+		return coarse.Duration().Milliseconds()%step == 0 && firstMonday.Milliseconds()%step == 0
+	}
+	return coarse.Duration().Milliseconds()%step == 0
+}
+
+// count returns the number of candles of t in the candle of coarse opened at open
+func (t Timeframe) count(coarse Timeframe, open int64) int64 {
+	if t.Calendar() {
+		return coarse.n / t.n
+	}
+	return (coarse.Next(open) - open) / t.Duration().Milliseconds()
+}
+
+func floorMod(a, b int64) int64 {
+	m := a % b
+	if m < 0 {
+		m += b
+	}
+	return m
+}
@@ -0,0 +1,199 @@
+package timeframe_test
+
+import (
+	"cb_grok/internal/timeframe"
+	"testing"
+	"time"
+	_ "time/tzdata"
+)
+
+func ms(year int, month time.Month, day, hour, minute int) int64 {
+	return time.Date(year, month, day, hour, minute, 0, 0, time.UTC).UnixMilli()
+}
+
+func format(ts int64) string {
+	return time.UnixMilli(ts).UTC().Format("2006-01-02 15:04 Mon")
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{value: "1m", want: "1m"},
+		{value: "15m", want: "15m"},
+		{value: "2h", want: "2h"},
+		{value: "3d", want: "3d"},
+		{value: "1w", want: "1w"},
+		{value: "1M", want: "1M"},
+		// a bare number is a number of minutes
+		{value: "60", want: "60m"},
+		{value: "240", want: "240m"},
+		{value: "", wantErr: true},
+		{value: "0", wantErr: true},
+		{value: "0h", wantErr: true},
+		{value: "-1h", wantErr: true},
+		{value: "h", wantErr: true},
+		{value: "5x", wantErr: true},
+		{value: "1y", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := timeframe.Parse(tt.value)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("Parse(%q) = %s, want an error", tt.value, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.String() != tt.want {
+				t.Errorf("Parse(%q) = %s, want %s", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOpen(t *testing.T) {
+	tests := []struct {
+		name string
+		tf   string
+		ts   int64
+		want int64
+	}{
+		{name: "minute", tf: "15m", ts: ms(2024, 5, 17, 10, 44), want: ms(2024, 5, 17, 10, 30)},
+		{name: "hour", tf: "4h", ts: ms(2024, 5, 17, 3, 59), want: ms(2024, 5, 17, 0, 0)},
+		// the week of New Year opens on Monday of the old year
+		{name: "week across the year", tf: "1w", ts: ms(2025, 1, 1, 12, 0), want: ms(2024, 12, 30, 0, 0)},
+		{name: "week on Sunday night", tf: "1w", ts: ms(2025, 1, 5, 23, 59), want: ms(2024, 12, 30, 0, 0)},
+		{name: "week on Monday", tf: "1w", ts: ms(2025, 1, 6, 0, 0), want: ms(2025, 1, 6, 0, 0)},
+		{name: "week before the first Monday", tf: "1w", ts: ms(1970, 1, 1, 0, 0), want: ms(1969, 12, 29, 0, 0)},
+		{name: "two weeks", tf: "2w", ts: ms(2024, 1, 3, 0, 0), want: ms(2023, 12, 25, 0, 0)},
+		{name: "month across the year", tf: "1M", ts: ms(2024, 12, 31, 23, 59), want: ms(2024, 12, 1, 0, 0)},
+		{name: "month on the 1st", tf: "1M", ts: ms(2025, 1, 1, 0, 0), want: ms(2025, 1, 1, 0, 0)},
+		{name: "leap day", tf: "1M", ts: ms(2024, 2, 29, 18, 0), want: ms(2024, 2, 1, 0, 0)},
+		{name: "quarter", tf: "3M", ts: ms(2024, 5, 15, 0, 0), want: ms(2024, 4, 1, 0, 0)},
+		{name: "quarter across the year", tf: "3M", ts: ms(2025, 2, 1, 0, 0), want: ms(2025, 1, 1, 0, 0)},
+		// candles are in UTC, the European and US clock changes do not move them
+		{name: "day on the EU clock change", tf: "1d", ts: ms(2024, 3, 31, 1, 30), want: ms(2024, 3, 31, 0, 0)},
+		{name: "week on the US clock change", tf: "1w", ts: ms(2024, 3, 10, 7, 30), want: ms(2024, 3, 4, 0, 0)},
+		{name: "month on the EU clock change", tf: "1M", ts: ms(2024, 10, 27, 1, 0), want: ms(2024, 10, 1, 0, 0)},
+	}
+
+	// the local zone must not leak into the grid
+	local := time.Local
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Local = berlin
+	t.Cleanup(func() { time.Local = local })
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tf := timeframe.MustParse(tt.tf)
+			if got := tf.Open(tt.ts); got != tt.want {
+				t.Errorf("%s Open(%s) = %s, want %s", tt.tf, format(tt.ts), format(got), format(tt.want))
+			}
+			if got := tf.Open(tt.want); got != tt.want {
+				t.Errorf("%s Open(%s) = %s, want the open itself", tt.tf, format(tt.want), format(got))
+			}
+		})
+	}
+}
+
+func TestNextPrev(t *testing.T) {
+	tests := []struct {
+		tf   string
+		open int64
+		next int64
+	}{
+		{tf: "1h", open: ms(2024, 12, 31, 23, 0), next: ms(2025, 1, 1, 0, 0)},
+		{tf: "1d", open: ms(2024, 2, 28, 0, 0), next: ms(2024, 2, 29, 0, 0)},
+		{tf: "1w", open: ms(2024, 12, 30, 0, 0), next: ms(2025, 1, 6, 0, 0)},
+		{tf: "1M", open: ms(2024, 1, 1, 0, 0), next: ms(2024, 2, 1, 0, 0)},
+		{tf: "1M", open: ms(2024, 2, 1, 0, 0), next: ms(2024, 3, 1, 0, 0)},
+		{tf: "1M", open: ms(2024, 12, 1, 0, 0), next: ms(2025, 1, 1, 0, 0)},
+		{tf: "3M", open: ms(2024, 10, 1, 0, 0), next: ms(2025, 1, 1, 0, 0)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.tf+" "+format(tt.open), func(t *testing.T) {
+			tf := timeframe.MustParse(tt.tf)
+			if got := tf.Next(tt.open); got != tt.next {
+				t.Errorf("Next = %s, want %s", format(got), format(tt.next))
+			}
+			if got := tf.Prev(tt.next); got != tt.open {
+				t.Errorf("Prev = %s, want %s", format(got), format(tt.open))
+			}
+		})
+	}
+}
+
+func TestDivides(t *testing.T) {
+	tests := []struct {
+		fine, coarse string
+		want         bool
+	}{
+		{"1m", "1h", true},
+		{"7m", "1h", false},
+		{"1h", "1h", true},
+		{"1d", "1h", false},
+		{"1h", "1d", true},
+		{"5h", "1d", false},
+		{"1h", "1w", true},
+		{"1d", "1w", true},
+		// 2d candles on the epoch grid do not open on Mondays
+		{"2d", "1w", false},
+		{"1w", "2w", true},
+		{"2w", "3w", false},
+		{"1d", "1M", true},
+		{"2d", "1M", false},
+		{"1w", "1M", false},
+		{"1M", "3M", true},
+		{"2M", "3M", false},
+		{"3M", "1M", false},
+		{"1M", "1w", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.fine+" into "+tt.coarse, func(t *testing.T) {
+			if got := timeframe.MustParse(tt.fine).Divides(timeframe.MustParse(tt.coarse)); got != tt.want {
+				t.Errorf("Divides = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if (timeframe.Timeframe{}).Divides(timeframe.MustParse("1h")) || timeframe.MustParse("1h").Divides(timeframe.Timeframe{}) {
+		t.Error("zero timeframe divides")
+	}
+}
+
+func TestDurationAndCandles(t *testing.T) {
+	tests := []struct {
+		tf       string
+		duration time.Duration
+		// candles covering 30 days
+		candles int
+	}{
+		{tf: "1m", duration: time.Minute, candles: 43200},
+		{tf: "60", duration: time.Hour, candles: 720},
+		{tf: "7m", duration: 7 * time.Minute, candles: 6172},
+		{tf: "1d", duration: 24 * time.Hour, candles: 30},
+		{tf: "1w", duration: 7 * 24 * time.Hour, candles: 5},
+		// a month has the mean Gregorian length
+		{tf: "1M", duration: time.Duration(365.2425 / 12 * float64(24*time.Hour)), candles: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.tf, func(t *testing.T) {
+			tf := timeframe.MustParse(tt.tf)
+			if got := tf.Duration(); got != tt.duration {
+				t.Errorf("Duration = %s, want %s", got, tt.duration)
+			}
+			if got := tf.Candles(30); got != tt.candles {
+				t.Errorf("Candles(30) = %d, want %d", got, tt.candles)
+			}
+		})
+	}
+}
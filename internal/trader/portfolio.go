@@ -0,0 +1,108 @@
+package trader
+
+import (
+	orderModel "cb_grok/internal/order/model"
+)
+
+func (s *state) GetCash() float64 {
+	return s.cash
+}
+
+func (s *state) GetPosition() float64 {
+	return s.position
+}
+
+// GetAverageEntryPrice returns the cost basis of the open position per base unit, fees included
+func (s *state) GetAverageEntryPrice() float64 {
+	return s.avgEntryPrice
+}
+
+func (s *state) GetFeesPaid() float64 {
+	return s.feesPaid
+}
+
+func (s *state) GetRealizedPnL() float64 {
+	return s.realizedPnL
+}
+
+func (s *state) GetUnrealizedPnL() float64 {
+	if s.position == 0 {
+		return 0
+	}
+	return s.position * (s.lastPrice - s.avgEntryPrice)
+}
+
+// bookable reports whether the order is filled and has not been booked into the portfolio yet.
+// Orders are booked in ID order, so every order up to lastAppliedOrderID is booked.
+func (s *state) bookable(ord *orderModel.Order) bool {
+	return ord != nil && ord.ID > s.lastAppliedOrderID && ord.StatusID == int64(orderModel.OrderStatusFilled) &&
+		ord.BaseQty != nil && ord.QuoteQty != nil
+}
+
+// applyOrder books a filled order into the portfolio exactly once, from its executions when there are any.
+// Orders without executions fall back to their netted quantities: buy orders carry the spent quote amount
+// in BaseQty and the received base amount in QuoteQty, sell orders the sold base amount in BaseQty and the
+// received quote amount in QuoteQty. Their fees are estimated from the commission rate.
+// It returns the realized PnL of the order and whether the order has been booked by this call.
+func (s *state) applyOrder(ord *orderModel.Order, executions []orderModel.Execution, base, quote string, commission float64) (float64, bool) {
+	if !s.bookable(ord) {
+		return 0, false
+	}
+	s.lastAppliedOrderID = ord.ID
+
+	side := orderModel.OrderSide(ord.SideID)
+	spent, received := *ord.BaseQty, *ord.QuoteQty
+	var fee float64
+	if len(executions) > 0 {
+		fills := orderModel.SumExecutions(side, executions, base, quote)
+		spent, received, fee = fills.Spent, fills.Received, fills.QuoteFee
+	} else if side == orderModel.OrderSideBuy {
+		fee = spent * commission
+	} else if commission < 1 {
+		fee = received * commission / (1 - commission)
+	}
+
+	switch side {
+	case orderModel.OrderSideBuy:
+		if received <= 0 {
+			return 0, true
+		}
+		cost := s.position*s.avgEntryPrice + spent
+		s.position += received
+		s.avgEntryPrice = cost / s.position
+		s.cash = max(s.cash-spent, 0)
+		s.feesPaid += fee
+		return 0, true
+	case orderModel.OrderSideSell:
+		sold := min(spent, s.position)
+		pnl := received - sold*s.avgEntryPrice
+		s.position -= sold
+		if s.position <= 0 {
+			s.position = 0
+			s.avgEntryPrice = 0
+		}
+		s.cash += received
+		s.feesPaid += fee
+		s.realizedPnL += pnl
+		return pnl, true
+	}
+
+	return 0, true
+}
+
+// markToMarket values the portfolio at the given close and appends it to the equity curve
+func (s *state) markToMarket(timestamp int64, price float64) float64 {
+	s.lastPrice = price
+	value := s.cash + s.position*price
+
+	if n := len(s.portfolioValues); n > 0 && s.portfolioValues[n-1].Timestamp == timestamp {
+		s.portfolioValues[n-1].Value = value
+	} else {
+		s.portfolioValues = append(s.portfolioValues, PortfolioValue{
+			Timestamp: timestamp,
+			Value:     value,
+		})
+	}
+
+	return value
+}
@@ -0,0 +1,195 @@
+package trader
+
+import (
+	"cb_grok/internal/order"
+	orderModel "cb_grok/internal/order/model"
+	symbolModel "cb_grok/internal/symbol/model"
+	traderModel "cb_grok/internal/trader/model"
+	"github.com/samber/lo"
+	"go.uber.org/zap"
+	"math"
+	"testing"
+)
+
+const testCommission = 0.001
+
+func filledOrder(id int64, side orderModel.OrderSide, baseQty, quoteQty float64) *orderModel.Order {
+	return &orderModel.Order{
+		ID:       id,
+		SideID:   int64(side),
+		StatusID: int64(orderModel.OrderStatusFilled),
+		BaseQty:  lo.ToPtr(baseQty),
+		QuoteQty: lo.ToPtr(quoteQty),
+	}
+}
+
+func near(a, b float64) bool {
+	return math.Abs(a-b) <= 1e-9*math.Max(1, math.Abs(b))
+}
+
+type portfolio struct {
+	cash, position, avgEntryPrice, feesPaid, realizedPnL float64
+}
+
+func (s *state) portfolio() portfolio {
+	return portfolio{s.cash, s.position, s.avgEntryPrice, s.feesPaid, s.realizedPnL}
+}
+
+func checkPortfolio(t *testing.T, s *state, want portfolio) {
+	t.Helper()
+	got := s.portfolio()
+	if !near(got.cash, want.cash) || !near(got.position, want.position) || !near(got.avgEntryPrice, want.avgEntryPrice) ||
+		!near(got.feesPaid, want.feesPaid) || !near(got.realizedPnL, want.realizedPnL) {
+		t.Errorf("portfolio = %+v, want %+v", got, want)
+	}
+}
+
+func TestApplyOrderNetted(t *testing.T) {
+	s := &state{initialCapital: 1000, cash: 1000}
+
+	// a buy spends quote in BaseQty and receives base net of the commission in QuoteQty
+	buy := filledOrder(1, orderModel.OrderSideBuy, 100, 0.0025)
+	pnl, booked := s.applyOrder(buy, nil, "BTC", "USDT", testCommission)
+	if !booked || pnl != 0 {
+		t.Fatalf("buy: pnl = %v, booked = %v", pnl, booked)
+	}
+	checkPortfolio(t, s, portfolio{cash: 900, position: 0.0025, avgEntryPrice: 40000, feesPaid: 0.1})
+
+	// a sell spends base in BaseQty and receives quote net of the commission in QuoteQty
+	sell := filledOrder(2, orderModel.OrderSideSell, 0.0025, 109.89)
+	pnl, booked = s.applyOrder(sell, nil, "BTC", "USDT", testCommission)
+	if !booked || !near(pnl, 9.89) {
+		t.Fatalf("sell: pnl = %v, booked = %v, want 9.89", pnl, booked)
+	}
+	checkPortfolio(t, s, portfolio{cash: 1009.89, feesPaid: 0.1 + 109.89*testCommission/(1-testCommission), realizedPnL: 9.89})
+}
+
+func TestApplyOrderExecutions(t *testing.T) {
+	s := &state{initialCapital: 1000, cash: 1000}
+
+	// two fills with the commission charged in the received base, the netted quantities are ignored
+	buy := filledOrder(1, orderModel.OrderSideBuy, 100, 1)
+	executions := []orderModel.Execution{
+		{OrderID: 1, Price: 40000, Qty: 0.001, Value: 40, Fee: 0.000001, FeeCurrency: "BTC"},
+		{OrderID: 1, Price: 40100, Qty: 0.0015, Value: 60.15, Fee: 0.0000015, FeeCurrency: "BTC"},
+	}
+	if _, booked := s.applyOrder(buy, executions, "BTC", "USDT", testCommission); !booked {
+		t.Fatal("buy not booked")
+	}
+	received := 0.0025 - 0.0000025
+	buyFee := 0.000001*40000 + 0.0000015*40100
+	checkPortfolio(t, s, portfolio{cash: 1000 - 100.15, position: received, avgEntryPrice: 100.15 / received, feesPaid: buyFee})
+
+	// a sell with the commission charged in quote
+	sell := filledOrder(2, orderModel.OrderSideSell, received, 1)
+	executions = []orderModel.Execution{
+		{OrderID: 2, Price: 42000, Qty: received, Value: received * 42000, Fee: received * 42, FeeCurrency: "USDT"},
+	}
+	pnl, booked := s.applyOrder(sell, executions, "BTC", "USDT", testCommission)
+	if !booked {
+		t.Fatal("sell not booked")
+	}
+	proceeds := received * (42000 - 42)
+	if want := proceeds - 100.15; !near(pnl, want) {
+		t.Errorf("pnl = %v, want %v", pnl, want)
+	}
+	checkPortfolio(t, s, portfolio{cash: 1000 - 100.15 + proceeds, feesPaid: buyFee + received*42, realizedPnL: proceeds - 100.15})
+}
+
+func TestApplyOrderAveragesEntries(t *testing.T) {
+	s := &state{initialCapital: 1000, cash: 1000}
+
+	s.applyOrder(filledOrder(1, orderModel.OrderSideBuy, 100, 0.0025), nil, "BTC", "USDT", testCommission)
+	s.applyOrder(filledOrder(2, orderModel.OrderSideBuy, 200, 0.004), nil, "BTC", "USDT", testCommission)
+	checkPortfolio(t, s, portfolio{cash: 700, position: 0.0065, avgEntryPrice: 300 / 0.0065, feesPaid: 0.3})
+
+	// a sell larger than the position only closes the position
+	pnl, _ := s.applyOrder(filledOrder(3, orderModel.OrderSideSell, 0.01, 330), nil, "BTC", "USDT", 0)
+	if !near(pnl, 30) {
+		t.Errorf("pnl = %v, want 30", pnl)
+	}
+	checkPortfolio(t, s, portfolio{cash: 1030, feesPaid: 0.3, realizedPnL: 30})
+}
+
+func TestApplyOrderBooksOnce(t *testing.T) {
+	tests := []struct {
+		name string
+		ord  *orderModel.Order
+	}{
+		{name: "nil", ord: nil},
+		{name: "booked", ord: filledOrder(5, orderModel.OrderSideBuy, 100, 0.0025)},
+		{name: "older than the booked one", ord: filledOrder(4, orderModel.OrderSideBuy, 100, 0.0025)},
+		{name: "not filled", ord: &orderModel.Order{ID: 6, SideID: int64(orderModel.OrderSideBuy), StatusID: int64(orderModel.OrderStatusPlaced), BaseQty: lo.ToPtr(100.0)}},
+		{name: "received qty not stored yet", ord: &orderModel.Order{ID: 6, SideID: int64(orderModel.OrderSideBuy), StatusID: int64(orderModel.OrderStatusFilled), BaseQty: lo.ToPtr(100.0)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &state{cash: 1000, lastAppliedOrderID: 5}
+			if _, booked := s.applyOrder(tt.ord, nil, "BTC", "USDT", testCommission); booked {
+				t.Fatal("order booked")
+			}
+			checkPortfolio(t, s, portfolio{cash: 1000})
+		})
+	}
+}
+
+// filledOrders serves the filled orders of a trader, the other methods are not called
+type filledOrders struct {
+	order.Order
+	orders []orderModel.Order
+}
+
+func (f *filledOrders) GetFilledOrders(traderID int64, afterID int64) ([]orderModel.Order, error) {
+	var result []orderModel.Order
+	for _, ord := range f.orders {
+		if ord.ID > afterID && ord.StatusID == int64(orderModel.OrderStatusFilled) {
+			result = append(result, ord)
+		}
+	}
+	return result, nil
+}
+
+func (f *filledOrders) GetOrderExecutions(orderID int64) ([]orderModel.Execution, error) {
+	return nil, nil
+}
+
+func TestBookFilledOrdersBooksEntryAndExit(t *testing.T) {
+	orders := &filledOrders{}
+	tr := &trader{
+		model:    &traderModel.Trader{ID: 1},
+		symbol:   symbolModel.Symbol{Base: "BTC", Quote: "USDT"},
+		settings: &Settings{Commission: 0},
+		state:    &state{initialCapital: 1000, cash: 1000},
+		orderUC:  orders,
+		log:      zap.NewNop(),
+	}
+
+	// the entry and its take-profit leg both fill between two candles
+	orders.orders = []orderModel.Order{
+		*filledOrder(1, orderModel.OrderSideBuy, 100, 0.0025),
+		*filledOrder(3, orderModel.OrderSideSell, 0.0025, 110),
+	}
+	pnl, booked := tr.bookFilledOrders()
+	if !booked || !near(pnl, 10) {
+		t.Fatalf("pnl = %v, booked = %v, want 10", pnl, booked)
+	}
+	checkPortfolio(t, tr.state, portfolio{cash: 1010, realizedPnL: 10})
+
+	// the next entry waits for its received qty, later fills wait for it
+	pending := *filledOrder(4, orderModel.OrderSideBuy, 100, 0)
+	pending.QuoteQty = nil
+	orders.orders = append(orders.orders, pending, *filledOrder(5, orderModel.OrderSideSell, 0.0025, 120))
+	if _, booked = tr.bookFilledOrders(); booked {
+		t.Fatal("fills booked before the received qty of the entry")
+	}
+
+	orders.orders[2].QuoteQty = lo.ToPtr(0.0025)
+	pnl, booked = tr.bookFilledOrders()
+	if !booked || !near(pnl, 20) {
+		t.Fatalf("pnl = %v, booked = %v, want 20", pnl, booked)
+	}
+	checkPortfolio(t, tr.state, portfolio{cash: 1030, realizedPnL: 30})
+	if tr.state.lastAppliedOrderID != 5 {
+		t.Errorf("last applied order = %d, want 5", tr.state.lastAppliedOrderID)
+	}
+}
@@ -2,18 +2,15 @@ package trader
 
 import (
 	"bytes"
+	"cb_grok/internal/candle/quality"
 	"cb_grok/internal/exchange"
-	"cb_grok/internal/exchange/bybit"
-	"cb_grok/internal/utils"
 	"cb_grok/pkg/models"
 	"context"
 	"encoding/json"
 	"fmt"
-	bybitapi "github.com/bybit-exchange/bybit.go.api"
 	"github.com/dnlo/struct2csv"
 	"github.com/gorilla/websocket"
 	"go.uber.org/zap"
-	"strconv"
 	"strings"
 	"time"
 )
@@ -25,75 +22,47 @@ func (t *trader) Run(mode TradeMode) error {
 	if mode != ModeLiveDemo {
 		return fmt.Errorf("unsupported trade mode")
 	}
+	if err := ValidateWindow(t.settings.Window, t.strategy); err != nil {
+		return err
+	}
 	t.log.Info(fmt.Sprintf("timeframe %s", t.strategyEntity.TimeFrame))
-	timeframeSec := utils.TimeframeToMilliseconds(t.strategyEntity.TimeFrame) / 1000
-	candlesPerDay := (24 * 60 * 60) / int(timeframeSec)
-
-	totalCandles := 60 * candlesPerDay
-
-	candles, err := t.exch.FetchSpotOHLCV(t.symbol.Code, exchange.Timeframe1h, totalCandles)
+	timeframe := exchange.Timeframe(t.strategyEntity.TimeFrame)
+	tf, err := timeframe.Parse()
 	if err != nil {
 		return err
 	}
 
-	t.state.ohlcv = candles
-
-	t.log.Info("CONNECTING TO WS")
+	totalCandles := max(tf.Candles(60), t.settings.Window)
 
-	ws := bybitapi.NewBybitPublicWebSocket("wss://stream.bybit.com/v5/public/spot", func(message string) error {
-		var msg bybit.WSKlineMessage
-		err := json.Unmarshal([]byte(message), &msg)
-		if err != nil {
-			t.log.Error("cannot parse received message", zap.Error(err))
-			return fmt.Errorf("cannot parse received message: %w", err)
-		}
-
-		if msg.Success {
-			t.log.Info("successfully connected to the bybit ws stream")
-			return nil
-		}
+	candles, err := t.exch.FetchSpotOHLCV(t.symbol.Code, timeframe, totalCandles)
+	if err != nil {
+		return err
+	}
 
-		if len(msg.Data) != 1 {
-			return fmt.Errorf("received data with unexpected length %d: %+v", len(msg.Data), msg)
-		}
+	t.quality = t.settings.Quality
+	t.quality.Timeframe = tf
+	candles, report, err := quality.Repair(fmt.Sprintf("trader_%d %s %s", t.model.ID, t.symbol.Code, timeframe), candles, t.quality)
+	report.Log(t.log)
+	if err != nil {
+		return err
+	}
 
-		currCandle := msg.Data[0]
+	t.state.ohlcv = candles
 
-		o, err := strconv.ParseFloat(currCandle.Open, 64)
-		if err != nil {
-			return fmt.Errorf("cannot parse candle: %+v", currCandle)
-		}
-		h, err := strconv.ParseFloat(currCandle.High, 64)
-		if err != nil {
-			return fmt.Errorf("cannot parse candle: %+v", currCandle)
-		}
-		l, err := strconv.ParseFloat(currCandle.Low, 64)
-		if err != nil {
-			return fmt.Errorf("cannot parse candle: %+v", currCandle)
-		}
-		c, err := strconv.ParseFloat(currCandle.Close, 64)
-		if err != nil {
-			return fmt.Errorf("cannot parse candle: %+v", currCandle)
-		}
-		v, err := strconv.ParseFloat(currCandle.Volume, 64)
-		if err != nil {
-			return fmt.Errorf("cannot parse candle: %+v", currCandle)
-		}
+	klines, err := t.exch.SubscribeKlines(t.ctx, t.symbol.Code, timeframe)
+	if err != nil {
+		return fmt.Errorf("subscribe to klines: %w", err)
+	}
+	t.log.Info("subscribed to klines", zap.String("exchange", t.exch.Name()), zap.String("symbol", t.symbol.Code))
 
-		var candle = models.OHLCV{
-			Timestamp: currCandle.Start,
-			Open:      o,
-			High:      h,
-			Low:       l,
-			Close:     c,
-			Volume:    v,
-		}
+	for kline := range klines {
+		candle := kline.OHLCV
 
-		// Save candle to database if repository is available
-		if t.candleRepo != nil {
+		// Save closed candles to database if repository is available
+		if kline.Confirm && t.candleRepo != nil {
 			symbol := strings.ReplaceAll(t.symbol.Code, "/", "")
 			ctx := context.Background()
-			if err := t.candleRepo.Create(ctx, symbol, "bybit", t.strategyEntity.TimeFrame, candle); err != nil {
+			if err := t.candleRepo.Create(ctx, symbol, t.exch.Name(), t.strategyEntity.TimeFrame, candle); err != nil {
 				t.log.Error("failed to save candle", zap.Error(err),
 					zap.String("symbol", symbol),
 					zap.String("timeframe", t.strategyEntity.TimeFrame),
@@ -101,10 +70,10 @@ func (t *trader) Run(mode TradeMode) error {
 			}
 		}
 
-		action, err := t.processAlgo(candle)
+		action, err := t.processKline(kline)
 		if err != nil {
-			t.log.Error("trade algo error", zap.Error(err), zap.String("message", message))
-			t.tg.SendMessage(fmt.Sprintf("trade algo error: %s\n\nMessage: %s", err.Error(), message))
+			t.log.Error("trade algo error", zap.Error(err), zap.Int64("timestamp", candle.Timestamp))
+			t.tg.SendMessage(fmt.Sprintf("trade algo error: %s\n\nCandle: %+v", err.Error(), candle))
 		}
 
 		if action != nil {
@@ -119,13 +88,10 @@ func (t *trader) Run(mode TradeMode) error {
 				)
 			}
 		}
-		return nil
-	})
-
-	symbol := strings.ReplaceAll(t.symbol.Code, "/", "")
-	_, _ = ws.Connect().SendSubscription([]string{fmt.Sprintf("kline.%s.%s", strconv.FormatInt(timeframeSec/60, 10), symbol)})
+	}
 
-	select {}
+	t.log.Info("kline stream closed", zap.String("exchange", t.exch.Name()), zap.String("symbol", t.symbol.Code))
+	return nil
 }
 
 func (t *trader) RunSimulation(mode TradeMode) error {
@@ -135,6 +101,11 @@ func (t *trader) RunSimulation(mode TradeMode) error {
 	if mode != ModeSimulation {
 		return fmt.Errorf("unsupported trade mode")
 	}
+	if err := ValidateWindow(t.settings.Window, t.strategy); err != nil {
+		return err
+	}
+	// the simulation feed has no fixed timeframe, so missing bars are not looked for
+	t.quality = t.settings.Quality
 
 	wsUrl := "ws://localhost:8080/ws"
 
@@ -149,7 +120,7 @@ func (t *trader) RunSimulation(mode TradeMode) error {
 	t.log.Info("connected to websocket", zap.String("url", wsUrl))
 
 	b, _ := json.MarshalIndent(t.strategyEntity.Params, "", "    ")
-	fmt.Printf("Use model:\n%+v\n", string(b))
+	fmt.Printf("Use model %s:\n%+v\n", t.strategy.Type(), string(b))
 
 	// --------------------------------------------------------------
 
@@ -212,7 +183,7 @@ func (t *trader) RunSimulation(mode TradeMode) error {
 
 	result := fmt.Sprintf(
 		"Результат симуляции\n\nСимвол: %s\nКол-во свечей: %d\nКоличество сделок: %d\nSharpe Ratio: %.2f\nИтоговый капитал: %.2f\nМаксимальная просадка: %.2f%%\nWin Rate: %.2f",
-		t.symbol, len(t.state.GetOHLCV()), len(t.state.GetOrders()), t.state.CalculateSharpeRatio(), t.state.GetPortfolioValue(), t.state.CalculateMaxDrawdown(), t.state.CalculateWinRate())
+		t.symbol.Code, len(t.state.GetOHLCV()), len(t.state.GetOrders()), t.state.CalculateSharpeRatio(), t.state.GetPortfolioValue(), t.state.CalculateMaxDrawdown(), t.state.CalculateWinRate())
 
 	buff := &bytes.Buffer{}
 	w := struct2csv.NewWriter(buff)
@@ -1,16 +1,20 @@
 package trader
 
 import (
+	"cb_grok/internal/candle/quality"
 	"cb_grok/internal/exchange"
 	"cb_grok/internal/strategy"
 	strategyModel "cb_grok/internal/strategy/model"
 	symbolModel "cb_grok/internal/symbol/model"
 	traderModel "cb_grok/internal/trader/model"
 	"cb_grok/pkg/models"
+	"context"
 	"math"
 )
 
 type Params struct {
+	// Context stops the order sync and the kline stream of the exchange, default never
+	Context        context.Context
 	Symbol         symbolModel.Symbol
 	Exchange       exchange.Exchange
 	Strategy       strategy.Strategy
@@ -26,6 +30,18 @@ type Settings struct {
 	Spread               float64
 	StopLossMultiplier   float64
 	TakeProfitMultiplier float64
+	// IntrabarExits checks stop-loss and take-profit against the forming candle,
+	// otherwise they are checked on candle close together with the signals
+	IntrabarExits bool
+	// PassiveEntries enters with post-only limit orders at the close of the signal candle instead of market orders.
+	// An unfilled entry follows the close and is canceled after EntryTimeoutCandles closed candles.
+	PassiveEntries      bool
+	EntryTimeoutCandles int
+	// Quality validates every closed candle before the strategy sees it, its timeframe is the strategy's
+	Quality quality.Options
+	// Window is the number of closed candles kept in memory. Indicators are recomputed or reseeded
+	// over the window, so it must cover the lookback of the strategy, see ValidateWindow.
+	Window int
 }
 
 type PortfolioValue struct {
@@ -49,6 +65,18 @@ type Action struct {
 type state struct {
 	initialCapital float64
 
+	// portfolio accounting, see portfolio.go
+	cash               float64
+	position           float64
+	avgEntryPrice      float64
+	feesPaid           float64
+	realizedPnL        float64
+	lastPrice          float64
+	lastAppliedOrderID int64
+
+	// closed candles the pending passive entry has waited for
+	pendingEntryCandles int
+
 	ohlcv           []models.OHLCV
 	appliedOHLCV    []models.AppliedOHLCV
 	orders          []Action
@@ -3,6 +3,7 @@ package trader
 import (
 	"bytes"
 	"cb_grok/internal/candle"
+	"cb_grok/internal/candle/quality"
 	"cb_grok/internal/exchange"
 	"cb_grok/internal/order"
 	"cb_grok/internal/strategy"
@@ -11,6 +12,7 @@ import (
 	"cb_grok/internal/telegram"
 	traderModel "cb_grok/internal/trader/model"
 	"cb_grok/pkg/models"
+	"context"
 	"go.uber.org/zap"
 )
 
@@ -39,14 +41,23 @@ var (
 		Spread:               0.0002, // 0.02%
 		StopLossMultiplier:   5,
 		TakeProfitMultiplier: 30,
+		IntrabarExits:        true,
+		EntryTimeoutCandles:  3,
+		Window:               1000,
 	}
 )
 
+// DefaultSettings returns a copy of the settings used when none are passed to Setup
+func DefaultSettings() Settings {
+	return defaultSettings
+}
+
 type Trader interface {
 	Setup(params Params)
 	Run(mode TradeMode) error
 	RunSimulation(mode TradeMode) error
 	BacktestAlgo(appliedOHLCV []models.AppliedOHLCV) (*Action, error)
+	BacktestSeries(appliedOHLCV []models.AppliedOHLCV) []error
 	GetState() State
 	SetMetricsCollector(collector MetricsCollector)
 }
@@ -60,6 +71,12 @@ type State interface {
 	CalculateMaxDrawdown() float64
 	CalculateSharpeRatio() float64
 	GetInitialCapital() float64
+	GetCash() float64
+	GetPosition() float64
+	GetAverageEntryPrice() float64
+	GetFeesPaid() float64
+	GetRealizedPnL() float64
+	GetUnrealizedPnL() float64
 	GetAppliedOHLCV() []models.AppliedOHLCV
 	GenerateCharts() (*bytes.Buffer, error)
 }
@@ -72,9 +89,17 @@ type trader struct {
 	state          *state
 	settings       *Settings
 	symbol         symbolModel.Symbol
+	// ctx stops the kline stream of a live run
+	ctx context.Context
 
 	orderUC    order.Order
 	candleRepo candle.Repository
+	// quality are the validation options of the running timeframe
+	quality quality.Options
+	// stream holds the indicators of a streaming strategy, streamed is the last candle it was updated with
+	stream      strategy.Stream
+	streamed    models.OHLCV
+	streamReady bool
 
 	tg  *telegram.TelegramService
 	log *zap.Logger
@@ -109,9 +134,15 @@ func (t *trader) Setup(params Params) {
 	t.model = params.Model
 	t.exch = params.Exchange
 	t.symbol = params.Symbol
-	t.orderUC.Init(params.Exchange)
+	ctx := params.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	t.ctx = ctx
+	t.orderUC.Init(ctx, t.model.ID, params.Exchange)
 
 	t.state = t.initState(params.InitialCapital)
+	t.stream = nil
 	if params.Settings != nil {
 		t.settings = params.Settings
 	}
@@ -131,5 +162,6 @@ func (t *trader) GetState() State {
 func (t *trader) initState(initialCapital float64) *state {
 	return &state{
 		initialCapital: initialCapital,
+		cash:           initialCapital,
 	}
 }
@@ -1,7 +1,11 @@
 package trader
 
 import (
+	"cb_grok/internal/candle/quality"
+	"cb_grok/internal/exchange"
+	"cb_grok/internal/order"
 	orderModel "cb_grok/internal/order/model"
+	"cb_grok/internal/strategy"
 	"cb_grok/pkg/models"
 	"encoding/json"
 	"fmt"
@@ -9,17 +13,32 @@ import (
 	"strings"
 )
 
+// processKline runs the strategy on closed candles only, so signals never repaint.
+// Updates of the forming candle are only used for intrabar exits.
+func (t *trader) processKline(kline exchange.Kline) (*Action, error) {
+	if !kline.Confirm {
+		return t.processIntrabar(kline.OHLCV)
+	}
+	return t.processAlgo(kline.OHLCV)
+}
+
 func (t *trader) processAlgo(candle models.OHLCV) (*Action, error) {
-	if len(t.state.ohlcv) > 0 && t.state.ohlcv[len(t.state.ohlcv)-1].Timestamp == candle.Timestamp {
-		t.state.ohlcv[len(t.state.ohlcv)-1] = candle
-	} else {
-		t.state.ohlcv = append(t.state.ohlcv, candle)
+	ohlcv, report, err := quality.Append(t.state.ohlcv, candle, t.quality)
+	t.state.ohlcv = ohlcv
+	t.observePrice(candle)
+	if !report.Clean() {
+		report.Dataset = fmt.Sprintf("trader_%d", t.model.ID)
+		report.Log(t.log)
+	}
+	if err != nil {
+		return nil, err
 	}
 
 	candleLog, _ := json.Marshal(candle)
 	t.log.Info(fmt.Sprintf("trader_%d: new candle has been processed", t.model.ID), zap.Int("total_length", len(t.state.ohlcv)), zap.String("candle", string(candleLog)))
 
-	appliedOHLCV := t.strategy.ApplyIndicators(t.state.ohlcv, t.strategyEntity.Params)
+	appliedOHLCV := t.applyIndicators()
+	t.state.ohlcv = lastCandles(t.state.ohlcv, t.settings.Window)
 	if appliedOHLCV == nil {
 		t.log.Info("trader: not enough candles in the dataset")
 		return nil, nil
@@ -28,22 +47,121 @@ func (t *trader) processAlgo(candle models.OHLCV) (*Action, error) {
 	return t.algo(appliedOHLCV)
 }
 
+// applyIndicators applies the strategy indicators to the closed candles. Streaming strategies are
+// updated with the candles added since the last call, seeded from the whole history on the first one,
+// and keep the last Window applied candles. Other strategies recompute the indicators over the window.
+func (t *trader) applyIndicators() []models.AppliedOHLCV {
+	streaming, ok := t.strategy.(strategy.Streaming)
+	if !ok {
+		return t.strategy.ApplyIndicators(t.state.ohlcv)
+	}
+
+	ohlcv := t.state.ohlcv
+	i := len(ohlcv)
+	for i > 0 && ohlcv[i-1].Timestamp > t.streamed.Timestamp {
+		i--
+	}
+	if t.stream == nil || i == 0 || ohlcv[i-1] != t.streamed {
+		// first call, or candles the stream has seen were repaired: start over from the window
+		t.stream = streaming.NewStream()
+		t.state.appliedOHLCV = nil
+		i = 0
+	}
+
+	for _, candle := range ohlcv[i:] {
+		var applied models.AppliedOHLCV
+		applied, t.streamReady = t.stream.Update(candle)
+		t.state.appliedOHLCV = append(t.state.appliedOHLCV, applied)
+		t.streamed = candle
+	}
+	t.state.appliedOHLCV = lastCandles(t.state.appliedOHLCV, t.settings.Window)
+
+	if !t.streamReady {
+		return nil
+	}
+	return t.state.appliedOHLCV
+}
+
+// WindowFor returns the window of a trader running the strategy: the configured number of candles,
+// or when not set the default window raised to the lookback of the strategy
+func WindowFor(candles int, str strategy.Strategy) int {
+	if candles > 0 {
+		return candles
+	}
+	return max(defaultSettings.Window, str.Lookback())
+}
+
+// ValidateWindow rejects a window shorter than the lookback of the strategy: after a reset the
+// indicators would never be ready again and the trader would stop trading
+func ValidateWindow(window int, str strategy.Strategy) error {
+	if window > 0 && window < str.Lookback() {
+		return fmt.Errorf("window of %d candles is shorter than the %d candles strategy %s needs", window, str.Lookback(), str.Type())
+	}
+	return nil
+}
+
+// lastCandles returns the last n candles, all of them when n is not positive
+func lastCandles[T any](candles []T, n int) []T {
+	if n > 0 && len(candles) > n {
+		return candles[len(candles)-n:]
+	}
+	return candles
+}
+
 func (t *trader) BacktestAlgo(appliedOHLCV []models.AppliedOHLCV) (*Action, error) {
 	tmpOHLCV := make([]models.OHLCV, len(appliedOHLCV))
 	for i := range appliedOHLCV {
 		tmpOHLCV[i] = appliedOHLCV[i].OHLCV
 	}
 	t.state.ohlcv = tmpOHLCV
+	if len(tmpOHLCV) > 0 {
+		t.observePrice(tmpOHLCV[len(tmpOHLCV)-1])
+	}
 
 	return t.algo(appliedOHLCV)
 }
 
+// observePrice feeds the candle to order usecases that fill against market price
+func (t *trader) observePrice(candle models.OHLCV) {
+	if observer, ok := t.orderUC.(order.PriceObserver); ok {
+		observer.ObservePrice(candle)
+	}
+}
+
+// BacktestSeries walks the candles one after another like BacktestAlgo on each prefix of them, with the
+// signals applied once to the whole series. The candles are modified in place. It returns the errors
+// of the candles the trader failed to decide on.
+func (t *trader) BacktestSeries(appliedOHLCV []models.AppliedOHLCV) []error {
+	appliedOHLCV = t.strategy.ApplySignals(appliedOHLCV)
+	if appliedOHLCV == nil {
+		return nil
+	}
+
+	ohlcv := make([]models.OHLCV, len(appliedOHLCV))
+	for i := range appliedOHLCV {
+		ohlcv[i] = appliedOHLCV[i].OHLCV
+	}
+	var errs []error
+	for i := range appliedOHLCV {
+		t.state.ohlcv = ohlcv[:i+1]
+		t.observePrice(ohlcv[i])
+		if _, err := t.decide(appliedOHLCV[:i+1]); err != nil {
+			errs = append(errs, fmt.Errorf("candle %d: %w", ohlcv[i].Timestamp, err))
+		}
+	}
+	return errs
+}
+
 func (t *trader) algo(appliedOHLCV []models.AppliedOHLCV) (*Action, error) {
-	appliedOHLCV = t.strategy.ApplySignals(appliedOHLCV, t.strategyEntity.Params)
+	appliedOHLCV = t.strategy.ApplySignals(appliedOHLCV)
 	if appliedOHLCV == nil {
 		return nil, nil
 	}
+	return t.decide(appliedOHLCV)
+}
 
+// decide acts on the signal of the last candle
+func (t *trader) decide(appliedOHLCV []models.AppliedOHLCV) (*Action, error) {
 	t.state.appliedOHLCV = appliedOHLCV
 
 	currentCandle := appliedOHLCV[len(appliedOHLCV)-1]
@@ -60,12 +178,19 @@ func (t *trader) algo(appliedOHLCV []models.AppliedOHLCV) (*Action, error) {
 
 	transactionAmount := 0.0
 
+	t.bookFilledOrders()
 	lastOrder, err := t.orderUC.GetLastOrder(t.model.ID)
 	if err != nil {
 		t.log.Error("failed to fetch last order", zap.Error(err))
 		return nil, err
 	}
 
+	if pendingEntry(lastOrder) {
+		t.managePendingEntry(lastOrder, currentPrice)
+		return t.completeAction(currentCandle, decision, decisionTrigger, transactionAmount), nil
+	}
+	t.state.pendingEntryCandles = 0
+
 	allowSell := lastOrder != nil && lastOrder.SideID == int64(orderModel.OrderSideBuy) && lastOrder.StatusID == int64(orderModel.OrderStatusFilled) && lastOrder.QuoteQty != nil
 	allowBuy := lastOrder == nil || (lastOrder.SideID == int64(orderModel.OrderSideSell) && lastOrder.StatusID == int64(orderModel.OrderStatusFilled) && lastOrder.QuoteQty != nil)
 
@@ -77,28 +202,12 @@ func (t *trader) algo(appliedOHLCV []models.AppliedOHLCV) (*Action, error) {
 	if allowSell {
 		if currentSignal == -1 { // sell signal
 			decision = DecisionSell
-
-			transactionAmount = *lastOrder.QuoteQty
-
-			err = t.orderUC.CreateSpotMarketOrder(t.symbol, "sell", transactionAmount, nil, nil, t.model.ID)
-			if err != nil {
-				t.log.Error("create order failed", zap.Error(err))
-			}
-		} else if currentPrice >= *lastOrder.TakeProfitPrice { // sell take-profit
+		} else if trigger, ok := t.exitTrigger(lastOrder, currentPrice); ok {
 			decision = DecisionSell
-			decisionTrigger = TriggerTakeProfit
-
-			transactionAmount = *lastOrder.QuoteQty
-
-			err = t.orderUC.CreateSpotMarketOrder(t.symbol, "sell", transactionAmount, nil, nil, t.model.ID)
-			if err != nil {
-				t.log.Error("create order failed", zap.Error(err))
-			}
-
-		} else if currentPrice <= *lastOrder.StopLossPrice {
-			decision = DecisionSell
-			decisionTrigger = TriggerStopLoss
+			decisionTrigger = trigger
+		}
 
+		if decision == DecisionSell {
 			transactionAmount = *lastOrder.QuoteQty
 
 			err = t.orderUC.CreateSpotMarketOrder(t.symbol, "sell", transactionAmount, nil, nil, t.model.ID)
@@ -120,18 +229,153 @@ func (t *trader) algo(appliedOHLCV []models.AppliedOHLCV) (*Action, error) {
 			stopLoss := currentPrice - currentCandle.ATR*t.settings.StopLossMultiplier
 			takeProfit := currentPrice + currentCandle.ATR*t.settings.TakeProfitMultiplier
 
-			err = t.orderUC.CreateSpotMarketOrder(t.symbol, "buy", transactionAmount, &takeProfit, &stopLoss, t.model.ID)
+			if t.settings.PassiveEntries {
+				err = t.orderUC.CreateSpotLimitOrder(t.symbol, "buy", transactionAmount, currentPrice, exchange.TimeInForcePostOnly, &takeProfit, &stopLoss, t.model.ID)
+			} else {
+				err = t.orderUC.CreateSpotMarketOrder(t.symbol, "buy", transactionAmount, &takeProfit, &stopLoss, t.model.ID)
+			}
 			if err != nil {
 				t.log.Error("create order failed", zap.Error(err))
 			}
 		}
 	}
 
-	portfolioValue := 0.0
-	t.state.portfolioValues = append(t.state.portfolioValues, PortfolioValue{
-		Timestamp: currentCandle.Timestamp,
-		Value:     portfolioValue,
-	})
+	return t.completeAction(currentCandle, decision, decisionTrigger, transactionAmount), nil
+}
+
+// pendingEntry reports whether the order is a passive entry waiting for its fill
+func pendingEntry(ord *orderModel.Order) bool {
+	return ord != nil && ord.TypeID == orderModel.OrderTypeLimit && ord.SideID == int64(orderModel.OrderSideBuy) && ord.IsOpen()
+}
+
+// managePendingEntry moves an unfilled passive entry to the close of the last candle
+// and cancels it once it has waited EntryTimeoutCandles candles
+func (t *trader) managePendingEntry(entry *orderModel.Order, price float64) {
+	t.state.pendingEntryCandles++
+
+	if t.state.pendingEntryCandles >= t.settings.EntryTimeoutCandles {
+		t.log.Info(fmt.Sprintf("trader_%d: passive entry timed out", t.model.ID), zap.Int64("order_id", entry.ID), zap.Int("candles", t.state.pendingEntryCandles))
+		if err := t.orderUC.CancelOrder(entry.ID); err != nil {
+			t.log.Error("cancel order failed", zap.Int64("order_id", entry.ID), zap.Error(err))
+			return
+		}
+		t.state.pendingEntryCandles = 0
+		return
+	}
+
+	if entry.Price != nil && *entry.Price != price {
+		if err := t.orderUC.AmendOrder(entry.ID, nil, &price); err != nil {
+			t.log.Error("amend order failed", zap.Int64("order_id", entry.ID), zap.Error(err))
+		}
+	}
+}
+
+// processIntrabar checks stop-loss and take-profit of the open position against the price of the forming candle.
+// Signals are not evaluated, the strategy only sees closed candles.
+func (t *trader) processIntrabar(candle models.OHLCV) (*Action, error) {
+	if !t.settings.IntrabarExits || len(t.state.appliedOHLCV) == 0 {
+		return nil, nil
+	}
+	t.observePrice(candle)
+
+	t.bookFilledOrders()
+	lastOrder, err := t.orderUC.GetLastOrder(t.model.ID)
+	if err != nil {
+		t.log.Error("failed to fetch last order", zap.Error(err))
+		return nil, err
+	}
+
+	allowSell := lastOrder != nil && lastOrder.SideID == int64(orderModel.OrderSideBuy) && lastOrder.StatusID == int64(orderModel.OrderStatusFilled) && lastOrder.QuoteQty != nil
+	if !allowSell {
+		return nil, nil
+	}
+	trigger, ok := t.exitTrigger(lastOrder, candle.Close)
+	if !ok {
+		return nil, nil
+	}
+
+	t.log.Info(fmt.Sprintf("trader_%d: intrabar exit", t.model.ID), zap.String("trigger", string(trigger)), zap.Float64("price", candle.Close))
+
+	transactionAmount := *lastOrder.QuoteQty
+	err = t.orderUC.CreateSpotMarketOrder(t.symbol, "sell", transactionAmount, nil, nil, t.model.ID)
+	if err != nil {
+		t.log.Error("create order failed", zap.Error(err))
+	}
+
+	// indicators are those of the last closed candle
+	current := t.state.appliedOHLCV[len(t.state.appliedOHLCV)-1]
+	current.OHLCV = candle
+
+	return t.completeAction(current, DecisionSell, trigger, transactionAmount), nil
+}
+
+// exitTrigger reports whether the price hits the take-profit or stop-loss of the entry order.
+// Exits resting on the exchange are left to it.
+func (t *trader) exitTrigger(entry *orderModel.Order, price float64) (TradeDecisionTrigger, bool) {
+	var takeProfitPlaced, stopLossPlaced bool
+	if exits, ok := t.orderUC.(order.ExchangeExits); ok {
+		takeProfitPlaced, stopLossPlaced = exits.ExchangeExits(*entry)
+	}
+	if !takeProfitPlaced && entry.TakeProfitPrice != nil && price >= *entry.TakeProfitPrice {
+		return TriggerTakeProfit, true
+	}
+	if !stopLossPlaced && entry.StopLossPrice != nil && price <= *entry.StopLossPrice {
+		return TriggerStopLoss, true
+	}
+	return "", false
+}
+
+// bookFilledOrders books the orders filled since the last booked one, oldest first.
+// It returns their realized PnL and whether any order has been booked.
+func (t *trader) bookFilledOrders() (float64, bool) {
+	orders, err := t.orderUC.GetFilledOrders(t.model.ID, t.state.lastAppliedOrderID)
+	if err != nil {
+		t.log.Error("failed to fetch filled orders", zap.Error(err))
+		return 0, false
+	}
+
+	var (
+		realizedPnL float64
+		booked      bool
+	)
+	for i := range orders {
+		// the received qty of a fill is stored after its status, later orders wait for it
+		if !t.state.bookable(&orders[i]) {
+			break
+		}
+		pnl, ok := t.bookOrder(&orders[i])
+		realizedPnL += pnl
+		booked = booked || ok
+	}
+	return realizedPnL, booked
+}
+
+// bookOrder books a filled order into the portfolio from its executions
+func (t *trader) bookOrder(ord *orderModel.Order) (float64, bool) {
+	if !t.state.bookable(ord) {
+		return 0, false
+	}
+	executions, err := t.orderUC.GetOrderExecutions(ord.ID)
+	if err != nil {
+		t.log.Error("failed to fetch order executions", zap.Int64("order_id", ord.ID), zap.Error(err))
+	}
+	return t.state.applyOrder(ord, executions, t.symbol.Base, t.symbol.Quote, t.settings.Commission)
+}
+
+// completeAction books the placed order, marks the portfolio to market and records the action
+func (t *trader) completeAction(currentCandle models.AppliedOHLCV, decision TradeDecision, decisionTrigger TradeDecisionTrigger, transactionAmount float64) *Action {
+	currentPrice := currentCandle.Close
+
+	var (
+		realizedPnL float64
+		booked      bool
+	)
+	if decision != DecisionHold {
+		// synchronous brokers fill right away, live orders are booked once synced
+		realizedPnL, booked = t.bookFilledOrders()
+	}
+
+	portfolioValue := t.state.markToMarket(currentCandle.Timestamp, currentPrice)
 
 	action := Action{
 		Timestamp:       currentCandle.Timestamp,
@@ -146,7 +390,9 @@ func (t *trader) algo(appliedOHLCV []models.AppliedOHLCV) (*Action, error) {
 
 	if action.Decision != DecisionHold {
 		// Calculate profit for sell orders
-		if action.Decision == DecisionSell && len(t.state.orders) > 0 {
+		if action.Decision == DecisionSell && booked {
+			action.Profit = realizedPnL
+		} else if action.Decision == DecisionSell && len(t.state.orders) > 0 {
 			// Find the last buy order
 			for i := len(t.state.orders) - 1; i >= 0; i-- {
 				if t.state.orders[i].Decision == DecisionBuy {
@@ -174,14 +420,16 @@ func (t *trader) algo(appliedOHLCV []models.AppliedOHLCV) (*Action, error) {
 			"LowerBB":     currentCandle.LowerBB,
 		}
 
-		if err := t.metricsCollector.SaveIndicatorData(currentCandle.Timestamp, indicators); err != nil {
-			t.log.Error("Failed to save indicator data", zap.Error(err))
-		}
+		if t.metricsCollector != nil {
+			if err := t.metricsCollector.SaveIndicatorData(currentCandle.Timestamp, indicators); err != nil {
+				t.log.Error("Failed to save indicator data", zap.Error(err))
+			}
 
-		if err := t.metricsCollector.SaveTradeMetric(action, indicators); err != nil {
-			t.log.Error("Failed to save trade metric", zap.Error(err))
+			if err := t.metricsCollector.SaveTradeMetric(action, indicators); err != nil {
+				t.log.Error("Failed to save trade metric", zap.Error(err))
+			}
 		}
 	}
 
-	return &action, nil
+	return &action
 }
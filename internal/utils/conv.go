@@ -1,5 +1,7 @@
 package utils
 
+import "cb_grok/internal/timeframe"
+
 func BoolToInt(v bool) int {
 	if v {
 		return 1
@@ -7,23 +9,11 @@ func BoolToInt(v bool) int {
 	return 0
 }
 
+// TimeframeToMilliseconds returns the candle length of the timeframe, the average month for 1M, 0 when it is unsupported
 func TimeframeToMilliseconds(tf string) int64 {
-	switch tf {
-	case "1m":
-		return 60 * 1000
-	case "15m":
-		return 900 * 1000
-	case "5m":
-		return 300 * 1000
-	case "30m":
-		return 1800 * 1000
-	case "1h":
-		return 3600 * 1000
-	case "60":
-		return 3600 * 1000
-	case "1d":
-		return 86400 * 1000
+	t, err := timeframe.Parse(tf)
+	if err != nil {
+		return 0
 	}
-
-	return 0
+	return t.Duration().Milliseconds()
 }
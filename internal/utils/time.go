@@ -0,0 +1,44 @@
+package utils
+
+import (
+	"fmt"
+	"time"
+)
+
+// ParseTime parses a date like 2006-01-02 or an RFC 3339 timestamp, dates are UTC midnight
+func ParseTime(value string) (time.Time, error) {
+	if t, err := time.Parse(time.DateOnly, value); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time %q, expected 2006-01-02 or RFC 3339", value)
+	}
+	return t, nil
+}
+
+// ParseTimeRange parses the --from/--to flags of the backtest tools. An empty from means no range,
+// an empty to means now.
+func ParseTimeRange(from, to string) (time.Time, time.Time, error) {
+	if from == "" {
+		if to != "" {
+			return time.Time{}, time.Time{}, fmt.Errorf("--to requires --from")
+		}
+		return time.Time{}, time.Time{}, nil
+	}
+	start, err := ParseTime(from)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	end := time.Now().UTC()
+	if to != "" {
+		end, err = ParseTime(to)
+		if err != nil {
+			return time.Time{}, time.Time{}, err
+		}
+	}
+	if !end.After(start) {
+		return time.Time{}, time.Time{}, fmt.Errorf("--to %s is not after --from %s", end.Format(time.RFC3339), start.Format(time.RFC3339))
+	}
+	return start, end, nil
+}
@@ -62,7 +62,7 @@ func connect(connectionUrl string) (*pgxpool.Pool, error) {
 			break
 		}
 
-		log.Println("ATTEMPT %d TO CONNECT TO POSTGRES BY URL %s FAILED: %s\n", connectionAttempts, connectionUrl, err.Error())
+		log.Printf("ATTEMPT %d TO CONNECT TO POSTGRES BY URL %s FAILED: %s\n", connectionAttempts, connectionUrl, err.Error())
 
 		connectionAttempts--
 
@@ -70,7 +70,7 @@ func connect(connectionUrl string) (*pgxpool.Pool, error) {
 	}
 
 	if result == nil {
-		log.Println("POSTGRES CONNECTION(%s) ERROR: %s\n", connectionUrl, err.Error())
+		log.Printf("POSTGRES CONNECTION(%s) ERROR: %s\n", connectionUrl, err.Error())
 		return nil, err
 	}
 	return result, nil